@@ -70,14 +70,14 @@ func (bdo *BSONDumpOptions) GetBSONReader() (io.ReadCloser, error) {
 	return ReadNopCloser{os.Stdin}, nil
 }
 
-func printJSON(doc *bson.Raw, out io.Writer, pretty bool) error {
+func printJSON(doc *bson.Raw, out io.Writer, pretty bool, format string) error {
 	decodedDoc := bson.D{}
 	err := bson.Unmarshal(doc.Data, &decodedDoc)
 	if err != nil {
 		return err
 	}
 
-	extendedDoc, err := bsonutil.ConvertBSONValueToJSON(decodedDoc)
+	extendedDoc, err := bsonutil.ConvertBSONValueToExtendedJSON(decodedDoc, format)
 	if err != nil {
 		return fmt.Errorf("error converting BSON to extended JSON: %v", err)
 	}
@@ -110,7 +110,7 @@ func (bd *BSONDump) JSON() (int, error) {
 
 	var result bson.Raw
 	for decodedStream.Next(&result) {
-		if err := printJSON(&result, bd.Out, bd.BSONDumpOptions.Pretty); err != nil {
+		if err := printJSON(&result, bd.Out, bd.BSONDumpOptions.Pretty, bd.BSONDumpOptions.JSONFormat); err != nil {
 			log.Logf(log.Always, "unable to dump document %v: %v", numFound+1, err)
 
 			//if objcheck is turned on, stop now. otherwise keep on dumpin'
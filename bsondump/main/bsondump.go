@@ -3,6 +3,7 @@ package main
 
 import (
 	"github.com/mongodb/mongo-tools/bsondump"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
 	"github.com/mongodb/mongo-tools/common/db"
 	"github.com/mongodb/mongo-tools/common/log"
 	"github.com/mongodb/mongo-tools/common/options"
@@ -81,6 +82,15 @@ func main() {
 		os.Exit(util.ExitBadOptions)
 	}
 
+	if err = bsonutil.ValidateJSONFormat(bsonDumpOpts.JSONFormat); err != nil {
+		log.Logf(log.Always, "%v", err)
+		os.Exit(util.ExitBadOptions)
+	}
+	if bsonDumpOpts.JSONFormat != "" && bsonDumpOpts.Type == "debug" {
+		log.Logf(log.Always, "Cannot use --jsonFormat with --type=debug")
+		os.Exit(util.ExitBadOptions)
+	}
+
 	var numFound int
 	if bsonDumpOpts.Type == "debug" {
 		numFound, err = dumper.Debug()
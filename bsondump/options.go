@@ -13,6 +13,9 @@ type BSONDumpOptions struct {
 	// Validate each BSON document before displaying
 	ObjCheck bool `long:"objcheck" description:"validate BSON during processing"`
 
+	// Extended JSON format to display documents in
+	JSONFormat string `long:"jsonFormat" value-name:"<format>" description:"the extended JSON format to output: legacy, canonical or relaxed; canonical and relaxed follow the Extended JSON v2 specification (defaults to 'legacy')"`
+
 	// Display JSON data with indents
 	Pretty bool `long:"pretty" description:"output JSON formatted to be human-readable"`
 
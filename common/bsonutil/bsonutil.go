@@ -10,6 +10,7 @@ import (
 	"github.com/mongodb/mongo-tools/common/util"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"math"
 	"strconv"
 	"time"
 )
@@ -205,6 +206,89 @@ func ParseSpecialKeys(special interface{}) (interface{}, error) {
 			return bson.MongoTimestamp(int64(ts.Seconds)<<32 | int64(ts.Increment)), nil
 		}
 
+		if jsonValue, ok := doc["$numberDouble"]; ok {
+			switch v := jsonValue.(type) {
+			case string:
+				return parseNumberDouble(v)
+			default:
+				return nil, errors.New("expected $numberDouble field to have string value")
+			}
+		}
+
+		if jsonValue, ok := doc["$numberDecimal"]; ok {
+			switch v := jsonValue.(type) {
+			case string:
+				return bson.ParseDecimal128(v)
+			default:
+				return nil, errors.New("expected $numberDecimal field to have string value")
+			}
+		}
+
+		if jsonValue, ok := doc["$binary"]; ok {
+			binDoc, ok := subdocumentMap(jsonValue)
+			if !ok {
+				return nil, errors.New("expected $binary field to have document value")
+			}
+			return parseBinary(binDoc["base64"], binDoc["subType"])
+		}
+
+		if jsonValue, ok := doc["$regularExpression"]; ok {
+			regexDoc, ok := subdocumentMap(jsonValue)
+			if !ok {
+				return nil, errors.New("expected $regularExpression field to have document value")
+			}
+			pattern, ok := regexDoc["pattern"].(string)
+			if !ok {
+				return nil, errors.New("expected $regularExpression to have string 'pattern' field")
+			}
+			options, ok := regexDoc["options"].(string)
+			if !ok {
+				return nil, errors.New("expected $regularExpression to have string 'options' field")
+			}
+			for i := range options {
+				switch o := options[i]; o {
+				default:
+					return nil, fmt.Errorf("invalid regular expression option '%v'", o)
+
+				case 'i', 'l', 'm', 's', 'u', 'x': // allowed
+				}
+			}
+			return bson.RegEx{Pattern: pattern, Options: options}, nil
+		}
+
+		if jsonValue, ok := doc["$symbol"]; ok {
+			switch v := jsonValue.(type) {
+			case string:
+				return bson.Symbol(v), nil
+			default:
+				return nil, errors.New("expected $symbol field to have string value")
+			}
+		}
+
+		if jsonValue, ok := doc["$dbPointer"]; ok {
+			pointerDoc, ok := subdocumentMap(jsonValue)
+			if !ok {
+				return nil, errors.New("expected $dbPointer field to have document value")
+			}
+			namespace, ok := pointerDoc["$ref"].(string)
+			if !ok {
+				return nil, errors.New("expected $dbPointer to have string '$ref' field")
+			}
+			idDoc, ok := subdocumentMap(pointerDoc["$id"])
+			if !ok {
+				return nil, errors.New("expected $dbPointer to have '$id' field with an $oid")
+			}
+			id, err := ParseSpecialKeys(idDoc)
+			if err != nil {
+				return nil, err
+			}
+			objectId, ok := id.(bson.ObjectId)
+			if !ok {
+				return nil, errors.New("expected $dbPointer to have '$id' field with an $oid")
+			}
+			return bson.DBPointer{Namespace: namespace, Id: objectId}, nil
+		}
+
 		if _, ok := doc["$undefined"]; ok {
 			return bson.Undefined, nil
 		}
@@ -279,37 +363,10 @@ func ParseSpecialKeys(special interface{}) (interface{}, error) {
 		}
 
 		if jsonValue, ok := doc["$binary"]; ok {
-			binary := bson.Binary{}
-
-			switch data := jsonValue.(type) {
-			case string:
-				bytes, err := base64.StdEncoding.DecodeString(data)
-				if err != nil {
-					return nil, err
-				}
-				binary.Data = bytes
-
-			default:
-				return nil, errors.New("expected $binary field to have string value")
-			}
-			if jsonValue, ok = doc["$type"]; !ok {
+			if _, ok = doc["$type"]; !ok {
 				return nil, errors.New("expected $type field with $binary field")
 			}
-
-			switch typ := jsonValue.(type) {
-			case string:
-				kind, err := hex.DecodeString(typ)
-				if err != nil {
-					return nil, err
-				} else if len(kind) != 1 {
-					return nil, errors.New("expected single byte (as hexadecimal string) for $type field")
-				}
-				binary.Kind = kind[0]
-
-			default:
-				return nil, errors.New("expected $type field to have string value")
-			}
-			return binary, nil
+			return parseBinary(jsonValue, doc["$type"])
 		}
 
 		if jsonValue, ok := doc["$ref"]; ok {
@@ -402,3 +459,60 @@ func parseNumberLongField(jsonValue interface{}) (int64, error) {
 		return 0, errors.New("expected $numberLong field to have string value")
 	}
 }
+
+// subdocumentMap returns the fields of value if it is a JSON document.
+func subdocumentMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case bson.D:
+		return v.Map(), true
+	}
+	return nil, false
+}
+
+// parseBinary returns the binary value with the given base64-encoded data and
+// subtype, expressed as a hexadecimal string.
+func parseBinary(data, subType interface{}) (bson.Binary, error) {
+	binary := bson.Binary{}
+
+	switch v := data.(type) {
+	case string:
+		bytes, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return binary, err
+		}
+		binary.Data = bytes
+
+	default:
+		return binary, errors.New("expected binary data to be a base64 string")
+	}
+
+	switch typ := subType.(type) {
+	case string:
+		kind, err := hex.DecodeString(typ)
+		if err != nil {
+			return binary, err
+		} else if len(kind) != 1 {
+			return binary, errors.New("expected single byte (as hexadecimal string) for binary subtype")
+		}
+		binary.Kind = kind[0]
+
+	default:
+		return binary, errors.New("expected binary subtype to be a hexadecimal string")
+	}
+	return binary, nil
+}
+
+// parseNumberDouble parses the value of a $numberDouble field.
+func parseNumberDouble(v string) (float64, error) {
+	switch v {
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
@@ -75,6 +75,10 @@ func ConvertJSONValueToBSON(x interface{}) (interface{}, error) {
 
 	case json.NumberFloat: // NumberFloat
 		return float64(v), nil
+
+	case json.Decimal128: // NumberDecimal
+		return v.Decimal128, nil
+
 	case json.BinData: // BinData
 		data, err := base64.StdEncoding.DecodeString(v.Base64)
 		if err != nil {
@@ -193,6 +197,9 @@ func ConvertBSONValueToJSON(x interface{}) (interface{}, error) {
 	case float32:
 		return json.NumberFloat(float64(v)), nil
 
+	case bson.Decimal128: // NumberDecimal
+		return json.Decimal128{v}, nil
+
 	case []byte: // BinData (with generic type)
 		data := base64.StdEncoding.EncodeToString(v)
 		return json.BinData{0x00, data}, nil
@@ -0,0 +1,87 @@
+package bsonutil
+
+import (
+	"github.com/mongodb/mongo-tools/common/json"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+func TestDecimal128Value(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("When parsing and formatting decimal values", t, func() {
+		cases := map[string]string{
+			"0":           "0",
+			"-0":          "-0",
+			"1.50":        "1.50",
+			"-12345.6789": "-12345.6789",
+			"0.000001":    "0.000001",
+			"0.0000001":   "1E-7",
+			"1e3":         "1E+3",
+			"1000":        "1000",
+			"0E-10":       "0E-10",
+			"1.000000000000000000000000000000001E+6144": "1.000000000000000000000000000000001E+6144",
+			"inf":       "Infinity",
+			"-Infinity": "-Infinity",
+			"NaN":       "NaN",
+		}
+		for input, expected := range cases {
+			value, err := bson.ParseDecimal128(input)
+			So(err, ShouldBeNil)
+			So(value.String(), ShouldEqual, expected)
+		}
+
+		Convey("values that can't be represented exactly should be rejected", func() {
+			_, err := bson.ParseDecimal128("1.0000000000000000000000000000000001")
+			So(err, ShouldNotBeNil)
+			_, err = bson.ParseDecimal128("1E+7000")
+			So(err, ShouldNotBeNil)
+			_, err = bson.ParseDecimal128("12a")
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("When converting decimal values", t, func() {
+		value, err := bson.ParseDecimal128("-9876.54321")
+		So(err, ShouldBeNil)
+
+		Convey("they should round-trip through BSON", func() {
+			data, err := bson.Marshal(bson.D{{"d", value}})
+			So(err, ShouldBeNil)
+			var doc bson.D
+			So(bson.Unmarshal(data, &doc), ShouldBeNil)
+			So(doc[0].Value, ShouldResemble, value)
+		})
+
+		Convey("they should round-trip through legacy extended JSON", func() {
+			jsonValue, err := ConvertBSONValueToJSON(bson.D{{"d", value}})
+			So(err, ShouldBeNil)
+			out, err := json.Marshal(jsonValue)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, `{"d":{"$numberDecimal":"-9876.54321"}}`)
+
+			parsed, err := json.UnmarshalBsonD(out)
+			So(err, ShouldBeNil)
+			parsed, err = GetExtendedBsonD(parsed)
+			So(err, ShouldBeNil)
+			So(parsed[0].Value, ShouldResemble, value)
+		})
+
+		Convey("they should round-trip through Extended JSON v2", func() {
+			jsonValue, err := ConvertBSONValueToExtendedJSON(bson.D{{"d", value}}, RelaxedJSONFormat)
+			So(err, ShouldBeNil)
+			out, err := json.Marshal(jsonValue)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, `{"d":{"$numberDecimal":"-9876.54321"}}`)
+		})
+
+		Convey("the NumberDecimal constructor should convert to BSON", func() {
+			jsonMap := map[string]interface{}{}
+			So(json.Unmarshal([]byte(`{"d":NumberDecimal("-9876.54321")}`), &jsonMap), ShouldBeNil)
+			So(ConvertJSONDocumentToBSON(jsonMap), ShouldBeNil)
+			So(jsonMap["d"], ShouldResemble, value)
+		})
+	})
+}
@@ -0,0 +1,238 @@
+package bsonutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/json"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extended JSON formats that BSON values can be converted to. The legacy
+// format is the one understood by the mongo shell; the canonical and relaxed
+// formats are those of the Extended JSON v2 specification. The canonical
+// format preserves the type of every value, while the relaxed format uses
+// plain JSON numbers and readable dates wherever possible.
+const (
+	LegacyJSONFormat    = "legacy"
+	CanonicalJSONFormat = "canonical"
+	RelaxedJSONFormat   = "relaxed"
+)
+
+// relaxedDateFormat is the format of dates in relaxed Extended JSON.
+const relaxedDateFormat = "2006-01-02T15:04:05.000Z"
+
+// ValidateJSONFormat returns an error if format is not one of the supported
+// Extended JSON formats. An empty format denotes the legacy format.
+func ValidateJSONFormat(format string) error {
+	switch format {
+	case "", LegacyJSONFormat, CanonicalJSONFormat, RelaxedJSONFormat:
+		return nil
+	}
+	return fmt.Errorf("invalid JSON format '%v', choose one of: %v, %v, %v",
+		format, LegacyJSONFormat, CanonicalJSONFormat, RelaxedJSONFormat)
+}
+
+// ConvertBSONValueToExtendedJSON walks through a document or an array and
+// converts any BSON value to its representation in the given Extended JSON
+// format, which must be valid. It returns the converted JSON document and any
+// error encountered.
+func ConvertBSONValueToExtendedJSON(x interface{}, format string) (interface{}, error) {
+	if format == "" || format == LegacyJSONFormat {
+		return ConvertBSONValueToJSON(x)
+	}
+	return convertBSONValueToV2(x, format == CanonicalJSONFormat)
+}
+
+// extendedValue returns a single-field document representing a value of a
+// type with no plain JSON equivalent.
+func extendedValue(key string, value interface{}) MarshalD {
+	return MarshalD{{key, value}}
+}
+
+func convertBSONValueToV2(x interface{}, canonical bool) (interface{}, error) {
+	switch v := x.(type) {
+	case nil, bool, string:
+		return v, nil
+
+	case *bson.M:
+		return convertDocumentToV2(bson.M(*v), canonical)
+	case bson.M:
+		return convertDocumentToV2(v, canonical)
+	case map[string]interface{}:
+		return convertDocumentToV2(bson.M(v), canonical)
+	case bson.D:
+		doc := make(MarshalD, len(v))
+		for i, elem := range v {
+			value, err := convertBSONValueToV2(elem.Value, canonical)
+			if err != nil {
+				return nil, err
+			}
+			doc[i] = bson.DocElem{elem.Name, value}
+		}
+		return doc, nil
+	case *bson.D:
+		return convertBSONValueToV2(*v, canonical)
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, elem := range v {
+			value, err := convertBSONValueToV2(elem, canonical)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = value
+		}
+		return array, nil
+
+	case int:
+		if int64(v) != int64(int32(v)) {
+			return convertBSONValueToV2(int64(v), canonical)
+		}
+		return convertBSONValueToV2(int32(v), canonical)
+	case int32:
+		if canonical {
+			return extendedValue("$numberInt", strconv.FormatInt(int64(v), 10)), nil
+		}
+		return json.NumberInt(v), nil
+	case int64:
+		if canonical {
+			return extendedValue("$numberLong", strconv.FormatInt(v, 10)), nil
+		}
+		return v, nil
+	case float32:
+		return convertBSONValueToV2(float64(v), canonical)
+	case float64:
+		if canonical || math.IsInf(v, 0) || math.IsNaN(v) {
+			return extendedValue("$numberDouble", FormatExtendedJSONDouble(v)), nil
+		}
+		return json.NumberFloat(v), nil
+
+	case bson.Decimal128:
+		return extendedValue("$numberDecimal", v.String()), nil
+
+	case bson.ObjectId:
+		return extendedValue("$oid", v.Hex()), nil
+
+	case time.Time:
+		millis := v.Unix()*1000 + int64(v.Nanosecond()/1e6)
+		if !canonical && v.Year() >= 1970 && v.Year() <= 9999 {
+			return extendedValue("$date", v.UTC().Format(relaxedDateFormat)), nil
+		}
+		return extendedValue("$date", extendedValue("$numberLong", strconv.FormatInt(millis, 10))), nil
+
+	case []byte:
+		return binaryToV2(0x00, v), nil
+	case bson.Binary:
+		return binaryToV2(v.Kind, v.Data), nil
+
+	case bson.RegEx:
+		// the specification requires the options to be sorted
+		options := strings.Split(v.Options, "")
+		sort.Strings(options)
+		return extendedValue("$regularExpression", MarshalD{
+			{"pattern", v.Pattern},
+			{"options", strings.Join(options, "")},
+		}), nil
+
+	case bson.MongoTimestamp:
+		return extendedValue("$timestamp", MarshalD{
+			{"t", uint32(int64(v) >> 32)},
+			{"i", uint32(v)},
+		}), nil
+
+	case bson.Symbol:
+		return extendedValue("$symbol", string(v)), nil
+
+	case bson.JavaScript:
+		if v.Scope == nil {
+			return extendedValue("$code", v.Code), nil
+		}
+		scope, err := convertBSONValueToV2(v.Scope, canonical)
+		if err != nil {
+			return nil, err
+		}
+		return MarshalD{{"$code", v.Code}, {"$scope", scope}}, nil
+
+	case mgo.DBRef:
+		id, err := convertBSONValueToV2(v.Id, canonical)
+		if err != nil {
+			return nil, err
+		}
+		ref := MarshalD{{"$ref", v.Collection}, {"$id", id}}
+		if v.Database != "" {
+			ref = append(ref, bson.DocElem{"$db", v.Database})
+		}
+		return ref, nil
+
+	case bson.DBPointer:
+		return extendedValue("$dbPointer", MarshalD{
+			{"$ref", v.Namespace},
+			{"$id", extendedValue("$oid", v.Id.Hex())},
+		}), nil
+
+	default:
+		switch x {
+		case bson.MinKey:
+			return extendedValue("$minKey", 1), nil
+		case bson.MaxKey:
+			return extendedValue("$maxKey", 1), nil
+		case bson.Undefined:
+			return extendedValue("$undefined", true), nil
+		}
+	}
+
+	return nil, fmt.Errorf("conversion of BSON type '%v' not supported %v", reflect.TypeOf(x), x)
+}
+
+// convertDocumentToV2 converts the values of an unordered document. Its
+// fields are sorted, so that the output is deterministic.
+func convertDocumentToV2(doc bson.M, canonical bool) (interface{}, error) {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ordered := make(bson.D, 0, len(doc))
+	for _, key := range keys {
+		ordered = append(ordered, bson.DocElem{key, doc[key]})
+	}
+	return convertBSONValueToV2(ordered, canonical)
+}
+
+func binaryToV2(kind byte, data []byte) MarshalD {
+	return extendedValue("$binary", MarshalD{
+		{"base64", base64.StdEncoding.EncodeToString(data)},
+		{"subType", fmt.Sprintf("%02x", kind)},
+	})
+}
+
+// FormatExtendedJSONDouble formats a double as the string value of a
+// $numberDouble field: non-finite values are spelled out, and integral
+// values keep a decimal point so that they read back as doubles.
+func FormatExtendedJSONDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	s := strconv.FormatFloat(f, 'G', -1, 64)
+	i := strings.IndexByte(s, 'E')
+	if i == -1 {
+		if !strings.ContainsRune(s, '.') {
+			s += ".0"
+		}
+		return s
+	}
+	// drop the leading zeros of the exponent, e.g. 1E+07 -> 1E+7
+	exponent := strings.TrimLeft(s[i+2:], "0")
+	return s[:i+2] + exponent
+}
@@ -0,0 +1,113 @@
+package bsonutil
+
+import (
+	"github.com/mongodb/mongo-tools/common/json"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"math"
+	"testing"
+	"time"
+)
+
+func marshalExtendedJSON(doc bson.D, format string) string {
+	converted, err := ConvertBSONValueToExtendedJSON(doc, format)
+	So(err, ShouldBeNil)
+	out, err := json.Marshal(converted)
+	So(err, ShouldBeNil)
+	return string(out)
+}
+
+func TestExtendedJSONFormats(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("When validating a JSON format", t, func() {
+		So(ValidateJSONFormat(""), ShouldBeNil)
+		So(ValidateJSONFormat(LegacyJSONFormat), ShouldBeNil)
+		So(ValidateJSONFormat(CanonicalJSONFormat), ShouldBeNil)
+		So(ValidateJSONFormat(RelaxedJSONFormat), ShouldBeNil)
+		So(ValidateJSONFormat("strict"), ShouldNotBeNil)
+	})
+
+	Convey("When converting BSON to Extended JSON v2", t, func() {
+		date := time.Date(2015, 3, 4, 5, 6, 7, 8e6, time.UTC)
+		doc := bson.D{
+			{"int", int32(1)},
+			{"long", int64(2)},
+			{"double", 3.0},
+			{"date", date},
+		}
+
+		Convey("canonical output should preserve every type", func() {
+			So(marshalExtendedJSON(doc, CanonicalJSONFormat), ShouldEqual,
+				`{"int":{"$numberInt":"1"},"long":{"$numberLong":"2"},`+
+					`"double":{"$numberDouble":"3.0"},`+
+					`"date":{"$date":{"$numberLong":"1425445567008"}}}`)
+		})
+
+		Convey("relaxed output should use plain numbers and readable dates", func() {
+			So(marshalExtendedJSON(doc, RelaxedJSONFormat), ShouldEqual,
+				`{"int":1,"long":2,"double":3.0,"date":{"$date":"2015-03-04T05:06:07.008Z"}}`)
+		})
+
+		Convey("relaxed output should keep non-finite doubles and old dates typed", func() {
+			doc := bson.D{
+				{"inf", math.Inf(-1)},
+				{"date", time.Unix(-1, 0)},
+			}
+			So(marshalExtendedJSON(doc, RelaxedJSONFormat), ShouldEqual,
+				`{"inf":{"$numberDouble":"-Infinity"},"date":{"$date":{"$numberLong":"-1000"}}}`)
+		})
+
+		Convey("types without a JSON equivalent should use v2 keys", func() {
+			doc := bson.D{
+				{"bin", bson.Binary{0x04, []byte("abc")}},
+				{"re", bson.RegEx{"^a", "xi"}},
+				{"ts", bson.MongoTimestamp(5<<32 | 6)},
+			}
+			So(marshalExtendedJSON(doc, CanonicalJSONFormat), ShouldEqual,
+				`{"bin":{"$binary":{"base64":"YWJj","subType":"04"}},`+
+					`"re":{"$regularExpression":{"pattern":"^a","options":"ix"}},`+
+					`"ts":{"$timestamp":{"t":5,"i":6}}}`)
+		})
+
+		Convey("the legacy format should match the existing converter", func() {
+			// the legacy converter modifies documents in place
+			legacy, err := ConvertBSONValueToExtendedJSON(bson.D{{"long", int64(2)}}, LegacyJSONFormat)
+			So(err, ShouldBeNil)
+			expected, err := ConvertBSONValueToJSON(bson.D{{"long", int64(2)}})
+			So(err, ShouldBeNil)
+			So(legacy, ShouldResemble, expected)
+		})
+	})
+
+	Convey("When parsing Extended JSON v2 output", t, func() {
+		doc := bson.D{
+			{"int", int32(1)},
+			{"long", int64(2)},
+			{"double", 2.5},
+			{"nan", math.Inf(1)},
+			{"bin", bson.Binary{0x04, []byte("abc")}},
+			{"re", bson.RegEx{"^a", "i"}},
+			{"sym", bson.Symbol("s")},
+			{"oid", bson.ObjectIdHex("5523e8e30d1f9ae3c45f5cdc")},
+		}
+
+		Convey("the canonical format should round-trip", func() {
+			parsed, err := json.UnmarshalBsonD([]byte(marshalExtendedJSON(doc, CanonicalJSONFormat)))
+			So(err, ShouldBeNil)
+			parsed, err = GetExtendedBsonD(parsed)
+			So(err, ShouldBeNil)
+			So(parsed, ShouldResemble, doc)
+		})
+
+		Convey("the relaxed format should round-trip all but the integer widths", func() {
+			parsed, err := json.UnmarshalBsonD([]byte(marshalExtendedJSON(doc, RelaxedJSONFormat)))
+			So(err, ShouldBeNil)
+			parsed, err = GetExtendedBsonD(parsed)
+			So(err, ShouldBeNil)
+			So(parsed[1].Value, ShouldEqual, 2)
+			So(parsed[2:], ShouldResemble, doc[2:])
+		})
+	})
+}
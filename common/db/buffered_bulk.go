@@ -66,6 +66,12 @@ func (bb *BufferedBulkInserter) Insert(doc interface{}) error {
 	return err
 }
 
+// Pending returns the number of buffered documents that have not yet been
+// written.
+func (bb *BufferedBulkInserter) Pending() int {
+	return bb.docCount
+}
+
 // Flush writes all buffered documents in one bulk insert then resets the buffer.
 func (bb *BufferedBulkInserter) Flush() error {
 	if bb.docCount == 0 {
@@ -23,6 +23,14 @@ func IsNoCollection(err error) bool {
 	return ok && e.Message == "no collection"
 }
 
+// IsNotSupportedOnView returns true if err indicates a command or an option
+// is not supported on a view, otherwise, returns false.
+func IsNotSupportedOnView(err error) bool {
+	e, ok := err.(*mgo.QueryError)
+	// CommandNotSupportedOnView and OptionNotSupportedOnView
+	return ok && (e.Code == 166 || e.Code == 167)
+}
+
 // buildBsonArray takes a cursor iterator and returns an array of
 // all of its documents as bson.D objects.
 func buildBsonArray(iter *mgo.Iter) ([]bson.D, error) {
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2"
+	"testing"
+)
+
+func TestIsNotSupportedOnView(t *testing.T) {
+	Convey("Only the errors of commands and options not supported on views should match", t, func() {
+		So(IsNotSupportedOnView(&mgo.QueryError{Code: 166, Message: "Namespace test.v is a view, not a collection"}), ShouldBeTrue)
+		So(IsNotSupportedOnView(&mgo.QueryError{Code: 167}), ShouldBeTrue)
+		So(IsNotSupportedOnView(&mgo.QueryError{Code: 13, Message: "not authorized"}), ShouldBeFalse)
+		So(IsNotSupportedOnView(errors.New("no reachable servers")), ShouldBeFalse)
+		So(IsNotSupportedOnView(nil), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,51 @@
+package json
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+)
+
+// Decodes a NumberDecimal literal stored in the underlying byte data into v.
+func (d *decodeState) storeNumberDecimal(v reflect.Value) {
+	switch kind := v.Kind(); kind {
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(d.getNumberDecimal()))
+	default:
+		d.error(fmt.Errorf("cannot store %v value into %v type", decimal128Type, kind))
+	}
+}
+
+// Returns a NumberDecimal literal from the underlying byte data.
+func (d *decodeState) getNumberDecimal() interface{} {
+	op := d.scanWhile(scanSkipSpace)
+	if op != scanBeginCtor {
+		d.error(fmt.Errorf("expected beginning of constructor"))
+	}
+
+	// Prevent d.convertNumber() from parsing the argument as a float64,
+	// which would lose precision.
+	useNumber := d.useNumber
+	d.useNumber = true
+
+	args := d.ctorInterface()
+	if err := ctorNumArgsMismatch("NumberDecimal", 1, len(args)); err != nil {
+		d.error(err)
+	}
+	var s string
+	switch v := args[0].(type) {
+	case Number:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		d.error(fmt.Errorf("expected string for first argument of NumberDecimal constructor, got %T", v))
+	}
+
+	d.useNumber = useNumber
+	value, err := bson.ParseDecimal128(s)
+	if err != nil {
+		d.error(fmt.Errorf("invalid first argument of NumberDecimal constructor: %v", err))
+	}
+	return Decimal128{value}
+}
@@ -0,0 +1,75 @@
+package json
+
+import (
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNumberDecimalValue(t *testing.T) {
+
+	Convey("When unmarshalling JSON with NumberDecimal values", t, func() {
+
+		Convey("works for a string argument", func() {
+			var jsonMap map[string]interface{}
+
+			key := "key"
+			value := `NumberDecimal("1234.5678")`
+			data := fmt.Sprintf(`{"%v":%v}`, key, value)
+
+			err := Unmarshal([]byte(data), &jsonMap)
+			So(err, ShouldBeNil)
+
+			jsonValue, ok := jsonMap[key].(Decimal128)
+			So(ok, ShouldBeTrue)
+			So(jsonValue.String(), ShouldEqual, "1234.5678")
+		})
+
+		Convey("works for a numeric argument without losing precision", func() {
+			var jsonMap map[string]interface{}
+
+			key := "key"
+			value := "NumberDecimal(0.1000000000000000000000000000000001)"
+			data := fmt.Sprintf(`{"%v":%v}`, key, value)
+
+			err := Unmarshal([]byte(data), &jsonMap)
+			So(err, ShouldBeNil)
+
+			jsonValue, ok := jsonMap[key].(Decimal128)
+			So(ok, ShouldBeTrue)
+			So(jsonValue.String(), ShouldEqual, "0.1000000000000000000000000000000001")
+		})
+
+		Convey("works with the new keyword", func() {
+			var jsonMap map[string]interface{}
+
+			data := `{"key":new NumberDecimal("-1E+10")}`
+
+			err := Unmarshal([]byte(data), &jsonMap)
+			So(err, ShouldBeNil)
+
+			jsonValue, ok := jsonMap["key"].(Decimal128)
+			So(ok, ShouldBeTrue)
+			So(jsonValue.String(), ShouldEqual, "-1E+10")
+		})
+
+		Convey("cannot use an invalid number", func() {
+			var jsonMap map[string]interface{}
+
+			data := `{"key":NumberDecimal("1.2.3")}`
+
+			err := Unmarshal([]byte(data), &jsonMap)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("When marshalling a NumberDecimal value", t, func() {
+		var jsonMap map[string]interface{}
+		err := Unmarshal([]byte(`{"key":NumberDecimal("1.50")}`), &jsonMap)
+		So(err, ShouldBeNil)
+
+		data, err := Marshal(jsonMap["key"])
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"$numberDecimal":"1.50"}`)
+	})
+}
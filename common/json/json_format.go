@@ -105,6 +105,11 @@ func (n NumberLong) MarshalJSON() ([]byte, error) {
 	return []byte(data), nil
 }
 
+func (n Decimal128) MarshalJSON() ([]byte, error) {
+	data := fmt.Sprintf(`{ "$numberDecimal": "%v" }`, n.Decimal128)
+	return []byte(data), nil
+}
+
 func (n NumberFloat) MarshalJSON() ([]byte, error) {
 
 	// check floats for infinity and return +Infinity or -Infinity if so
@@ -48,6 +48,11 @@ type NumberLong int64
 // Represents a signed 64-bit float.
 type NumberFloat float64
 
+// Represents a 128-bit decimal.
+type Decimal128 struct {
+	bson.Decimal128
+}
+
 // Represents a regular expression.
 type RegExp struct {
 	Pattern string
@@ -87,6 +92,7 @@ var (
 	numberIntType   = reflect.TypeOf(NumberInt(0))
 	numberLongType  = reflect.TypeOf(NumberLong(0))
 	numberFloatType = reflect.TypeOf(NumberFloat(0))
+	decimal128Type  = reflect.TypeOf(Decimal128{})
 	objectIdType    = reflect.TypeOf(ObjectId(""))
 	regexpType      = reflect.TypeOf(RegExp{})
 	timestampType   = reflect.TypeOf(Timestamp{})
@@ -270,12 +276,14 @@ func (d *decodeState) storeExtendedLiteral(item []byte, v reflect.Value, fromQuo
 	case 'O': // ObjectId
 		d.storeObjectId(v)
 
-	case 'N': // NumberInt or NumberLong
+	case 'N': // NumberInt, NumberLong or NumberDecimal
 		switch item[6] {
 		case 'I': // NumberInt
 			d.storeNumberInt(v)
 		case 'L': // NumberLong
 			d.storeNumberLong(v)
+		case 'D': // NumberDecimal
+			d.storeNumberDecimal(v)
 		}
 
 	case 'R': // RegExp constructor
@@ -351,12 +359,14 @@ func (d *decodeState) getExtendedLiteral(item []byte) (interface{}, bool) {
 	case 'O': // ObjectId
 		return d.getObjectId(), true
 
-	case 'N': // NumberInt or NumberLong
+	case 'N': // NumberInt, NumberLong or NumberDecimal
 		switch item[6] {
 		case 'I': // NumberInt
 			return d.getNumberInt(), true
 		case 'L': // NumberLong
 			return d.getNumberLong(), true
+		case 'D': // NumberDecimal
+			return d.getNumberDecimal(), true
 		}
 
 	case 'R': // RegExp constructor
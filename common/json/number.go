@@ -27,7 +27,11 @@ func stateUpperNumber(s *scanner, c int) int {
 		s.step = generateState("NumberLong", []byte("ong"), stateConstructor)
 		return scanContinue
 	}
-	return s.error(c, "in literal NumberInt or NumberLong (expecting 'I' or 'L')")
+	if c == 'D' {
+		s.step = generateState("NumberDecimal", []byte("ecimal"), stateConstructor)
+		return scanContinue
+	}
+	return s.error(c, "in literal NumberInt, NumberLong or NumberDecimal (expecting 'I', 'L' or 'D')")
 }
 
 // Decodes a NumberInt literal stored in the underlying byte data into v.
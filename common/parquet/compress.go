@@ -0,0 +1,218 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// compress compresses the data of a page with the given codec.
+func compress(codec Codec, data []byte) ([]byte, error) {
+	switch codec {
+	case Uncompressed:
+		return data, nil
+	case Snappy:
+		return snappyEncode(data), nil
+	case Gzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported compression codec %v", codec)
+}
+
+// decompress decompresses the data of a page with the given codec; size is
+// the uncompressed size recorded in the page header.
+func decompress(codec Codec, data []byte, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid uncompressed page size %v", size)
+	}
+	var page []byte
+	var err error
+	switch codec {
+	case Uncompressed:
+		page = data
+	case Snappy:
+		page, err = snappyDecode(data, size)
+	case Gzip:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		page, err = ioutil.ReadAll(io.LimitReader(gz, int64(size)+1))
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %v", codec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing page: %v", err)
+	}
+	if len(page) != size {
+		return nil, fmt.Errorf("page has %v bytes instead of %v", len(page), size)
+	}
+	return page, nil
+}
+
+// snappyBlockSize is the size of the blocks compressed independently, which
+// keeps the offsets of copies within 16 bits.
+const snappyBlockSize = 1 << 16
+
+// snappyEncode compresses data in the raw Snappy format: the uncompressed
+// length followed by literals and copies of earlier bytes.
+func snappyEncode(src []byte) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	dst := make([]byte, 0, len(src)+len(src)/6+binary.MaxVarintLen64)
+	dst = append(dst, varint[:binary.PutUvarint(varint[:], uint64(len(src)))]...)
+	for len(src) > 0 {
+		block := src[:minInt(len(src), snappyBlockSize)]
+		dst = snappyEncodeBlock(dst, block)
+		src = src[len(block):]
+	}
+	return dst
+}
+
+// snappyEncodeBlock greedily replaces sequences of at least 4 bytes seen
+// earlier in the block by copies.
+func snappyEncodeBlock(dst, src []byte) []byte {
+	const tableBits = 14
+	var table [1 << tableBits]int
+	load := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(src[i:])
+	}
+	hash := func(u uint32) uint32 {
+		return (u * 0x1e35a7bd) >> (32 - tableBits)
+	}
+	literalStart := 0
+	for s := 0; s+4 <= len(src); {
+		h := hash(load(s))
+		candidate := table[h]
+		table[h] = s
+		if candidate >= s || load(candidate) != load(s) {
+			s++
+			continue
+		}
+		dst = snappyLiteral(dst, src[literalStart:s])
+		length := 4
+		for s+length < len(src) && src[candidate+length] == src[s+length] {
+			length++
+		}
+		dst = snappyCopy(dst, s-candidate, length)
+		s += length
+		literalStart = s
+	}
+	return snappyLiteral(dst, src[literalStart:])
+}
+
+func snappyLiteral(dst, literal []byte) []byte {
+	n := len(literal) - 1
+	switch {
+	case n < 0:
+		return dst
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(dst, literal...)
+}
+
+// snappyCopy appends copies of length bytes from offset bytes back, which
+// must be less than 1<<16.
+func snappyCopy(dst []byte, offset, length int) []byte {
+	// copies with a 2-byte offset hold up to 64 bytes; the remainder is kept
+	// at 4 bytes or more for it to fit a copy with a 1-byte offset
+	for length >= 68 {
+		dst = append(dst, 63<<2|2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|1, byte(offset))
+}
+
+// snappyDecode decompresses data in the raw Snappy format, whose length must
+// be size.
+func snappyDecode(src []byte, size int) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid snappy length")
+	}
+	if length != uint64(size) {
+		return nil, fmt.Errorf("snappy data has %v bytes instead of %v", length, size)
+	}
+	dst := make([]byte, 0, size)
+	for s := n; s < len(src); {
+		tag := src[s]
+		var offset, length int
+		switch tag & 3 {
+		case 0:
+			length = int(tag >> 2)
+			s++
+			if length >= 60 {
+				extra := length - 59
+				if s+extra > len(src) {
+					return nil, fmt.Errorf("unexpected end of snappy data")
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[s+i])
+				}
+				s += extra
+			}
+			length++
+			if length > len(src)-s || length > size-len(dst) {
+				return nil, fmt.Errorf("invalid snappy literal")
+			}
+			dst = append(dst, src[s:s+length]...)
+			s += length
+			continue
+		case 1:
+			if s+2 > len(src) {
+				return nil, fmt.Errorf("unexpected end of snappy data")
+			}
+			length = 4 + int(tag>>2&7)
+			offset = int(tag>>5)<<8 | int(src[s+1])
+			s += 2
+		case 2:
+			if s+3 > len(src) {
+				return nil, fmt.Errorf("unexpected end of snappy data")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s+1:]))
+			s += 3
+		case 3:
+			if s+5 > len(src) {
+				return nil, fmt.Errorf("unexpected end of snappy data")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s+1:]))
+			s += 5
+		}
+		if offset <= 0 || offset > len(dst) || length > size-len(dst) {
+			return nil, fmt.Errorf("invalid snappy copy")
+		}
+		// copies may overlap the bytes they produce
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if len(dst) != size {
+		return nil, fmt.Errorf("snappy data has %v bytes instead of %v", len(dst), size)
+	}
+	return dst, nil
+}
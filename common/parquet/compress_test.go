@@ -0,0 +1,66 @@
+package parquet
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	random := rand.New(rand.NewSource(1))
+	inputs := [][]byte{
+		{},
+		[]byte("a"),
+		bytes.Repeat([]byte("abc"), 100000),
+	}
+	text := make([]byte, 200000)
+	for i := range text {
+		if i > 10 && random.Intn(3) == 0 {
+			text[i] = text[i-1-random.Intn(10)]
+		} else {
+			text[i] = byte('a' + random.Intn(26))
+		}
+	}
+	inputs = append(inputs, text)
+
+	Convey("Pages should round trip through each codec", t, func() {
+		for _, codec := range []Codec{Uncompressed, Snappy, Gzip} {
+			for _, input := range inputs {
+				compressed, err := compress(codec, input)
+				So(err, ShouldBeNil)
+				page, err := decompress(codec, compressed, len(input))
+				So(err, ShouldBeNil)
+				So(bytes.Equal(page, input), ShouldBeTrue)
+			}
+		}
+	})
+
+	Convey("Repetitive pages should be compressed", t, func() {
+		So(len(snappyEncode(inputs[2])), ShouldBeLessThan, len(inputs[2])/20)
+	})
+
+	Convey("Snappy data from other encoders should be decoded", t, func() {
+		page, err := snappyDecode([]byte{0x24, 0x08, 'a', 'b', 'c', 0x82, 0x03, 0x00}, 36)
+		So(err, ShouldBeNil)
+		So(string(page), ShouldEqual, "abcabcabcabcabcabcabcabcabcabcabcabc")
+	})
+
+	Convey("Invalid pages should be rejected", t, func() {
+		_, err := decompress(Snappy, []byte{0x24, 0x08, 'a', 'b', 'c', 0x82, 0x03, 0x00}, 35)
+		So(err, ShouldNotBeNil)
+		_, err = decompress(Snappy, []byte{0x04, 0x0c, 'a', 'b'}, 4)
+		So(err, ShouldNotBeNil)
+		_, err = decompress(Snappy, []byte{0x04, 0x00, 'a', 0x0a, 0x05}, 4)
+		So(err, ShouldNotBeNil)
+		_, err = decompress(Gzip, []byte("not gzip"), 8)
+		So(err, ShouldNotBeNil)
+		_, err = decompress(Uncompressed, []byte("abc"), 4)
+		So(err, ShouldNotBeNil)
+		_, err = compress(ZSTD, []byte("abc"))
+		So(err, ShouldNotBeNil)
+	})
+}
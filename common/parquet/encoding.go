@@ -0,0 +1,575 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// maxPreallocatedValues bounds the space allocated for the values of a page
+// before they are decoded, since the counts of a malformed file can't be
+// trusted.
+const maxPreallocatedValues = 1 << 16
+
+// maxDeltaBlockSize bounds the number of values of the blocks of the
+// DELTA_BINARY_PACKED encoding, which writers set to a few hundred.
+const maxDeltaBlockSize = 1 << 16
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// bitWidth returns the number of bits needed to store values up to max.
+func bitWidth(max int) int {
+	width := 0
+	for ; max > 0; max >>= 1 {
+		width++
+	}
+	return width
+}
+
+// appendRLE appends values encoded with the RLE/bit-packing hybrid encoding:
+// runs of at least 8 equal values are run-length encoded, and other values
+// are bit-packed in groups of 8.
+func appendRLE(dst []byte, values []int, width int) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	byteWidth := (width + 7) / 8
+	runLength := func(i int) int {
+		n := 1
+		for i+n < len(values) && values[i+n] == values[i] {
+			n++
+		}
+		return n
+	}
+	for i := 0; i < len(values); {
+		if run := runLength(i); run >= 8 {
+			dst = append(dst, varint[:binary.PutUvarint(varint[:], uint64(run)<<1)]...)
+			for b := 0; b < byteWidth; b++ {
+				dst = append(dst, byte(values[i]>>uint(8*b)))
+			}
+			i += run
+			continue
+		}
+		// bit-pack groups of 8 values until a run is found at the start of a
+		// group; only the last group may be padded
+		start := i
+		for i < len(values) {
+			i += 8
+			if i >= len(values) {
+				i = len(values)
+				break
+			}
+			if runLength(i) >= 8 {
+				break
+			}
+		}
+		groups := (i - start + 7) / 8
+		dst = append(dst, varint[:binary.PutUvarint(varint[:], uint64(groups)<<1|1)]...)
+		packed := make([]byte, groups*width)
+		for j, v := range values[start:i] {
+			for b := 0; b < width; b++ {
+				if v>>uint(b)&1 != 0 {
+					bit := j*width + b
+					packed[bit/8] |= 1 << uint(bit%8)
+				}
+			}
+		}
+		dst = append(dst, packed...)
+	}
+	return dst
+}
+
+// decodeRLE decodes n values encoded with the RLE/bit-packing hybrid encoding.
+func decodeRLE(data []byte, width, n int) ([]int, error) {
+	if width > 32 {
+		return nil, fmt.Errorf("invalid bit width %v", width)
+	}
+	values := make([]int, 0, minInt(n, maxPreallocatedValues))
+	byteWidth := (width + 7) / 8
+	pos := 0
+	for len(values) < n {
+		header, size := binary.Uvarint(data[pos:])
+		if size <= 0 {
+			return nil, fmt.Errorf("unexpected end of RLE data")
+		}
+		pos += size
+		if header&1 == 0 {
+			count := int(header >> 1)
+			if pos+byteWidth > len(data) {
+				return nil, fmt.Errorf("unexpected end of RLE data")
+			}
+			value := 0
+			for b := 0; b < byteWidth; b++ {
+				value |= int(data[pos+b]) << uint(8*b)
+			}
+			pos += byteWidth
+			for i := 0; i < count && len(values) < n; i++ {
+				values = append(values, value)
+			}
+			continue
+		}
+		if header>>1 > uint64(len(data)-pos) {
+			return nil, fmt.Errorf("unexpected end of RLE data")
+		}
+		count := int(header>>1) * 8
+		if count*width/8 > len(data)-pos {
+			return nil, fmt.Errorf("unexpected end of RLE data")
+		}
+		for i := 0; i < count && len(values) < n; i++ {
+			value := 0
+			for b := 0; b < width; b++ {
+				bit := i*width + b
+				value |= int(data[pos+bit/8]>>uint(bit%8)&1) << uint(b)
+			}
+			values = append(values, value)
+		}
+		pos += count * width / 8
+	}
+	return values, nil
+}
+
+// decodeBitPacked decodes n values encoded with the deprecated BIT_PACKED
+// encoding, which packs values from the most significant bit. It returns the
+// values and the number of bytes they take.
+func decodeBitPacked(data []byte, width, n int) ([]int, int, error) {
+	size := (n*width + 7) / 8
+	if size > len(data) {
+		return nil, 0, fmt.Errorf("unexpected end of bit-packed data")
+	}
+	values := make([]int, n)
+	for i := range values {
+		for b := 0; b < width; b++ {
+			bit := i*width + b
+			values[i] = values[i]<<1 | int(data[bit/8]>>uint(7-bit%8)&1)
+		}
+	}
+	return values, size, nil
+}
+
+// plainEncoder encodes values of a physical type with the PLAIN encoding.
+type plainEncoder struct {
+	typ        Type
+	typeLength int
+
+	buf bytes.Buffer
+
+	// bits holds the booleans that don't fill a byte yet
+	bits, numBits uint
+}
+
+// encode appends a value, which must be of the Go type used for the physical
+// type by the Writer.
+func (e *plainEncoder) encode(value interface{}) error {
+	var b [8]byte
+	switch e.typ {
+	case Boolean:
+		v, ok := value.(bool)
+		if !ok {
+			break
+		}
+		if v {
+			e.bits |= 1 << e.numBits
+		}
+		if e.numBits++; e.numBits == 8 {
+			e.buf.WriteByte(byte(e.bits))
+			e.bits, e.numBits = 0, 0
+		}
+		return nil
+	case Int32:
+		v, ok := value.(int32)
+		if !ok {
+			break
+		}
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		e.buf.Write(b[:4])
+		return nil
+	case Int64:
+		v, ok := value.(int64)
+		if !ok {
+			break
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		e.buf.Write(b[:8])
+		return nil
+	case Float:
+		v, ok := value.(float32)
+		if !ok {
+			break
+		}
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(v))
+		e.buf.Write(b[:4])
+		return nil
+	case Double:
+		v, ok := value.(float64)
+		if !ok {
+			break
+		}
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+		e.buf.Write(b[:8])
+		return nil
+	case ByteArray:
+		var v []byte
+		switch s := value.(type) {
+		case []byte:
+			v = s
+		case string:
+			v = []byte(s)
+		default:
+			return fmt.Errorf("value of type %T can't be stored as %v", value, e.typ)
+		}
+		binary.LittleEndian.PutUint32(b[:], uint32(len(v)))
+		e.buf.Write(b[:4])
+		e.buf.Write(v)
+		return nil
+	case Int96, FixedLenByteArray:
+		v, ok := value.([]byte)
+		if !ok {
+			break
+		}
+		if len(v) != e.typeLength {
+			return fmt.Errorf("value of %v bytes can't be stored as %v of %v bytes", len(v), e.typ, e.typeLength)
+		}
+		e.buf.Write(v)
+		return nil
+	}
+	return fmt.Errorf("value of type %T can't be stored as %v", value, e.typ)
+}
+
+// bytes returns the encoded values, padding the last byte of booleans.
+func (e *plainEncoder) bytes() []byte {
+	if e.numBits > 0 {
+		e.buf.WriteByte(byte(e.bits))
+		e.bits, e.numBits = 0, 0
+	}
+	return e.buf.Bytes()
+}
+
+// size returns the number of bytes of the values encoded so far.
+func (e *plainEncoder) size() int {
+	return e.buf.Len()
+}
+
+func (e *plainEncoder) reset() {
+	e.buf.Reset()
+	e.bits, e.numBits = 0, 0
+}
+
+// valueLength returns the number of bytes taken by each value of a physical
+// type, or 0 if its values have a variable length.
+func valueLength(typ Type, typeLength int) int {
+	switch typ {
+	case Int32, Float:
+		return 4
+	case Int64, Double:
+		return 8
+	case Int96:
+		return 12
+	case FixedLenByteArray:
+		return typeLength
+	}
+	return 0
+}
+
+// decodePlain decodes n values encoded with the PLAIN encoding.
+func decodePlain(data []byte, typ Type, typeLength, n int) ([]interface{}, error) {
+	// every value takes at least a bit, or 4 bytes for byte arrays
+	if (typ == Boolean && (n+7)/8 > len(data)) || (typ == ByteArray && n > len(data)/4) {
+		return nil, fmt.Errorf("unexpected end of plain data")
+	}
+	values := make([]interface{}, n)
+	if typ == Boolean {
+		for i := range values {
+			values[i] = data[i/8]>>uint(i%8)&1 != 0
+		}
+		return values, nil
+	}
+	if typ == ByteArray {
+		pos := 0
+		for i := range values {
+			if pos+4 > len(data) {
+				return nil, fmt.Errorf("unexpected end of plain data")
+			}
+			length := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if length < 0 || length > len(data)-pos {
+				return nil, fmt.Errorf("unexpected end of plain data")
+			}
+			values[i] = data[pos : pos+length]
+			pos += length
+		}
+		return values, nil
+	}
+	length := valueLength(typ, typeLength)
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid type %v of length %v", typ, typeLength)
+	}
+	if n > len(data)/length {
+		return nil, fmt.Errorf("unexpected end of plain data")
+	}
+	for i := range values {
+		values[i] = decodeFixed(data[i*length:(i+1)*length], typ)
+	}
+	return values, nil
+}
+
+// decodeFixed decodes a value of a fixed-length physical type.
+func decodeFixed(data []byte, typ Type) interface{} {
+	switch typ {
+	case Int32:
+		return int32(binary.LittleEndian.Uint32(data))
+	case Int64:
+		return int64(binary.LittleEndian.Uint64(data))
+	case Float:
+		return math.Float32frombits(binary.LittleEndian.Uint32(data))
+	case Double:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data))
+	}
+	return data
+}
+
+// decodeDeltaBinaryPacked decodes integers encoded with the
+// DELTA_BINARY_PACKED encoding, returning them along with the number of bytes
+// they take.
+func decodeDeltaBinaryPacked(data []byte) ([]int64, int, error) {
+	pos := 0
+	readUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return 0, fmt.Errorf("unexpected end of delta data")
+		}
+		pos += n
+		return v, nil
+	}
+	readZigZag := func() (int64, error) {
+		v, err := readUvarint()
+		return int64(v>>1) ^ -int64(v&1), err
+	}
+
+	blockSize, err := readUvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	numMiniblocks, err := readUvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := readUvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	first, err := readZigZag()
+	if err != nil {
+		return nil, 0, err
+	}
+	if numMiniblocks == 0 || blockSize%(numMiniblocks*32) != 0 || blockSize > maxDeltaBlockSize || total > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("invalid delta header")
+	}
+	valuesPerMiniblock := int(blockSize / numMiniblocks)
+
+	values := make([]int64, 0, minInt(int(total), maxPreallocatedValues))
+	if total > 0 {
+		values = append(values, first)
+	}
+	last := first
+	for uint64(len(values)) < total {
+		minDelta, err := readZigZag()
+		if err != nil {
+			return nil, 0, err
+		}
+		if pos+int(numMiniblocks) > len(data) {
+			return nil, 0, fmt.Errorf("unexpected end of delta data")
+		}
+		widths := data[pos : pos+int(numMiniblocks)]
+		pos += int(numMiniblocks)
+		for _, width := range widths {
+			if uint64(len(values)) == total {
+				break
+			}
+			if width > 64 {
+				return nil, 0, fmt.Errorf("invalid delta bit width %v", width)
+			}
+			size := valuesPerMiniblock * int(width) / 8
+			if pos+size > len(data) {
+				return nil, 0, fmt.Errorf("unexpected end of delta data")
+			}
+			for i := 0; i < valuesPerMiniblock && uint64(len(values)) < total; i++ {
+				var delta uint64
+				for b := 0; b < int(width); b++ {
+					bit := i*int(width) + b
+					delta |= uint64(data[pos+bit/8]>>uint(bit%8)&1) << uint(b)
+				}
+				last += minDelta + int64(delta)
+				values = append(values, last)
+			}
+			pos += size
+		}
+	}
+	return values, pos, nil
+}
+
+// decodeDeltaLengthByteArray decodes byte arrays encoded with the
+// DELTA_LENGTH_BYTE_ARRAY encoding, returning them along with the number of
+// bytes they take.
+func decodeDeltaLengthByteArray(data []byte) ([][]byte, int, error) {
+	lengths, pos, err := decodeDeltaBinaryPacked(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make([][]byte, len(lengths))
+	for i, length := range lengths {
+		if length < 0 || length > int64(len(data)-pos) {
+			return nil, 0, fmt.Errorf("unexpected end of delta data")
+		}
+		values[i] = data[pos : pos+int(length)]
+		pos += int(length)
+	}
+	return values, pos, nil
+}
+
+// decodeDeltaByteArray decodes byte arrays encoded with the DELTA_BYTE_ARRAY
+// encoding, which stores the length of the prefix shared with the previous
+// value followed by the suffix.
+func decodeDeltaByteArray(data []byte) ([][]byte, error) {
+	prefixLengths, pos, err := decodeDeltaBinaryPacked(data)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, _, err := decodeDeltaLengthByteArray(data[pos:])
+	if err != nil {
+		return nil, err
+	}
+	if len(suffixes) != len(prefixLengths) {
+		return nil, fmt.Errorf("delta data has %v prefixes and %v suffixes", len(prefixLengths), len(suffixes))
+	}
+	values := make([][]byte, len(suffixes))
+	var previous []byte
+	for i, suffix := range suffixes {
+		prefixLength := prefixLengths[i]
+		if prefixLength < 0 || prefixLength > int64(len(previous)) {
+			return nil, fmt.Errorf("invalid delta prefix length %v", prefixLength)
+		}
+		value := make([]byte, 0, int(prefixLength)+len(suffix))
+		value = append(append(value, previous[:prefixLength]...), suffix...)
+		values[i] = value
+		previous = value
+	}
+	return values, nil
+}
+
+// decodeByteStreamSplit decodes n values encoded with the BYTE_STREAM_SPLIT
+// encoding, which stores the k-th bytes of all the values in the k-th stream.
+func decodeByteStreamSplit(data []byte, typ Type, typeLength, n int) ([]interface{}, error) {
+	length := valueLength(typ, typeLength)
+	if length <= 0 {
+		return nil, fmt.Errorf("BYTE_STREAM_SPLIT can't encode %v", typ)
+	}
+	if n > len(data)/length {
+		return nil, fmt.Errorf("unexpected end of byte stream split data")
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		value := make([]byte, length)
+		for k := range value {
+			value[k] = data[k*n+i]
+		}
+		values[i] = decodeFixed(value, typ)
+	}
+	return values, nil
+}
+
+// decodeValues decodes n values of a page. Dictionary encoded values are
+// looked up in the dictionary of the column chunk.
+func decodeValues(enc encoding, data []byte, typ Type, typeLength, n int, dictionary []interface{}) ([]interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of values %v", n)
+	}
+	switch enc {
+	case encodingPlain:
+		return decodePlain(data, typ, typeLength, n)
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if dictionary == nil {
+			return nil, fmt.Errorf("dictionary encoded page without a dictionary")
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("unexpected end of dictionary indexes")
+		}
+		indexes, err := decodeRLE(data[1:], int(data[0]), n)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i, index := range indexes {
+			if index >= len(dictionary) {
+				return nil, fmt.Errorf("dictionary index %v out of range", index)
+			}
+			values[i] = dictionary[index]
+		}
+		return values, nil
+	case encodingRLE:
+		if typ != Boolean {
+			return nil, fmt.Errorf("RLE can't encode %v values", typ)
+		}
+		// RLE encoded booleans are prefixed with their length
+		if len(data) < 4 {
+			return nil, fmt.Errorf("unexpected end of RLE data")
+		}
+		bits, err := decodeRLE(data[4:], 1, n)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i, bit := range bits {
+			values[i] = bit != 0
+		}
+		return values, nil
+	case encodingDeltaBinaryPacked:
+		integers, _, err := decodeDeltaBinaryPacked(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(integers) < n {
+			return nil, fmt.Errorf("page has %v values instead of %v", len(integers), n)
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			switch typ {
+			case Int32:
+				values[i] = int32(integers[i])
+			case Int64:
+				values[i] = integers[i]
+			default:
+				return nil, fmt.Errorf("DELTA_BINARY_PACKED can't encode %v values", typ)
+			}
+		}
+		return values, nil
+	case encodingDeltaLengthByteArray, encodingDeltaByteArray:
+		var arrays [][]byte
+		var err error
+		if enc == encodingDeltaByteArray {
+			arrays, err = decodeDeltaByteArray(data)
+		} else {
+			arrays, _, err = decodeDeltaLengthByteArray(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(arrays) < n {
+			return nil, fmt.Errorf("page has %v values instead of %v", len(arrays), n)
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			values[i] = arrays[i]
+		}
+		return values, nil
+	case encodingByteStreamSplit:
+		return decodeByteStreamSplit(data, typ, typeLength, n)
+	}
+	return nil, fmt.Errorf("unsupported encoding %v", enc)
+}
@@ -0,0 +1,256 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// appendDeltaBinaryPacked appends integers encoded with the
+// DELTA_BINARY_PACKED encoding, in blocks of 128 values and 4 miniblocks.
+func appendDeltaBinaryPacked(dst []byte, values []int64) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	uvarint := func(v uint64) {
+		dst = append(dst, varint[:binary.PutUvarint(varint[:], v)]...)
+	}
+	zigzag := func(v int64) {
+		uvarint(uint64((v << 1) ^ (v >> 63)))
+	}
+	uvarint(128)
+	uvarint(4)
+	uvarint(uint64(len(values)))
+	if len(values) == 0 {
+		zigzag(0)
+		return dst
+	}
+	zigzag(values[0])
+	for start := 1; start < len(values); start += 128 {
+		deltas := make([]int64, minInt(128, len(values)-start))
+		minDelta := int64(math.MaxInt64)
+		for i := range deltas {
+			deltas[i] = values[start+i] - values[start+i-1]
+			if deltas[i] < minDelta {
+				minDelta = deltas[i]
+			}
+		}
+		zigzag(minDelta)
+		widths := make([]int, 4)
+		for i, delta := range deltas {
+			if w := bitWidth(int(delta - minDelta)); w > widths[i/32] {
+				widths[i/32] = w
+			}
+		}
+		for _, w := range widths {
+			dst = append(dst, byte(w))
+		}
+		for m := 0; m*32 < len(deltas); m++ {
+			packed := make([]byte, 32*widths[m]/8)
+			for i := 0; i < 32 && m*32+i < len(deltas); i++ {
+				v := deltas[m*32+i] - minDelta
+				for b := 0; b < widths[m]; b++ {
+					if v>>uint(b)&1 != 0 {
+						bit := i*widths[m] + b
+						packed[bit/8] |= 1 << uint(bit%8)
+					}
+				}
+			}
+			dst = append(dst, packed...)
+		}
+	}
+	return dst
+}
+
+func appendDeltaLengthByteArray(dst []byte, values []string) []byte {
+	lengths := make([]int64, len(values))
+	for i, value := range values {
+		lengths[i] = int64(len(value))
+	}
+	dst = appendDeltaBinaryPacked(dst, lengths)
+	for _, value := range values {
+		dst = append(dst, value...)
+	}
+	return dst
+}
+
+func TestLevelEncoding(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("The RLE/bit-packing hybrid encoding", t, func() {
+		Convey("should bit-pack short runs as in the format specification", func() {
+			values := []int{0, 1, 2, 3, 4, 5, 6, 7}
+			encoded := appendRLE(nil, values, 3)
+			So(encoded, ShouldResemble, []byte{0x03, 0x88, 0xc6, 0xfa})
+			decoded, err := decodeRLE(encoded, 3, len(values))
+			So(err, ShouldBeNil)
+			So(decoded, ShouldResemble, values)
+		})
+
+		Convey("should run-length encode long runs", func() {
+			values := make([]int, 1000)
+			for i := range values {
+				values[i] = 300
+			}
+			encoded := appendRLE(nil, values, 9)
+			So(encoded, ShouldResemble, []byte{0xd0, 0x0f, 0x2c, 0x01})
+			decoded, err := decodeRLE(encoded, 9, len(values))
+			So(err, ShouldBeNil)
+			So(decoded, ShouldResemble, values)
+		})
+
+		Convey("should round trip mixed runs", func() {
+			var values []int
+			for i := 0; i < 500; i++ {
+				switch {
+				case i%100 < 40:
+					values = append(values, 1)
+				case i%7 == 0:
+					values = append(values, 0)
+				default:
+					values = append(values, i%4)
+				}
+			}
+			for _, width := range []int{2, 3, 8, 17} {
+				decoded, err := decodeRLE(appendRLE(nil, values, width), width, len(values))
+				So(err, ShouldBeNil)
+				So(decoded, ShouldResemble, values)
+			}
+		})
+
+		Convey("should reject truncated data", func() {
+			_, err := decodeRLE([]byte{0x03, 0x88}, 3, 8)
+			So(err, ShouldNotBeNil)
+			_, err = decodeRLE(nil, 1, 1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("The deprecated BIT_PACKED encoding should pack values from the most significant bit", t, func() {
+		values, size, err := decodeBitPacked([]byte{0x05, 0x39, 0x77}, 3, 8)
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, 3)
+		So(values, ShouldResemble, []int{0, 1, 2, 3, 4, 5, 6, 7})
+		_, _, err = decodeBitPacked([]byte{0x05}, 3, 8)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestValueEncoding(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("PLAIN encoded values should round trip", t, func() {
+		cases := []struct {
+			typ        Type
+			typeLength int
+			values     []interface{}
+		}{
+			{Boolean, 0, []interface{}{true, false, false, true, true, true, false, true, true}},
+			{Int32, 0, []interface{}{int32(0), int32(-1), int32(math.MaxInt32)}},
+			{Int64, 0, []interface{}{int64(math.MinInt64), int64(42)}},
+			{Int96, 12, []interface{}{[]byte("abcdefghijkl")}},
+			{Float, 0, []interface{}{float32(1.5), float32(math.Inf(1))}},
+			{Double, 0, []interface{}{-0.25, math.MaxFloat64}},
+			{ByteArray, 0, []interface{}{[]byte("hello"), []byte{}, []byte{0}}},
+			{FixedLenByteArray, 2, []interface{}{[]byte{1, 2}, []byte{3, 4}}},
+		}
+		for _, c := range cases {
+			encoder := &plainEncoder{typ: c.typ, typeLength: c.typeLength}
+			for _, value := range c.values {
+				So(encoder.encode(value), ShouldBeNil)
+			}
+			values, err := decodePlain(encoder.bytes(), c.typ, c.typeLength, len(c.values))
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, c.values)
+		}
+	})
+
+	Convey("PLAIN encoding should reject values of the wrong type", t, func() {
+		So((&plainEncoder{typ: Int32}).encode(int64(1)), ShouldNotBeNil)
+		So((&plainEncoder{typ: FixedLenByteArray, typeLength: 4}).encode([]byte{1}), ShouldNotBeNil)
+	})
+
+	Convey("PLAIN decoding should reject truncated data", t, func() {
+		_, err := decodePlain([]byte{1, 2, 3}, Int32, 0, 1)
+		So(err, ShouldNotBeNil)
+		_, err = decodePlain([]byte{5, 0, 0, 0, 'a'}, ByteArray, 0, 1)
+		So(err, ShouldNotBeNil)
+		_, err = decodePlain(nil, ByteArray, 0, 1<<30)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("DELTA_BINARY_PACKED values should be decoded", t, func() {
+		values := []int64{7, 5, 3, 1, 2, 3, 4, 5}
+		for i := 0; i < 300; i++ {
+			values = append(values, int64(i*i)-1000)
+		}
+		data := appendDeltaBinaryPacked(nil, values)
+		decoded, size, err := decodeDeltaBinaryPacked(data)
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, len(data))
+		So(decoded, ShouldResemble, values)
+
+		int32s, err := decodeValues(encodingDeltaBinaryPacked, data, Int32, 0, 3, nil)
+		So(err, ShouldBeNil)
+		So(int32s, ShouldResemble, []interface{}{int32(7), int32(5), int32(3)})
+
+		_, _, err = decodeDeltaBinaryPacked(data[:len(data)-1])
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("DELTA_LENGTH_BYTE_ARRAY and DELTA_BYTE_ARRAY values should be decoded", t, func() {
+		data := appendDeltaLengthByteArray(nil, []string{"Hello", "World", "Foobar", "ABCDEF"})
+		values, err := decodeValues(encodingDeltaLengthByteArray, data, ByteArray, 0, 4, nil)
+		So(err, ShouldBeNil)
+		So(values, ShouldResemble, []interface{}{[]byte("Hello"), []byte("World"), []byte("Foobar"), []byte("ABCDEF")})
+
+		data = appendDeltaBinaryPacked(nil, []int64{0, 4, 6, 0})
+		data = appendDeltaLengthByteArray(data, []string{"axis", "le", "s", "b"})
+		values, err = decodeValues(encodingDeltaByteArray, data, ByteArray, 0, 4, nil)
+		So(err, ShouldBeNil)
+		So(values, ShouldResemble, []interface{}{[]byte("axis"), []byte("axisle"), []byte("axisles"), []byte("b")})
+
+		data = appendDeltaBinaryPacked(nil, []int64{1})
+		data = appendDeltaLengthByteArray(data, []string{"a"})
+		_, err = decodeValues(encodingDeltaByteArray, data, ByteArray, 0, 1, nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Dictionary indexes should be looked up", t, func() {
+		dictionary := []interface{}{[]byte("a"), []byte("b")}
+		data := appendRLE([]byte{1}, []int{1, 0, 1}, 1)
+		values, err := decodeValues(encodingRLEDictionary, data, ByteArray, 0, 3, dictionary)
+		So(err, ShouldBeNil)
+		So(values, ShouldResemble, []interface{}{[]byte("b"), []byte("a"), []byte("b")})
+
+		data = appendRLE([]byte{2}, []int{2}, 2)
+		_, err = decodeValues(encodingRLEDictionary, data, ByteArray, 0, 1, dictionary)
+		So(err, ShouldNotBeNil)
+		_, err = decodeValues(encodingPlainDictionary, data, ByteArray, 0, 1, nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("RLE booleans should be decoded", t, func() {
+		encoded := appendRLE(nil, []int{1, 0, 1}, 1)
+		data := make([]byte, 4, 4+len(encoded))
+		binary.LittleEndian.PutUint32(data, uint32(len(encoded)))
+		values, err := decodeValues(encodingRLE, append(data, encoded...), Boolean, 0, 3, nil)
+		So(err, ShouldBeNil)
+		So(values, ShouldResemble, []interface{}{true, false, true})
+	})
+
+	Convey("BYTE_STREAM_SPLIT values should be decoded", t, func() {
+		// the k-th bytes of the values are stored in the k-th stream
+		data := make([]byte, 8)
+		for i, f := range []float32{1.5, -2} {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(f))
+			for k := range b {
+				data[k*2+i] = b[k]
+			}
+		}
+		values, err := decodeValues(encodingByteStreamSplit, data, Float, 0, 2, nil)
+		So(err, ShouldBeNil)
+		So(values, ShouldResemble, []interface{}{float32(1.5), float32(-2)})
+	})
+}
@@ -0,0 +1,543 @@
+package parquet
+
+// Type is the physical type of the values of a column.
+type Type int32
+
+// Physical types.
+const (
+	Boolean           Type = 0
+	Int32             Type = 1
+	Int64             Type = 2
+	Int96             Type = 3
+	Float             Type = 4
+	Double            Type = 5
+	ByteArray         Type = 6
+	FixedLenByteArray Type = 7
+)
+
+var typeNames = map[Type]string{
+	Boolean:           "boolean",
+	Int32:             "int32",
+	Int64:             "int64",
+	Int96:             "int96",
+	Float:             "float",
+	Double:            "double",
+	ByteArray:         "binary",
+	FixedLenByteArray: "fixed_len_byte_array",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Repetition states whether a field is required, optional or repeated.
+type Repetition int32
+
+// Field repetitions.
+const (
+	Required Repetition = 0
+	Optional Repetition = 1
+	Repeated Repetition = 2
+)
+
+var repetitionNames = map[Repetition]string{
+	Required: "required",
+	Optional: "optional",
+	Repeated: "repeated",
+}
+
+func (r Repetition) String() string {
+	if name, ok := repetitionNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Codec is the compression codec of the pages of a column.
+type Codec int32
+
+// Compression codecs. Only Uncompressed, Snappy and Gzip are supported.
+const (
+	Uncompressed Codec = 0
+	Snappy       Codec = 1
+	Gzip         Codec = 2
+	LZO          Codec = 3
+	Brotli       Codec = 4
+	LZ4          Codec = 5
+	ZSTD         Codec = 6
+	LZ4Raw       Codec = 7
+)
+
+// encoding is the encoding of values or levels within a page.
+type encoding int32
+
+const (
+	encodingPlain                encoding = 0
+	encodingPlainDictionary      encoding = 2
+	encodingRLE                  encoding = 3
+	encodingBitPacked            encoding = 4
+	encodingDeltaBinaryPacked    encoding = 5
+	encodingDeltaLengthByteArray encoding = 6
+	encodingDeltaByteArray       encoding = 7
+	encodingRLEDictionary        encoding = 8
+	encodingByteStreamSplit      encoding = 9
+)
+
+// convertedType is the deprecated annotation of a field, superseded by
+// logical types; it is -1 if a field has none.
+type convertedType int32
+
+const (
+	convertedNone            convertedType = -1
+	convertedUTF8            convertedType = 0
+	convertedMap             convertedType = 1
+	convertedMapKeyValue     convertedType = 2
+	convertedList            convertedType = 3
+	convertedEnum            convertedType = 4
+	convertedDecimal         convertedType = 5
+	convertedDate            convertedType = 6
+	convertedTimeMillis      convertedType = 7
+	convertedTimeMicros      convertedType = 8
+	convertedTimestampMillis convertedType = 9
+	convertedTimestampMicros convertedType = 10
+	convertedUint8           convertedType = 11
+	convertedUint16          convertedType = 12
+	convertedUint32          convertedType = 13
+	convertedUint64          convertedType = 14
+	convertedInt8            convertedType = 15
+	convertedInt16           convertedType = 16
+	convertedInt32           convertedType = 17
+	convertedInt64           convertedType = 18
+	convertedJSON            convertedType = 19
+	convertedBSON            convertedType = 20
+	convertedInterval        convertedType = 21
+)
+
+// pageType is the type of a page of a column chunk.
+type pageType int32
+
+const (
+	pageData       pageType = 0
+	pageIndex      pageType = 1
+	pageDictionary pageType = 2
+	pageDataV2     pageType = 3
+)
+
+// fileMetaData is the footer of a Parquet file.
+type fileMetaData struct {
+	version   int32
+	schema    []*schemaElement
+	numRows   int64
+	rowGroups []*rowGroup
+	createdBy string
+}
+
+func (m *fileMetaData) write(w *thriftWriter) {
+	w.writeI32Field(1, m.version)
+	w.writeListField(2, compactStruct, len(m.schema))
+	for _, element := range m.schema {
+		w.writeStruct(element)
+	}
+	w.writeI64Field(3, m.numRows)
+	w.writeListField(4, compactStruct, len(m.rowGroups))
+	for _, group := range m.rowGroups {
+		w.writeStruct(group)
+	}
+	if m.createdBy != "" {
+		w.writeBinaryField(6, []byte(m.createdBy))
+	}
+}
+
+func (m *fileMetaData) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			m.version = r.readI32(typ)
+		case 2:
+			_, size := r.readList(typ)
+			for i := 0; i < size && r.err == nil; i++ {
+				element := &schemaElement{}
+				element.read(r)
+				m.schema = append(m.schema, element)
+			}
+		case 3:
+			m.numRows = r.readI64(typ)
+		case 4:
+			_, size := r.readList(typ)
+			for i := 0; i < size && r.err == nil; i++ {
+				group := &rowGroup{}
+				group.read(r)
+				m.rowGroups = append(m.rowGroups, group)
+			}
+		case 6:
+			m.createdBy = string(r.readBinary(typ))
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// schemaElement is a node of the schema, which is flattened depth first in
+// the footer.
+type schemaElement struct {
+	typ           *Type
+	typeLength    int32
+	repetition    *Repetition
+	name          string
+	numChildren   int32
+	convertedType convertedType
+	scale         int32
+	precision     int32
+	logicalType   *LogicalType
+}
+
+func (e *schemaElement) write(w *thriftWriter) {
+	if e.typ != nil {
+		w.writeI32Field(1, int32(*e.typ))
+	}
+	if e.typ != nil && *e.typ == FixedLenByteArray {
+		w.writeI32Field(2, e.typeLength)
+	}
+	if e.repetition != nil {
+		w.writeI32Field(3, int32(*e.repetition))
+	}
+	w.writeBinaryField(4, []byte(e.name))
+	if e.typ == nil {
+		w.writeI32Field(5, e.numChildren)
+	}
+	if e.convertedType != convertedNone {
+		w.writeI32Field(6, int32(e.convertedType))
+		if e.convertedType == convertedDecimal {
+			w.writeI32Field(7, e.scale)
+			w.writeI32Field(8, e.precision)
+		}
+	}
+	if e.logicalType != nil {
+		w.writeStructField(10, e.logicalType)
+	}
+}
+
+func (e *schemaElement) read(r *thriftReader) {
+	e.convertedType = convertedNone
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			t := Type(r.readI32(typ))
+			e.typ = &t
+		case 2:
+			e.typeLength = r.readI32(typ)
+		case 3:
+			repetition := Repetition(r.readI32(typ))
+			e.repetition = &repetition
+		case 4:
+			e.name = string(r.readBinary(typ))
+		case 5:
+			e.numChildren = r.readI32(typ)
+		case 6:
+			e.convertedType = convertedType(r.readI32(typ))
+		case 7:
+			e.scale = r.readI32(typ)
+		case 8:
+			e.precision = r.readI32(typ)
+		case 10:
+			e.logicalType = &LogicalType{}
+			r.readStructField(typ, e.logicalType)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// rowGroup is the metadata of a row group.
+type rowGroup struct {
+	columns       []*columnChunk
+	totalByteSize int64
+	numRows       int64
+}
+
+func (g *rowGroup) write(w *thriftWriter) {
+	w.writeListField(1, compactStruct, len(g.columns))
+	for _, chunk := range g.columns {
+		w.writeStruct(chunk)
+	}
+	w.writeI64Field(2, g.totalByteSize)
+	w.writeI64Field(3, g.numRows)
+}
+
+func (g *rowGroup) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			_, size := r.readList(typ)
+			for i := 0; i < size && r.err == nil; i++ {
+				chunk := &columnChunk{}
+				chunk.read(r)
+				g.columns = append(g.columns, chunk)
+			}
+		case 2:
+			g.totalByteSize = r.readI64(typ)
+		case 3:
+			g.numRows = r.readI64(typ)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// columnChunk locates the chunk of a column within a row group.
+type columnChunk struct {
+	filePath   string
+	fileOffset int64
+	metaData   *columnMetaData
+}
+
+func (c *columnChunk) write(w *thriftWriter) {
+	w.writeI64Field(2, c.fileOffset)
+	w.writeStructField(3, c.metaData)
+}
+
+func (c *columnChunk) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			c.filePath = string(r.readBinary(typ))
+		case 2:
+			c.fileOffset = r.readI64(typ)
+		case 3:
+			c.metaData = &columnMetaData{}
+			r.readStructField(typ, c.metaData)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// columnMetaData describes the pages of a column chunk.
+type columnMetaData struct {
+	typ                   Type
+	encodings             []encoding
+	pathInSchema          []string
+	codec                 Codec
+	numValues             int64
+	totalUncompressedSize int64
+	totalCompressedSize   int64
+	dataPageOffset        int64
+	dictionaryPageOffset  int64
+}
+
+func (m *columnMetaData) write(w *thriftWriter) {
+	w.writeI32Field(1, int32(m.typ))
+	w.writeListField(2, compactI32, len(m.encodings))
+	for _, e := range m.encodings {
+		w.writeZigZag(int64(e))
+	}
+	w.writeListField(3, compactBinary, len(m.pathInSchema))
+	for _, name := range m.pathInSchema {
+		w.writeBinary([]byte(name))
+	}
+	w.writeI32Field(4, int32(m.codec))
+	w.writeI64Field(5, m.numValues)
+	w.writeI64Field(6, m.totalUncompressedSize)
+	w.writeI64Field(7, m.totalCompressedSize)
+	w.writeI64Field(9, m.dataPageOffset)
+	if m.dictionaryPageOffset > 0 {
+		w.writeI64Field(11, m.dictionaryPageOffset)
+	}
+}
+
+func (m *columnMetaData) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			m.typ = Type(r.readI32(typ))
+		case 2:
+			elemType, size := r.readList(typ)
+			for i := 0; i < size && r.err == nil; i++ {
+				m.encodings = append(m.encodings, encoding(r.readI32(elemType)))
+			}
+		case 3:
+			elemType, size := r.readList(typ)
+			for i := 0; i < size && r.err == nil; i++ {
+				m.pathInSchema = append(m.pathInSchema, string(r.readBinary(elemType)))
+			}
+		case 4:
+			m.codec = Codec(r.readI32(typ))
+		case 5:
+			m.numValues = r.readI64(typ)
+		case 6:
+			m.totalUncompressedSize = r.readI64(typ)
+		case 7:
+			m.totalCompressedSize = r.readI64(typ)
+		case 9:
+			m.dataPageOffset = r.readI64(typ)
+		case 11:
+			m.dictionaryPageOffset = r.readI64(typ)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// pageHeader precedes the data of each page.
+type pageHeader struct {
+	typ                  pageType
+	uncompressedPageSize int32
+	compressedPageSize   int32
+	dataPageHeader       *dataPageHeader
+	dictionaryPageHeader *dictionaryPageHeader
+	dataPageHeaderV2     *dataPageHeaderV2
+}
+
+func (h *pageHeader) write(w *thriftWriter) {
+	w.writeI32Field(1, int32(h.typ))
+	w.writeI32Field(2, h.uncompressedPageSize)
+	w.writeI32Field(3, h.compressedPageSize)
+	if h.dataPageHeader != nil {
+		w.writeStructField(5, h.dataPageHeader)
+	}
+	if h.dictionaryPageHeader != nil {
+		w.writeStructField(7, h.dictionaryPageHeader)
+	}
+	if h.dataPageHeaderV2 != nil {
+		w.writeStructField(8, h.dataPageHeaderV2)
+	}
+}
+
+func (h *pageHeader) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			h.typ = pageType(r.readI32(typ))
+		case 2:
+			h.uncompressedPageSize = r.readI32(typ)
+		case 3:
+			h.compressedPageSize = r.readI32(typ)
+		case 5:
+			h.dataPageHeader = &dataPageHeader{}
+			r.readStructField(typ, h.dataPageHeader)
+		case 7:
+			h.dictionaryPageHeader = &dictionaryPageHeader{}
+			r.readStructField(typ, h.dictionaryPageHeader)
+		case 8:
+			h.dataPageHeaderV2 = &dataPageHeaderV2{isCompressed: true}
+			r.readStructField(typ, h.dataPageHeaderV2)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// dataPageHeader describes a version 1 data page, whose levels and values are
+// compressed together.
+type dataPageHeader struct {
+	numValues               int32
+	encoding                encoding
+	definitionLevelEncoding encoding
+	repetitionLevelEncoding encoding
+}
+
+func (h *dataPageHeader) write(w *thriftWriter) {
+	w.writeI32Field(1, h.numValues)
+	w.writeI32Field(2, int32(h.encoding))
+	w.writeI32Field(3, int32(h.definitionLevelEncoding))
+	w.writeI32Field(4, int32(h.repetitionLevelEncoding))
+}
+
+func (h *dataPageHeader) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			h.numValues = r.readI32(typ)
+		case 2:
+			h.encoding = encoding(r.readI32(typ))
+		case 3:
+			h.definitionLevelEncoding = encoding(r.readI32(typ))
+		case 4:
+			h.repetitionLevelEncoding = encoding(r.readI32(typ))
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// dictionaryPageHeader describes the page holding the dictionary of a column
+// chunk.
+type dictionaryPageHeader struct {
+	numValues int32
+	encoding  encoding
+}
+
+func (h *dictionaryPageHeader) write(w *thriftWriter) {
+	w.writeI32Field(1, h.numValues)
+	w.writeI32Field(2, int32(h.encoding))
+}
+
+func (h *dictionaryPageHeader) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			h.numValues = r.readI32(typ)
+		case 2:
+			h.encoding = encoding(r.readI32(typ))
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// dataPageHeaderV2 describes a version 2 data page, whose levels are never
+// compressed and precede the values.
+type dataPageHeaderV2 struct {
+	numValues                  int32
+	numNulls                   int32
+	numRows                    int32
+	encoding                   encoding
+	definitionLevelsByteLength int32
+	repetitionLevelsByteLength int32
+	isCompressed               bool
+}
+
+func (h *dataPageHeaderV2) write(w *thriftWriter) {
+	w.writeI32Field(1, h.numValues)
+	w.writeI32Field(2, h.numNulls)
+	w.writeI32Field(3, h.numRows)
+	w.writeI32Field(4, int32(h.encoding))
+	w.writeI32Field(5, h.definitionLevelsByteLength)
+	w.writeI32Field(6, h.repetitionLevelsByteLength)
+	w.writeBoolField(7, h.isCompressed)
+}
+
+func (h *dataPageHeaderV2) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			h.numValues = r.readI32(typ)
+		case 2:
+			h.numNulls = r.readI32(typ)
+		case 3:
+			h.numRows = r.readI32(typ)
+		case 4:
+			h.encoding = encoding(r.readI32(typ))
+		case 5:
+			h.definitionLevelsByteLength = r.readI32(typ)
+		case 6:
+			h.repetitionLevelsByteLength = r.readI32(typ)
+		case 7:
+			h.isCompressed = r.readBool(typ)
+		default:
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,453 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Reader reads the records of a Parquet file, one row group at a time.
+type Reader struct {
+	r         io.ReaderAt
+	size      int64
+	meta      *fileMetaData
+	schema    *Schema
+	rowGroup  int
+	rowsLeft  int64
+	columns   []*columnReader
+	bytesRead int64
+}
+
+// columnReader reads the entries of a column chunk, one page at a time.
+type columnReader struct {
+	column *column
+	codec  Codec
+
+	// data holds the pages that haven't been read yet
+	data       []byte
+	dictionary []interface{}
+
+	// levels and values of the current page, which holds numEntries
+	// entries; the levels are nil if their maximum is 0
+	repLevels, defLevels []int
+	values               []interface{}
+	numEntries           int
+	next, nextValue      int
+}
+
+// readAt reads len(p) bytes at the given offset.
+func readAt(r io.ReaderAt, p []byte, offset int64) error {
+	n, err := r.ReadAt(p, offset)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// NewReader returns a Reader of the Parquet file of the given size, after
+// reading its footer.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, fmt.Errorf("file is too small to be a Parquet file")
+	}
+	head := make([]byte, len(magic))
+	tail := make([]byte, 4+len(magic))
+	if err := readAt(r, head, 0); err != nil {
+		return nil, err
+	}
+	if err := readAt(r, tail, size-int64(len(tail))); err != nil {
+		return nil, err
+	}
+	if string(head) != magic || string(tail[4:]) != magic {
+		return nil, fmt.Errorf("file is not a Parquet file")
+	}
+	length := int64(binary.LittleEndian.Uint32(tail))
+	if length > size-int64(len(head)+len(tail)) {
+		return nil, fmt.Errorf("footer of %v bytes exceeds the file", length)
+	}
+	footer := make([]byte, length)
+	if err := readAt(r, footer, size-int64(len(tail))-length); err != nil {
+		return nil, err
+	}
+	meta := &fileMetaData{}
+	tr := &thriftReader{data: footer}
+	meta.read(tr)
+	if tr.err != nil {
+		return nil, fmt.Errorf("invalid footer: %v", tr.err)
+	}
+	schema, err := schemaFromElements(meta.schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	for _, group := range meta.rowGroups {
+		if len(group.columns) != len(schema.columns) {
+			return nil, fmt.Errorf("row group has %v columns instead of %v", len(group.columns), len(schema.columns))
+		}
+		if group.numRows < 0 {
+			return nil, fmt.Errorf("row group has %v rows", group.numRows)
+		}
+	}
+	return &Reader{
+		r:         r,
+		size:      size,
+		meta:      meta,
+		schema:    schema,
+		bytesRead: int64(len(head)+len(tail)) + length,
+	}, nil
+}
+
+// Schema returns the schema of the file.
+func (r *Reader) Schema() *Schema {
+	return r.schema
+}
+
+// NumRows returns the number of records of the file.
+func (r *Reader) NumRows() int64 {
+	return r.meta.numRows
+}
+
+// BytesRead returns the number of bytes of the file read so far.
+func (r *Reader) BytesRead() int64 {
+	return r.bytesRead
+}
+
+// Read returns the next record, whose values are of the Go types described
+// by Group, or io.EOF after the last record.
+func (r *Reader) Read() (Group, error) {
+	for r.rowsLeft == 0 {
+		if r.rowGroup >= len(r.meta.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := r.openRowGroup(); err != nil {
+			return nil, err
+		}
+	}
+	record := make(Group, len(r.schema.Root.Children))
+	for _, cr := range r.columns {
+		// the entries of a record are those up to the next one whose
+		// repetition level is 0
+		counts := make([]int, len(cr.column.nodes))
+		for first := true; ; first = false {
+			if !first {
+				more, err := cr.more()
+				if err != nil {
+					return nil, err
+				}
+				if !more {
+					break
+				}
+			}
+			e, err := cr.read()
+			if err != nil {
+				return nil, err
+			}
+			if first && e.rep != 0 {
+				return nil, fmt.Errorf("column '%v' has invalid repetition levels", cr.column.path())
+			}
+			if err = cr.column.insert(record, e, counts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.rowsLeft--
+	return record, nil
+}
+
+// openRowGroup reads the column chunks of the next row group.
+func (r *Reader) openRowGroup() error {
+	group := r.meta.rowGroups[r.rowGroup]
+	r.rowGroup++
+	r.columns = nil
+	for i, chunk := range group.columns {
+		c := r.schema.columns[i]
+		meta := chunk.metaData
+		if meta == nil || chunk.filePath != "" {
+			return fmt.Errorf("column chunk of '%v' is stored outside of the file", c.path())
+		}
+		if meta.typ != c.leaf().Type {
+			return fmt.Errorf("column chunk of '%v' has type %v instead of %v", c.path(), meta.typ, c.leaf().Type)
+		}
+		start := meta.dataPageOffset
+		if meta.dictionaryPageOffset > 0 && meta.dictionaryPageOffset < start {
+			start = meta.dictionaryPageOffset
+		}
+		length := meta.totalCompressedSize
+		if start < 0 || length < 0 || start > r.size-length {
+			return fmt.Errorf("column chunk of '%v' exceeds the file", c.path())
+		}
+		data := make([]byte, length)
+		if err := readAt(r.r, data, start); err != nil {
+			return err
+		}
+		r.bytesRead += length
+		r.columns = append(r.columns, &columnReader{column: c, codec: meta.codec, data: data})
+	}
+	r.rowsLeft = group.numRows
+	return nil
+}
+
+// insert adds an entry of a column to a record; counts holds the index of the
+// current element of each repeated field on the path of the column.
+func (c *column) insert(record Group, e entry, counts []int) error {
+	group := record
+	for i, node := range c.nodes {
+		index := c.indexes[i]
+		if e.def < c.defLevels[i] {
+			// an undefined repeated field is an empty list
+			if node.Repetition == Repeated && group[index] == nil {
+				group[index] = []interface{}{}
+			}
+			return nil
+		}
+		if node.Repetition != Repeated {
+			if node.IsLeaf() {
+				group[index] = e.value
+				return nil
+			}
+			if group[index] == nil {
+				group[index] = make(Group, len(node.Children))
+			}
+			group = group[index].(Group)
+			continue
+		}
+		// a new element starts at the field repeated at the repetition
+		// level of the entry, and in every field nested within it
+		if c.repLevels[i] == e.rep {
+			counts[i]++
+		} else if c.repLevels[i] > e.rep {
+			counts[i] = 0
+		}
+		elements, _ := group[index].([]interface{})
+		if counts[i] == len(elements) {
+			var element interface{} = e.value
+			if !node.IsLeaf() {
+				element = make(Group, len(node.Children))
+			}
+			elements = append(elements, element)
+			group[index] = elements
+		} else if counts[i] > len(elements) || node.IsLeaf() {
+			return fmt.Errorf("column '%v' has invalid repetition levels", c.path())
+		}
+		if node.IsLeaf() {
+			return nil
+		}
+		group = elements[counts[i]].(Group)
+	}
+	return nil
+}
+
+// level returns the level of an entry, which is 0 if the page has no levels.
+func level(levels []int, i int) int {
+	if levels == nil {
+		return 0
+	}
+	return levels[i]
+}
+
+// fill reads pages until one has entries left, returning false at the end of
+// the column chunk.
+func (cr *columnReader) fill() (bool, error) {
+	for cr.next >= cr.numEntries {
+		if len(cr.data) == 0 {
+			return false, nil
+		}
+		if err := cr.readPage(); err != nil {
+			return false, fmt.Errorf("error reading page of column '%v': %v", cr.column.path(), err)
+		}
+	}
+	return true, nil
+}
+
+// more reports whether the next entry belongs to the current record.
+func (cr *columnReader) more() (bool, error) {
+	ok, err := cr.fill()
+	if !ok || err != nil {
+		return false, err
+	}
+	return level(cr.repLevels, cr.next) > 0, nil
+}
+
+// read returns the next entry.
+func (cr *columnReader) read() (entry, error) {
+	ok, err := cr.fill()
+	if err != nil {
+		return entry{}, err
+	}
+	if !ok {
+		return entry{}, fmt.Errorf("column '%v' has fewer values than its row group has rows", cr.column.path())
+	}
+	e := entry{rep: level(cr.repLevels, cr.next), def: level(cr.defLevels, cr.next)}
+	if e.def == cr.column.maxDef {
+		if cr.nextValue >= len(cr.values) {
+			return entry{}, fmt.Errorf("column '%v' has fewer values than levels", cr.column.path())
+		}
+		e.value = cr.values[cr.nextValue]
+		cr.nextValue++
+	}
+	cr.next++
+	return e, nil
+}
+
+// readPage reads the next page of the column chunk.
+func (cr *columnReader) readPage() error {
+	header := &pageHeader{}
+	tr := &thriftReader{data: cr.data}
+	header.read(tr)
+	if tr.err != nil {
+		return fmt.Errorf("invalid page header: %v", tr.err)
+	}
+	size := int(header.compressedPageSize)
+	if size < 0 || size > len(cr.data)-tr.pos {
+		return fmt.Errorf("page of %v bytes exceeds the column chunk", size)
+	}
+	body := cr.data[tr.pos : tr.pos+size]
+	cr.data = cr.data[tr.pos+size:]
+	switch header.typ {
+	case pageDictionary:
+		return cr.readDictionaryPage(header, body)
+	case pageData:
+		return cr.readDataPage(header, body)
+	case pageDataV2:
+		return cr.readDataPageV2(header, body)
+	}
+	// index pages are skipped
+	return nil
+}
+
+func (cr *columnReader) readDictionaryPage(header *pageHeader, body []byte) error {
+	h := header.dictionaryPageHeader
+	if h == nil {
+		return fmt.Errorf("dictionary page without a dictionary page header")
+	}
+	page, err := decompress(cr.codec, body, int(header.uncompressedPageSize))
+	if err != nil {
+		return err
+	}
+	leaf := cr.column.leaf()
+	cr.dictionary, err = decodeValues(encodingPlain, page, leaf.Type, int(leaf.TypeLength), int(h.numValues), nil)
+	return err
+}
+
+// readDataPage reads a version 1 data page, whose levels precede the values.
+func (cr *columnReader) readDataPage(header *pageHeader, body []byte) error {
+	h := header.dataPageHeader
+	if h == nil {
+		return fmt.Errorf("data page without a data page header")
+	}
+	page, err := decompress(cr.codec, body, int(header.uncompressedPageSize))
+	if err != nil {
+		return err
+	}
+	n := int(h.numValues)
+	if n < 0 {
+		return fmt.Errorf("invalid number of values %v", n)
+	}
+	if cr.repLevels, page, err = readLevels(page, h.repetitionLevelEncoding, cr.column.maxRep, n); err != nil {
+		return err
+	}
+	if cr.defLevels, page, err = readLevels(page, h.definitionLevelEncoding, cr.column.maxDef, n); err != nil {
+		return err
+	}
+	return cr.readValues(h.encoding, page, n)
+}
+
+// readDataPageV2 reads a version 2 data page, whose levels are RLE encoded
+// without a length prefix and never compressed.
+func (cr *columnReader) readDataPageV2(header *pageHeader, body []byte) error {
+	h := header.dataPageHeaderV2
+	if h == nil {
+		return fmt.Errorf("data page without a data page header")
+	}
+	n := int(h.numValues)
+	repLength, defLength := int(h.repetitionLevelsByteLength), int(h.definitionLevelsByteLength)
+	if n < 0 || repLength < 0 || defLength < 0 || repLength > len(body)-defLength {
+		return fmt.Errorf("invalid data page header")
+	}
+	var err error
+	if cr.repLevels, err = decodeLevels(body[:repLength], cr.column.maxRep, n); err != nil {
+		return err
+	}
+	if cr.defLevels, err = decodeLevels(body[repLength:repLength+defLength], cr.column.maxDef, n); err != nil {
+		return err
+	}
+	data := body[repLength+defLength:]
+	if h.isCompressed {
+		data, err = decompress(cr.codec, data, int(header.uncompressedPageSize)-repLength-defLength)
+		if err != nil {
+			return err
+		}
+	}
+	return cr.readValues(h.encoding, data, n)
+}
+
+// readValues decodes the values of the entries of a page whose levels were
+// read.
+func (cr *columnReader) readValues(enc encoding, data []byte, n int) error {
+	count := n
+	if cr.defLevels != nil {
+		count = 0
+		for _, def := range cr.defLevels {
+			if def == cr.column.maxDef {
+				count++
+			}
+		}
+	}
+	leaf := cr.column.leaf()
+	values, err := decodeValues(enc, data, leaf.Type, int(leaf.TypeLength), count, cr.dictionary)
+	if err != nil {
+		return err
+	}
+	cr.values, cr.numEntries, cr.next, cr.nextValue = values, n, 0, 0
+	return nil
+}
+
+// readLevels reads the n levels at the start of a version 1 data page,
+// returning them along with the rest of the page.
+func readLevels(data []byte, enc encoding, max, n int) ([]int, []byte, error) {
+	if max == 0 {
+		return nil, data, nil
+	}
+	switch enc {
+	case encodingRLE:
+		if len(data) < 4 {
+			return nil, nil, fmt.Errorf("unexpected end of levels")
+		}
+		length := int64(binary.LittleEndian.Uint32(data))
+		if length > int64(len(data)-4) {
+			return nil, nil, fmt.Errorf("unexpected end of levels")
+		}
+		levels, err := decodeLevels(data[4:4+length], max, n)
+		return levels, data[4+length:], err
+	case encodingBitPacked:
+		levels, size, err := decodeBitPacked(data, bitWidth(max), n)
+		if err != nil {
+			return nil, nil, err
+		}
+		return levels, data[size:], checkLevels(levels, max)
+	}
+	return nil, nil, fmt.Errorf("unsupported level encoding %v", enc)
+}
+
+// decodeLevels decodes n RLE encoded levels.
+func decodeLevels(data []byte, max, n int) ([]int, error) {
+	if max == 0 {
+		return nil, nil
+	}
+	levels, err := decodeRLE(data, bitWidth(max), n)
+	if err != nil {
+		return nil, err
+	}
+	return levels, checkLevels(levels, max)
+}
+
+func checkLevels(levels []int, max int) error {
+	for _, l := range levels {
+		if l > max {
+			return fmt.Errorf("level %v exceeds the maximum of %v", l, max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,795 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LogicalKind is the kind of a logical type, which annotates a field to
+// specify how its physical values are interpreted.
+type LogicalKind int
+
+// Kinds of logical types; the values are those of the LogicalType union in
+// the Parquet format.
+const (
+	NoLogicalType LogicalKind = 0
+	String        LogicalKind = 1
+	Map           LogicalKind = 2
+	List          LogicalKind = 3
+	Enum          LogicalKind = 4
+	Decimal       LogicalKind = 5
+	Date          LogicalKind = 6
+	Time          LogicalKind = 7
+	Timestamp     LogicalKind = 8
+	Integer       LogicalKind = 10
+	Unknown       LogicalKind = 11
+	JSON          LogicalKind = 12
+	BSON          LogicalKind = 13
+	UUID          LogicalKind = 14
+)
+
+var logicalKindNames = map[LogicalKind]string{
+	String:    "STRING",
+	Map:       "MAP",
+	List:      "LIST",
+	Enum:      "ENUM",
+	Decimal:   "DECIMAL",
+	Date:      "DATE",
+	Time:      "TIME",
+	Timestamp: "TIMESTAMP",
+	Integer:   "INTEGER",
+	Unknown:   "UNKNOWN",
+	JSON:      "JSON",
+	BSON:      "BSON",
+	UUID:      "UUID",
+}
+
+// TimeUnit is the unit of Time and Timestamp values.
+type TimeUnit int
+
+// Time units; the values are those of the TimeUnit union in the Parquet
+// format.
+const (
+	Millis TimeUnit = 1
+	Micros TimeUnit = 2
+	Nanos  TimeUnit = 3
+)
+
+var timeUnitNames = map[TimeUnit]string{
+	Millis: "MILLIS",
+	Micros: "MICROS",
+	Nanos:  "NANOS",
+}
+
+// LogicalType annotates a field with the interpretation of its values.
+type LogicalType struct {
+	Kind LogicalKind
+
+	// Scale and Precision are the parameters of Decimal types.
+	Scale, Precision int32
+
+	// IsAdjustedToUTC and Unit are the parameters of Time and Timestamp types.
+	IsAdjustedToUTC bool
+	Unit            TimeUnit
+
+	// BitWidth and IsSigned are the parameters of Integer types.
+	BitWidth int8
+	IsSigned bool
+}
+
+func (t *LogicalType) String() string {
+	name := logicalKindNames[t.Kind]
+	switch t.Kind {
+	case Decimal:
+		return fmt.Sprintf("%v(%v,%v)", name, t.Precision, t.Scale)
+	case Time, Timestamp:
+		return fmt.Sprintf("%v(%v,%v)", name, timeUnitNames[t.Unit], t.IsAdjustedToUTC)
+	case Integer:
+		return fmt.Sprintf("%v(%v,%v)", name, t.BitWidth, t.IsSigned)
+	}
+	return name
+}
+
+func (t *LogicalType) write(w *thriftWriter) {
+	w.fieldHeader(int16(t.Kind), compactStruct)
+	w.writeStruct(logicalTypeParams{t})
+}
+
+func (t *LogicalType) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		if _, ok := logicalKindNames[LogicalKind(id)]; !ok {
+			// an unknown logical type is ignored, as the physical values
+			// can still be read
+			return false
+		}
+		t.Kind = LogicalKind(id)
+		r.readStructField(typ, logicalTypeParams{t})
+		return true
+	})
+}
+
+// logicalTypeParams serializes the parameters of a logical type, which are a
+// structure of their own within the LogicalType union.
+type logicalTypeParams struct {
+	*LogicalType
+}
+
+func (p logicalTypeParams) write(w *thriftWriter) {
+	switch p.Kind {
+	case Decimal:
+		w.writeI32Field(1, p.Scale)
+		w.writeI32Field(2, p.Precision)
+	case Time, Timestamp:
+		w.writeBoolField(1, p.IsAdjustedToUTC)
+		unit := timeUnit(p.Unit)
+		w.writeStructField(2, &unit)
+	case Integer:
+		w.fieldHeader(1, compactByte)
+		w.buf.WriteByte(byte(p.BitWidth))
+		w.writeBoolField(2, p.IsSigned)
+	}
+}
+
+func (p logicalTypeParams) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		switch {
+		case p.Kind == Decimal && id == 1:
+			p.Scale = r.readI32(typ)
+		case p.Kind == Decimal && id == 2:
+			p.Precision = r.readI32(typ)
+		case (p.Kind == Time || p.Kind == Timestamp) && id == 1:
+			p.IsAdjustedToUTC = r.readBool(typ)
+		case (p.Kind == Time || p.Kind == Timestamp) && id == 2:
+			var unit timeUnit
+			r.readStructField(typ, &unit)
+			p.Unit = TimeUnit(unit)
+		case p.Kind == Integer && id == 1:
+			if r.expect(typ, compactByte) {
+				p.BitWidth = int8(r.readByte())
+			}
+		case p.Kind == Integer && id == 2:
+			p.IsSigned = r.readBool(typ)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// timeUnit serializes the TimeUnit union, whose members are empty structures.
+type timeUnit TimeUnit
+
+func (u *timeUnit) write(w *thriftWriter) {
+	w.fieldHeader(int16(*u), compactStruct)
+	w.writeStruct(emptyStruct{})
+}
+
+func (u *timeUnit) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool {
+		*u = timeUnit(id)
+		return false
+	})
+}
+
+// emptyStruct serializes a structure without fields.
+type emptyStruct struct{}
+
+func (emptyStruct) write(w *thriftWriter) {}
+func (emptyStruct) read(r *thriftReader) {
+	r.readStruct(func(id int16, typ byte) bool { return false })
+}
+
+// Node is a field of a schema: either a group of fields, or a leaf field
+// holding values of a physical type.
+type Node struct {
+	Name       string
+	Repetition Repetition
+
+	// Type is the physical type of a leaf field, and TypeLength the length
+	// of the values of FixedLenByteArray fields.
+	Type       Type
+	TypeLength int32
+
+	// LogicalType, if set, annotates the field.
+	LogicalType *LogicalType
+
+	// Children are the fields of a group; a node without children is a leaf.
+	Children []*Node
+}
+
+// IsLeaf reports whether a node is a leaf field.
+func (node *Node) IsLeaf() bool {
+	return node.Children == nil
+}
+
+// Is reports whether a node is annotated with the given kind of logical type.
+func (node *Node) Is(kind LogicalKind) bool {
+	return node.LogicalType != nil && node.LogicalType.Kind == kind
+}
+
+// Schema is the tree of fields of the records of a file.
+type Schema struct {
+	// Root is the group holding the top-level fields of the records.
+	Root *Node
+
+	columns []*column
+}
+
+// column is a leaf field, whose values are stored in a column of their own.
+type column struct {
+	// nodes are the fields on the path from the root to the leaf, and
+	// indexes their indexes within their parents' children
+	nodes   []*Node
+	indexes []int
+
+	// defLevels and repLevels hold the definition and repetition levels of
+	// each of the nodes
+	defLevels, repLevels []int
+	maxDef, maxRep       int
+}
+
+func (c *column) leaf() *Node {
+	return c.nodes[len(c.nodes)-1]
+}
+
+// path returns the dotted path of the column.
+func (c *column) path() string {
+	names := make([]string, len(c.nodes))
+	for i, node := range c.nodes {
+		names[i] = node.Name
+	}
+	return strings.Join(names, ".")
+}
+
+// NewSchema returns a schema with the given top-level fields, after checking
+// that the tree of fields is valid.
+func NewSchema(name string, fields []*Node) (*Schema, error) {
+	schema := &Schema{Root: &Node{Name: name, Repetition: Required, Children: fields}}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("schema must have at least one field")
+	}
+	if err := schema.addColumns(schema.Root, nil, nil); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// addColumns validates the fields of a group and adds their columns.
+func (schema *Schema) addColumns(group *Node, nodes []*Node, indexes []int) error {
+	names := map[string]bool{}
+	for i, node := range group.Children {
+		if node == nil {
+			return fmt.Errorf("group '%v' has a nil field", group.Name)
+		}
+		if node.Name == "" {
+			return fmt.Errorf("group '%v' has a field without a name", group.Name)
+		}
+		if names[node.Name] {
+			return fmt.Errorf("group '%v' has several fields named '%v'", group.Name, node.Name)
+		}
+		names[node.Name] = true
+		if _, ok := repetitionNames[node.Repetition]; !ok {
+			return fmt.Errorf("field '%v' has an invalid repetition", node.Name)
+		}
+		path := append(append([]*Node{}, nodes...), node)
+		pathIndexes := append(append([]int{}, indexes...), i)
+		if !node.IsLeaf() {
+			if len(node.Children) == 0 {
+				return fmt.Errorf("group '%v' must have at least one field", node.Name)
+			}
+			if err := schema.addColumns(node, path, pathIndexes); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := typeNames[node.Type]; !ok {
+			return fmt.Errorf("field '%v' has an invalid type", node.Name)
+		}
+		if node.Type == FixedLenByteArray && node.TypeLength <= 0 {
+			return fmt.Errorf("fixed length field '%v' must have a positive length", node.Name)
+		}
+		c := &column{nodes: path, indexes: pathIndexes}
+		def, rep := 0, 0
+		for _, n := range path {
+			if n.Repetition != Required {
+				def++
+			}
+			if n.Repetition == Repeated {
+				rep++
+			}
+			c.defLevels = append(c.defLevels, def)
+			c.repLevels = append(c.repLevels, rep)
+		}
+		c.maxDef, c.maxRep = def, rep
+		schema.columns = append(schema.columns, c)
+	}
+	return nil
+}
+
+// elements returns the schema flattened depth first, as stored in the footer.
+func (schema *Schema) elements() []*schemaElement {
+	var elements []*schemaElement
+	var flatten func(node *Node, root bool)
+	flatten = func(node *Node, root bool) {
+		element := &schemaElement{name: node.Name, convertedType: convertedNone}
+		if !root {
+			repetition := node.Repetition
+			element.repetition = &repetition
+		}
+		if node.IsLeaf() {
+			t := node.Type
+			element.typ = &t
+			element.typeLength = node.TypeLength
+		} else {
+			element.numChildren = int32(len(node.Children))
+		}
+		if node.LogicalType != nil {
+			logicalType := *node.LogicalType
+			element.logicalType = &logicalType
+			element.convertedType = logicalType.convertedType()
+			if logicalType.Kind == Decimal {
+				element.scale, element.precision = logicalType.Scale, logicalType.Precision
+			}
+		}
+		elements = append(elements, element)
+		for _, child := range node.Children {
+			flatten(child, false)
+		}
+	}
+	flatten(schema.Root, true)
+	return elements
+}
+
+// schemaFromElements rebuilds a schema from its flattened form.
+func schemaFromElements(elements []*schemaElement) (*Schema, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("file has no schema")
+	}
+	next := 0
+	var unflatten func(depth int) (*Node, error)
+	unflatten = func(depth int) (*Node, error) {
+		if next >= len(elements) {
+			return nil, fmt.Errorf("schema has fewer elements than its groups have children")
+		}
+		if depth > maxThriftDepth {
+			return nil, fmt.Errorf("schema is nested too deeply")
+		}
+		element := elements[next]
+		next++
+		node := &Node{Name: element.name, Repetition: Required}
+		if element.repetition != nil {
+			node.Repetition = *element.repetition
+		}
+		node.LogicalType = element.logicalType
+		if node.LogicalType == nil {
+			node.LogicalType = element.convertedLogicalType()
+		}
+		if element.typ != nil && element.numChildren == 0 {
+			node.Type = *element.typ
+			node.TypeLength = element.typeLength
+			return node, nil
+		}
+		node.Children = []*Node{}
+		for i := int32(0); i < element.numChildren; i++ {
+			child, err := unflatten(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+		return node, nil
+	}
+	root, err := unflatten(0)
+	if err != nil {
+		return nil, err
+	}
+	if next != len(elements) {
+		return nil, fmt.Errorf("schema has more elements than its groups have children")
+	}
+	return NewSchema(root.Name, root.Children)
+}
+
+// convertedType returns the deprecated annotation equivalent to a logical
+// type, which is written along with it for older readers.
+func (t *LogicalType) convertedType() convertedType {
+	switch t.Kind {
+	case String:
+		return convertedUTF8
+	case Map:
+		return convertedMap
+	case List:
+		return convertedList
+	case Enum:
+		return convertedEnum
+	case Decimal:
+		return convertedDecimal
+	case Date:
+		return convertedDate
+	case JSON:
+		return convertedJSON
+	case BSON:
+		return convertedBSON
+	case Time:
+		switch t.Unit {
+		case Millis:
+			return convertedTimeMillis
+		case Micros:
+			return convertedTimeMicros
+		}
+	case Timestamp:
+		switch t.Unit {
+		case Millis:
+			return convertedTimestampMillis
+		case Micros:
+			return convertedTimestampMicros
+		}
+	case Integer:
+		integers := map[int8][2]convertedType{
+			8:  {convertedUint8, convertedInt8},
+			16: {convertedUint16, convertedInt16},
+			32: {convertedUint32, convertedInt32},
+			64: {convertedUint64, convertedInt64},
+		}
+		if types, ok := integers[t.BitWidth]; ok {
+			if t.IsSigned {
+				return types[1]
+			}
+			return types[0]
+		}
+	}
+	return convertedNone
+}
+
+// convertedLogicalType returns the logical type equivalent to the deprecated
+// annotation of a schema element written by an older writer.
+func (e *schemaElement) convertedLogicalType() *LogicalType {
+	switch e.convertedType {
+	case convertedUTF8:
+		return &LogicalType{Kind: String}
+	case convertedMap, convertedMapKeyValue:
+		return &LogicalType{Kind: Map}
+	case convertedList:
+		return &LogicalType{Kind: List}
+	case convertedEnum:
+		return &LogicalType{Kind: Enum}
+	case convertedDecimal:
+		return &LogicalType{Kind: Decimal, Scale: e.scale, Precision: e.precision}
+	case convertedDate:
+		return &LogicalType{Kind: Date}
+	case convertedTimeMillis:
+		return &LogicalType{Kind: Time, Unit: Millis, IsAdjustedToUTC: true}
+	case convertedTimeMicros:
+		return &LogicalType{Kind: Time, Unit: Micros, IsAdjustedToUTC: true}
+	case convertedTimestampMillis:
+		return &LogicalType{Kind: Timestamp, Unit: Millis, IsAdjustedToUTC: true}
+	case convertedTimestampMicros:
+		return &LogicalType{Kind: Timestamp, Unit: Micros, IsAdjustedToUTC: true}
+	case convertedUint8, convertedUint16, convertedUint32, convertedUint64:
+		return &LogicalType{Kind: Integer, BitWidth: 8 << uint(e.convertedType-convertedUint8)}
+	case convertedInt8, convertedInt16, convertedInt32, convertedInt64:
+		return &LogicalType{Kind: Integer, BitWidth: 8 << uint(e.convertedType-convertedInt8), IsSigned: true}
+	case convertedJSON:
+		return &LogicalType{Kind: JSON}
+	case convertedBSON:
+		return &LogicalType{Kind: BSON}
+	}
+	return nil
+}
+
+// String returns the schema in the text format of the Parquet tools, e.g.
+//
+//	message schema {
+//	  required binary name (STRING);
+//	  optional group address {
+//	    optional int32 zip;
+//	  }
+//	}
+func (schema *Schema) String() string {
+	var out bytes.Buffer
+	var format func(node *Node, indent string)
+	format = func(node *Node, indent string) {
+		out.WriteString(indent + node.Repetition.String() + " ")
+		if node.IsLeaf() {
+			out.WriteString(node.Type.String())
+			if node.Type == FixedLenByteArray {
+				fmt.Fprintf(&out, "(%v)", node.TypeLength)
+			}
+		} else {
+			out.WriteString("group")
+		}
+		out.WriteString(" " + node.Name)
+		if node.LogicalType != nil {
+			out.WriteString(" (" + node.LogicalType.String() + ")")
+		}
+		if node.IsLeaf() {
+			out.WriteString(";\n")
+			return
+		}
+		out.WriteString(" {\n")
+		for _, child := range node.Children {
+			format(child, indent+"  ")
+		}
+		out.WriteString(indent + "}\n")
+	}
+	out.WriteString("message " + schema.Root.Name + " {\n")
+	for _, child := range schema.Root.Children {
+		format(child, "  ")
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// ParseSchema parses a schema in the text format of the Parquet tools, as
+// returned by Schema.String. Deprecated annotations such as UTF8 and
+// TIMESTAMP_MILLIS are accepted as well as logical types, and field ids are
+// ignored.
+func ParseSchema(text string) (*Schema, error) {
+	p := &schemaParser{tokens: tokenizeSchema(text)}
+	if p.next() != "message" {
+		return nil, fmt.Errorf("schema must start with 'message'")
+	}
+	name := p.next()
+	fields, err := p.parseGroup()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%v' after the end of the schema", p.tokens[p.pos])
+	}
+	return NewSchema(name, fields)
+}
+
+// tokenizeSchema splits a schema into names, numbers and punctuation.
+func tokenizeSchema(text string) []string {
+	var tokens []string
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == '$'
+		if isWord {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			tokens = append(tokens, text[start:i])
+			start = -1
+		}
+		if !unicode.IsSpace(r) {
+			tokens = append(tokens, string(r))
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+type schemaParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *schemaParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *schemaParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// expect consumes a token, returning an error if it isn't the expected one.
+func (p *schemaParser) expect(expected string) error {
+	if token := p.next(); token != expected {
+		if token == "" {
+			return fmt.Errorf("expected '%v' at the end of the schema", expected)
+		}
+		return fmt.Errorf("expected '%v' instead of '%v'", expected, token)
+	}
+	return nil
+}
+
+// parseGroup parses the fields of a group, between braces.
+func (p *schemaParser) parseGroup() ([]*Node, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	fields := []*Node{}
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("expected '}' at the end of the schema")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	p.next()
+	return fields, nil
+}
+
+// parseField parses a field, e.g. "optional int32 a (INTEGER(8,true)) = 1;".
+func (p *schemaParser) parseField() (*Node, error) {
+	node := &Node{}
+	repetition := p.next()
+	found := false
+	for r, name := range repetitionNames {
+		if strings.EqualFold(repetition, name) {
+			node.Repetition, found = r, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("expected a repetition instead of '%v'", repetition)
+	}
+
+	typeName := strings.ToLower(p.next())
+	if typeName != "group" {
+		found = false
+		for t, name := range typeNames {
+			if typeName == name {
+				node.Type, found = t, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown type '%v'", typeName)
+		}
+		if node.Type == FixedLenByteArray {
+			params, err := p.parseParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(params) != 1 {
+				return nil, fmt.Errorf("fixed_len_byte_array must have a length")
+			}
+			length, err := strconv.ParseInt(params[0], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fixed_len_byte_array length '%v'", params[0])
+			}
+			node.TypeLength = int32(length)
+		}
+	}
+
+	node.Name = p.next()
+	if node.Name == "" {
+		return nil, fmt.Errorf("expected a field name at the end of the schema")
+	}
+	if p.peek() == "(" {
+		p.next()
+		logicalType, err := p.parseLogicalType()
+		if err != nil {
+			return nil, fmt.Errorf("field '%v': %v", node.Name, err)
+		}
+		node.LogicalType = logicalType
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek() == "=" {
+		p.next()
+		if _, err := strconv.Atoi(p.next()); err != nil {
+			return nil, fmt.Errorf("field '%v' has an invalid id", node.Name)
+		}
+	}
+
+	if typeName == "group" {
+		children, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = children
+		return node, nil
+	}
+	return node, p.expect(";")
+}
+
+// parseParams parses a parenthesized list of parameters, if there is one.
+func (p *schemaParser) parseParams() ([]string, error) {
+	if p.peek() != "(" {
+		return nil, nil
+	}
+	p.next()
+	var params []string
+	for {
+		params = append(params, p.next())
+		switch p.next() {
+		case ")":
+			return params, nil
+		case ",":
+		default:
+			return nil, fmt.Errorf("invalid parameters")
+		}
+	}
+}
+
+// parseLogicalType parses the annotation of a field.
+func (p *schemaParser) parseLogicalType() (*LogicalType, error) {
+	name := strings.ToUpper(p.next())
+	params, err := p.parseParams()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	paramCount := map[string]int{"DECIMAL": 2, "TIME": 2, "TIMESTAMP": 2, "INTEGER": 2}[name]
+	if len(params) != paramCount && !(name == "DECIMAL" && len(params) == 1) {
+		return nil, fmt.Errorf("%v must have %v parameters", name, paramCount)
+	}
+
+	converted := map[string]*LogicalType{
+		"UTF8":             {Kind: String},
+		"MAP_KEY_VALUE":    {Kind: Map},
+		"TIME_MILLIS":      {Kind: Time, Unit: Millis, IsAdjustedToUTC: true},
+		"TIME_MICROS":      {Kind: Time, Unit: Micros, IsAdjustedToUTC: true},
+		"TIMESTAMP_MILLIS": {Kind: Timestamp, Unit: Millis, IsAdjustedToUTC: true},
+		"TIMESTAMP_MICROS": {Kind: Timestamp, Unit: Micros, IsAdjustedToUTC: true},
+		"UINT_8":           {Kind: Integer, BitWidth: 8},
+		"UINT_16":          {Kind: Integer, BitWidth: 16},
+		"UINT_32":          {Kind: Integer, BitWidth: 32},
+		"UINT_64":          {Kind: Integer, BitWidth: 64},
+		"INT_8":            {Kind: Integer, BitWidth: 8, IsSigned: true},
+		"INT_16":           {Kind: Integer, BitWidth: 16, IsSigned: true},
+		"INT_32":           {Kind: Integer, BitWidth: 32, IsSigned: true},
+		"INT_64":           {Kind: Integer, BitWidth: 64, IsSigned: true},
+	}
+	if logicalType, ok := converted[name]; ok {
+		return logicalType, nil
+	}
+	logicalType := &LogicalType{}
+	for kind, kindName := range logicalKindNames {
+		if name == kindName {
+			logicalType.Kind = kind
+		}
+	}
+	switch logicalType.Kind {
+	case NoLogicalType:
+		return nil, fmt.Errorf("unknown annotation '%v'", name)
+	case Decimal:
+		precision, err := strconv.ParseInt(params[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DECIMAL precision '%v'", params[0])
+		}
+		logicalType.Precision = int32(precision)
+		if len(params) == 2 {
+			scale, err := strconv.ParseInt(params[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DECIMAL scale '%v'", params[1])
+			}
+			logicalType.Scale = int32(scale)
+		}
+	case Time, Timestamp:
+		found := false
+		for unit, unitName := range timeUnitNames {
+			if strings.EqualFold(params[0], unitName) {
+				logicalType.Unit, found = unit, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid %v unit '%v'", name, params[0])
+		}
+		adjusted, err := strconv.ParseBool(params[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v UTC adjustment '%v'", name, params[1])
+		}
+		logicalType.IsAdjustedToUTC = adjusted
+	case Integer:
+		bitWidth, err := strconv.ParseInt(params[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INTEGER bit width '%v'", params[0])
+		}
+		signed, err := strconv.ParseBool(params[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid INTEGER signedness '%v'", params[1])
+		}
+		logicalType.BitWidth, logicalType.IsSigned = int8(bitWidth), signed
+	}
+	return logicalType, nil
+}
@@ -0,0 +1,113 @@
+package parquet
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+const testSchema = `message doc {
+  required int64 id;
+  optional binary name (STRING);
+  optional group address {
+    optional binary city (STRING);
+    optional int32 zip (INTEGER(32,false));
+  }
+  optional group tags (LIST) {
+    repeated group list {
+      optional binary element (STRING);
+    }
+  }
+  repeated group matrix {
+    repeated int32 row;
+  }
+  optional fixed_len_byte_array(16) price (DECIMAL(38,2));
+  optional int64 created (TIMESTAMP(MILLIS,true));
+  optional boolean flag;
+  optional double score;
+  optional float ratio;
+  optional int96 legacy;
+}
+`
+
+func TestSchema(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a schema in the text format", t, func() {
+		schema, err := ParseSchema(testSchema)
+		So(err, ShouldBeNil)
+
+		Convey("it should be formatted back to the same text", func() {
+			So(schema.String(), ShouldEqual, testSchema)
+		})
+
+		Convey("its columns should have the levels of their paths", func() {
+			So(len(schema.columns), ShouldEqual, 12)
+			tags := schema.columns[4]
+			So(tags.path(), ShouldEqual, "tags.list.element")
+			So(tags.defLevels, ShouldResemble, []int{1, 2, 3})
+			So(tags.repLevels, ShouldResemble, []int{0, 1, 1})
+			matrix := schema.columns[5]
+			So(matrix.path(), ShouldEqual, "matrix.row")
+			So(matrix.maxDef, ShouldEqual, 2)
+			So(matrix.maxRep, ShouldEqual, 2)
+		})
+
+		Convey("it should survive the round trip through the footer", func() {
+			footer := &thriftWriter{}
+			footer.writeStruct(&fileMetaData{version: 1, schema: schema.elements()})
+			meta := &fileMetaData{}
+			r := &thriftReader{data: footer.buf.Bytes()}
+			meta.read(r)
+			So(r.err, ShouldBeNil)
+			read, err := schemaFromElements(meta.schema)
+			So(err, ShouldBeNil)
+			So(read.String(), ShouldEqual, testSchema)
+		})
+	})
+
+	Convey("Deprecated annotations and field ids should be accepted", t, func() {
+		schema, err := ParseSchema(`message m {
+			optional int64 t (TIMESTAMP_MICROS) = 1;
+			optional binary s (UTF8) = 2;
+			optional int32 u (UINT_8);
+		}`)
+		So(err, ShouldBeNil)
+		So(schema.Root.Children[0].LogicalType, ShouldResemble,
+			&LogicalType{Kind: Timestamp, Unit: Micros, IsAdjustedToUTC: true})
+		So(schema.Root.Children[1].Is(String), ShouldBeTrue)
+		So(schema.Root.Children[2].LogicalType, ShouldResemble, &LogicalType{Kind: Integer, BitWidth: 8})
+	})
+
+	Convey("Files without logical types should be read with their converted types", t, func() {
+		timestamp, decimal := Int64, FixedLenByteArray
+		schema, err := schemaFromElements([]*schemaElement{
+			{name: "m", numChildren: 2, convertedType: convertedNone},
+			{name: "t", typ: &timestamp, convertedType: convertedTimestampMillis},
+			{name: "d", typ: &decimal, typeLength: 8, convertedType: convertedDecimal, precision: 18, scale: 3},
+		})
+		So(err, ShouldBeNil)
+		So(schema.Root.Children[0].LogicalType, ShouldResemble,
+			&LogicalType{Kind: Timestamp, Unit: Millis, IsAdjustedToUTC: true})
+		So(schema.Root.Children[1].LogicalType, ShouldResemble, &LogicalType{Kind: Decimal, Precision: 18, Scale: 3})
+	})
+
+	Convey("Invalid schemas should be rejected", t, func() {
+		for _, text := range []string{
+			"",
+			"message m {}",
+			"message m { required int32 a }",
+			"message m { required int32 a; required int64 a; }",
+			"message m { required integer a; }",
+			"message m { maybe int32 a; }",
+			"message m { required group g {} }",
+			"message m { required fixed_len_byte_array a; }",
+			"message m { required int32 a (DECIMAL); }",
+			"message m { required int32 a (SOMETHING); }",
+			"message m { required int32 a; } }",
+		} {
+			_, err := ParseSchema(text)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Types of the Thrift compact protocol, in which the metadata of Parquet
+// files is serialized.
+const (
+	compactStop         = 0
+	compactBooleanTrue  = 1
+	compactBooleanFalse = 2
+	compactByte         = 3
+	compactI16          = 4
+	compactI32          = 5
+	compactI64          = 6
+	compactDouble       = 7
+	compactBinary       = 8
+	compactList         = 9
+	compactSet          = 10
+	compactMap          = 11
+	compactStruct       = 12
+)
+
+// maxThriftDepth bounds the nesting of the structures that are skipped, so
+// that a malformed file can't exhaust the stack.
+const maxThriftDepth = 64
+
+// thriftStruct is implemented by the metadata structures.
+type thriftStruct interface {
+	write(w *thriftWriter)
+	read(r *thriftReader)
+}
+
+// thriftWriter serializes structures with the Thrift compact protocol.
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// lastID is the id of the last field written in the current structure,
+	// and lastIDs those of the enclosing structures
+	lastID  int16
+	lastIDs []int16
+}
+
+func (w *thriftWriter) writeVarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (w *thriftWriter) writeZigZag(v int64) {
+	w.writeVarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - w.lastID; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.writeZigZag(int64(id))
+	}
+	w.lastID = id
+}
+
+func (w *thriftWriter) writeBoolField(id int16, v bool) {
+	if v {
+		w.fieldHeader(id, compactBooleanTrue)
+	} else {
+		w.fieldHeader(id, compactBooleanFalse)
+	}
+}
+
+func (w *thriftWriter) writeI32Field(id int16, v int32) {
+	w.fieldHeader(id, compactI32)
+	w.writeZigZag(int64(v))
+}
+
+func (w *thriftWriter) writeI64Field(id int16, v int64) {
+	w.fieldHeader(id, compactI64)
+	w.writeZigZag(v)
+}
+
+func (w *thriftWriter) writeBinaryField(id int16, v []byte) {
+	w.fieldHeader(id, compactBinary)
+	w.writeBinary(v)
+}
+
+func (w *thriftWriter) writeBinary(v []byte) {
+	w.writeVarint(uint64(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *thriftWriter) writeStructField(id int16, s thriftStruct) {
+	w.fieldHeader(id, compactStruct)
+	w.writeStruct(s)
+}
+
+// writeStruct writes the fields of a structure followed by a stop field.
+func (w *thriftWriter) writeStruct(s thriftStruct) {
+	w.lastIDs = append(w.lastIDs, w.lastID)
+	w.lastID = 0
+	s.write(w)
+	w.buf.WriteByte(compactStop)
+	w.lastID = w.lastIDs[len(w.lastIDs)-1]
+	w.lastIDs = w.lastIDs[:len(w.lastIDs)-1]
+}
+
+// writeListField writes the header of a list field, which must be followed by
+// size elements of the given type.
+func (w *thriftWriter) writeListField(id int16, elemType byte, size int) {
+	w.fieldHeader(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.writeVarint(uint64(size))
+	}
+}
+
+// thriftReader deserializes structures written with the Thrift compact
+// protocol. The first error encountered is recorded, after which every read
+// returns zero values.
+type thriftReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *thriftReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (r *thriftReader) readByte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.fail("unexpected end of metadata")
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) readVarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.fail("invalid varint in metadata")
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) readZigZag() int64 {
+	v := r.readVarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readBytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.fail("unexpected end of metadata")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// expect records an error if a field doesn't have the expected type.
+func (r *thriftReader) expect(typ, expected byte) bool {
+	if typ != expected {
+		r.fail("metadata field has type %v instead of %v", typ, expected)
+		return false
+	}
+	return true
+}
+
+func (r *thriftReader) readI32(typ byte) int32 {
+	if !r.expect(typ, compactI32) {
+		return 0
+	}
+	v := r.readZigZag()
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		r.fail("metadata value %v overflows i32", v)
+	}
+	return int32(v)
+}
+
+func (r *thriftReader) readI64(typ byte) int64 {
+	if !r.expect(typ, compactI64) {
+		return 0
+	}
+	return r.readZigZag()
+}
+
+func (r *thriftReader) readBool(typ byte) bool {
+	if typ != compactBooleanTrue && typ != compactBooleanFalse {
+		r.fail("metadata field has type %v instead of a boolean", typ)
+	}
+	return typ == compactBooleanTrue
+}
+
+func (r *thriftReader) readBinary(typ byte) []byte {
+	if !r.expect(typ, compactBinary) {
+		return nil
+	}
+	return r.readBytes(int(r.readVarint()))
+}
+
+// readList reads the header of a list field, returning the type and number of
+// its elements.
+func (r *thriftReader) readList(typ byte) (byte, int) {
+	if !r.expect(typ, compactList) {
+		return 0, 0
+	}
+	header := r.readByte()
+	size := int(header >> 4)
+	if size == 15 {
+		size = int(r.readVarint())
+	}
+	// every element takes at least a byte
+	if size > len(r.data)-r.pos {
+		r.fail("list of %v elements exceeds metadata", size)
+		return 0, 0
+	}
+	return header & 0x0f, size
+}
+
+// readStruct reads the fields of a structure, calling field with the id and
+// type of each; fields for which it returns false are skipped.
+func (r *thriftReader) readStruct(field func(id int16, typ byte) bool) {
+	var lastID int16
+	for r.err == nil {
+		header := r.readByte()
+		typ := header & 0x0f
+		if typ == compactStop {
+			return
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			lastID += delta
+		} else {
+			lastID = int16(r.readZigZag())
+		}
+		if !field(lastID, typ) {
+			r.skip(typ, 0)
+		}
+	}
+}
+
+// readStructField reads a structure field into s.
+func (r *thriftReader) readStructField(typ byte, s thriftStruct) {
+	if r.expect(typ, compactStruct) {
+		s.read(r)
+	}
+}
+
+// skip reads past a value of the given type.
+func (r *thriftReader) skip(typ byte, depth int) {
+	if depth > maxThriftDepth {
+		r.fail("metadata is nested too deeply")
+		return
+	}
+	switch typ {
+	case compactBooleanTrue, compactBooleanFalse:
+	case compactByte:
+		r.readByte()
+	case compactI16, compactI32, compactI64:
+		r.readVarint()
+	case compactDouble:
+		r.readBytes(8)
+	case compactBinary:
+		r.readBytes(int(r.readVarint()))
+	case compactList, compactSet:
+		elemType, size := r.readList(compactList)
+		for i := 0; i < size && r.err == nil; i++ {
+			if elemType == compactBooleanTrue || elemType == compactBooleanFalse {
+				// booleans within lists take a byte each
+				r.readByte()
+			} else {
+				r.skip(elemType, depth+1)
+			}
+		}
+	case compactMap:
+		size := int(r.readVarint())
+		if size == 0 {
+			return
+		}
+		types := r.readByte()
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skip(types>>4, depth+1)
+			r.skip(types&0x0f, depth+1)
+		}
+	case compactStruct:
+		r.readStruct(func(id int16, typ byte) bool {
+			r.skip(typ, depth+1)
+			return true
+		})
+	default:
+		r.fail("unknown metadata field type %v", typ)
+	}
+}
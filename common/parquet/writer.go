@@ -0,0 +1,425 @@
+// Package parquet reads and writes files in the Apache Parquet format.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// magic starts and ends every Parquet file.
+const magic = "PAR1"
+
+// Defaults of the Writer.
+const (
+	DefaultRowGroupSize = 128 * 1024 * 1024
+	DefaultPageSize     = 1024 * 1024
+)
+
+// Group holds the values of the fields of a record or of a group field, by
+// index. The value of a leaf field is a bool, int32, int64, float32 or
+// float64 for the physical types of the same name, a []byte or string for
+// byte arrays, and a []byte of the type's length for int96 and fixed length
+// byte arrays. The value of a group field is a Group. Optional fields may be
+// nil, and the value of a repeated field is a []interface{} of its elements.
+type Group []interface{}
+
+// Writer writes records to a Parquet file. Values are PLAIN encoded, and
+// records are buffered until their size reaches the row group size.
+type Writer struct {
+	// Codec compresses the pages.
+	Codec Codec
+
+	// RowGroupSize is the number of bytes of buffered pages above which a
+	// row group is written, and PageSize the number of bytes of encoded
+	// values above which a page is cut.
+	RowGroupSize int64
+	PageSize     int
+
+	// CreatedBy names the application that wrote the file.
+	CreatedBy string
+
+	out       *countingWriter
+	schema    *Schema
+	columns   []*columnWriter
+	spans     map[*Node]span
+	rowGroups []*rowGroup
+	numRows   int64
+	totalRows int64
+	started   bool
+	closed    bool
+}
+
+// span is the range of the columns of the leaves under a node.
+type span struct {
+	first, count int
+}
+
+// columnWriter buffers the pages of a column chunk.
+type columnWriter struct {
+	column *column
+
+	// pending holds the entries of the record being shredded
+	pending []entry
+
+	// levels and values of the current page, which holds numEntries
+	// entries
+	repLevels, defLevels []int
+	values               plainEncoder
+	numEntries           int
+
+	// chunk holds the pages written so far
+	chunk                            bytes.Buffer
+	numValues                        int64
+	uncompressedSize, compressedSize int64
+}
+
+// entry is a value of a column along with its levels; the value is nil if
+// the definition level is below the maximum.
+type entry struct {
+	rep, def int
+	value    interface{}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// NewWriter returns a Writer of records of the given schema, which compresses
+// pages with Snappy.
+func NewWriter(w io.Writer, schema *Schema) *Writer {
+	writer := &Writer{
+		Codec:        Snappy,
+		RowGroupSize: DefaultRowGroupSize,
+		PageSize:     DefaultPageSize,
+		out:          &countingWriter{w: w},
+		schema:       schema,
+		spans:        map[*Node]span{},
+	}
+	for _, c := range schema.columns {
+		leaf := c.leaf()
+		writer.columns = append(writer.columns, &columnWriter{
+			column: c,
+			values: plainEncoder{typ: leaf.Type, typeLength: valueLength(leaf.Type, int(leaf.TypeLength))},
+		})
+	}
+	writer.addSpans(schema.Root, 0)
+	return writer
+}
+
+// addSpans records the columns under each node, which are consecutive since
+// columns are ordered depth first.
+func (w *Writer) addSpans(node *Node, first int) int {
+	if node.IsLeaf() {
+		w.spans[node] = span{first, 1}
+		return 1
+	}
+	count := 0
+	for _, child := range node.Children {
+		count += w.addSpans(child, first+count)
+	}
+	w.spans[node] = span{first, count}
+	return count
+}
+
+// Write writes a record, whose values must match the schema; a record that
+// doesn't is rejected without affecting the file.
+func (w *Writer) Write(record Group) error {
+	if w.closed {
+		return fmt.Errorf("parquet writer is closed")
+	}
+	for _, cw := range w.columns {
+		cw.pending = cw.pending[:0]
+	}
+	if err := w.shredGroup(w.schema.Root, record, 0, 0, 0); err != nil {
+		return err
+	}
+	var size int64
+	for _, cw := range w.columns {
+		if err := cw.commit(); err != nil {
+			return err
+		}
+		if cw.values.size()+len(cw.defLevels)/8 >= w.PageSize {
+			if err := cw.writePage(w.Codec); err != nil {
+				return err
+			}
+		}
+		size += int64(cw.chunk.Len() + cw.values.size())
+	}
+	w.numRows++
+	if size >= w.RowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// shredField appends the entries of a field to the pending entries of its
+// columns. rep is the repetition level of its first entry, def the definition
+// level of its parent, and maxRep the repetition level of its parent.
+func (w *Writer) shredField(node *Node, value interface{}, rep, def, maxRep int) error {
+	switch node.Repetition {
+	case Required:
+		if value == nil {
+			return fmt.Errorf("required field '%v' has no value", node.Name)
+		}
+		return w.shredValue(node, value, rep, def, maxRep)
+	case Optional:
+		if value == nil {
+			w.shredNull(node, rep, def)
+			return nil
+		}
+		return w.shredValue(node, value, rep, def+1, maxRep)
+	}
+	if value == nil {
+		w.shredNull(node, rep, def)
+		return nil
+	}
+	elements, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("repeated field '%v' has a value of type %T instead of []interface{}", node.Name, value)
+	}
+	if len(elements) == 0 {
+		w.shredNull(node, rep, def)
+		return nil
+	}
+	for i, element := range elements {
+		if element == nil {
+			return fmt.Errorf("repeated field '%v' has a null element", node.Name)
+		}
+		if i > 0 {
+			rep = maxRep + 1
+		}
+		if err := w.shredValue(node, element, rep, def+1, maxRep+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shredValue appends the entries of a value of a field, defined up to def.
+func (w *Writer) shredValue(node *Node, value interface{}, rep, def, maxRep int) error {
+	if node.IsLeaf() {
+		if err := checkValue(node, value); err != nil {
+			return err
+		}
+		cw := w.columns[w.spans[node].first]
+		cw.pending = append(cw.pending, entry{rep, def, value})
+		return nil
+	}
+	group, ok := value.(Group)
+	if !ok {
+		return fmt.Errorf("group field '%v' has a value of type %T instead of Group", node.Name, value)
+	}
+	return w.shredGroup(node, group, rep, def, maxRep)
+}
+
+func (w *Writer) shredGroup(node *Node, group Group, rep, def, maxRep int) error {
+	if len(group) != len(node.Children) {
+		return fmt.Errorf("group '%v' has %v values instead of %v", node.Name, len(group), len(node.Children))
+	}
+	for i, child := range node.Children {
+		if err := w.shredField(child, group[i], rep, def, maxRep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shredNull appends an undefined entry to each column under a node.
+func (w *Writer) shredNull(node *Node, rep, def int) {
+	s := w.spans[node]
+	for _, cw := range w.columns[s.first : s.first+s.count] {
+		cw.pending = append(cw.pending, entry{rep, def, nil})
+	}
+}
+
+// checkValue returns an error if a value can't be stored in a leaf field.
+func checkValue(node *Node, value interface{}) error {
+	ok := false
+	switch node.Type {
+	case Boolean:
+		_, ok = value.(bool)
+	case Int32:
+		_, ok = value.(int32)
+	case Int64:
+		_, ok = value.(int64)
+	case Float:
+		_, ok = value.(float32)
+	case Double:
+		_, ok = value.(float64)
+	case ByteArray:
+		switch value.(type) {
+		case []byte, string:
+			ok = true
+		}
+	case Int96, FixedLenByteArray:
+		var data []byte
+		if data, ok = value.([]byte); ok && len(data) != valueLength(node.Type, int(node.TypeLength)) {
+			return fmt.Errorf("field '%v' can't hold a value of %v bytes", node.Name, len(data))
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%v field '%v' can't hold a value of type %T", node.Type, node.Name, value)
+	}
+	return nil
+}
+
+// commit moves the pending entries to the current page.
+func (cw *columnWriter) commit() error {
+	for _, e := range cw.pending {
+		cw.numEntries++
+		if cw.column.maxRep > 0 {
+			cw.repLevels = append(cw.repLevels, e.rep)
+		}
+		if cw.column.maxDef > 0 {
+			cw.defLevels = append(cw.defLevels, e.def)
+		}
+		if e.def == cw.column.maxDef {
+			if err := cw.values.encode(e.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writePage appends the current page to the chunk.
+func (cw *columnWriter) writePage(codec Codec) error {
+	var page []byte
+	if cw.column.maxRep > 0 {
+		page = appendLevels(page, cw.repLevels, cw.column.maxRep)
+	}
+	if cw.column.maxDef > 0 {
+		page = appendLevels(page, cw.defLevels, cw.column.maxDef)
+	}
+	page = append(page, cw.values.bytes()...)
+	compressed, err := compress(codec, page)
+	if err != nil {
+		return err
+	}
+	header := &thriftWriter{}
+	header.writeStruct(&pageHeader{
+		typ:                  pageData,
+		uncompressedPageSize: int32(len(page)),
+		compressedPageSize:   int32(len(compressed)),
+		dataPageHeader: &dataPageHeader{
+			numValues:               int32(cw.numEntries),
+			encoding:                encodingPlain,
+			definitionLevelEncoding: encodingRLE,
+			repetitionLevelEncoding: encodingRLE,
+		},
+	})
+	cw.chunk.Write(header.buf.Bytes())
+	cw.chunk.Write(compressed)
+	cw.numValues += int64(cw.numEntries)
+	cw.uncompressedSize += int64(header.buf.Len() + len(page))
+	cw.compressedSize += int64(header.buf.Len() + len(compressed))
+	cw.repLevels, cw.defLevels = cw.repLevels[:0], cw.defLevels[:0]
+	cw.values.reset()
+	cw.numEntries = 0
+	return nil
+}
+
+// appendLevels appends levels RLE encoded, prefixed with their length.
+func appendLevels(dst []byte, levels []int, max int) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0)
+	dst = appendRLE(dst, levels, bitWidth(max))
+	binary.LittleEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	return dst
+}
+
+// start writes the magic number at the start of the file.
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := io.WriteString(w.out, magic)
+	return err
+}
+
+// flushRowGroup writes the buffered records as a row group.
+func (w *Writer) flushRowGroup() error {
+	if w.numRows == 0 {
+		return nil
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	group := &rowGroup{numRows: w.numRows}
+	for _, cw := range w.columns {
+		if cw.numEntries > 0 {
+			if err := cw.writePage(w.Codec); err != nil {
+				return err
+			}
+		}
+		offset := w.out.n
+		if _, err := w.out.Write(cw.chunk.Bytes()); err != nil {
+			return err
+		}
+		path := make([]string, len(cw.column.nodes))
+		for i, node := range cw.column.nodes {
+			path[i] = node.Name
+		}
+		group.columns = append(group.columns, &columnChunk{
+			fileOffset: offset,
+			metaData: &columnMetaData{
+				typ:                   cw.column.leaf().Type,
+				encodings:             []encoding{encodingPlain, encodingRLE},
+				pathInSchema:          path,
+				codec:                 w.Codec,
+				numValues:             cw.numValues,
+				totalUncompressedSize: cw.uncompressedSize,
+				totalCompressedSize:   cw.compressedSize,
+				dataPageOffset:        offset,
+			},
+		})
+		group.totalByteSize += cw.uncompressedSize
+		cw.chunk.Reset()
+		cw.numValues, cw.uncompressedSize, cw.compressedSize = 0, 0, 0
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.totalRows += w.numRows
+	w.numRows = 0
+	return nil
+}
+
+// Close writes the buffered records and the footer of the file. It doesn't
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.flushRowGroup(); err != nil {
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	footer := &thriftWriter{}
+	footer.writeStruct(&fileMetaData{
+		version:   1,
+		schema:    w.schema.elements(),
+		numRows:   w.totalRows,
+		rowGroups: w.rowGroups,
+		createdBy: w.CreatedBy,
+	})
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(footer.buf.Len()))
+	for _, b := range [][]byte{footer.buf.Bytes(), length[:], []byte(magic)} {
+		if _, err := w.out.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
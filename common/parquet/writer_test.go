@@ -0,0 +1,257 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"testing"
+)
+
+// testRecords returns records of testSchema exercising nulls, empty lists and
+// nested repeated fields.
+func testRecords() []Group {
+	price := bytes.Repeat([]byte{0}, 16)
+	price[15] = 150
+	legacy := []byte("abcdefghijkl")
+	return []Group{
+		{
+			int64(1),
+			[]byte("Ann"),
+			Group{[]byte("Dublin"), int32(12345)},
+			Group{[]interface{}{Group{[]byte("a")}, Group{nil}, Group{[]byte("b")}}},
+			[]interface{}{
+				Group{[]interface{}{int32(1), int32(2)}},
+				Group{[]interface{}{}},
+				Group{[]interface{}{int32(3)}},
+			},
+			price,
+			int64(1500000000000),
+			true,
+			0.5,
+			float32(2.5),
+			legacy,
+		},
+		{
+			int64(2), nil, Group{nil, nil}, Group{[]interface{}{}}, []interface{}{},
+			nil, nil, false, nil, nil, nil,
+		},
+		{
+			int64(3), []byte(""), nil, nil, []interface{}{Group{[]interface{}{int32(4)}}},
+			nil, int64(-1), nil, -2.0, nil, nil,
+		},
+	}
+}
+
+func readAll(data []byte) ([]Group, error) {
+	reader, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var records []Group
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// appendPage appends a page along with its header.
+func appendPage(dst []byte, header *pageHeader, body []byte) []byte {
+	w := &thriftWriter{}
+	w.writeStruct(header)
+	return append(append(dst, w.buf.Bytes()...), body...)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Records should round trip", t, func() {
+		for _, codec := range []Codec{Uncompressed, Snappy, Gzip} {
+			for _, size := range []int{1, DefaultPageSize} {
+				out := &bytes.Buffer{}
+				writer := NewWriter(out, schema)
+				writer.Codec = codec
+				writer.PageSize = size
+				writer.RowGroupSize = int64(size * 300)
+				var expected []Group
+				for i := 0; i < 100; i++ {
+					for _, record := range testRecords() {
+						So(writer.Write(record), ShouldBeNil)
+						expected = append(expected, record)
+					}
+				}
+				So(writer.Close(), ShouldBeNil)
+				So(len(writer.rowGroups) > 1, ShouldEqual, size == 1)
+
+				reader, err := NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+				So(err, ShouldBeNil)
+				So(reader.NumRows(), ShouldEqual, 300)
+				So(reader.Schema().String(), ShouldEqual, testSchema)
+				records, err := readAll(out.Bytes())
+				So(err, ShouldBeNil)
+				So(records, ShouldResemble, expected)
+			}
+		}
+	})
+
+	Convey("With a writer", t, func() {
+		out := &bytes.Buffer{}
+		writer := NewWriter(out, schema)
+
+		Convey("invalid records should be rejected without affecting the file", func() {
+			invalid := testRecords()
+			invalid[0][0] = nil
+			So(writer.Write(invalid[0]), ShouldNotBeNil)
+			invalid[1][1] = 5
+			So(writer.Write(invalid[1]), ShouldNotBeNil)
+			invalid[2][2] = Group{nil}
+			So(writer.Write(invalid[2]), ShouldNotBeNil)
+			So(writer.Write(Group{int64(1)}), ShouldNotBeNil)
+
+			record := testRecords()[0]
+			record[5] = []byte{1}
+			So(writer.Write(record), ShouldNotBeNil)
+			record = testRecords()[0]
+			record[4] = []interface{}{nil}
+			So(writer.Write(record), ShouldNotBeNil)
+
+			So(writer.Write(testRecords()[1]), ShouldBeNil)
+			So(writer.Close(), ShouldBeNil)
+			records, err := readAll(out.Bytes())
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, testRecords()[1:2])
+		})
+
+		Convey("strings should be written as byte arrays", func() {
+			record := testRecords()[0]
+			record[1] = "Ann"
+			So(writer.Write(record), ShouldBeNil)
+			So(writer.Close(), ShouldBeNil)
+			records, err := readAll(out.Bytes())
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, testRecords()[:1])
+		})
+
+		Convey("a file without records should be valid", func() {
+			So(writer.Close(), ShouldBeNil)
+			So(writer.Write(testRecords()[0]), ShouldNotBeNil)
+			records, err := readAll(out.Bytes())
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Pages with dictionaries, version 2 headers and other encodings should be read", t, func() {
+		file := []byte(magic)
+
+		dictionaryOffset := int64(len(file))
+		dictionary := []byte{1, 0, 0, 0, 'x', 1, 0, 0, 0, 'y'}
+		compressed := snappyEncode(dictionary)
+		file = appendPage(file, &pageHeader{
+			typ:                  pageDictionary,
+			uncompressedPageSize: int32(len(dictionary)),
+			compressedPageSize:   int32(len(compressed)),
+			dictionaryPageHeader: &dictionaryPageHeader{numValues: 2, encoding: encodingPlainDictionary},
+		}, compressed)
+		dataOffset := int64(len(file))
+		defLevels := appendRLE(nil, []int{1, 0, 1}, 1)
+		indexes := appendRLE([]byte{1}, []int{1, 0}, 1)
+		body := append(append([]byte{}, defLevels...), snappyEncode(indexes)...)
+		file = appendPage(file, &pageHeader{
+			typ:                  pageDataV2,
+			uncompressedPageSize: int32(len(defLevels) + len(indexes)),
+			compressedPageSize:   int32(len(body)),
+			dataPageHeaderV2: &dataPageHeaderV2{
+				numValues:                  3,
+				numNulls:                   1,
+				numRows:                    3,
+				encoding:                   encodingRLEDictionary,
+				definitionLevelsByteLength: int32(len(defLevels)),
+				isCompressed:               true,
+			},
+		}, body)
+
+		deltaOffset := int64(len(file))
+		deltas := appendDeltaBinaryPacked(nil, []int64{10, 20, 30})
+		compressed = snappyEncode(deltas)
+		file = appendPage(file, &pageHeader{
+			typ:                  pageData,
+			uncompressedPageSize: int32(len(deltas)),
+			compressedPageSize:   int32(len(compressed)),
+			dataPageHeader:       &dataPageHeader{numValues: 3, encoding: encodingDeltaBinaryPacked},
+		}, compressed)
+
+		schema, err := ParseSchema("message m { optional binary s (STRING); required int32 n; }")
+		So(err, ShouldBeNil)
+		footer := &thriftWriter{}
+		footer.writeStruct(&fileMetaData{
+			version: 1,
+			schema:  schema.elements(),
+			numRows: 3,
+			rowGroups: []*rowGroup{{numRows: 3, columns: []*columnChunk{
+				{fileOffset: dataOffset, metaData: &columnMetaData{
+					typ:                  ByteArray,
+					pathInSchema:         []string{"s"},
+					codec:                Snappy,
+					numValues:            3,
+					totalCompressedSize:  deltaOffset - dictionaryOffset,
+					dataPageOffset:       dataOffset,
+					dictionaryPageOffset: dictionaryOffset,
+				}},
+				{fileOffset: deltaOffset, metaData: &columnMetaData{
+					typ:                 Int32,
+					pathInSchema:        []string{"n"},
+					codec:               Snappy,
+					numValues:           3,
+					totalCompressedSize: int64(len(file)) - deltaOffset,
+					dataPageOffset:      deltaOffset,
+				}},
+			}}},
+		})
+		file = append(file, footer.buf.Bytes()...)
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(footer.buf.Len()))
+		file = append(append(file, length[:]...), magic...)
+
+		records, err := readAll(file)
+		So(err, ShouldBeNil)
+		So(records, ShouldResemble, []Group{
+			{[]byte("y"), int32(10)},
+			{nil, int32(20)},
+			{[]byte("x"), int32(30)},
+		})
+	})
+
+	Convey("Invalid files should be rejected", t, func() {
+		out := &bytes.Buffer{}
+		writer := NewWriter(out, schema)
+		So(writer.Write(testRecords()[0]), ShouldBeNil)
+		So(writer.Close(), ShouldBeNil)
+		data := out.Bytes()
+
+		_, err := readAll([]byte("PAR1PAR1"))
+		So(err, ShouldNotBeNil)
+		_, err = readAll(append([]byte("PAR2"), data[4:]...))
+		So(err, ShouldNotBeNil)
+		_, err = readAll(data[:len(data)-1])
+		So(err, ShouldNotBeNil)
+
+		// corrupt the first page header
+		corrupt := append([]byte{}, data...)
+		corrupt[4] = 0xff
+		_, err = readAll(corrupt)
+		So(err, ShouldNotBeNil)
+	})
+}
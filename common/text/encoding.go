@@ -0,0 +1,320 @@
+package text
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Character encodings supported for tool input and output.
+const (
+	UTF8        = "utf-8"
+	Latin1      = "latin1"
+	Windows1252 = "windows-1252"
+	UTF16       = "utf-16"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+)
+
+// encodingAliases maps accepted spellings of encoding names to the
+// corresponding canonical name.
+var encodingAliases = map[string]string{
+	"utf-8":        UTF8,
+	"utf8":         UTF8,
+	"latin1":       Latin1,
+	"latin-1":      Latin1,
+	"iso-8859-1":   Latin1,
+	"iso8859-1":    Latin1,
+	"windows-1252": Windows1252,
+	"cp1252":       Windows1252,
+	"utf-16":       UTF16,
+	"utf16":        UTF16,
+	"utf-16le":     UTF16LE,
+	"utf16le":      UTF16LE,
+	"utf-16be":     UTF16BE,
+	"utf16be":      UTF16BE,
+}
+
+// windows1252High maps the bytes 0x80 to 0x9F of windows-1252 to runes. The
+// five bytes left undefined by the code page map to the C1 control codes
+// they denote in latin1. All other bytes are identical to latin1.
+var windows1252High = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// unencodableByte is written in place of characters that can't be represented
+// in a single-byte output encoding.
+const unencodableByte = '?'
+
+// byteOrderMark may start UTF-16 text to indicate its byte order.
+const byteOrderMark = '\uFEFF'
+
+// ParseEncoding returns the canonical name of the given character encoding,
+// or an error if it is not supported. An empty name denotes UTF-8.
+func ParseEncoding(name string) (string, error) {
+	if name == "" {
+		return UTF8, nil
+	}
+	encoding, ok := encodingAliases[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding '%v', choose one of: %v, %v, %v, %v, %v, %v",
+			name, UTF8, Latin1, Windows1252, UTF16, UTF16LE, UTF16BE)
+	}
+	return encoding, nil
+}
+
+// NewDecodingReader returns a reader that converts the content of r from the
+// given encoding to UTF-8. For utf-16, the byte order is taken from the byte
+// order mark if there is one, and defaults to little-endian otherwise; a
+// leading byte order mark is always discarded.
+func NewDecodingReader(r io.Reader, encoding string) (io.Reader, error) {
+	encoding, err := ParseEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	switch encoding {
+	case Latin1:
+		return &transformReader{r: r, transform: decodeSingleByte(nil)}, nil
+	case Windows1252:
+		return &transformReader{r: r, transform: decodeSingleByte(&windows1252High)}, nil
+	case UTF16:
+		return &transformReader{r: r, transform: (&utf16Decoder{detectBOM: true}).decode}, nil
+	case UTF16LE:
+		return &transformReader{r: r, transform: (&utf16Decoder{littleEndian: true}).decode}, nil
+	case UTF16BE:
+		return &transformReader{r: r, transform: (&utf16Decoder{}).decode}, nil
+	}
+	return r, nil
+}
+
+// NewEncodingWriter returns a writer that converts the UTF-8 text written to
+// it to the given encoding before writing it to w. Characters that can't be
+// represented in a single-byte encoding are written as '?'. utf-16 output is
+// little-endian and starts with a byte order mark.
+func NewEncodingWriter(w io.Writer, encoding string) (io.Writer, error) {
+	encoding, err := ParseEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	switch encoding {
+	case Latin1:
+		return &transformWriter{w: w, transform: encodeSingleByte(nil)}, nil
+	case Windows1252:
+		return &transformWriter{w: w, transform: encodeSingleByte(&windows1252High)}, nil
+	case UTF16:
+		return &transformWriter{w: w, transform: (&utf16Encoder{littleEndian: true, writeBOM: true}).encode}, nil
+	case UTF16LE:
+		return &transformWriter{w: w, transform: (&utf16Encoder{littleEndian: true}).encode}, nil
+	case UTF16BE:
+		return &transformWriter{w: w, transform: (&utf16Encoder{}).encode}, nil
+	}
+	return w, nil
+}
+
+// transformFunc appends the conversion of a prefix of src to dst and returns
+// the result along with the number of bytes of src consumed. Unless atEOF is
+// set, it may leave an incomplete character at the end of src unconsumed.
+type transformFunc func(dst, src []byte, atEOF bool) ([]byte, int, error)
+
+// transformReader applies a transformFunc to the content of a reader.
+type transformReader struct {
+	r         io.Reader
+	transform transformFunc
+	src       []byte
+	dst       []byte
+	err       error
+}
+
+func (tr *transformReader) Read(p []byte) (int, error) {
+	for len(tr.dst) == 0 {
+		if tr.err != nil {
+			return 0, tr.err
+		}
+		buf := make([]byte, len(tr.src)+4096)
+		copy(buf, tr.src)
+		n, err := tr.r.Read(buf[len(tr.src):])
+		tr.src = buf[:len(tr.src)+n]
+		atEOF := err == io.EOF
+		if err != nil {
+			tr.err = err
+		}
+		var consumed int
+		var transformErr error
+		tr.dst, consumed, transformErr = tr.transform(tr.dst[:0], tr.src, atEOF)
+		tr.src = tr.src[consumed:]
+		if transformErr != nil {
+			tr.err = transformErr
+		}
+	}
+	n := copy(p, tr.dst)
+	tr.dst = tr.dst[n:]
+	return n, nil
+}
+
+// transformWriter applies a transformFunc to the data written to a writer.
+type transformWriter struct {
+	w         io.Writer
+	transform transformFunc
+	src       []byte
+}
+
+func (tw *transformWriter) Write(p []byte) (int, error) {
+	tw.src = append(tw.src, p...)
+	out, consumed, err := tw.transform(nil, tw.src, false)
+	if err != nil {
+		return 0, err
+	}
+	tw.src = append(tw.src[:0], tw.src[consumed:]...)
+	if _, err = tw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decodeSingleByte returns a transformFunc converting latin1, or a code page
+// that differs from latin1 in the range 0x80 to 0x9F, to UTF-8.
+func decodeSingleByte(high *[32]rune) transformFunc {
+	return func(dst, src []byte, _ bool) ([]byte, int, error) {
+		var buf [utf8.UTFMax]byte
+		for _, b := range src {
+			r := rune(b)
+			if high != nil && b >= 0x80 && b < 0xA0 {
+				r = high[b-0x80]
+			}
+			n := utf8.EncodeRune(buf[:], r)
+			dst = append(dst, buf[:n]...)
+		}
+		return dst, len(src), nil
+	}
+}
+
+// encodeSingleByte returns a transformFunc converting UTF-8 to latin1 or a
+// code page that differs from latin1 in the range 0x80 to 0x9F.
+func encodeSingleByte(high *[32]rune) transformFunc {
+	return func(dst, src []byte, atEOF bool) ([]byte, int, error) {
+		consumed := 0
+		for consumed < len(src) {
+			if !atEOF && !utf8.FullRune(src[consumed:]) {
+				break
+			}
+			r, size := utf8.DecodeRune(src[consumed:])
+			consumed += size
+			dst = append(dst, encodeSingleByteRune(r, high))
+		}
+		return dst, consumed, nil
+	}
+}
+
+// encodeSingleByteRune returns the byte representing r in latin1 or the code
+// page described by high.
+func encodeSingleByteRune(r rune, high *[32]rune) byte {
+	if r < 0x80 || (r < 0x100 && (high == nil || r >= 0xA0)) {
+		return byte(r)
+	}
+	if high != nil {
+		for i, h := range high {
+			if h == r {
+				return byte(0x80 + i)
+			}
+		}
+	}
+	return unencodableByte
+}
+
+// utf16Decoder converts UTF-16 to UTF-8.
+type utf16Decoder struct {
+	littleEndian bool
+	detectBOM    bool
+	started      bool
+}
+
+func (d *utf16Decoder) unit(b []byte) uint16 {
+	if d.littleEndian {
+		return uint16(b[0]) | uint16(b[1])<<8
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func (d *utf16Decoder) decode(dst, src []byte, atEOF bool) ([]byte, int, error) {
+	consumed := 0
+	if !d.started {
+		if len(src) < 2 && !atEOF {
+			return dst, 0, nil
+		}
+		d.started = true
+		if d.detectBOM {
+			d.littleEndian = !(len(src) >= 2 && src[0] == 0xFE && src[1] == 0xFF)
+		}
+		if len(src) >= 2 && d.unit(src) == byteOrderMark {
+			consumed = 2
+		}
+	}
+	var buf [utf8.UTFMax]byte
+	for len(src)-consumed >= 2 {
+		r := rune(d.unit(src[consumed:]))
+		size := 2
+		if utf16.IsSurrogate(r) {
+			if len(src)-consumed < 4 {
+				if !atEOF {
+					break
+				}
+				r = utf8.RuneError
+			} else {
+				r = utf16.DecodeRune(r, rune(d.unit(src[consumed+2:])))
+				if r == utf8.RuneError {
+					// unpaired surrogate; only consume the first unit
+					size = 2
+				} else {
+					size = 4
+				}
+			}
+		}
+		consumed += size
+		n := utf8.EncodeRune(buf[:], r)
+		dst = append(dst, buf[:n]...)
+	}
+	if atEOF && consumed < len(src) {
+		return dst, consumed, fmt.Errorf("UTF-16 input ends with an incomplete character")
+	}
+	return dst, consumed, nil
+}
+
+// utf16Encoder converts UTF-8 to UTF-16.
+type utf16Encoder struct {
+	littleEndian bool
+	writeBOM     bool
+}
+
+func (e *utf16Encoder) appendUnit(dst []byte, u uint16) []byte {
+	if e.littleEndian {
+		return append(dst, byte(u), byte(u>>8))
+	}
+	return append(dst, byte(u>>8), byte(u))
+}
+
+func (e *utf16Encoder) encode(dst, src []byte, atEOF bool) ([]byte, int, error) {
+	if e.writeBOM {
+		dst = e.appendUnit(dst, byteOrderMark)
+		e.writeBOM = false
+	}
+	consumed := 0
+	for consumed < len(src) {
+		if !atEOF && !utf8.FullRune(src[consumed:]) {
+			break
+		}
+		r, size := utf8.DecodeRune(src[consumed:])
+		consumed += size
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			dst = e.appendUnit(dst, uint16(r1))
+			dst = e.appendUnit(dst, uint16(r2))
+		} else {
+			dst = e.appendUnit(dst, uint16(r))
+		}
+	}
+	return dst, consumed, nil
+}
@@ -0,0 +1,108 @@
+package text
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"testing"
+)
+
+func decode(data []byte, encoding string) (string, error) {
+	r, err := NewDecodingReader(bytes.NewReader(data), encoding)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := ioutil.ReadAll(r)
+	return string(decoded), err
+}
+
+func encode(s, encoding string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := NewEncodingWriter(buf, encoding)
+	if err != nil {
+		return nil, err
+	}
+	// write one byte at a time to split multi-byte characters
+	for i := 0; i < len(s); i++ {
+		if _, err = w.Write([]byte{s[i]}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func TestParseEncoding(t *testing.T) {
+	Convey("When parsing encoding names", t, func() {
+		Convey("aliases should map to the canonical name", func() {
+			encoding, err := ParseEncoding("ISO-8859-1")
+			So(err, ShouldBeNil)
+			So(encoding, ShouldEqual, Latin1)
+			encoding, err = ParseEncoding("")
+			So(err, ShouldBeNil)
+			So(encoding, ShouldEqual, UTF8)
+		})
+		Convey("unknown encodings should be rejected", func() {
+			_, err := ParseEncoding("ebcdic")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestDecodingReader(t *testing.T) {
+	Convey("With a decoding reader", t, func() {
+		Convey("latin1 bytes should map to the same code points", func() {
+			s, err := decode([]byte("caf\xe9 \x80"), Latin1)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "café \u0080")
+		})
+		Convey("windows-1252 bytes should use the code page", func() {
+			s, err := decode([]byte("\x80 caf\xe9 \x93x\x94"), Windows1252)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "€ café “x”")
+		})
+		Convey("utf-16 should detect the byte order from the byte order mark", func() {
+			s, err := decode([]byte{0xFE, 0xFF, 0, 'h', 0, 'i'}, UTF16)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "hi")
+			s, err = decode([]byte{0xFF, 0xFE, 'h', 0, 'i', 0}, UTF16)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "hi")
+		})
+		Convey("utf-16 without a byte order mark should default to little-endian", func() {
+			s, err := decode([]byte{'h', 0, 0x3D, 0xD8, 0x00, 0xDE}, UTF16)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "h\U0001F600")
+		})
+		Convey("truncated utf-16 input should result in an error", func() {
+			_, err := decode([]byte{'h', 0, 'i'}, UTF16LE)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestEncodingWriter(t *testing.T) {
+	Convey("With an encoding writer", t, func() {
+		Convey("unencodable characters should be replaced in latin1", func() {
+			data, err := encode("café €", Latin1)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("caf\xe9 ?"))
+		})
+		Convey("windows-1252 should use the code page", func() {
+			data, err := encode("café €", Windows1252)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("caf\xe9 \x80"))
+		})
+		Convey("utf-16 should be little-endian with a byte order mark", func() {
+			data, err := encode("h\U0001F600", UTF16)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{0xFF, 0xFE, 'h', 0, 0x3D, 0xD8, 0x00, 0xDE})
+		})
+		Convey("utf-16be output should round trip", func() {
+			data, err := encode("añb", UTF16BE)
+			So(err, ShouldBeNil)
+			s, err := decode(data, UTF16BE)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "añb")
+		})
+	})
+}
@@ -12,6 +12,7 @@ import (
 type Cell struct {
 	contents string
 	feed     bool
+	style    string
 }
 
 type GridWriter struct {
@@ -22,6 +23,9 @@ type GridWriter struct {
 	colWidths     []int
 }
 
+// StyleReset is the terminal escape sequence ending the style of a styled cell.
+const StyleReset = "\x1b[0m"
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -40,7 +44,15 @@ func (gw *GridWriter) init() {
 // WriteCell writes the given string into the next cell in the current row.
 func (gw *GridWriter) WriteCell(data string) {
 	gw.init()
-	gw.Grid[gw.CurrentRow] = append(gw.Grid[gw.CurrentRow], Cell{data, false})
+	gw.Grid[gw.CurrentRow] = append(gw.Grid[gw.CurrentRow], Cell{data, false, ""})
+}
+
+// WriteStyledCell writes the given string into the next cell in the current row,
+// wrapped in the given terminal escape sequence once padded, so that styles such
+// as reverse video don't affect the width of the column.
+func (gw *GridWriter) WriteStyledCell(data, style string) {
+	gw.init()
+	gw.Grid[gw.CurrentRow] = append(gw.Grid[gw.CurrentRow], Cell{data, false, style})
 }
 
 // WriteCells writes multiple cells by calling WriteCell for each argument.
@@ -54,7 +66,7 @@ func (gw *GridWriter) WriteCells(data ...string) {
 // to extend past the width of the current column, and ends the row.
 func (gw *GridWriter) Feed(data string) {
 	gw.init()
-	gw.Grid[gw.CurrentRow] = append(gw.Grid[gw.CurrentRow], Cell{data, true})
+	gw.Grid[gw.CurrentRow] = append(gw.Grid[gw.CurrentRow], Cell{data, true, ""})
 	gw.EndRow()
 }
 
@@ -142,7 +154,13 @@ func (gw *GridWriter) Flush(w io.Writer) {
 		lastRow := i == (len(gw.Grid) - 1)
 		for j, cell := range row {
 			lastCol := (j == len(row)-1)
+			if cell.style != "" {
+				fmt.Fprint(w, cell.style)
+			}
 			fmt.Fprintf(w, fmt.Sprintf("%%%vs", gw.colWidths[j]), cell.contents)
+			if cell.style != "" {
+				fmt.Fprint(w, StyleReset)
+			}
 			if gw.ColumnPadding > 0 && !lastCol {
 				fmt.Fprint(w, strings.Repeat(" ", gw.ColumnPadding))
 			}
@@ -102,3 +102,17 @@ func TestWriteGrid(t *testing.T) {
 		So(gw.calculateWidths(), ShouldResemble, []int{7, 2, 4, 9})
 	})
 }
+
+func TestWriteStyledCell(t *testing.T) {
+	Convey("Styled cells should be padded inside their style", t, func() {
+		gw := GridWriter{ColumnPadding: 1}
+		gw.WriteCells("host", "conn")
+		gw.EndRow()
+		gw.WriteCell("a")
+		gw.WriteStyledCell("5", "\x1b[7m")
+		gw.EndRow()
+		buf := bytes.Buffer{}
+		gw.Flush(&buf)
+		So(buf.String(), ShouldEqual, "host conn\n   a \x1b[7m   5\x1b[0m\n")
+	})
+}
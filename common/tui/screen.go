@@ -0,0 +1,527 @@
+// Package tui provides an interactive full-screen table of statistics, with
+// a row per host or namespace updated in place, for the monitoring tools.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/util"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ColumnKind says how the values of a column add up over time, when totals
+// are shown instead of the latest values.
+type ColumnKind int
+
+const (
+	// Gauge columns hold instantaneous values, which are shown as is
+	Gauge ColumnKind = iota
+	// Rate columns hold amounts per second
+	Rate
+	// Delta columns hold amounts per polling interval
+	Delta
+)
+
+// Terminal escape sequences used to draw the screen.
+const (
+	enterScreen    = "\x1b[?1049h\x1b[?25l"
+	exitScreen     = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+	highlightStyle = "\x1b[7m"
+)
+
+// Key codes handled by the screen, besides printable characters. The arrow
+// keys, read as escape sequences, are given the codes of their emacs
+// equivalents.
+const (
+	keyLeft      = 2
+	keyCtrlC     = 3
+	keyRight     = 6
+	keyEnter     = 13
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// escapeTimeout is how long to wait for the rest of an escape sequence, such
+// as that of an arrow key, before taking an escape as the escape key.
+const escapeTimeout = 50 * time.Millisecond
+
+// Column describes a column of statistics.
+type Column struct {
+	Header string
+	Kind   ColumnKind
+
+	// Format formats the totals of the column, as whole numbers if not set
+	Format func(total float64) string
+}
+
+// Cell is a value in a row of statistics.
+type Cell struct {
+	// Text is the value formatted for display
+	Text string
+
+	// Value is the value used for sorting, totals and thresholds, if Numeric
+	Value   float64
+	Numeric bool
+}
+
+// Row holds the statistics of a host or namespace.
+type Row struct {
+	Key   string
+	Cells []Cell
+
+	// Error, if set, is shown instead of the cells
+	Error string
+}
+
+// Screen is an interactive table of statistics, redrawn in place as they are
+// updated. Keys sort the rows by any column, filter them by key, pause the
+// updates and toggle between the latest values and their totals since the
+// screen was started.
+type Screen struct {
+	// Title shown on the first line of the screen
+	Title string
+
+	// Header of the column of row keys
+	KeyHeader string
+
+	// Columns of the statistics of each row
+	Columns []Column
+
+	// Values at or above which cells are highlighted, by column header
+	Thresholds map[string]float64
+
+	// Mutex to protect the state of the screen, which is updated and drawn
+	// concurrently
+	lock sync.Mutex
+
+	rows   []Row
+	totals map[string][]float64
+	status string
+
+	// rows and totals shown while the screen is paused
+	frozenRows   []Row
+	frozenTotals map[string][]float64
+
+	// sortColumn is 0 for the key column, and i+1 for Columns[i]
+	sortColumn    int
+	descending    bool
+	filter        string
+	editingFilter bool
+	paused        bool
+	showTotals    bool
+
+	// Signalled whenever the screen needs to be redrawn
+	redraw chan struct{}
+}
+
+// NewScreen returns a screen of the given columns, sorted by key.
+func NewScreen(title, keyHeader string, columns []Column) *Screen {
+	return &Screen{
+		Title:      title,
+		KeyHeader:  keyHeader,
+		Columns:    columns,
+		Thresholds: map[string]float64{},
+		totals:     map[string][]float64{},
+		redraw:     make(chan struct{}, 1),
+	}
+}
+
+// ParseThresholds parses comma-separated <column>=<value> thresholds, at or
+// above which the values of columns are highlighted.
+func ParseThresholds(spec string) (map[string]float64, error) {
+	thresholds := map[string]float64{}
+	for _, field := range strings.Split(spec, ",") {
+		equals := strings.LastIndex(field, "=")
+		if equals <= 0 {
+			return nil, fmt.Errorf("invalid threshold '%v', expected <column>=<value>", field)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(field[equals+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of threshold '%v': %v", field, err)
+		}
+		thresholds[strings.TrimSpace(field[:equals])] = value
+	}
+	return thresholds, nil
+}
+
+// Update replaces the rows of the screen with the latest statistics, sampled
+// over the given polling interval, and adds them to the totals.
+func (screen *Screen) Update(rows []Row, interval time.Duration) {
+	screen.lock.Lock()
+	for _, row := range rows {
+		if row.Error != "" {
+			continue
+		}
+		totals, ok := screen.totals[row.Key]
+		if !ok {
+			totals = make([]float64, len(screen.Columns))
+			screen.totals[row.Key] = totals
+		}
+		for i, cell := range row.Cells {
+			if !cell.Numeric || i >= len(totals) {
+				continue
+			}
+			switch screen.Columns[i].Kind {
+			case Rate:
+				totals[i] += cell.Value * interval.Seconds()
+			case Delta:
+				totals[i] += cell.Value
+			default:
+				totals[i] = cell.Value
+			}
+		}
+	}
+	screen.rows = rows
+	screen.status = ""
+	screen.lock.Unlock()
+	screen.requestRedraw()
+}
+
+// SetStatus shows a message, such as an error polling for statistics, until
+// the next update.
+func (screen *Screen) SetStatus(status string) {
+	screen.lock.Lock()
+	screen.status = status
+	screen.lock.Unlock()
+	screen.requestRedraw()
+}
+
+func (screen *Screen) requestRedraw() {
+	select {
+	case screen.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// HandleKey changes the state of the screen according to a key pressed, and
+// returns whether the key quits the screen.
+func (screen *Screen) HandleKey(key byte) bool {
+	screen.lock.Lock()
+	defer screen.lock.Unlock()
+
+	if screen.editingFilter {
+		switch {
+		case key == keyEnter:
+			screen.editingFilter = false
+		case key == keyEscape:
+			screen.editingFilter = false
+			screen.filter = ""
+		case key == keyBackspace:
+			if len(screen.filter) > 0 {
+				screen.filter = screen.filter[:len(screen.filter)-1]
+			}
+		case key == keyCtrlC:
+			return true
+		case key >= ' ' && key < keyBackspace:
+			screen.filter += string(key)
+		}
+		return false
+	}
+
+	switch key {
+	case 'q', keyCtrlC:
+		return true
+	case '<', ',', keyLeft:
+		if screen.sortColumn > 0 {
+			screen.sortColumn--
+		}
+	case '>', '.', keyRight:
+		if screen.sortColumn < len(screen.Columns) {
+			screen.sortColumn++
+		}
+	case 'r':
+		screen.descending = !screen.descending
+	case '/':
+		screen.editingFilter = true
+	case keyEscape:
+		screen.filter = ""
+	case 'p', ' ':
+		screen.paused = !screen.paused
+		if screen.paused {
+			screen.frozenRows = screen.rows
+			screen.frozenTotals = map[string][]float64{}
+			for key, totals := range screen.totals {
+				screen.frozenTotals[key] = append([]float64{}, totals...)
+			}
+		}
+	case 't':
+		screen.showTotals = !screen.showTotals
+	}
+	return false
+}
+
+// sortableRows sorts rows by one of their columns.
+type sortableRows struct {
+	rows       []Row
+	column     int
+	descending bool
+}
+
+func (s sortableRows) Len() int      { return len(s.rows) }
+func (s sortableRows) Swap(i, j int) { s.rows[i], s.rows[j] = s.rows[j], s.rows[i] }
+
+func (s sortableRows) Less(i, j int) bool {
+	a, b := s.rows[i], s.rows[j]
+	if s.column > 0 && len(a.Cells) >= s.column && len(b.Cells) >= s.column {
+		x, y := a.Cells[s.column-1], b.Cells[s.column-1]
+		if x.Numeric && y.Numeric && x.Value != y.Value {
+			return (x.Value < y.Value) != s.descending
+		}
+		if !x.Numeric && !y.Numeric && x.Text != y.Text {
+			return (x.Text < y.Text) != s.descending
+		}
+		if x.Numeric != y.Numeric {
+			// values sort before blank or unknown cells
+			return x.Numeric
+		}
+	}
+	if a.Key == b.Key {
+		return false
+	}
+	return (a.Key < b.Key) != (s.descending && s.column == 0)
+}
+
+// displayedCells returns the cells of a row as they're shown: the latest
+// values, or their totals.
+func (screen *Screen) displayedCells(row Row, totals []float64) []Cell {
+	if !screen.showTotals || totals == nil {
+		return row.Cells
+	}
+	cells := make([]Cell, len(row.Cells))
+	for i, cell := range row.Cells {
+		cells[i] = cell
+		if !cell.Numeric || i >= len(totals) || screen.Columns[i].Kind == Gauge {
+			continue
+		}
+		cells[i].Value = totals[i]
+		if format := screen.Columns[i].Format; format != nil {
+			cells[i].Text = format(totals[i])
+		} else {
+			cells[i].Text = strconv.FormatFloat(totals[i], 'f', 0, 64)
+		}
+	}
+	return cells
+}
+
+// Render writes the screen, with as many rows as fit in the given height if
+// it is known.
+func (screen *Screen) Render(w io.Writer, height int) {
+	screen.lock.Lock()
+	defer screen.lock.Unlock()
+
+	rows, totals := screen.rows, screen.totals
+	if screen.paused {
+		rows, totals = screen.frozenRows, screen.frozenTotals
+	}
+
+	// filter and sort the rows
+	shown := []Row{}
+	filter := strings.ToLower(screen.filter)
+	for _, row := range rows {
+		if !strings.Contains(strings.ToLower(row.Key), filter) {
+			continue
+		}
+		if row.Error == "" {
+			row.Cells = screen.displayedCells(row, totals[row.Key])
+		}
+		shown = append(shown, row)
+	}
+	sort.Sort(sortableRows{shown, screen.sortColumn, screen.descending})
+
+	lines := []string{screen.Title, screen.describe()}
+	if screen.status != "" {
+		lines = append(lines, screen.status)
+	}
+	if height > 0 && len(shown) > height-len(lines)-1 {
+		shown = shown[:util.MaxInt(height-len(lines)-1, 0)]
+	}
+
+	grid := &text.GridWriter{ColumnPadding: 1}
+	grid.WriteCell(screen.header(0, screen.KeyHeader))
+	for i, column := range screen.Columns {
+		grid.WriteCell(screen.header(i+1, column.Header))
+	}
+	grid.EndRow()
+	for _, row := range shown {
+		grid.WriteCell(row.Key)
+		if row.Error != "" {
+			grid.Feed(row.Error)
+			continue
+		}
+		for i, cell := range row.Cells {
+			threshold, ok := screen.Thresholds[screen.Columns[i].Header]
+			if ok && cell.Numeric && cell.Value >= threshold {
+				grid.WriteStyledCell(cell.Text, highlightStyle)
+			} else {
+				grid.WriteCell(cell.Text)
+			}
+		}
+		grid.EndRow()
+	}
+	buf := &bytes.Buffer{}
+	grid.Flush(buf)
+	lines = append(lines, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")...)
+
+	// the terminal is in raw mode, so lines must also return the cursor
+	fmt.Fprint(w, clearScreen+strings.Join(lines, "\r\n"))
+}
+
+// header returns the header of a column, marked if the rows are sorted by it.
+func (screen *Screen) header(column int, header string) string {
+	if column == screen.sortColumn {
+		return "[" + header + "]"
+	}
+	return header
+}
+
+// describe returns the line describing the state of the screen and its keys.
+func (screen *Screen) describe() string {
+	parts := []string{}
+	if screen.paused {
+		parts = append(parts, "PAUSED")
+	}
+	if screen.showTotals {
+		parts = append(parts, "totals")
+	} else {
+		parts = append(parts, "latest")
+	}
+	if screen.descending {
+		parts = append(parts, "descending")
+	}
+	if screen.editingFilter {
+		parts = append(parts, "filter: "+screen.filter+"_")
+	} else if screen.filter != "" {
+		parts = append(parts, "filter: "+screen.filter)
+	}
+	return strings.Join(parts, " | ") +
+		" (< > sort, r reverse, / filter, p pause, t totals, q quit)"
+}
+
+// readKeys sends the keys of the bytes read from the terminal to keys, until
+// input is closed. The escape sequences of the left and right arrow keys are
+// sent as keyLeft and keyRight, and other escape sequences are dropped, so
+// that only an escape not followed by a sequence within the timeout is sent
+// as the escape key.
+func readKeys(input <-chan byte, keys chan<- byte, timeout time.Duration) {
+	defer close(keys)
+	var pushedBack []byte
+	read := func(wait <-chan time.Time) (byte, bool) {
+		if len(pushedBack) > 0 {
+			b := pushedBack[0]
+			pushedBack = pushedBack[1:]
+			return b, true
+		}
+		select {
+		case b, ok := <-input:
+			return b, ok
+		case <-wait:
+			return 0, false
+		}
+	}
+
+	for {
+		b, ok := read(nil)
+		if !ok {
+			return
+		}
+		if b != keyEscape {
+			keys <- b
+			continue
+		}
+		b, ok = read(time.After(timeout))
+		if !ok || b != '[' && b != 'O' {
+			keys <- keyEscape
+			if ok {
+				pushedBack = append(pushedBack, b)
+			}
+			continue
+		}
+		// the parameter bytes of a sequence end with a final byte from '@'
+		// to '~', which names the key
+		for {
+			if b, ok = read(time.After(timeout)); !ok || b >= '@' && b <= '~' {
+				break
+			}
+		}
+		switch {
+		case ok && b == 'C':
+			keys <- keyRight
+		case ok && b == 'D':
+			keys <- keyLeft
+		}
+	}
+}
+
+// logBuffer holds the messages logged while the screen is shown, which would
+// otherwise be drawn over it, until it is closed.
+type logBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (logs *logBuffer) Write(p []byte) (int, error) {
+	logs.lock.Lock()
+	defer logs.lock.Unlock()
+	return logs.buf.Write(p)
+}
+
+// Run draws the screen on the terminal until a key quitting it is pressed,
+// redrawing it whenever it is updated or a key is pressed. Messages logged
+// meanwhile are written to stderr once the terminal is restored.
+func (screen *Screen) Run(in, out *os.File) error {
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return fmt.Errorf("interactive mode requires a terminal: %v", err)
+	}
+	logs := &logBuffer{}
+	log.SetWriter(logs)
+	defer func() {
+		log.SetWriter(os.Stderr)
+		logs.lock.Lock()
+		os.Stderr.Write(logs.buf.Bytes())
+		logs.lock.Unlock()
+	}()
+	defer restore()
+	fmt.Fprint(out, enterScreen)
+	defer fmt.Fprint(out, exitScreen)
+
+	input := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				close(input)
+				return
+			}
+			if n > 0 {
+				input <- buf[0]
+			}
+		}
+	}()
+	keys := make(chan byte)
+	go readKeys(input, keys, escapeTimeout)
+
+	for {
+		buf := &bytes.Buffer{}
+		screen.Render(buf, terminalHeight(int(out.Fd())))
+		out.Write(buf.Bytes())
+
+		select {
+		case key, ok := <-keys:
+			if !ok || screen.HandleKey(key) {
+				return nil
+			}
+		case <-screen.redraw:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renderedRows returns the fields of the rows of the rendered screen, after
+// the title, state and header lines.
+func renderedRows(screen *Screen, height int) [][]string {
+	buf := &bytes.Buffer{}
+	screen.Render(buf, height)
+	lines := strings.Split(strings.TrimPrefix(buf.String(), clearScreen), "\r\n")
+	rows := [][]string{}
+	for _, line := range lines[3:] {
+		rows = append(rows, strings.Fields(line))
+	}
+	return rows
+}
+
+func number(text string, value float64) Cell {
+	return Cell{Text: text, Value: value, Numeric: true}
+}
+
+func TestScreen(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a screen of statistics", t, func() {
+		screen := NewScreen("title", "host", []Column{
+			{Header: "insert", Kind: Rate},
+			{Header: "flushes", Kind: Delta},
+			{Header: "conn", Kind: Gauge},
+		})
+		screen.Update([]Row{
+			{Key: "b:27017", Cells: []Cell{number("10", 10), number("1", 1), number("5", 5)}},
+			{Key: "a:27017", Cells: []Cell{number("20", 20), number("0", 0), number("7", 7)}},
+			{Key: "c:27017", Error: "no reachable servers"},
+		}, 2*time.Second)
+
+		Convey("rows should be sorted by key", func() {
+			So(renderedRows(screen, 0), ShouldResemble, [][]string{
+				{"a:27017", "20", "0", "7"},
+				{"b:27017", "10", "1", "5"},
+				{"c:27017", "no", "reachable", "servers"},
+			})
+		})
+
+		Convey("keys should choose the sort column and order", func() {
+			screen.HandleKey('>')
+			So(renderedRows(screen, 0)[0][0], ShouldEqual, "b:27017")
+			screen.HandleKey('r')
+			So(renderedRows(screen, 0)[0][0], ShouldEqual, "a:27017")
+			screen.HandleKey('<')
+			So(renderedRows(screen, 0)[0][0], ShouldEqual, "c:27017")
+		})
+
+		Convey("rows should be filtered by key", func() {
+			for _, key := range []byte("/B:") {
+				So(screen.HandleKey(key), ShouldBeFalse)
+			}
+			So(screen.HandleKey('q'), ShouldBeFalse)
+			screen.HandleKey(keyBackspace)
+			screen.HandleKey(keyEnter)
+			So(renderedRows(screen, 0), ShouldResemble, [][]string{{"b:27017", "10", "1", "5"}})
+			screen.HandleKey(keyEscape)
+			So(len(renderedRows(screen, 0)), ShouldEqual, 3)
+		})
+
+		Convey("totals should add up rates and deltas", func() {
+			screen.Update([]Row{
+				{Key: "b:27017", Cells: []Cell{number("30", 30), number("2", 2), number("6", 6)}},
+			}, 2*time.Second)
+			screen.HandleKey('t')
+			So(renderedRows(screen, 0), ShouldResemble, [][]string{{"b:27017", "80", "3", "6"}})
+		})
+
+		Convey("pausing should freeze the rows", func() {
+			screen.HandleKey('p')
+			screen.Update([]Row{}, time.Second)
+			So(len(renderedRows(screen, 0)), ShouldEqual, 3)
+			screen.HandleKey('p')
+			So(len(renderedRows(screen, 0)), ShouldEqual, 0)
+		})
+
+		Convey("values at or above their threshold should be highlighted", func() {
+			screen.Thresholds["conn"] = 6
+			buf := &bytes.Buffer{}
+			screen.Render(buf, 0)
+			So(strings.Count(buf.String(), highlightStyle), ShouldEqual, 1)
+			So(buf.String(), ShouldContainSubstring, highlightStyle+"   7")
+		})
+
+		Convey("only the rows fitting the terminal should be drawn", func() {
+			So(len(renderedRows(screen, 5)), ShouldEqual, 2)
+		})
+
+		Convey("q and ctrl-c should quit", func() {
+			So(screen.HandleKey('q'), ShouldBeTrue)
+			So(screen.HandleKey(keyCtrlC), ShouldBeTrue)
+		})
+	})
+
+	Convey("Thresholds should be parsed", t, func() {
+		thresholds, err := ParseThresholds("qrw=50, dirty=20.5")
+		So(err, ShouldBeNil)
+		So(thresholds, ShouldResemble, map[string]float64{"qrw": 50, "dirty": 20.5})
+		for _, spec := range []string{"", "qrw", "=5", "qrw=many"} {
+			_, err := ParseThresholds(spec)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+// keysOf returns the keys read from the given bytes, written at once.
+func keysOf(input string) []byte {
+	bytesIn := make(chan byte, len(input))
+	for i := 0; i < len(input); i++ {
+		bytesIn <- input[i]
+	}
+	close(bytesIn)
+	keys := make(chan byte)
+	go readKeys(bytesIn, keys, escapeTimeout)
+	read := []byte{}
+	for key := range keys {
+		read = append(read, key)
+	}
+	return read
+}
+
+func TestReadKeys(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Printable keys should be read as they are", t, func() {
+		So(keysOf("/ab\r"), ShouldResemble, []byte("/ab\r"))
+	})
+
+	Convey("The left and right arrow keys should be read", t, func() {
+		So(keysOf("\x1b[D\x1b[C\x1bOD\x1b[1;5C"), ShouldResemble, []byte{keyLeft, keyRight, keyLeft, keyRight})
+	})
+
+	Convey("Other escape sequences should be dropped", t, func() {
+		So(keysOf("/a\x1b[A\x1b[B\x1b[3~b"), ShouldResemble, []byte("/ab"))
+	})
+
+	Convey("An escape without a sequence should be read as the escape key", t, func() {
+		So(keysOf("a\x1b"), ShouldResemble, []byte{'a', keyEscape})
+		So(keysOf("\x1bq"), ShouldResemble, []byte{keyEscape, 'q'})
+		So(keysOf("\x1b\x1b[D"), ShouldResemble, []byte{keyEscape, keyLeft})
+	})
+
+	Convey("An escape followed by nothing within the timeout should be the escape key", t, func() {
+		input := make(chan byte)
+		keys := make(chan byte)
+		go readKeys(input, keys, time.Millisecond)
+		input <- keyEscape
+		So(<-keys, ShouldEqual, keyEscape)
+		input <- 'q'
+		So(<-keys, ShouldEqual, 'q')
+		close(input)
+		_, ok := <-keys
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("With a screen of statistics", t, func() {
+		screen := NewScreen("title", "host", []Column{{Header: "insert"}})
+
+		Convey("arrow keys should choose the sort column", func() {
+			for _, key := range keysOf("\x1b[C") {
+				screen.HandleKey(key)
+			}
+			So(screen.sortColumn, ShouldEqual, 1)
+			for _, key := range keysOf("\x1b[D") {
+				screen.HandleKey(key)
+			}
+			So(screen.sortColumn, ShouldEqual, 0)
+		})
+
+		Convey("arrow keys should neither clear nor change the filter being edited", func() {
+			for _, key := range keysOf("/ab\x1b[D\x1b[C") {
+				screen.HandleKey(key)
+			}
+			So(screen.filter, ShouldEqual, "ab")
+			So(screen.editingFilter, ShouldBeTrue)
+		})
+	})
+}
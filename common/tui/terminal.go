@@ -0,0 +1,29 @@
+// +build !solaris
+
+package tui
+
+import (
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// makeRaw puts the terminal in raw mode, and returns a function restoring
+// its previous state.
+func makeRaw(fd int) (func(), error) {
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		terminal.Restore(fd, state)
+	}, nil
+}
+
+// terminalHeight returns the number of lines of the terminal, or 0 if it
+// can't be found.
+func terminalHeight(fd int) int {
+	_, height, err := terminal.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+	return height
+}
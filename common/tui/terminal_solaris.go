@@ -0,0 +1,14 @@
+package tui
+
+import (
+	"fmt"
+)
+
+// makeRaw isn't supported on Solaris, which lacks the terminal package.
+func makeRaw(fd int) (func(), error) {
+	return nil, fmt.Errorf("not supported on Solaris")
+}
+
+func terminalHeight(fd int) int {
+	return 0
+}
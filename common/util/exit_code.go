@@ -9,6 +9,7 @@ const (
 	ExitClean      int = 0
 	ExitBadOptions int = 3
 	ExitKill       int = 4
+	ExitAlert      int = 5
 	// Go reserves exit code 2 for its own use
 )
 
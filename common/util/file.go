@@ -26,6 +26,25 @@ func GetFieldsFromFile(path string) ([]string, error) {
 	return fields, nil
 }
 
+// WriteFieldsToFile writes fields to the file at "path", one per line, so
+// that they can be read back by GetFieldsFromFile.
+func WriteFieldsToFile(path string, fields []string) error {
+	file, err := os.Create(ToUniversalPath(path))
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, field := range fields {
+		writer.WriteString(field)
+		writer.WriteByte('\n')
+	}
+	if err = writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 // ToUniversalPath returns the result of replacing each slash ('/') character
 // in "path" with an OS-sepcific separator character. Multiple slashes are
 // replaced by multiple separators
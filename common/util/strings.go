@@ -1,5 +1,10 @@
 package util
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Pluralize takes an amount and two strings denoting the singular
 // and plural noun the amount represents. If the amount is singular,
 // the singular form is returned; otherwise plural is returned. E.g.
@@ -10,3 +15,42 @@ func Pluralize(amount int, singular, plural string) string {
 	}
 	return plural
 }
+
+// ParseCharOption parses the value of an option that must be a single
+// character, such as a CSV delimiter. The escape sequence `\t` is accepted
+// for a tab. An empty value returns 0.
+func ParseCharOption(name, value string) (rune, error) {
+	if value == `\t` {
+		return '\t', nil
+	}
+	if value == "" {
+		return 0, nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 || runes[0] == '\r' || runes[0] == '\n' {
+		return 0, fmt.Errorf("--%v must be a single character other than a line terminator", name)
+	}
+	return runes[0], nil
+}
+
+// Line terminators accepted by the --lineTerminator options.
+const (
+	LineTerminatorLF   = "lf"
+	LineTerminatorCRLF = "crlf"
+	LineTerminatorCR   = "cr"
+)
+
+// ParseLineTerminator returns the character sequence designated by the name
+// of a line terminator. An empty name designates "\n".
+func ParseLineTerminator(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", LineTerminatorLF:
+		return "\n", nil
+	case LineTerminatorCRLF:
+		return "\r\n", nil
+	case LineTerminatorCR:
+		return "\r", nil
+	}
+	return "", fmt.Errorf("invalid line terminator '%v', choose one of: %v, %v, %v",
+		name, LineTerminatorLF, LineTerminatorCRLF, LineTerminatorCR)
+}
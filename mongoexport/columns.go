@@ -0,0 +1,202 @@
+package mongoexport
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// columnNode records the structure of the values found at a field path, so
+// that the field can be expanded into the columns of its subdocument fields
+// or array elements.
+type columnNode struct {
+	// leaf is set if a value that isn't expanded was found at the path
+	leaf bool
+
+	// names lists the names of the subfields in the order they were first
+	// seen, and nodes holds their structure
+	names []string
+	nodes map[string]*columnNode
+}
+
+// columnScan discovers the columns of a CSV export by observing the documents
+// to export, expanding subdocuments into dotted fields if flatten is set, and
+// arrays into columns named after their indexes if arrayColumns is set. If
+// arrayElements is set, the elements of arrays are observed as values of the
+// array's own field instead, so that their subfields can be unwound.
+type columnScan struct {
+	flatten, arrayColumns, arrayElements bool
+
+	// fields are the fields to expand; if empty, the top-level fields of the
+	// documents are discovered as well
+	fields []string
+	root   *columnNode
+}
+
+// newColumnScan returns a columnScan expanding the given fields according to
+// the array mode and flatten option.
+func newColumnScan(fields []string, arrayMode string, flatten bool) *columnScan {
+	return &columnScan{
+		flatten:       flatten,
+		arrayColumns:  arrayMode == ArrayModeColumns,
+		arrayElements: flatten && arrayMode == ArrayModeExplode,
+		fields:        fields,
+		root:          &columnNode{},
+	}
+}
+
+// child returns the node of a subfield, creating it if it's the first time the
+// subfield is seen.
+func (node *columnNode) child(name string) *columnNode {
+	if node.nodes == nil {
+		node.nodes = map[string]*columnNode{}
+	}
+	child, ok := node.nodes[name]
+	if !ok {
+		child = &columnNode{}
+		node.nodes[name] = child
+		node.names = append(node.names, name)
+	}
+	return child
+}
+
+// observe records the structure of a document to export.
+func (scan *columnScan) observe(document bson.D) {
+	if len(scan.fields) == 0 {
+		scan.observeValue(scan.root, document, true)
+		return
+	}
+	for _, field := range scan.fields {
+		if value, ok := lookupBSONValue(document, field); ok {
+			scan.observeValue(scan.root.child(field), value, false)
+		}
+	}
+}
+
+// observeValue records the structure of a value. Top-level documents are
+// always expanded.
+func (scan *columnScan) observeValue(node *columnNode, value interface{}, expand bool) {
+	switch v := value.(type) {
+	case bson.D:
+		if scan.flatten || expand {
+			for _, elem := range v {
+				scan.observeValue(node.child(elem.Name), elem.Value, false)
+			}
+			return
+		}
+	case bson.M:
+		if scan.flatten || expand {
+			for name, subvalue := range v {
+				scan.observeValue(node.child(name), subvalue, false)
+			}
+			return
+		}
+	case []interface{}:
+		if scan.arrayColumns {
+			for i, element := range v {
+				scan.observeValue(node.child(strconv.Itoa(i)), element, false)
+			}
+			return
+		}
+		if scan.arrayElements {
+			for _, element := range v {
+				scan.observeValue(node, element, false)
+			}
+			return
+		}
+	}
+	node.leaf = true
+}
+
+// columns returns the expanded list of fields, in the order of the fields to
+// expand and of the subfields first seen within them.
+func (scan *columnScan) columns() []string {
+	if len(scan.fields) == 0 {
+		return scan.root.columns("")
+	}
+	var columns []string
+	for _, field := range scan.fields {
+		columns = append(columns, scan.root.child(field).columns(field)...)
+	}
+	return columns
+}
+
+// columns returns the columns of the node at the given path: the path itself
+// if the node is a leaf or was never seen, followed by those of its subfields.
+func (node *columnNode) columns(path string) []string {
+	var columns []string
+	if node.leaf || (len(node.names) == 0 && path != "") {
+		columns = append(columns, path)
+	}
+	for _, name := range node.names {
+		childPath := name
+		if path != "" {
+			childPath = path + "." + name
+		}
+		columns = append(columns, node.nodes[name].columns(childPath)...)
+	}
+	return columns
+}
+
+// sortColumns sorts dotted field names alphabetically, comparing them one part
+// at a time so that subfields follow their parent, and comparing array indexes
+// numerically.
+func sortColumns(columns []string) {
+	sort.Sort(byColumnName(columns))
+}
+
+type byColumnName []string
+
+func (c byColumnName) Len() int      { return len(c) }
+func (c byColumnName) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byColumnName) Less(i, j int) bool {
+	left, right := strings.Split(c[i], "."), strings.Split(c[j], ".")
+	for k := 0; k < len(left) && k < len(right); k++ {
+		if left[k] == right[k] {
+			continue
+		}
+		leftIndex, leftErr := strconv.Atoi(left[k])
+		rightIndex, rightErr := strconv.Atoi(right[k])
+		if leftErr == nil && rightErr == nil {
+			return leftIndex < rightIndex
+		}
+		return left[k] < right[k]
+	}
+	return len(left) < len(right)
+}
+
+// lookupBSONValue returns the value of a dot-delimited field of a document,
+// which may index into arrays, and whether it was found.
+func lookupBSONValue(document bson.D, field string) (interface{}, bool) {
+	var value interface{} = document
+	for _, name := range strings.Split(field, ".") {
+		var found bool
+		if value, found = lookupBSONChild(value, name); !found {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// lookupBSONChild returns the value of a field of a document, or of an element
+// of an array, and whether it was found.
+func lookupBSONChild(value interface{}, name string) (interface{}, bool) {
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			if elem.Name == name {
+				return elem.Value, true
+			}
+		}
+	case bson.M:
+		subvalue, ok := v[name]
+		return subvalue, ok
+	case []interface{}:
+		i, err := strconv.Atoi(name)
+		if err == nil && i >= 0 && i < len(v) {
+			return v[i], true
+		}
+	}
+	return nil, false
+}
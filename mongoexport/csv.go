@@ -1,7 +1,7 @@
 package mongoexport
 
 import (
-	"encoding/csv"
+	"bufio"
 	"fmt"
 	"github.com/mongodb/mongo-tools/common/bsonutil"
 	"github.com/mongodb/mongo-tools/common/json"
@@ -10,11 +10,32 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // type for reflect code
 var marshalDType = reflect.TypeOf(bsonutil.MarshalD{})
 
+// defaultArrayDelimiter separates the elements of arrays in ArrayModeJoin if
+// --arrayDelimiter isn't set.
+const defaultArrayDelimiter = ";"
+
+// Modes of writing arrays in CSV format.
+const (
+	// ArrayModeJSON writes arrays as JSON.
+	ArrayModeJSON = "json"
+	// ArrayModeJoin joins the elements of arrays of values other than
+	// documents and arrays, and writes other arrays as JSON.
+	ArrayModeJoin = "join"
+	// ArrayModeExplode writes a line for each element of an array, repeating
+	// the other fields of the document.
+	ArrayModeExplode = "explode"
+	// ArrayModeColumns writes each element of an array in its own column,
+	// named after its index.
+	ArrayModeColumns = "columns"
+)
+
 // CSVExportOutput is an implementation of ExportOutput that writes documents to the output in CSV format.
 type CSVExportOutput struct {
 	// Fields is a list of field names in the bson documents to be exported.
@@ -28,20 +49,36 @@ type CSVExportOutput struct {
 	// NoHeaderLine, if set, will export CSV data without a list of field names at the first line
 	NoHeaderLine bool
 
-	csvWriter *csv.Writer
+	// ArrayMode determines how arrays are written, and is one of the ArrayMode
+	// constants; an empty ArrayMode writes them as JSON.
+	ArrayMode string
+
+	// ArrayDelimiter separates the elements of arrays joined in ArrayModeJoin.
+	ArrayDelimiter string
+
+	csvWriter *csvWriter
 }
 
 // NewCSVExportOutput returns a CSVExportOutput configured to write output to the
 // given io.Writer, extracting the specified fields only.
 func NewCSVExportOutput(fields []string, noHeaderLine bool, out io.Writer) *CSVExportOutput {
 	return &CSVExportOutput{
-		fields,
-		0,
-		noHeaderLine,
-		csv.NewWriter(out),
+		Fields:       fields,
+		NoHeaderLine: noHeaderLine,
+		csvWriter:    newCSVWriter(out),
 	}
 }
 
+// setDialect configures the characters used to structure the output. A
+// zero escape character means quotes within fields are escaped by doubling
+// them.
+func (csvExporter *CSVExportOutput) setDialect(delimiter, quote, escape rune, lineTerminator string) {
+	csvExporter.csvWriter.Comma = delimiter
+	csvExporter.csvWriter.Quote = quote
+	csvExporter.csvWriter.Escape = escape
+	csvExporter.csvWriter.LineTerminator = lineTerminator
+}
+
 // WriteHeader writes a comma-delimited list of fields as the output header row.
 func (csvExporter *CSVExportOutput) WriteHeader() error {
 	if !csvExporter.NoHeaderLine {
@@ -64,54 +101,148 @@ func (csvExporter *CSVExportOutput) Flush() error {
 }
 
 // ExportDocument writes a line to output with the CSV representation of a document.
+// If ArrayMode is explode, a line is written for each element of the arrays found
+// at the exported fields instead.
 func (csvExporter *CSVExportOutput) ExportDocument(document bson.D) error {
-	rowOut := make([]string, 0, len(csvExporter.Fields))
 	extendedDoc, err := bsonutil.ConvertBSONValueToJSON(document)
 	if err != nil {
 		return err
 	}
 
+	if csvExporter.ArrayMode == ArrayModeExplode {
+		csvExporter.explode(extendedDoc, map[string]int{})
+	} else {
+		rowOut := make([]string, 0, len(csvExporter.Fields))
+		for _, fieldName := range csvExporter.Fields {
+			rowOut = append(rowOut, csvExporter.formatValue(extractFieldByName(fieldName, extendedDoc)))
+		}
+		csvExporter.csvWriter.Write(rowOut)
+	}
+	csvExporter.NumExported++
+	return csvExporter.csvWriter.Error()
+}
+
+// explode writes a line for each combination of elements of the arrays found
+// at the exported fields, like successive $unwind stages. The elements already
+// chosen are given by indexes, as described by extractField. Empty arrays
+// produce a single line, with blank values for the fields within them.
+func (csvExporter *CSVExportOutput) explode(document interface{}, indexes map[string]int) {
+	rowOut := make([]string, 0, len(csvExporter.Fields))
 	for _, fieldName := range csvExporter.Fields {
-		fieldVal := extractFieldByName(fieldName, extendedDoc)
-		if fieldVal == nil {
-			rowOut = append(rowOut, "")
-		} else if reflect.TypeOf(fieldVal) == reflect.TypeOf(bson.M{}) ||
-			reflect.TypeOf(fieldVal) == reflect.TypeOf(bson.D{}) ||
-			reflect.TypeOf(fieldVal) == marshalDType ||
-			reflect.TypeOf(fieldVal) == reflect.TypeOf([]interface{}{}) {
-			buf, err := json.Marshal(fieldVal)
-			if err != nil {
-				rowOut = append(rowOut, "")
-			} else {
-				rowOut = append(rowOut, string(buf))
+		value, arrayPath := extractField(fieldName, document, indexes)
+		if arrayPath != "" {
+			array := value.([]interface{})
+			if len(array) == 0 {
+				indexes[arrayPath] = -1
+				csvExporter.explode(document, indexes)
 			}
-		} else {
-			rowOut = append(rowOut, fmt.Sprintf("%v", fieldVal))
+			for i := range array {
+				indexes[arrayPath] = i
+				csvExporter.explode(document, indexes)
+			}
+			delete(indexes, arrayPath)
+			return
 		}
+		rowOut = append(rowOut, csvExporter.formatValue(value))
 	}
 	csvExporter.csvWriter.Write(rowOut)
-	csvExporter.NumExported++
-	return csvExporter.csvWriter.Error()
+}
+
+// formatValue returns the text of a CSV field holding the given value. Documents
+// and arrays are written as JSON, unless ArrayMode is join and the array only
+// holds values of other types, in which case they are joined by ArrayDelimiter.
+func (csvExporter *CSVExportOutput) formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		if csvExporter.ArrayMode == ArrayModeJoin && isScalarArray(v) {
+			elements := make([]string, len(v))
+			for i, element := range v {
+				elements[i] = csvExporter.formatValue(element)
+			}
+			return strings.Join(elements, csvExporter.ArrayDelimiter)
+		}
+	case bson.M, bson.D, bsonutil.MarshalD:
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// isScalarArray reports whether an array holds neither documents nor arrays.
+func isScalarArray(array []interface{}) bool {
+	for _, element := range array {
+		switch element.(type) {
+		case []interface{}, bson.M, bson.D, bsonutil.MarshalD:
+			return false
+		}
+	}
+	return true
 }
 
 // extractFieldByName takes a field name and document, and returns a value representing
 // the value of that field in the document in a format that can be printed as a string.
 // It will also handle dot-delimited field names for nested arrays or documents.
 func extractFieldByName(fieldName string, document interface{}) interface{} {
+	value, _ := extractField(fieldName, document, nil)
+	return value
+}
+
+// extractField is like extractFieldByName, but if indexes is non-nil, arrays that
+// aren't addressed by an index in the field name are unwound: the element at
+// indexes[path] is used instead, where path is the dot-delimited path of the
+// array, suffixed with ".$" for each array enclosing it at the same path. A
+// negative index denotes an empty array. If the index of an array isn't in
+// indexes, extractField returns the array along with its path.
+func extractField(fieldName string, document interface{}, indexes map[string]int) (interface{}, string) {
 	dotParts := strings.Split(fieldName, ".")
 	var subdoc interface{} = document
 
-	for _, path := range dotParts {
+	for i := 0; i <= len(dotParts); i++ {
+		if indexes != nil {
+			// unwind arrays, unless the next part indexes into them
+			arrayPath := strings.Join(dotParts[:i], ".")
+			for {
+				array, ok := subdoc.([]interface{})
+				if !ok {
+					break
+				}
+				if i < len(dotParts) {
+					if _, err := strconv.Atoi(dotParts[i]); err == nil {
+						break
+					}
+				}
+				arrayIndex, ok := indexes[arrayPath]
+				if !ok {
+					return array, arrayPath
+				}
+				if arrayIndex < 0 || arrayIndex >= len(array) {
+					return "", ""
+				}
+				subdoc = array[arrayIndex]
+				arrayPath += ".$"
+			}
+		}
+		if i == len(dotParts) {
+			break
+		}
+		path := dotParts[i]
+
 		docValue := reflect.ValueOf(subdoc)
 		if !docValue.IsValid() {
-			return ""
+			return "", ""
 		}
 		docType := docValue.Type()
 		docKind := docType.Kind()
 		if docKind == reflect.Map {
 			subdocVal := docValue.MapIndex(reflect.ValueOf(path))
 			if subdocVal.Kind() == reflect.Invalid {
-				return ""
+				return "", ""
 			}
 			subdoc = subdocVal.Interface()
 		} else if docKind == reflect.Slice {
@@ -121,28 +252,113 @@ func extractFieldByName(fieldName string, document interface{}) interface{} {
 				var err error
 				subdoc, err = bsonutil.FindValueByKey(path, &asD)
 				if err != nil {
-					return ""
+					return "", ""
 				}
 			} else {
 				//  check that the path can be converted to int
 				arrayIndex, err := strconv.Atoi(path)
 				if err != nil {
-					return ""
+					return "", ""
 				}
 				// bounds check for slice
 				if arrayIndex < 0 || arrayIndex >= docValue.Len() {
-					return ""
+					return "", ""
 				}
 				subdocVal := docValue.Index(arrayIndex)
 				if subdocVal.Kind() == reflect.Invalid {
-					return ""
+					return "", ""
 				}
 				subdoc = subdocVal.Interface()
 			}
 		} else {
 			// trying to index into a non-compound type - just return blank.
-			return ""
+			return "", ""
 		}
 	}
-	return subdoc
+	return subdoc, ""
+}
+
+// csvWriter writes records in a configurable CSV dialect. Unlike the writer
+// of the encoding/csv package, the quote character, escape character and
+// line terminator can be changed.
+type csvWriter struct {
+	Comma          rune   // field delimiter (set to ',' by newCSVWriter)
+	Quote          rune   // quote character (set to '"' by newCSVWriter)
+	Escape         rune   // if not 0 or Quote, escapes quotes instead of doubling them
+	LineTerminator string // set to "\n" by newCSVWriter
+	w              *bufio.Writer
+	err            error
+}
+
+// newCSVWriter returns a csvWriter writing RFC 4180 CSV to w.
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{
+		Comma:          ',',
+		Quote:          '"',
+		LineTerminator: "\n",
+		w:              bufio.NewWriter(w),
+	}
+}
+
+// Write writes a single record, quoting fields as needed.
+func (w *csvWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			w.w.WriteRune(w.Comma)
+		}
+		if !w.fieldNeedsQuotes(field) {
+			w.w.WriteString(field)
+			continue
+		}
+		w.w.WriteRune(w.Quote)
+		for _, r := range field {
+			if r == w.Quote || (w.hasEscape() && r == w.Escape) {
+				if w.hasEscape() {
+					w.w.WriteRune(w.Escape)
+				} else {
+					w.w.WriteRune(w.Quote)
+				}
+			}
+			w.w.WriteRune(r)
+		}
+		w.w.WriteRune(w.Quote)
+	}
+	_, w.err = w.w.WriteString(w.LineTerminator)
+	return w.err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *csvWriter) Flush() {
+	if err := w.w.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// Error reports any error that has occurred during a previous Write or Flush.
+func (w *csvWriter) Error() error {
+	return w.err
+}
+
+// hasEscape reports whether quotes are escaped with a distinct character.
+func (w *csvWriter) hasEscape() bool {
+	return w.Escape != 0 && w.Escape != w.Quote
+}
+
+// fieldNeedsQuotes reports whether field must be quoted: if it contains the
+// delimiter, a quote, the escape character or a line break, or starts with
+// white space.
+func (w *csvWriter) fieldNeedsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` || strings.ContainsRune(field, w.Comma) ||
+		strings.ContainsRune(field, w.Quote) || strings.ContainsAny(field, "\r\n") ||
+		(w.hasEscape() && strings.ContainsRune(field, w.Escape)) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/csv"
 	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/db"
+	"github.com/mongodb/mongo-tools/common/options"
 	"github.com/mongodb/mongo-tools/common/testutil"
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/mgo.v2/bson"
@@ -42,6 +44,18 @@ func TestWriteCSV(t *testing.T) {
 			So(rec, ShouldResemble, []string{"12345", "", "", ""})
 		})
 
+		Convey("Decimal values should be written in full", func() {
+			value, err := bson.ParseDecimal128("1234567890.123456789012345")
+			So(err, ShouldBeNil)
+			csvExporter := NewCSVExportOutput(fields, true, out)
+			csvExporter.ExportDocument(bson.D{{"_id", "12345"}, {"x", value}})
+			csvExporter.WriteFooter()
+			csvExporter.Flush()
+			rec, err := csv.NewReader(strings.NewReader(out.String())).Read()
+			So(err, ShouldBeNil)
+			So(rec, ShouldResemble, []string{"12345", "1234567890.123456789012345", "", ""})
+		})
+
 		Convey("Exported document with missing fields should print as blank", func() {
 			csvExporter := NewCSVExportOutput(fields, true, out)
 			csvExporter.ExportDocument(bson.D{{"_id", "12345"}})
@@ -69,6 +83,29 @@ func TestWriteCSV(t *testing.T) {
 	})
 }
 
+func TestWriteCSVDialect(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a CSV export output using a custom dialect", t, func() {
+		out := &bytes.Buffer{}
+		csvExporter := NewCSVExportOutput([]string{"a", "b", "c"}, true, out)
+
+		Convey("fields should be delimited, quoted and terminated as configured", func() {
+			csvExporter.setDialect(';', '\'', 0, "\r\n")
+			csvExporter.ExportDocument(bson.D{{"a", "x;y"}, {"b", "it's"}, {"c", `"z"`}})
+			csvExporter.Flush()
+			So(out.String(), ShouldEqual, "'x;y';'it''s';\"z\"\r\n")
+		})
+
+		Convey("quotes should be escaped with the escape character if set", func() {
+			csvExporter.setDialect(',', '"', '\\', "\n")
+			csvExporter.ExportDocument(bson.D{{"a", `x"y`}, {"b", `p\q`}, {"c", 1}})
+			csvExporter.Flush()
+			So(out.String(), ShouldEqual, `"x\"y","p\\q",1`+"\n")
+		})
+	})
+}
+
 func TestExtractDField(t *testing.T) {
 	Convey("With a test bson.D", t, func() {
 		testD := bsonutil.MarshalD{
@@ -133,3 +170,286 @@ func TestExtractDField(t *testing.T) {
 		So(val, ShouldEqual, "")
 	})
 }
+
+func TestWriteCSVArrayModes(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a CSV export output", t, func() {
+		out := &bytes.Buffer{}
+		// exporting a document converts its values in place
+		newDocument := func() bson.D {
+			return bson.D{
+				{"_id", 1},
+				{"tags", []interface{}{"a", "b"}},
+				{"items", []interface{}{
+					bson.D{{"name", "x"}, {"qty", 2}},
+					bson.D{{"name", "y"}, {"qty", 3}},
+				}},
+			}
+		}
+
+		Convey("arrays should be written as JSON by default", func() {
+			csvExporter := NewCSVExportOutput([]string{"_id", "tags"}, true, out)
+			csvExporter.ExportDocument(newDocument())
+			csvExporter.Flush()
+			So(out.String(), ShouldEqual, `1,"[""a"",""b""]"`+"\n")
+		})
+
+		Convey("arrays of values should be joined in join mode", func() {
+			csvExporter := NewCSVExportOutput([]string{"_id", "tags", "items"}, true, out)
+			csvExporter.ArrayMode = ArrayModeJoin
+			csvExporter.ArrayDelimiter = "|"
+			csvExporter.ExportDocument(newDocument())
+			csvExporter.Flush()
+			rec, err := csv.NewReader(strings.NewReader(out.String())).Read()
+			So(err, ShouldBeNil)
+			So(rec[:2], ShouldResemble, []string{"1", "a|b"})
+			So(rec[2], ShouldStartWith, "[")
+		})
+
+		Convey("arrays should be unwound in explode mode", func() {
+			csvExporter := NewCSVExportOutput([]string{"_id", "items.name", "items.qty"}, true, out)
+			csvExporter.ArrayMode = ArrayModeExplode
+			csvExporter.ExportDocument(newDocument())
+			csvExporter.Flush()
+			So(out.String(), ShouldEqual, "1,x,2\n1,y,3\n")
+			So(csvExporter.NumExported, ShouldEqual, 1)
+
+			Convey("giving every combination of the elements of several arrays", func() {
+				out.Reset()
+				csvExporter.Fields = []string{"tags", "items.name"}
+				csvExporter.ExportDocument(newDocument())
+				csvExporter.Flush()
+				So(out.String(), ShouldEqual, "a,x\na,y\nb,x\nb,y\n")
+			})
+
+			Convey("keeping documents with empty arrays", func() {
+				out.Reset()
+				csvExporter.Fields = []string{"_id", "tags"}
+				csvExporter.ExportDocument(bson.D{{"_id", 2}, {"tags", []interface{}{}}})
+				csvExporter.Flush()
+				So(out.String(), ShouldEqual, "2,\n")
+			})
+
+			Convey("unless an index is given", func() {
+				out.Reset()
+				csvExporter.Fields = []string{"tags.1", "items.0.name"}
+				csvExporter.ExportDocument(newDocument())
+				csvExporter.Flush()
+				So(out.String(), ShouldEqual, "b,x\n")
+			})
+		})
+	})
+}
+
+func TestCSVColumnScan(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With documents holding arrays and subdocuments", t, func() {
+		documents := []bson.D{
+			{{"_id", 1}, {"tags", []interface{}{"a"}}, {"addr", bson.D{{"city", "x"}}}},
+			{{"_id", 2}, {"tags", []interface{}{"b", "c", "d"}}, {"addr", bson.D{{"city", "y"}, {"zip", 1}}}},
+			{{"_id", 3}, {"addr", "unknown"}},
+		}
+		scanAll := func(scan *columnScan) []string {
+			for _, document := range documents {
+				scan.observe(document)
+			}
+			return scan.columns()
+		}
+
+		Convey("arrays should be expanded into indexed columns in columns mode", func() {
+			scan := newColumnScan([]string{"_id", "tags", "addr", "missing"}, ArrayModeColumns, false)
+			So(scanAll(scan), ShouldResemble,
+				[]string{"_id", "tags.0", "tags.1", "tags.2", "addr", "missing"})
+		})
+
+		Convey("subdocuments should be expanded into dotted fields when flattening", func() {
+			scan := newColumnScan([]string{"addr", "tags"}, ArrayModeJSON, true)
+			So(scanAll(scan), ShouldResemble, []string{"addr", "addr.city", "addr.zip", "tags"})
+		})
+
+		Convey("all fields should be found when flattening without a field list", func() {
+			scan := newColumnScan(nil, ArrayModeColumns, true)
+			So(scanAll(scan), ShouldResemble, []string{
+				"_id", "tags.0", "tags.1", "tags.2", "addr", "addr.city", "addr.zip"})
+		})
+
+		Convey("the subfields of array elements should be found in explode mode", func() {
+			scan := newColumnScan(nil, ArrayModeExplode, true)
+			scan.observe(bson.D{{"items", []interface{}{bson.D{{"name", "x"}}, bson.D{{"qty", 1}}}}})
+			So(scan.columns(), ShouldResemble, []string{"items.name", "items.qty"})
+		})
+	})
+}
+
+func TestArrayModeValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a CSV export", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: CSV, Fields: "a", ArrayMode: ArrayModeJoin, ArrayDelimiter: "|"},
+			InputOpts:  &InputOptions{},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldBeNil)
+
+		Convey("unknown array modes should be rejected", func() {
+			exp.OutputOpts.ArrayMode = "flat"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("an array delimiter should require join mode", func() {
+			exp.OutputOpts.ArrayMode = ArrayModeExplode
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("array options should be rejected for JSON", func() {
+			exp.OutputOpts.Type = JSON
+			exp.OutputOpts.ArrayDelimiter = ""
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+			exp.OutputOpts.ArrayMode = ""
+			exp.OutputOpts.FlattenSubdocuments = true
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestAutoFields(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Discovered fields should sort alphabetically by part", t, func() {
+		columns := []string{"b", "a.tags.10", "a.tags.2", "a", "a.b", "_id"}
+		sortColumns(columns)
+		So(columns, ShouldResemble, []string{"_id", "a", "a.b", "a.tags.2", "a.tags.10", "b"})
+	})
+
+	Convey("With a CSV export discovering its fields", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: CSV, AutoFields: "1000"},
+			InputOpts:  &InputOptions{},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldBeNil)
+		sampleSize, err := exp.OutputOpts.autoFieldsSampleSize()
+		So(err, ShouldBeNil)
+		So(sampleSize, ShouldEqual, 1000)
+
+		Convey("all documents should be scanned if asked", func() {
+			exp.OutputOpts.AutoFields = "all"
+			So(exp.ValidateSettings(), ShouldBeNil)
+			sampleSize, err := exp.OutputOpts.autoFieldsSampleSize()
+			So(err, ShouldBeNil)
+			So(sampleSize, ShouldEqual, 0)
+		})
+
+		Convey("invalid sample sizes should be rejected", func() {
+			exp.OutputOpts.AutoFields = "-5"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("a field list should be rejected", func() {
+			exp.OutputOpts.Fields = "a,b"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("the order should be firstSeen or alphabetical", func() {
+			exp.OutputOpts.AutoFieldsOrder = autoFieldsAlphabetical
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.OutputOpts.AutoFieldsOrder = "random"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("other options should require --autoFields", func() {
+			exp.OutputOpts.AutoFields = ""
+			exp.OutputOpts.Fields = "a"
+			exp.OutputOpts.AutoFieldsFile = "fields.txt"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestAutoFieldsSample(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a CSV export discovering its fields from a sample", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: CSV, AutoFields: "10"},
+			InputOpts:  &InputOptions{},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+
+		Convey("the sample should be drawn from all the documents", func() {
+			pipeline, err := exp.getSamplePipeline(10)
+			So(err, ShouldBeNil)
+			So(pipeline, ShouldResemble, []interface{}{bson.D{{"$sample", bson.D{{"size", 10}}}}})
+		})
+
+		Convey("the sample should be drawn from the documents matching the query", func() {
+			exp.InputOpts.Query = `{"a": 1}`
+			exp.InputOpts.Skip = 5
+			exp.InputOpts.Sort = `{"b": -1}`
+			pipeline, err := exp.getSamplePipeline(10)
+			So(err, ShouldBeNil)
+			So(pipeline, ShouldResemble, []interface{}{
+				bson.D{{"$match", map[string]interface{}{"a": int32(1)}}},
+				bson.D{{"$sort", bson.D{{"b", int32(-1)}}}},
+				bson.D{{"$skip", 5}},
+				bson.D{{"$sample", bson.D{{"size", 10}}}},
+			})
+		})
+
+		Convey("the sample should be drawn from the results of a pipeline", func() {
+			exp.InputOpts.Pipeline = `[{"$unwind": "$a"}]`
+			pipeline, err := exp.getSamplePipeline(10)
+			So(err, ShouldBeNil)
+			So(pipeline, ShouldHaveLength, 2)
+			So(pipeline[1], ShouldResemble, bson.D{{"$sample", bson.D{{"size", 10}}}})
+		})
+	})
+}
+
+func TestAutoFieldsDiscovery(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With a collection whose newest documents have a new field", t, func() {
+		ssl := testutil.GetSSLOptions()
+		auth := testutil.GetAuthOptions()
+		toolOptions := options.ToolOptions{
+			SSL:        &ssl,
+			Connection: &options.Connection{Host: "localhost", Port: db.DefaultTestPort},
+			Auth:       &auth,
+			Verbosity:  &options.Verbosity{},
+			Namespace:  &options.Namespace{DB: "mongoexport_test", Collection: "autofields"},
+		}
+		sessionProvider, err := db.NewSessionProvider(toolOptions)
+		So(err, ShouldBeNil)
+		session, err := sessionProvider.GetSession()
+		So(err, ShouldBeNil)
+		defer session.Close()
+		collection := session.DB("mongoexport_test").C("autofields")
+		collection.DropCollection()
+		defer collection.DropCollection()
+		for i := 0; i < 1000; i++ {
+			document := bson.D{{"_id", i}, {"a", i}}
+			if i >= 100 {
+				document = append(document, bson.DocElem{"added", i})
+			}
+			So(collection.Insert(document), ShouldBeNil)
+		}
+
+		Convey("a field missing from the first documents should be discovered", func() {
+			exp := MongoExport{
+				ToolOptions:     toolOptions,
+				OutputOpts:      &OutputFormatOptions{Type: CSV, AutoFields: "10"},
+				InputOpts:       &InputOptions{},
+				SessionProvider: sessionProvider,
+			}
+			So(exp.ValidateSettings(), ShouldBeNil)
+			So(exp.scanCSVColumns(), ShouldBeNil)
+			So(exp.csvColumns, ShouldContain, "added")
+		})
+	})
+}
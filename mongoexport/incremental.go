@@ -0,0 +1,205 @@
+package mongoexport
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/json"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// incrementalState is the content of the --stateFile of an incremental
+// export: the greatest value of the incremental field that was exported from
+// a namespace.
+type incrementalState struct {
+	namespace string
+	field     string
+	last      interface{}
+}
+
+// isIncrementalValue reports whether value has one of the types that can be
+// used as the watermark of an incremental export: a date, an ObjectId or a
+// number.
+func isIncrementalValue(value interface{}) bool {
+	switch value.(type) {
+	case time.Time, bson.ObjectId, int, int32, int64, float64, bson.Decimal128:
+		return true
+	}
+	return false
+}
+
+// readIncrementalState reads the state of an incremental export from path. It
+// returns nil if the file doesn't exist, as before the first export.
+func readIncrementalState(path string) (*incrementalState, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %v", err)
+	}
+	parsedJSON, err := json.UnmarshalBsonD(content)
+	if err != nil {
+		return nil, fmt.Errorf("state file '%v' is not valid JSON: %v", path, err)
+	}
+	doc, err := bsonutil.GetExtendedBsonD(parsedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("state file '%v' is invalid: %v", path, err)
+	}
+	state := &incrementalState{}
+	for _, elem := range doc {
+		switch elem.Name {
+		case "namespace":
+			state.namespace, _ = elem.Value.(string)
+		case "field":
+			state.field, _ = elem.Value.(string)
+		case "last":
+			state.last = elem.Value
+		}
+	}
+	if state.namespace == "" || state.field == "" || !isIncrementalValue(state.last) {
+		return nil, fmt.Errorf("state file '%v' must have a namespace, a field and "+
+			"a last value that is a date, an ObjectId or a number", path)
+	}
+	return state, nil
+}
+
+// write replaces the file at path with the state. The state is written to a
+// temporary file first, so that an interrupted write leaves the previous
+// state intact.
+func (state *incrementalState) write(path string) error {
+	doc, err := bsonutil.ConvertBSONValueToJSON(bson.D{
+		{"namespace", state.namespace},
+		{"field", state.field},
+		{"last", state.last},
+	})
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err = ioutil.WriteFile(tempPath, append(content, '\n'), 0640); err != nil {
+		return fmt.Errorf("error writing state file: %v", err)
+	}
+	if err = os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("error writing state file: %v", err)
+	}
+	return nil
+}
+
+// isIncremental reports whether the export only exports the documents added
+// or updated since the previous one.
+func (exp *MongoExport) isIncremental() bool {
+	return exp.InputOpts != nil && exp.InputOpts.IncrementalField != ""
+}
+
+// addIncrementalFilter restricts a query to the documents whose incremental
+// field is greater than the one recorded in the state file, if there is one.
+func (exp *MongoExport) addIncrementalFilter(query map[string]interface{}) (map[string]interface{}, error) {
+	namespace := fmt.Sprintf("%v.%v", exp.ToolOptions.Namespace.DB, exp.ToolOptions.Namespace.Collection)
+	field := exp.InputOpts.IncrementalField
+	state, err := readIncrementalState(exp.InputOpts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return query, nil
+	}
+	if state.namespace != namespace || state.field != field {
+		return nil, fmt.Errorf("state file '%v' was written for field '%v' of %v, not '%v' of %v",
+			exp.InputOpts.StateFile, state.field, state.namespace, field, namespace)
+	}
+	filter := map[string]interface{}{field: bson.M{"$gt": state.last}}
+	if len(query) == 0 {
+		return filter, nil
+	}
+	return map[string]interface{}{"$and": []interface{}{query, filter}}, nil
+}
+
+// selectIncremental adds the incremental field to a projection of --fields,
+// so that its values can be recorded. Unless the projection already returns
+// it, the documents it was added to must be passed to stripIncremental
+// before they are exported.
+func (exp *MongoExport) selectIncremental(selector bson.M) {
+	field := exp.InputOpts.IncrementalField
+	top := strings.Split(field, ".")[0]
+	if _, ok := selector[top]; ok {
+		exp.incrementalStrip = ""
+		return
+	}
+	selector[field] = 1
+	exp.incrementalStrip = top
+}
+
+// stripIncremental removes from a document the incremental field that was
+// only projected to record its values.
+func (exp *MongoExport) stripIncremental(document bson.D) bson.D {
+	if exp.incrementalStrip == "" {
+		return document
+	}
+	for i, elem := range document {
+		if elem.Name == exp.incrementalStrip {
+			return append(document[:i], document[i+1:]...)
+		}
+	}
+	return document
+}
+
+// observeIncremental records the value of the incremental field of an exported
+// document. Documents are exported in ascending order of the field, so the
+// last value of a watermark type is the greatest. It is only saved once
+// commitIncremental is called, after the output holding the document is
+// flushed.
+func (exp *MongoExport) observeIncremental(document bson.D) {
+	if !exp.isIncremental() {
+		return
+	}
+	var value interface{} = document
+	for _, name := range strings.Split(exp.InputOpts.IncrementalField, ".") {
+		subdoc, ok := value.(bson.D)
+		if !ok {
+			return
+		}
+		var err error
+		if value, err = bsonutil.FindValueByKey(name, &subdoc); err != nil {
+			return
+		}
+	}
+	if isIncrementalValue(value) {
+		exp.incrementalSeen = value
+	}
+}
+
+// commitIncremental makes the greatest value of the incremental field of the
+// documents exported so far the one saved, once their output is flushed.
+func (exp *MongoExport) commitIncremental() {
+	if exp.incrementalSeen != nil {
+		exp.incrementalLast = exp.incrementalSeen
+	}
+}
+
+// SaveIncrementalState records the greatest value of the incremental field
+// that was exported in the --stateFile, so that the next export starts after
+// it. It must only be called once the output is flushed and closed, and does
+// nothing if no document was exported.
+func (exp *MongoExport) SaveIncrementalState() error {
+	if !exp.isIncremental() || exp.incrementalLast == nil {
+		return nil
+	}
+	state := &incrementalState{
+		namespace: fmt.Sprintf("%v.%v", exp.ToolOptions.Namespace.DB, exp.ToolOptions.Namespace.Collection),
+		field:     exp.InputOpts.IncrementalField,
+		last:      exp.incrementalLast,
+	}
+	return state.write(exp.InputOpts.StateFile)
+}
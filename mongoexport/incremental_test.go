@@ -0,0 +1,238 @@
+package mongoexport
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mongodb/mongo-tools/common/options"
+	"github.com/mongodb/mongo-tools/common/progress"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newIncrementalExport(stateFile string) *MongoExport {
+	exp := &MongoExport{
+		OutputOpts: &OutputFormatOptions{Type: JSON},
+		InputOpts:  &InputOptions{IncrementalField: "meta.updated", StateFile: stateFile},
+	}
+	exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+	exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+	return exp
+}
+
+func TestIncrementalState(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a temporary state file", t, func() {
+		dir, err := ioutil.TempDir("", "mongoexport_incremental")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		stateFile := filepath.Join(dir, "state", "c.json")
+
+		Convey("a missing state file should not filter the query", func() {
+			exp := newIncrementalExport(stateFile)
+			query, err := exp.addIncrementalFilter(map[string]interface{}{"a": 1})
+			So(err, ShouldBeNil)
+			So(query, ShouldResemble, map[string]interface{}{"a": 1})
+		})
+
+		Convey("nothing should be saved if no document was exported", func() {
+			exp := newIncrementalExport(stateFile)
+			So(exp.SaveIncrementalState(), ShouldBeNil)
+			_, err := os.Stat(stateFile)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("a saved date should be read back", func() {
+			last := time.Unix(1500000000, 0)
+			exp := newIncrementalExport(stateFile)
+			exp.observeIncremental(bson.D{{"meta", bson.D{{"updated", last}}}})
+			exp.commitIncremental()
+			So(exp.SaveIncrementalState(), ShouldBeNil)
+
+			state, err := readIncrementalState(stateFile)
+			So(err, ShouldBeNil)
+			So(state.namespace, ShouldEqual, "db.c")
+			So(state.field, ShouldEqual, "meta.updated")
+			So(state.last.(time.Time).Equal(last), ShouldBeTrue)
+
+			Convey("and filter the next export", func() {
+				exp := newIncrementalExport(stateFile)
+				query, err := exp.addIncrementalFilter(nil)
+				So(err, ShouldBeNil)
+				filter := query["meta.updated"].(bson.M)
+				So(filter["$gt"].(time.Time).Equal(last), ShouldBeTrue)
+
+				query, err = exp.addIncrementalFilter(map[string]interface{}{"a": 1})
+				So(err, ShouldBeNil)
+				So(query["$and"], ShouldHaveLength, 2)
+			})
+
+			Convey("and be rejected for another namespace", func() {
+				exp := newIncrementalExport(stateFile)
+				exp.ToolOptions.Namespace.Collection = "other"
+				_, err := exp.addIncrementalFilter(nil)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("a saved ObjectId should be read back", func() {
+			last := bson.NewObjectId()
+			exp := newIncrementalExport(stateFile)
+			exp.InputOpts.IncrementalField = "_id"
+			exp.observeIncremental(bson.D{{"_id", last}})
+			exp.commitIncremental()
+			So(exp.SaveIncrementalState(), ShouldBeNil)
+
+			state, err := readIncrementalState(stateFile)
+			So(err, ShouldBeNil)
+			So(state.last, ShouldEqual, last)
+		})
+
+		Convey("an invalid state file should be rejected", func() {
+			So(os.MkdirAll(filepath.Dir(stateFile), 0750), ShouldBeNil)
+			So(ioutil.WriteFile(stateFile, []byte(`{"namespace": "db.c"}`), 0640), ShouldBeNil)
+			_, err := readIncrementalState(stateFile)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Values that can't be compared in order should not be observed", t, func() {
+		exp := newIncrementalExport("state.json")
+		exp.observeIncremental(bson.D{{"meta", bson.D{{"updated", "yesterday"}}}})
+		So(exp.incrementalSeen, ShouldBeNil)
+		exp.observeIncremental(bson.D{{"meta", "missing"}})
+		So(exp.incrementalSeen, ShouldBeNil)
+	})
+}
+
+func TestIncrementalFields(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With an incremental export of --fields without the incremental field", t, func() {
+		exp := newIncrementalExport("state.json")
+		exp.OutputOpts.Fields = "a"
+		selector := makeFieldSelector(exp.OutputOpts.Fields)
+		exp.selectIncremental(selector)
+
+		Convey("the incremental field should be projected", func() {
+			So(selector, ShouldResemble, bson.M{"_id": 1, "a": 1, "meta.updated": 1})
+		})
+
+		Convey("its values should be recorded but not exported", func() {
+			last := time.Unix(1500000000, 0)
+			document := bson.D{{"_id", 1}, {"a", 2}, {"meta", bson.D{{"updated", last}}}}
+			exp.observeIncremental(document)
+			document = exp.stripIncremental(document)
+			So(exp.incrementalSeen.(time.Time).Equal(last), ShouldBeTrue)
+
+			out := &bytes.Buffer{}
+			output := NewJSONExportOutput(false, false, out)
+			So(output.ExportDocument(document), ShouldBeNil)
+			So(output.Flush(), ShouldBeNil)
+			So(out.String(), ShouldEqual, `{"_id":1,"a":2}`+"\n")
+		})
+	})
+
+	Convey("With an incremental export of --fields with the incremental field", t, func() {
+		exp := newIncrementalExport("state.json")
+		exp.OutputOpts.Fields = "a,meta"
+		selector := makeFieldSelector(exp.OutputOpts.Fields)
+		exp.selectIncremental(selector)
+
+		Convey("the documents should be exported as they were selected", func() {
+			document := bson.D{{"a", 2}, {"meta", bson.D{{"updated", 3}}}}
+			So(exp.stripIncremental(document), ShouldResemble, document)
+		})
+	})
+}
+
+// documentSlice is a cursor over documents in memory.
+type documentSlice []bson.D
+
+func (documents *documentSlice) Next(result interface{}) bool {
+	if len(*documents) == 0 {
+		return false
+	}
+	*result.(*bson.D) = (*documents)[0]
+	*documents = (*documents)[1:]
+	return true
+}
+
+func (documents *documentSlice) Err() error {
+	return nil
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestIncrementalFlush(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With an incremental export and a temporary state file", t, func() {
+		dir, err := ioutil.TempDir("", "mongoexport_incremental")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		stateFile := filepath.Join(dir, "c.json")
+		exp := newIncrementalExport(stateFile)
+		exp.OutputOpts.Type = CSV
+		documents := documentSlice{{{"meta", bson.D{{"updated", time.Unix(1500000000, 0)}}}}}
+
+		Convey("the state should not be saved if the output can't be flushed", func() {
+			output := NewCSVExportOutput([]string{"meta.updated"}, false, failingWriter{})
+			_, err := exp.writeDocuments(&documents, output, progress.NewCounter(1))
+			So(err, ShouldNotBeNil)
+			So(exp.SaveIncrementalState(), ShouldBeNil)
+			_, err = os.Stat(stateFile)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("the state should be saved once the output is flushed", func() {
+			output := NewCSVExportOutput([]string{"meta.updated"}, false, &bytes.Buffer{})
+			count, err := exp.writeDocuments(&documents, output, progress.NewCounter(1))
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			So(exp.SaveIncrementalState(), ShouldBeNil)
+			_, err = os.Stat(stateFile)
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestIncrementalValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With an incremental export", t, func() {
+		exp := newIncrementalExport("state.json")
+		So(exp.ValidateSettings(), ShouldBeNil)
+
+		Convey("a state file should be required", func() {
+			exp.InputOpts.StateFile = ""
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("an incremental field should be required", func() {
+			exp.InputOpts.IncrementalField = ""
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("options that change the order of the documents should be rejected", func() {
+			exp.InputOpts.Sort = `{"a": 1}`
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("--skip should be rejected", func() {
+			exp.InputOpts.Skip = 10
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+}
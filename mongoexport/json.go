@@ -20,6 +20,9 @@ type JSONExportOutput struct {
 	Encoder      *json.Encoder
 	Out          io.Writer
 	NumExported  int64
+	// JSONFormat is the extended JSON format in which documents are written,
+	// legacy if empty.
+	JSONFormat string
 }
 
 // NewJSONExportOutput creates a new JSONExportOutput in array mode if specified,
@@ -31,6 +34,7 @@ func NewJSONExportOutput(arrayOutput bool, prettyOutput bool, out io.Writer) *JS
 		json.NewEncoder(out),
 		out,
 		0,
+		bsonutil.LegacyJSONFormat,
 	}
 }
 
@@ -80,7 +84,7 @@ func (jsonExporter *JSONExportOutput) ExportDocument(document bson.D) error {
 				jsonExporter.Out.Write([]byte("\n"))
 			}
 		}
-		extendedDoc, err := bsonutil.ConvertBSONValueToJSON(document)
+		extendedDoc, err := bsonutil.ConvertBSONValueToExtendedJSON(document, jsonExporter.JSONFormat)
 		if err != nil {
 			return err
 		}
@@ -95,7 +99,7 @@ func (jsonExporter *JSONExportOutput) ExportDocument(document bson.D) error {
 		}
 		jsonExporter.Out.Write(jsonOut)
 	} else {
-		extendedDoc, err := bsonutil.ConvertBSONValueToJSON(document)
+		extendedDoc, err := bsonutil.ConvertBSONValueToExtendedJSON(document, jsonExporter.JSONFormat)
 		if err != nil {
 			return err
 		}
@@ -2,6 +2,7 @@ package mongoexport
 
 import (
 	"bytes"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
 	"github.com/mongodb/mongo-tools/common/json"
 	"github.com/mongodb/mongo-tools/common/testutil"
 	. "github.com/smartystreets/goconvey/convey"
@@ -29,6 +30,17 @@ func TestWriteJSON(t *testing.T) {
 				So(out.String(), ShouldEqual, `{"_id":{"$oid":"`+objId.Hex()+`"}}`+"\n")
 			})
 
+			Convey("Canonical Extended JSON should preserve numeric types", func() {
+				jsonExporter := NewJSONExportOutput(false, false, out)
+				jsonExporter.JSONFormat = bsonutil.CanonicalJSONFormat
+				err := jsonExporter.ExportDocument(bson.D{{"a", int32(1)}, {"b", int64(2)}, {"c", 1.5}})
+				So(err, ShouldBeNil)
+				err = jsonExporter.WriteFooter()
+				So(err, ShouldBeNil)
+				So(out.String(), ShouldEqual,
+					`{"a":{"$numberInt":"1"},"b":{"$numberLong":"2"},"c":{"$numberDouble":"1.5"}}`+"\n")
+			})
+
 			Reset(func() {
 				out.Reset()
 			})
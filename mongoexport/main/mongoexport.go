@@ -10,6 +10,7 @@ import (
 	"github.com/mongodb/mongo-tools/mongoexport"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"io"
 	"os"
 )
 
@@ -117,19 +118,32 @@ func main() {
 		os.Exit(util.ExitBadOptions)
 	}
 
-	writer, err := exporter.GetOutputWriter()
+	var numDocs int64
+	if outputOpts.SplitOutput {
+		numDocs, err = exporter.ExportSplit()
+	} else {
+		var writer io.WriteCloser
+		writer, err = exporter.GetOutputWriter()
+		if err != nil {
+			log.Logf(log.Always, "error opening output stream: %v", err)
+			os.Exit(util.ExitError)
+		}
+		if writer == nil {
+			numDocs, err = exporter.Export(os.Stdout)
+		} else {
+			numDocs, err = exporter.Export(writer)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
 	if err != nil {
-		log.Logf(log.Always, "error opening output stream: %v", err)
+		log.Logf(log.Always, "Failed: %v", err)
 		os.Exit(util.ExitError)
 	}
-	if writer == nil {
-		writer = os.Stdout
-	} else {
-		defer writer.Close()
-	}
 
-	numDocs, err := exporter.Export(writer)
-	if err != nil {
+	// the output is complete, so the next incremental export can start after it
+	if err = exporter.SaveIncrementalState(); err != nil {
 		log.Logf(log.Always, "Failed: %v", err)
 		os.Exit(util.ExitError)
 	}
@@ -1,4 +1,4 @@
-// Package mongoexport produces a JSON or CSV export of data stored in a MongoDB instance.
+// Package mongoexport produces a JSON, CSV, SQL or Parquet export of data stored in a MongoDB instance.
 package mongoexport
 
 import (
@@ -9,6 +9,7 @@ import (
 	"github.com/mongodb/mongo-tools/common/log"
 	"github.com/mongodb/mongo-tools/common/options"
 	"github.com/mongodb/mongo-tools/common/progress"
+	"github.com/mongodb/mongo-tools/common/text"
 	"github.com/mongodb/mongo-tools/common/util"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -23,6 +24,8 @@ import (
 const (
 	CSV                            = "csv"
 	JSON                           = "json"
+	SQL                            = "sql"
+	Parquet                        = "parquet"
 	progressBarLength              = 24
 	progressBarWaitTime            = time.Second
 	watchProgressorUpdateFrequency = 8000
@@ -42,6 +45,28 @@ type MongoExport struct {
 	// for connecting to the db
 	SessionProvider *db.SessionProvider
 	ExportOutput    ExportOutput
+
+	// csvColumns, if set, are the CSV fields found by scanning the documents
+	csvColumns []string
+
+	// sqlTypes, if set, are the types of the SQL columns inferred by scanning
+	// the documents
+	sqlTypes []sqlType
+
+	// parquetSchema, if set, is the schema of the Parquet output, read from
+	// --parquetSchemaFile or inferred by scanning the documents
+	parquetSchema *parquetSchema
+
+	// incrementalLast is the greatest value of the incremental field of the
+	// documents whose output was flushed, and incrementalSeen that of the
+	// documents exported so far
+	incrementalLast interface{}
+	incrementalSeen interface{}
+
+	// incrementalStrip, if set, is the top-level field removed from the
+	// documents exported, which holds the incremental field but was not
+	// selected by --fields
+	incrementalStrip string
 }
 
 // ExportOutput is an interface that specifies how a document should be formatted
@@ -90,8 +115,71 @@ func (exp *MongoExport) ValidateSettings() error {
 		// special error for an empty type value
 		return fmt.Errorf("--type cannot be empty")
 	}
-	if exp.OutputOpts.Type != CSV && exp.OutputOpts.Type != JSON {
-		return fmt.Errorf("invalid output type '%v', choose 'json' or 'csv'", exp.OutputOpts.Type)
+	switch exp.OutputOpts.Type {
+	case CSV, JSON, SQL, Parquet:
+	default:
+		return fmt.Errorf("invalid output type '%v', choose 'json', 'csv', 'sql' or 'parquet'", exp.OutputOpts.Type)
+	}
+
+	if exp.OutputOpts.Type == CSV {
+		if _, _, _, _, err := exp.OutputOpts.csvDialect(); err != nil {
+			return err
+		}
+	} else if option := exp.OutputOpts.dialectOption(); option != "" {
+		return fmt.Errorf("can only use --%v when output type is CSV", option)
+	}
+
+	if exp.OutputOpts.Type == CSV {
+		switch exp.OutputOpts.ArrayMode {
+		case "", ArrayModeJSON, ArrayModeJoin, ArrayModeExplode, ArrayModeColumns:
+		default:
+			return fmt.Errorf("invalid array mode '%v', choose one of: %v, %v, %v, %v", exp.OutputOpts.ArrayMode,
+				ArrayModeJSON, ArrayModeJoin, ArrayModeExplode, ArrayModeColumns)
+		}
+		if exp.OutputOpts.ArrayDelimiter != "" && exp.OutputOpts.ArrayMode != ArrayModeJoin {
+			return fmt.Errorf("can only use --arrayDelimiter with --arrayMode=join")
+		}
+		if err := exp.validateAutoFields(); err != nil {
+			return err
+		}
+	} else {
+		switch {
+		case exp.OutputOpts.ArrayMode != "":
+			return fmt.Errorf("can only use --arrayMode when output type is CSV")
+		case exp.OutputOpts.ArrayDelimiter != "":
+			return fmt.Errorf("can only use --arrayDelimiter when output type is CSV")
+		case exp.OutputOpts.FlattenSubdocuments:
+			return fmt.Errorf("can only use --flattenSubdocuments when output type is CSV")
+		case exp.OutputOpts.AutoFields != "":
+			return fmt.Errorf("can only use --autoFields when output type is CSV")
+		}
+	}
+
+	if exp.OutputOpts.Type == SQL {
+		if err := exp.validateSQLSettings(); err != nil {
+			return err
+		}
+	} else if option := exp.OutputOpts.sqlOption(); option != "" {
+		return fmt.Errorf("can only use --%v when output type is SQL", option)
+	}
+
+	if exp.OutputOpts.Type == Parquet {
+		if err := exp.validateParquetSettings(); err != nil {
+			return err
+		}
+	} else if option := exp.OutputOpts.parquetOption(); option != "" {
+		return fmt.Errorf("can only use --%v when output type is Parquet", option)
+	}
+
+	if exp.OutputOpts.JSONFormat != "" && exp.OutputOpts.Type != JSON {
+		return fmt.Errorf("can only use --jsonFormat when output type is JSON")
+	}
+	if err := bsonutil.ValidateJSONFormat(exp.OutputOpts.JSONFormat); err != nil {
+		return err
+	}
+
+	if _, err := text.ParseEncoding(exp.OutputOpts.Encoding); err != nil {
+		return err
 	}
 
 	if exp.InputOpts.Query != "" && exp.InputOpts.ForceTableScan {
@@ -119,6 +207,163 @@ func (exp *MongoExport) ValidateSettings() error {
 			return err
 		}
 	}
+
+	if exp.InputOpts != nil && exp.InputOpts.HasPipeline() {
+		if exp.InputOpts.Pipeline != "" && exp.InputOpts.PipelineFile != "" {
+			return fmt.Errorf("either --pipeline or --pipelineFile can be specified as a pipeline option")
+		}
+		// the pipeline replaces the query, and must include any equivalent stages
+		switch {
+		case exp.InputOpts.HasQuery():
+			return fmt.Errorf("cannot use --query or --queryFile with an aggregation pipeline; use a $match stage instead")
+		case exp.InputOpts.Sort != "":
+			return fmt.Errorf("cannot use --sort with an aggregation pipeline; use a $sort stage instead")
+		case exp.InputOpts.Skip != 0:
+			return fmt.Errorf("cannot use --skip with an aggregation pipeline; use a $skip stage instead")
+		case exp.InputOpts.Limit != 0:
+			return fmt.Errorf("cannot use --limit with an aggregation pipeline; use a $limit stage instead")
+		case exp.InputOpts.ForceTableScan:
+			return fmt.Errorf("cannot use --forceTableScan with an aggregation pipeline")
+		}
+		content, err := exp.InputOpts.GetPipeline()
+		if err != nil {
+			return err
+		}
+		if _, err = getPipelineFromArg(content); err != nil {
+			return err
+		}
+	}
+
+	if err := exp.validateIncrementalSettings(); err != nil {
+		return err
+	}
+	return exp.validateSplitSettings()
+}
+
+// validateIncrementalSettings returns an error if the options controlling
+// incremental exports are invalid.
+func (exp *MongoExport) validateIncrementalSettings() error {
+	if exp.InputOpts == nil || (exp.InputOpts.IncrementalField == "" && exp.InputOpts.StateFile == "") {
+		return nil
+	}
+	// documents are exported in order of the incremental field
+	switch {
+	case exp.InputOpts.IncrementalField == "":
+		return fmt.Errorf("--stateFile requires --incrementalField")
+	case exp.InputOpts.StateFile == "":
+		return fmt.Errorf("--incrementalField requires --stateFile")
+	case exp.InputOpts.HasPipeline():
+		return fmt.Errorf("cannot use --incrementalField with an aggregation pipeline")
+	case exp.InputOpts.Sort != "":
+		return fmt.Errorf("cannot use --sort with --incrementalField")
+	case exp.InputOpts.Skip != 0:
+		return fmt.Errorf("cannot use --skip with --incrementalField")
+	case exp.InputOpts.NumParallelReaders > 1:
+		return fmt.Errorf("cannot use --numParallelReaders with --incrementalField")
+	}
+	return nil
+}
+
+// validateParquetSettings returns an error if the options controlling the
+// Parquet output are invalid.
+func (exp *MongoExport) validateParquetSettings() error {
+	if exp.OutputOpts.Encoding != "" {
+		return fmt.Errorf("cannot use --encoding when output type is Parquet")
+	}
+	if exp.OutputOpts.ParquetSchemaFile != "" && (exp.OutputOpts.Fields != "" || exp.OutputOpts.FieldFile != "") {
+		return fmt.Errorf("cannot use --fields or --fieldFile with --parquetSchemaFile")
+	}
+	if exp.OutputOpts.ParquetCompression != "" {
+		if _, ok := parquetCodecs[exp.OutputOpts.ParquetCompression]; !ok {
+			return fmt.Errorf("invalid Parquet compression '%v', choose one of: %v, %v, %v",
+				exp.OutputOpts.ParquetCompression, ParquetCompressionNone, ParquetCompressionSnappy,
+				ParquetCompressionGzip)
+		}
+	}
+	if exp.OutputOpts.ParquetRowGroupSize < 0 {
+		return fmt.Errorf("--parquetRowGroupSize must be positive")
+	}
+	_, err := exp.OutputOpts.parquetSampleSize()
+	return err
+}
+
+// validateSQLSettings returns an error if the options controlling the SQL
+// output are invalid.
+func (exp *MongoExport) validateSQLSettings() error {
+	switch exp.OutputOpts.SQLDialect {
+	case "", SQLDialectPostgres, SQLDialectMySQL, SQLDialectSQLite:
+	default:
+		return fmt.Errorf("invalid SQL dialect '%v', choose one of: %v, %v, %v", exp.OutputOpts.SQLDialect,
+			SQLDialectPostgres, SQLDialectMySQL, SQLDialectSQLite)
+	}
+	if exp.OutputOpts.SQLBatchSize < 0 {
+		return fmt.Errorf("--sqlBatchSize must be positive")
+	}
+	_, err := exp.OutputOpts.sqlSampleSize()
+	return err
+}
+
+// validateAutoFields returns an error if the options controlling the discovery
+// of CSV fields are invalid.
+func (exp *MongoExport) validateAutoFields() error {
+	if exp.OutputOpts.AutoFields == "" {
+		if exp.OutputOpts.AutoFieldsOrder != "" || exp.OutputOpts.AutoFieldsFile != "" {
+			return fmt.Errorf("--autoFieldsOrder and --autoFieldsFile require --autoFields")
+		}
+		return nil
+	}
+	if exp.OutputOpts.Fields != "" || exp.OutputOpts.FieldFile != "" {
+		return fmt.Errorf("cannot use --autoFields with --fields or --fieldFile")
+	}
+	if _, err := exp.OutputOpts.autoFieldsSampleSize(); err != nil {
+		return err
+	}
+	switch exp.OutputOpts.AutoFieldsOrder {
+	case "", autoFieldsFirstSeen, autoFieldsAlphabetical:
+	default:
+		return fmt.Errorf("invalid --autoFieldsOrder '%v', choose %v or %v",
+			exp.OutputOpts.AutoFieldsOrder, autoFieldsFirstSeen, autoFieldsAlphabetical)
+	}
+	return nil
+}
+
+// validateSplitSettings returns an error if the options controlling parallel
+// and split exports are invalid.
+func (exp *MongoExport) validateSplitSettings() error {
+	if exp.OutputOpts.MaxDocsPerFile < 0 {
+		return fmt.Errorf("--maxDocsPerFile can not be negative")
+	}
+	if exp.OutputOpts.MaxDocsPerFile != 0 && !exp.OutputOpts.SplitOutput {
+		return fmt.Errorf("--maxDocsPerFile requires --splitOutput")
+	}
+	if exp.OutputOpts.SplitOutput && exp.OutputOpts.OutputFile == "" {
+		return fmt.Errorf("--splitOutput requires --out to name the output files")
+	}
+	if exp.InputOpts == nil {
+		return nil
+	}
+	if exp.InputOpts.NumParallelReaders < 0 {
+		return fmt.Errorf("--numParallelReaders can not be negative")
+	}
+	if exp.InputOpts.NumParallelReaders <= 1 {
+		if exp.InputOpts.PartitionField != "" {
+			return fmt.Errorf("--partitionField requires --numParallelReaders greater than 1")
+		}
+		return nil
+	}
+	// each reader exports a range of the partition field in its own files
+	switch {
+	case !exp.OutputOpts.SplitOutput:
+		return fmt.Errorf("--numParallelReaders requires --splitOutput")
+	case exp.InputOpts.HasPipeline():
+		return fmt.Errorf("cannot use --numParallelReaders with an aggregation pipeline")
+	case exp.InputOpts.Sort != "":
+		return fmt.Errorf("cannot use --sort with --numParallelReaders")
+	case exp.InputOpts.Skip != 0:
+		return fmt.Errorf("cannot use --skip with --numParallelReaders")
+	case exp.InputOpts.Limit != 0:
+		return fmt.Errorf("cannot use --limit with --numParallelReaders")
+	}
 	return nil
 }
 
@@ -169,8 +414,10 @@ func makeFieldSelector(fields string) bson.M {
 // getCount returns an estimate of how many documents the cursor will fetch
 // It always returns Limit if there is a limit, assuming that in general
 // limits will less then the total possible.
-// If there is a query and no limit then it returns 0, because it's too expensive to count the query.
-// Otherwise it returns the count minus the skip
+// If there is a query, an aggregation pipeline or an incremental field and no limit,
+// then it returns 0, because it's too expensive to count the results.
+// Otherwise it returns the count minus the skip, or 0 if the namespace is a
+// view that can't be counted.
 func (exp *MongoExport) getCount() (c int, err error) {
 	session, err := exp.SessionProvider.GetSession()
 	if err != nil {
@@ -180,13 +427,19 @@ func (exp *MongoExport) getCount() (c int, err error) {
 	if exp.InputOpts != nil && exp.InputOpts.Limit != 0 {
 		return exp.InputOpts.Limit, nil
 	}
-	if exp.InputOpts != nil && exp.InputOpts.Query != "" {
+	if exp.InputOpts != nil && (exp.InputOpts.Query != "" || exp.InputOpts.HasPipeline() || exp.isIncremental()) {
 		return 0, nil
 	}
 	q := session.DB(exp.ToolOptions.Namespace.DB).C(exp.ToolOptions.Namespace.Collection).Find(nil)
 	c, err = q.Count()
+	if db.IsNotSupportedOnView(err) {
+		// the export can proceed without an estimate, with the progress bar
+		// only showing the count so far
+		log.Logf(log.Info, "unable to count the documents to export: %v", err)
+		return 0, nil
+	}
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("error counting the documents to export: %v", err)
 	}
 	var skip int
 	if exp.InputOpts != nil {
@@ -200,10 +453,27 @@ func (exp *MongoExport) getCount() (c int, err error) {
 	return c, nil
 }
 
+// getQuery returns the query filter given to mongoexport, which is empty if
+// there is none.
+func (exp *MongoExport) getQuery() (map[string]interface{}, error) {
+	if exp.InputOpts == nil || !exp.InputOpts.HasQuery() {
+		return map[string]interface{}{}, nil
+	}
+	content, err := exp.InputOpts.GetQuery()
+	if err != nil {
+		return nil, err
+	}
+	return getObjectFromByteArg(content)
+}
+
 // getCursor returns a cursor that can be iterated over to get all the documents
 // to export, based on the options given to mongoexport. Also returns the
 // associated session, so that it can be closed once the cursor is used up.
 func (exp *MongoExport) getCursor() (*mgo.Iter, *mgo.Session, error) {
+	if exp.InputOpts != nil && exp.InputOpts.HasPipeline() {
+		return exp.getPipelineCursor()
+	}
+
 	sortFields := []string{}
 	if exp.InputOpts != nil && exp.InputOpts.Sort != "" {
 		sortD, err := getSortFromArg(exp.InputOpts.Sort)
@@ -216,21 +486,19 @@ func (exp *MongoExport) getCursor() (*mgo.Iter, *mgo.Session, error) {
 		}
 	}
 
-	query := map[string]interface{}{}
-	if exp.InputOpts != nil && exp.InputOpts.HasQuery() {
-		var err error
-		content, err := exp.InputOpts.GetQuery()
-		if err != nil {
-			return nil, nil, err
-		}
-		query, err = getObjectFromByteArg(content)
-		if err != nil {
+	query, err := exp.getQuery()
+	if err != nil {
+		return nil, nil, err
+	}
+	if exp.isIncremental() {
+		if query, err = exp.addIncrementalFilter(query); err != nil {
 			return nil, nil, err
 		}
+		sortFields = []string{exp.InputOpts.IncrementalField}
 	}
 
 	flags := 0
-	if len(query) == 0 && exp.InputOpts != nil &&
+	if len(query) == 0 && exp.InputOpts != nil && !exp.isIncremental() &&
 		exp.InputOpts.ForceTableScan != true && exp.InputOpts.Sort == "" {
 		flags = flags | db.Snapshot
 	}
@@ -255,7 +523,12 @@ func (exp *MongoExport) getCursor() (*mgo.Iter, *mgo.Session, error) {
 		Skip(skip).Limit(limit)
 
 	if len(exp.OutputOpts.Fields) > 0 {
-		q.Select(makeFieldSelector(exp.OutputOpts.Fields))
+		selector := makeFieldSelector(exp.OutputOpts.Fields)
+		if exp.isIncremental() {
+			// the incremental field is needed to record the state
+			exp.selectIncremental(selector)
+		}
+		q.Select(selector)
 	}
 
 	q = db.ApplyFlags(q, session, flags)
@@ -264,6 +537,94 @@ func (exp *MongoExport) getCursor() (*mgo.Iter, *mgo.Session, error) {
 
 }
 
+// getSamplePipeline returns the aggregation pipeline drawing a random sample
+// of the given size from the documents to export.
+func (exp *MongoExport) getSamplePipeline(size int) ([]interface{}, error) {
+	sample := bson.D{{"$sample", bson.D{{"size", size}}}}
+	if exp.InputOpts != nil && exp.InputOpts.HasPipeline() {
+		content, err := exp.InputOpts.GetPipeline()
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := getPipelineFromArg(content)
+		if err != nil {
+			return nil, err
+		}
+		return append(pipeline, sample), nil
+	}
+
+	query, err := exp.getQuery()
+	if err != nil {
+		return nil, err
+	}
+	if exp.isIncremental() {
+		if query, err = exp.addIncrementalFilter(query); err != nil {
+			return nil, err
+		}
+	}
+	pipeline := []interface{}{}
+	if len(query) != 0 {
+		pipeline = append(pipeline, bson.D{{"$match", query}})
+	}
+	// the order only matters to the documents skipped or left out by a limit
+	if exp.InputOpts != nil && (exp.InputOpts.Skip > 0 || exp.InputOpts.Limit > 0) {
+		if exp.InputOpts.Sort != "" {
+			sort, err := getSortFromArg(exp.InputOpts.Sort)
+			if err != nil {
+				return nil, err
+			}
+			pipeline = append(pipeline, bson.D{{"$sort", sort}})
+		}
+		if exp.InputOpts.Skip > 0 {
+			pipeline = append(pipeline, bson.D{{"$skip", exp.InputOpts.Skip}})
+		}
+		if exp.InputOpts.Limit > 0 {
+			pipeline = append(pipeline, bson.D{{"$limit", exp.InputOpts.Limit}})
+		}
+	}
+	return append(pipeline, sample), nil
+}
+
+// getSampleCursor returns a cursor over a random sample of the given size of
+// the documents to export, along with the associated session.
+func (exp *MongoExport) getSampleCursor(size int) (*mgo.Iter, *mgo.Session, error) {
+	pipeline, err := exp.getSamplePipeline(size)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := exp.SessionProvider.GetSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	pipe := session.DB(exp.ToolOptions.Namespace.DB).
+		C(exp.ToolOptions.Namespace.Collection).Pipe(pipeline).AllowDiskUse()
+	return pipe.Iter(), session, nil
+}
+
+// getPipelineCursor returns a cursor over the results of the aggregation
+// pipeline given to mongoexport, along with the associated session. Stages
+// may use temporary files on the server, so that large results can be sorted
+// or grouped.
+func (exp *MongoExport) getPipelineCursor() (*mgo.Iter, *mgo.Session, error) {
+	content, err := exp.InputOpts.GetPipeline()
+	if err != nil {
+		return nil, nil, err
+	}
+	pipeline, err := getPipelineFromArg(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := exp.SessionProvider.GetSession()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pipe := session.DB(exp.ToolOptions.Namespace.DB).
+		C(exp.ToolOptions.Namespace.Collection).Pipe(pipeline).AllowDiskUse()
+	return pipe.Iter(), session, nil
+}
+
 // Internal function that handles exporting to the given writer. Used primarily
 // for testing, because it bypasses writing to the file system.
 func (exp *MongoExport) exportInternal(out io.Writer) (int64, error) {
@@ -273,6 +634,16 @@ func (exp *MongoExport) exportInternal(out io.Writer) (int64, error) {
 		return 0, err
 	}
 
+	if err := exp.scanCSVColumns(); err != nil {
+		return 0, err
+	}
+	if err := exp.scanSQLColumns(); err != nil {
+		return 0, err
+	}
+	if err := exp.scanParquetSchema(); err != nil {
+		return 0, err
+	}
+
 	progressManager := progress.NewProgressBarManager(log.Writer(0), progressBarWaitTime)
 	progressManager.Start()
 	defer progressManager.Stop()
@@ -307,8 +678,23 @@ func (exp *MongoExport) exportInternal(out io.Writer) (int64, error) {
 	}
 	log.Logf(log.Always, "connected to: %v", connURL)
 
+	return exp.writeDocuments(cursor, exportOutput, watchProgressor)
+}
+
+// documentCursor is the part of *mgo.Iter through which the documents to
+// export are read.
+type documentCursor interface {
+	Next(result interface{}) bool
+	Err() error
+}
+
+// writeDocuments writes the documents read from the cursor to the output,
+// followed by its footer, and flushes it. It returns the number of documents
+// exported.
+func (exp *MongoExport) writeDocuments(cursor documentCursor, exportOutput ExportOutput,
+	watchProgressor progress.Updateable) (int64, error) {
 	// Write headers
-	err = exportOutput.WriteHeader()
+	err := exportOutput.WriteHeader()
 	if err != nil {
 		return 0, err
 	}
@@ -319,6 +705,9 @@ func (exp *MongoExport) exportInternal(out io.Writer) (int64, error) {
 
 	// Write document content
 	for cursor.Next(&result) {
+		// exporting a document converts its values in place
+		exp.observeIncremental(result)
+		result = exp.stripIncremental(result)
 		err := exportOutput.ExportDocument(result)
 		if err != nil {
 			return docsCount, err
@@ -338,7 +727,10 @@ func (exp *MongoExport) exportInternal(out io.Writer) (int64, error) {
 	if err != nil {
 		return docsCount, err
 	}
-	exportOutput.Flush()
+	if err = exportOutput.Flush(); err != nil {
+		return docsCount, err
+	}
+	exp.commitIncremental()
 	return docsCount, nil
 }
 
@@ -354,34 +746,174 @@ func (exp *MongoExport) Export(out io.Writer) (int64, error) {
 // transforming BSON documents into the appropriate output format and writing
 // them to an output stream.
 func (exp *MongoExport) getExportOutput(out io.Writer) (ExportOutput, error) {
+	if exp.OutputOpts.Type == Parquet {
+		// Parquet files are binary, so the output isn't encoded
+		if exp.parquetSchema == nil {
+			return nil, fmt.Errorf("Parquet mode requires a schema")
+		}
+		parquetOutput := NewParquetExportOutput(exp.parquetSchema, out)
+		compression := exp.OutputOpts.ParquetCompression
+		if compression == "" {
+			compression = ParquetCompressionSnappy
+		}
+		parquetOutput.writer.Codec = parquetCodecs[compression]
+		rowGroupSize := exp.OutputOpts.ParquetRowGroupSize
+		if rowGroupSize == 0 {
+			rowGroupSize = defaultParquetRowGroupSize
+		}
+		parquetOutput.writer.RowGroupSize = int64(rowGroupSize) * 1024 * 1024
+		parquetOutput.writer.CreatedBy = "mongoexport"
+		return parquetOutput, nil
+	}
+	out, err := text.NewEncodingWriter(out, exp.OutputOpts.Encoding)
+	if err != nil {
+		return nil, err
+	}
 	if exp.OutputOpts.Type == CSV {
-		// TODO what if user specifies *both* --fields and --fieldFile?
-		var fields []string
-		var err error
-		if len(exp.OutputOpts.Fields) > 0 {
-			fields = strings.Split(exp.OutputOpts.Fields, ",")
-		} else if exp.OutputOpts.FieldFile != "" {
-			fields, err = util.GetFieldsFromFile(exp.OutputOpts.FieldFile)
-			if err != nil {
+		exportFields := exp.csvColumns
+		if exportFields == nil {
+			var err error
+			if exportFields, err = exp.getFields(); err != nil {
 				return nil, err
 			}
+			if len(exportFields) == 0 {
+				return nil, fmt.Errorf("CSV mode requires a field list")
+			}
+		}
+
+		delimiter, quote, escape, lineTerminator, err := exp.OutputOpts.csvDialect()
+		if err != nil {
+			return nil, err
+		}
+		csvOutput := NewCSVExportOutput(exportFields, exp.OutputOpts.NoHeaderLine, out)
+		csvOutput.setDialect(delimiter, quote, escape, lineTerminator)
+		csvOutput.ArrayMode = exp.OutputOpts.ArrayMode
+		csvOutput.ArrayDelimiter = exp.OutputOpts.ArrayDelimiter
+		if csvOutput.ArrayDelimiter == "" {
+			csvOutput.ArrayDelimiter = defaultArrayDelimiter
+		}
+		return csvOutput, nil
+	}
+	if exp.OutputOpts.Type == SQL {
+		fields, err := exp.getFields()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("SQL mode requires a field list")
+		}
+		table := exp.OutputOpts.SQLTable
+		if table == "" {
+			table = exp.ToolOptions.Namespace.Collection
+		}
+		dialect := exp.OutputOpts.SQLDialect
+		if dialect == "" {
+			dialect = SQLDialectPostgres
+		}
+		sqlOutput := NewSQLExportOutput(table, fields, dialect, out)
+		if exp.OutputOpts.SQLBatchSize > 0 {
+			sqlOutput.BatchSize = exp.OutputOpts.SQLBatchSize
+		}
+		if exp.sqlTypes != nil {
+			sqlOutput.setColumnTypes(exp.sqlTypes)
+		}
+		return sqlOutput, nil
+	}
+	jsonOutput := NewJSONExportOutput(exp.OutputOpts.JSONArray, exp.OutputOpts.Pretty, out)
+	jsonOutput.JSONFormat = exp.OutputOpts.JSONFormat
+	return jsonOutput, nil
+}
+
+// getFields returns the fields given by --fields or --fieldFile, which is
+// empty if there are none.
+func (exp *MongoExport) getFields() ([]string, error) {
+	// TODO what if user specifies *both* --fields and --fieldFile?
+	var fields []string
+	var err error
+	if len(exp.OutputOpts.Fields) > 0 {
+		fields = strings.Split(exp.OutputOpts.Fields, ",")
+	} else if exp.OutputOpts.FieldFile != "" {
+		fields, err = util.GetFieldsFromFile(exp.OutputOpts.FieldFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	exportFields := make([]string, 0, len(fields))
+	for _, field := range fields {
+		// for '$' field projections, exclude '.$' from the field name
+		if i := strings.LastIndex(field, "."); i != -1 && field[i+1:] == "$" {
+			exportFields = append(exportFields, field[:i])
 		} else {
-			return nil, fmt.Errorf("CSV mode requires a field list")
+			exportFields = append(exportFields, field)
 		}
+	}
+	return exportFields, nil
+}
 
-		exportFields := make([]string, 0, len(fields))
-		for _, field := range fields {
-			// for '$' field projections, exclude '.$' from the field name
-			if i := strings.LastIndex(field, "."); i != -1 && field[i+1:] == "$" {
-				exportFields = append(exportFields, field[:i])
-			} else {
-				exportFields = append(exportFields, field)
-			}
+// scanCSVColumns finds the CSV fields to export by reading the documents once
+// before the export, if --arrayMode=columns or --flattenSubdocuments requires
+// the fields to be expanded, or if --autoFields discovers them from a sample.
+func (exp *MongoExport) scanCSVColumns() error {
+	autoFields := exp.OutputOpts.AutoFields != ""
+	if exp.OutputOpts.Type != CSV || (exp.OutputOpts.ArrayMode != ArrayModeColumns &&
+		!exp.OutputOpts.FlattenSubdocuments && !autoFields) {
+		return nil
+	}
+	fields, err := exp.getFields()
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 && !exp.OutputOpts.FlattenSubdocuments && !autoFields {
+		return fmt.Errorf("CSV mode requires a field list")
+	}
+	sampleSize := 0
+	if autoFields {
+		if sampleSize, err = exp.OutputOpts.autoFieldsSampleSize(); err != nil {
+			return err
 		}
+	}
+
+	// fields are discovered from a random sample, which unlike the first
+	// documents includes those of fields added recently
+	var cursor *mgo.Iter
+	var session *mgo.Session
+	if sampleSize > 0 {
+		cursor, session, err = exp.getSampleCursor(sampleSize)
+	} else {
+		cursor, session, err = exp.getCursor()
+	}
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer cursor.Close()
 
-		return NewCSVExportOutput(exportFields, exp.OutputOpts.NoHeaderLine, out), nil
+	// discovered fields are the dotted paths of the leaves of the documents
+	scan := newColumnScan(fields, exp.OutputOpts.ArrayMode, exp.OutputOpts.FlattenSubdocuments || autoFields)
+	var result bson.D
+	for cursor.Next(&result) {
+		result = exp.stripIncremental(result)
+		scan.observe(result)
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("error scanning documents for CSV fields: %v", err)
+	}
+	exp.csvColumns = scan.columns()
+	if exp.OutputOpts.AutoFieldsOrder == autoFieldsAlphabetical {
+		sortColumns(exp.csvColumns)
 	}
-	return NewJSONExportOutput(exp.OutputOpts.JSONArray, exp.OutputOpts.Pretty, out), nil
+	log.Logf(log.Info, "exporting %v CSV %v", len(exp.csvColumns),
+		util.Pluralize(len(exp.csvColumns), "field", "fields"))
+	if len(exp.csvColumns) == 0 {
+		return fmt.Errorf("no fields to export were found")
+	}
+	if exp.OutputOpts.AutoFieldsFile != "" {
+		if err := util.WriteFieldsToFile(exp.OutputOpts.AutoFieldsFile, exp.csvColumns); err != nil {
+			return fmt.Errorf("error writing --autoFieldsFile: %v", err)
+		}
+	}
+	return nil
 }
 
 // getObjectFromByteArg takes an object in extended JSON, and converts it to an object that
@@ -401,6 +933,31 @@ func getObjectFromByteArg(queryRaw []byte) (map[string]interface{}, error) {
 	return parsedJSON, nil
 }
 
+// getPipelineFromArg takes an aggregation pipeline, as a JSON array of stages
+// in extended JSON, and converts it to a slice of stages that can be passed to
+// collection.Pipe(...). The order of the fields of each stage is preserved.
+func getPipelineFromArg(pipelineRaw []byte) ([]interface{}, error) {
+	wrapped := append(append([]byte(`{"pipeline":`), pipelineRaw...), '}')
+	parsedJSON, err := json.UnmarshalBsonD(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline '%s' is not valid JSON: %v", pipelineRaw, err)
+	}
+	extended, err := bsonutil.GetExtendedBsonD(parsedJSON)
+	if err != nil {
+		return nil, err
+	}
+	stages, ok := extended[0].Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("pipeline '%s' is not a JSON array", pipelineRaw)
+	}
+	for i, stage := range stages {
+		if _, ok := stage.(bson.D); !ok {
+			return nil, fmt.Errorf("stage #%v of the pipeline is not a document", i+1)
+		}
+	}
+	return stages, nil
+}
+
 // getSortFromArg takes a sort specification in JSON and returns it as a bson.D
 // object which preserves the ordering of the keys as they appear in the input.
 func getSortFromArg(queryRaw string) (bson.D, error) {
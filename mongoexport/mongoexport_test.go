@@ -3,6 +3,7 @@ package mongoexport
 import (
 	"encoding/json"
 	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/options"
 	"github.com/mongodb/mongo-tools/common/testutil"
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/mgo.v2/bson"
@@ -42,3 +43,49 @@ func TestFieldSelect(t *testing.T) {
 		So(makeFieldSelector("x,foo.baz"), ShouldResemble, bson.M{"_id": 1, "foo": 1, "x": 1})
 	})
 }
+
+func TestPipelineFromArg(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Using getPipelineFromArg should return the stages in order", t, func() {
+		stages, err := getPipelineFromArg([]byte(`[{"$match": {"_id": {"$oid": "5565d8c9e3b16a7e12ca3e32"}}}, {"$sort": {"b": 1, "a": -1}}]`))
+		So(err, ShouldBeNil)
+		So(stages, ShouldResemble, []interface{}{
+			bson.D{{"$match", bson.D{{"_id", bson.ObjectIdHex("5565d8c9e3b16a7e12ca3e32")}}}},
+			bson.D{{"$sort", bson.D{{"b", int32(1)}, {"a", int32(-1)}}}},
+		})
+	})
+
+	Convey("Using getPipelineFromArg should reject invalid pipelines", t, func() {
+		_, err := getPipelineFromArg([]byte(`{"$match": {}}`))
+		So(err, ShouldNotBeNil)
+		_, err = getPipelineFromArg([]byte(`[{"$match": {}}, 1]`))
+		So(err, ShouldNotBeNil)
+		_, err = getPipelineFromArg([]byte(`[{"$match": }]`))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestPipelineValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With an export using an aggregation pipeline", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: JSON},
+			InputOpts:  &InputOptions{Pipeline: `[{"$unwind": "$items"}]`},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldBeNil)
+
+		Convey("query options should be rejected", func() {
+			exp.InputOpts.Sort = `{"a": 1}`
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("both --pipeline and --pipelineFile should be rejected", func() {
+			exp.InputOpts.PipelineFile = "pipeline.json"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+}
@@ -2,25 +2,44 @@ package mongoexport
 
 import (
 	"fmt"
+	"github.com/mongodb/mongo-tools/common/util"
 	"io/ioutil"
+	"strconv"
+)
+
+// Values of the --autoFields and --autoFieldsOrder options; --sqlSampleSize
+// also accepts autoFieldsAll.
+const (
+	autoFieldsAll          = "all"
+	autoFieldsFirstSeen    = "firstSeen"
+	autoFieldsAlphabetical = "alphabetical"
 )
 
 var Usage = `<options>
 
-Export data from MongoDB in CSV or JSON format.
+Export data from MongoDB in CSV, JSON, SQL or Parquet format.
 
 See http://docs.mongodb.org/manual/reference/program/mongoexport/ for more information.`
 
 // OutputFormatOptions defines the set of options to use in formatting exported data.
 type OutputFormatOptions struct {
 	// Fields is an option to directly specify comma-separated fields to export to CSV.
-	Fields string `long:"fields" value-name:"<field>[,<field>]*" short:"f" description:"comma separated list of field names (required for exporting CSV) e.g. -f \"name,age\" "`
+	Fields string `long:"fields" value-name:"<field>[,<field>]*" short:"f" description:"comma separated list of field names (required for exporting CSV and SQL) e.g. -f \"name,age\" "`
 
 	// FieldFile is a filename that refers to a list of fields to export, 1 per line.
 	FieldFile string `long:"fieldFile" value-name:"<filename>" description:"file with field names - 1 per line"`
 
+	// AutoFields, if set, discovers the fields to export to CSV from a sample of the documents.
+	AutoFields string `long:"autoFields" value-name:"<sampleSize>|all" optional:"true" optional-value:"1000" description:"discover the fields to export to CSV from a random sample of the documents to export, of 1000 unless a sample size is given, or from all of them with --autoFields=all (CSV only)"`
+
+	// AutoFieldsOrder is the order of the fields discovered by AutoFields.
+	AutoFieldsOrder string `long:"autoFieldsOrder" value-name:"<order>" description:"order of the discovered fields: firstSeen or alphabetical (defaults to 'firstSeen')"`
+
+	// AutoFieldsFile is a filename to which the fields discovered by AutoFields are written.
+	AutoFieldsFile string `long:"autoFieldsFile" value-name:"<filename>" description:"write the discovered fields to a file, 1 per line, for use with --fieldFile"`
+
 	// Type selects the type of output to export as (json or csv).
-	Type string `long:"type" value-name:"<type>" default:"json" default-mask:"-" description:"the output format, either json or csv (defaults to 'json')"`
+	Type string `long:"type" value-name:"<type>" default:"json" default-mask:"-" description:"the output format: json, csv, sql or parquet (defaults to 'json')"`
 
 	// OutputFile specifies an output file path.
 	OutputFile string `long:"out" value-name:"<filename>" short:"o" description:"output file; if not specified, stdout is used"`
@@ -28,11 +47,68 @@ type OutputFormatOptions struct {
 	// JSONArray if set will export the documents an array of JSON documents.
 	JSONArray bool `long:"jsonArray" description:"output to a JSON array rather than one object per line"`
 
+	// JSONFormat selects the flavour of extended JSON to write.
+	JSONFormat string `long:"jsonFormat" value-name:"<format>" description:"the extended JSON format to write: legacy, canonical or relaxed; canonical and relaxed follow the Extended JSON v2 specification (JSON only; defaults to 'legacy')"`
+
 	// Pretty displays JSON data in a human-readable form.
 	Pretty bool `long:"pretty" description:"output JSON formatted to be human-readable"`
 
 	// NoHeaderLine, if set, will export CSV data without a list of field names at the first line.
 	NoHeaderLine bool `long:"noHeaderLine" description:"export CSV data without a list of field names at the first line"`
+
+	// ArrayMode determines how arrays are written to CSV fields.
+	ArrayMode string `long:"arrayMode" value-name:"<mode>" description:"how to write arrays: json writes them as JSON, join joins their elements with --arrayDelimiter, explode writes a line for each element, repeating the other fields, and columns writes each element in its own column, found by first scanning the documents (CSV only; defaults to 'json')"`
+
+	// ArrayDelimiter separates the elements of arrays in join mode.
+	ArrayDelimiter string `long:"arrayDelimiter" value-name:"<string>" description:"string separating the elements of arrays written with --arrayMode=join (defaults to ';')"`
+
+	// FlattenSubdocuments expands subdocuments into dotted fields.
+	FlattenSubdocuments bool `long:"flattenSubdocuments" description:"write each field of subdocuments in its own column with a dotted name, found by first scanning the documents; the field list is optional (CSV only)"`
+
+	// Delimiter is the character separating CSV fields.
+	Delimiter string `long:"delimiter" value-name:"<char>" description:"character separating fields, use '\\t' for a tab (CSV only; defaults to ',')"`
+
+	// Quote is the character used to quote CSV fields.
+	Quote string `long:"quote" value-name:"<char>" description:"character used to quote fields (CSV only; defaults to '\"')"`
+
+	// Escape, if set, is written before quote characters within quoted fields instead of doubling them.
+	Escape string `long:"escape" value-name:"<char>" description:"character used to escape quotes within fields, instead of doubling them (CSV only)"`
+
+	// LineTerminator is the sequence ending each CSV record.
+	LineTerminator string `long:"lineTerminator" value-name:"<terminator>" description:"line terminator of the output: lf, crlf or cr (CSV only; defaults to lf)"`
+
+	// SplitOutput writes the export to numbered files named after OutputFile.
+	SplitOutput bool `long:"splitOutput" description:"write the export to numbered files named after --out, e.g. out-0001.json, out-0002.json; required by --numParallelReaders and --maxDocsPerFile"`
+
+	// MaxDocsPerFile is the number of documents after which the next numbered file is started.
+	MaxDocsPerFile int `long:"maxDocsPerFile" value-name:"<count>" description:"start a new file after writing this many documents to a file (with --splitOutput)"`
+
+	// SQLDialect is the SQL dialect of the statements written.
+	SQLDialect string `long:"sqlDialect" value-name:"<dialect>" description:"the SQL dialect to write: postgres, mysql or sqlite (SQL only; defaults to 'postgres')"`
+
+	// SQLTable is the name of the table created and inserted into.
+	SQLTable string `long:"sqlTable" value-name:"<table>" description:"name of the table to create and insert into (SQL only; defaults to the collection name)"`
+
+	// SQLBatchSize is the maximum number of rows inserted by each INSERT statement.
+	SQLBatchSize int `long:"sqlBatchSize" value-name:"<count>" description:"maximum number of rows inserted by each INSERT statement (SQL only; defaults to 100)"`
+
+	// SQLSampleSize is the number of documents from which the types of the SQL columns are inferred.
+	SQLSampleSize string `long:"sqlSampleSize" value-name:"<count>|all" description:"infer the types of the columns from the first documents to export, 1000 unless a sample size is given, or from all of them with --sqlSampleSize=all (SQL only)"`
+
+	// ParquetSchemaFile is a file holding the schema of the Parquet output, in the Parquet text format.
+	ParquetSchemaFile string `long:"parquetSchemaFile" value-name:"<filename>" description:"file with the schema of the output in the Parquet text format, e.g. 'message doc { optional binary name (STRING); }', instead of inferring it (Parquet only)"`
+
+	// ParquetSampleSize is the number of documents from which the Parquet schema is inferred.
+	ParquetSampleSize string `long:"parquetSampleSize" value-name:"<count>|all" description:"infer the schema from the first documents to export, 1000 unless a sample size is given, or from all of them with --parquetSampleSize=all (Parquet only)"`
+
+	// ParquetCompression is the compression codec of the Parquet pages.
+	ParquetCompression string `long:"parquetCompression" value-name:"<codec>" description:"compression of the pages: none, snappy or gzip (Parquet only; defaults to 'snappy')"`
+
+	// ParquetRowGroupSize is the size in megabytes after which Parquet row groups are flushed.
+	ParquetRowGroupSize int `long:"parquetRowGroupSize" value-name:"<megabytes>" description:"size of the data buffered in each row group before it is written (Parquet only; defaults to 128)"`
+
+	// Encoding is the character encoding of the output.
+	Encoding string `long:"encoding" value-name:"<encoding>" description:"character encoding of the output: utf-8, latin1, windows-1252, utf-16, utf-16le or utf-16be; utf-16 output is little-endian with a byte order mark (defaults to utf-8)"`
 }
 
 // Name returns a human-readable group name for output format options.
@@ -40,6 +116,121 @@ func (*OutputFormatOptions) Name() string {
 	return "output"
 }
 
+// autoFieldsSampleSize returns the number of documents from which to discover
+// the fields to export, which is 0 for all of them.
+func (outputOptions *OutputFormatOptions) autoFieldsSampleSize() (int, error) {
+	return parseSampleSize("autoFields", outputOptions.AutoFields)
+}
+
+// sqlSampleSize returns the number of documents from which to infer the types
+// of the SQL columns, which is 0 for all of them.
+func (outputOptions *OutputFormatOptions) sqlSampleSize() (int, error) {
+	if outputOptions.SQLSampleSize == "" {
+		return defaultSQLSampleSize, nil
+	}
+	return parseSampleSize("sqlSampleSize", outputOptions.SQLSampleSize)
+}
+
+// parquetSampleSize returns the number of documents from which to infer the
+// Parquet schema, which is 0 for all of them.
+func (outputOptions *OutputFormatOptions) parquetSampleSize() (int, error) {
+	if outputOptions.ParquetSampleSize == "" {
+		return defaultParquetSampleSize, nil
+	}
+	return parseSampleSize("parquetSampleSize", outputOptions.ParquetSampleSize)
+}
+
+// parseSampleSize parses the value of an option giving a number of documents
+// to sample, returning 0 for all of them.
+func parseSampleSize(option, value string) (int, error) {
+	if value == autoFieldsAll {
+		return 0, nil
+	}
+	sampleSize, err := strconv.Atoi(value)
+	if err != nil || sampleSize <= 0 {
+		return 0, fmt.Errorf("invalid --%v sample size '%v', use a positive number or '%v'",
+			option, value, autoFieldsAll)
+	}
+	return sampleSize, nil
+}
+
+// sqlOption returns the name of the first SQL option set, or an empty string
+// if there is none.
+func (outputOptions *OutputFormatOptions) sqlOption() string {
+	switch {
+	case outputOptions.SQLDialect != "":
+		return "sqlDialect"
+	case outputOptions.SQLTable != "":
+		return "sqlTable"
+	case outputOptions.SQLBatchSize != 0:
+		return "sqlBatchSize"
+	case outputOptions.SQLSampleSize != "":
+		return "sqlSampleSize"
+	}
+	return ""
+}
+
+// parquetOption returns the name of the first Parquet option set, or an empty
+// string if there is none.
+func (outputOptions *OutputFormatOptions) parquetOption() string {
+	switch {
+	case outputOptions.ParquetSchemaFile != "":
+		return "parquetSchemaFile"
+	case outputOptions.ParquetSampleSize != "":
+		return "parquetSampleSize"
+	case outputOptions.ParquetCompression != "":
+		return "parquetCompression"
+	case outputOptions.ParquetRowGroupSize != 0:
+		return "parquetRowGroupSize"
+	}
+	return ""
+}
+
+// csvDialect parses the CSV dialect options, returning the delimiter, quote
+// and escape characters and the line terminator to write.
+func (outputOptions *OutputFormatOptions) csvDialect() (delimiter, quote, escape rune, lineTerminator string, err error) {
+	delimiter, quote = ',', '"'
+	if outputOptions.Delimiter != "" {
+		if delimiter, err = util.ParseCharOption("delimiter", outputOptions.Delimiter); err != nil {
+			return
+		}
+	}
+	if outputOptions.Quote != "" {
+		if quote, err = util.ParseCharOption("quote", outputOptions.Quote); err != nil {
+			return
+		}
+	}
+	if escape, err = util.ParseCharOption("escape", outputOptions.Escape); err != nil {
+		return
+	}
+	if delimiter == quote {
+		err = fmt.Errorf("--delimiter and --quote can not both be '%c'", quote)
+		return
+	}
+	if escape == delimiter {
+		err = fmt.Errorf("--delimiter and --escape can not both be '%c'", escape)
+		return
+	}
+	lineTerminator, err = util.ParseLineTerminator(outputOptions.LineTerminator)
+	return
+}
+
+// dialectOption returns the name of the first CSV dialect option set, or an
+// empty string if there is none.
+func (outputOptions *OutputFormatOptions) dialectOption() string {
+	switch {
+	case outputOptions.Delimiter != "":
+		return "delimiter"
+	case outputOptions.Quote != "":
+		return "quote"
+	case outputOptions.Escape != "":
+		return "escape"
+	case outputOptions.LineTerminator != "":
+		return "lineTerminator"
+	}
+	return ""
+}
+
 // InputOptions defines the set of options to use in retrieving data from the server.
 type InputOptions struct {
 	Query          string `long:"query" value-name:"<json>" short:"q" description:"query filter, as a JSON string, e.g., '{x:{$gt:1}}'"`
@@ -50,6 +241,16 @@ type InputOptions struct {
 	Skip           int    `long:"skip" value-name:"<count>" description:"number of documents to skip"`
 	Limit          int    `long:"limit" value-name:"<count>" description:"limit the number of documents to export"`
 	Sort           string `long:"sort" value-name:"<json>" description:"sort order, as a JSON string, e.g. '{x:1}'"`
+	Pipeline       string `long:"pipeline" value-name:"<json>" description:"export the result of an aggregation pipeline, as a JSON array of stages, e.g. '[{$match:{x:{$gt:1}}}]'; --fields does not project the results"`
+	PipelineFile   string `long:"pipelineFile" value-name:"<filename>" description:"path to a file containing an aggregation pipeline (JSON array)"`
+
+	// NumParallelReaders is the number of ranges of the partition field that are exported concurrently.
+	NumParallelReaders int    `long:"numParallelReaders" value-name:"<count>" description:"number of ranges of the partition field to export concurrently, each to its own files (requires --splitOutput)"`
+	PartitionField     string `long:"partitionField" value-name:"<field>" description:"indexed field whose values are used to partition the collection between parallel readers (defaults to '_id')"`
+
+	// IncrementalField and StateFile export the documents whose field is greater than in the previous export.
+	IncrementalField string `long:"incrementalField" value-name:"<field>" description:"indexed field increasing with each insert or update, holding dates, ObjectIds or numbers; only the documents whose field is greater than the greatest one of the previous export are exported, in ascending order of the field (requires --stateFile)"`
+	StateFile        string `long:"stateFile" value-name:"<filename>" description:"file recording the greatest value of --incrementalField exported, updated once the output is written"`
 }
 
 // Name returns a human-readable group name for input options.
@@ -61,6 +262,23 @@ func (inputOptions *InputOptions) HasQuery() bool {
 	return inputOptions.Query != "" || inputOptions.QueryFile != ""
 }
 
+func (inputOptions *InputOptions) HasPipeline() bool {
+	return inputOptions.Pipeline != "" || inputOptions.PipelineFile != ""
+}
+
+func (inputOptions *InputOptions) GetPipeline() ([]byte, error) {
+	if inputOptions.Pipeline != "" {
+		return []byte(inputOptions.Pipeline), nil
+	} else if inputOptions.PipelineFile != "" {
+		content, err := ioutil.ReadFile(inputOptions.PipelineFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading pipelineFile: %v", err)
+		}
+		return content, nil
+	}
+	panic("GetPipeline can return valid values only for pipeline or pipelineFile input")
+}
+
 func (inputOptions *InputOptions) GetQuery() ([]byte, error) {
 	if inputOptions.Query != "" {
 		return []byte(inputOptions.Query), nil
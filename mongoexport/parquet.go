@@ -0,0 +1,835 @@
+package mongoexport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/parquet"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Compression codecs of the Parquet output type.
+const (
+	ParquetCompressionNone   = "none"
+	ParquetCompressionSnappy = "snappy"
+	ParquetCompressionGzip   = "gzip"
+)
+
+var parquetCodecs = map[string]parquet.Codec{
+	ParquetCompressionNone:   parquet.Uncompressed,
+	ParquetCompressionSnappy: parquet.Snappy,
+	ParquetCompressionGzip:   parquet.Gzip,
+}
+
+const (
+	// defaultParquetRowGroupSize is the size in megabytes of the row groups
+	// if --parquetRowGroupSize isn't set.
+	defaultParquetRowGroupSize = 128
+
+	// defaultParquetSampleSize is the number of documents from which the
+	// schema is inferred if --parquetSampleSize isn't set.
+	defaultParquetSampleSize = 1000
+
+	// maxParquetDecimalPrecision is the number of digits of the decimals
+	// written, which are stored in 16 bytes.
+	maxParquetDecimalPrecision = 38
+)
+
+// parquetKind is a class of BSON values stored in the same type of Parquet
+// field.
+type parquetKind int
+
+const (
+	// parquetNull is the kind of a field for which only missing or null
+	// values were seen; it is written as a string field.
+	parquetNull parquetKind = iota
+	parquetBoolean
+	// numeric kinds, in increasing order of the values they can hold
+	parquetInt32
+	parquetInt64
+	parquetDecimal
+	parquetDouble
+	parquetString
+	parquetTimestamp
+	parquetBinary
+	parquetDocument
+	parquetArray
+)
+
+// bsonParquetKind returns the kind of field that holds a BSON value. Values
+// of types without a Parquet type of their own are stored as strings.
+func bsonParquetKind(value interface{}) parquetKind {
+	switch value.(type) {
+	case nil:
+		return parquetNull
+	case bool:
+		return parquetBoolean
+	case int, int32:
+		return parquetInt32
+	case int64:
+		return parquetInt64
+	case bson.Decimal128:
+		return parquetDecimal
+	case float32, float64:
+		return parquetDouble
+	case time.Time:
+		return parquetTimestamp
+	case []byte, bson.Binary:
+		return parquetBinary
+	case bson.D, bson.M:
+		return parquetDocument
+	case []interface{}:
+		return parquetArray
+	}
+	if value == bson.Undefined {
+		return parquetNull
+	}
+	return parquetString
+}
+
+// mergeParquetKinds returns the kind of a field holding values of both kinds:
+// the wider of two numeric kinds, or string if the kinds differ otherwise.
+// Decimals and doubles are merged into strings, since neither type holds
+// every value of the other exactly.
+func mergeParquetKinds(left, right parquetKind) parquetKind {
+	switch {
+	case left == right || right == parquetNull:
+		return left
+	case left == parquetNull:
+		return right
+	case left == parquetDecimal && right == parquetDouble || left == parquetDouble && right == parquetDecimal:
+		return parquetString
+	case left >= parquetInt32 && left <= parquetDouble && right >= parquetInt32 && right <= parquetDouble:
+		if left > right {
+			return left
+		}
+		return right
+	}
+	return parquetString
+}
+
+// parquetField is a field of the inferred schema, along with the kind of the
+// values seen for it.
+type parquetField struct {
+	name string
+	kind parquetKind
+
+	// digits is the greatest number of digits before the decimal point of
+	// the integers and decimals seen, and scale the greatest number of
+	// decimal places of the decimals seen
+	digits int
+	scale  int
+
+	// fields are the fields of documents, in the order they were seen, and
+	// element the field of the elements of arrays
+	fields  []*parquetField
+	element *parquetField
+
+	// prefix is set for the groups made of the dotted fields given by
+	// --fields, whose other subfields aren't exported
+	prefix bool
+}
+
+// field returns the subfield of the given name, adding it if needed.
+func (f *parquetField) field(name string) *parquetField {
+	for _, field := range f.fields {
+		if field.name == name {
+			return field
+		}
+	}
+	field := &parquetField{name: name}
+	f.fields = append(f.fields, field)
+	return field
+}
+
+// observe widens the kind of the field to hold a value.
+func (f *parquetField) observe(value interface{}) {
+	kind := bsonParquetKind(value)
+	f.kind = mergeParquetKinds(f.kind, kind)
+	if digits, scale, ok := parquetDecimalDigits(value); ok {
+		if digits > f.digits {
+			f.digits = digits
+		}
+		if scale > f.scale {
+			f.scale = scale
+		}
+	}
+	if f.kind != kind {
+		return
+	}
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			f.field(elem.Name).observe(elem.Value)
+		}
+	case bson.M:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f.field(name).observe(v[name])
+		}
+	case []interface{}:
+		if f.element == nil {
+			f.element = &parquetField{name: "element"}
+		}
+		for _, element := range v {
+			f.element.observe(element)
+		}
+	}
+}
+
+// parquetDecimalDigits returns the number of digits before and after the
+// decimal point of an integer or a finite decimal.
+func parquetDecimalDigits(value interface{}) (digits, scale int, ok bool) {
+	var coefficient *big.Int
+	var exponent int
+	switch v := value.(type) {
+	case bson.Decimal128:
+		var err error
+		if coefficient, exponent, err = v.BigInt(); err != nil {
+			return 0, 0, false
+		}
+	default:
+		integer, ok := parquetInteger(value)
+		if !ok {
+			return 0, 0, false
+		}
+		coefficient = big.NewInt(integer)
+	}
+	if coefficient.Sign() == 0 {
+		return 0, 0, true
+	}
+	digits = len(new(big.Int).Abs(coefficient).String()) + exponent
+	if digits < 0 {
+		digits = 0
+	}
+	if exponent < 0 {
+		scale = -exponent
+	}
+	return digits, scale, true
+}
+
+// node returns the optional Parquet field holding the values seen, recording
+// the groups made of sampled documents in strict.
+func (f *parquetField) node(strict map[*parquet.Node]bool) *parquet.Node {
+	node := &parquet.Node{Name: f.name, Repetition: parquet.Optional}
+	kind := f.kind
+	switch {
+	case f.prefix:
+		kind = parquetDocument
+	case kind == parquetDocument && len(f.fields) == 0:
+		// a group must have fields, so empty documents are written as JSON
+		kind = parquetString
+	case kind == parquetDecimal && f.digits+f.scale > maxParquetDecimalPrecision:
+		// decimals needing more digits are written as text
+		kind = parquetString
+	}
+	switch kind {
+	case parquetBoolean:
+		node.Type = parquet.Boolean
+	case parquetInt32:
+		node.Type = parquet.Int32
+	case parquetInt64:
+		node.Type = parquet.Int64
+	case parquetDecimal:
+		node.Type, node.TypeLength = parquet.FixedLenByteArray, 16
+		node.LogicalType = &parquet.LogicalType{Kind: parquet.Decimal,
+			Precision: maxParquetDecimalPrecision, Scale: int32(f.scale)}
+	case parquetDouble:
+		node.Type = parquet.Double
+	case parquetTimestamp:
+		node.Type = parquet.Int64
+		node.LogicalType = &parquet.LogicalType{Kind: parquet.Timestamp, Unit: parquet.Millis, IsAdjustedToUTC: true}
+	case parquetBinary:
+		node.Type = parquet.ByteArray
+	case parquetDocument:
+		node.Children = []*parquet.Node{}
+		for _, field := range f.fields {
+			node.Children = append(node.Children, field.node(strict))
+		}
+		if !f.prefix {
+			strict[node] = true
+		}
+	case parquetArray:
+		// arrays are written as lists of three levels, whose elements may
+		// be null
+		element := f.element
+		if element == nil {
+			element = &parquetField{name: "element"}
+		}
+		node.LogicalType = &parquet.LogicalType{Kind: parquet.List}
+		node.Children = []*parquet.Node{{
+			Name:       "list",
+			Repetition: parquet.Repeated,
+			Children:   []*parquet.Node{element.node(strict)},
+		}}
+	default:
+		node.Type = parquet.ByteArray
+		node.LogicalType = &parquet.LogicalType{Kind: parquet.String}
+	}
+	return node
+}
+
+// schema returns the schema of the records holding the fields of the root.
+func (f *parquetField) schema(name string) (*parquetSchema, error) {
+	strict := map[*parquet.Node]bool{}
+	schema, err := parquet.NewSchema(name, f.node(strict).Children)
+	if err != nil {
+		return nil, err
+	}
+	if !f.prefix {
+		strict[schema.Root] = true
+	}
+	return newParquetSchema(schema, strict), nil
+}
+
+// parquetSchema is the schema of the Parquet output, along with what is
+// needed to convert documents to its records.
+type parquetSchema struct {
+	*parquet.Schema
+
+	// strict holds the groups inferred from whole documents, which can't
+	// hold fields that weren't sampled
+	strict map[*parquet.Node]bool
+
+	// indexes holds the index of each field of each group, by name
+	indexes map[*parquet.Node]map[string]int
+}
+
+func newParquetSchema(schema *parquet.Schema, strict map[*parquet.Node]bool) *parquetSchema {
+	ps := &parquetSchema{Schema: schema, strict: strict, indexes: map[*parquet.Node]map[string]int{}}
+	var addIndexes func(node *parquet.Node)
+	addIndexes = func(node *parquet.Node) {
+		if node.IsLeaf() {
+			return
+		}
+		indexes := map[string]int{}
+		for i, child := range node.Children {
+			indexes[child.Name] = i
+			addIndexes(child)
+		}
+		ps.indexes[node] = indexes
+	}
+	addIndexes(schema.Root)
+	return ps
+}
+
+// scanParquetSchema reads the schema given by --parquetSchemaFile, or infers
+// it from a sample of the documents to export, before the export.
+func (exp *MongoExport) scanParquetSchema() error {
+	if exp.OutputOpts.Type != Parquet {
+		return nil
+	}
+	if exp.OutputOpts.ParquetSchemaFile != "" {
+		content, err := ioutil.ReadFile(util.ToUniversalPath(exp.OutputOpts.ParquetSchemaFile))
+		if err != nil {
+			return fmt.Errorf("error reading --parquetSchemaFile: %v", err)
+		}
+		schema, err := parquet.ParseSchema(string(content))
+		if err != nil {
+			return fmt.Errorf("invalid --parquetSchemaFile: %v", err)
+		}
+		exp.parquetSchema = newParquetSchema(schema, nil)
+		return nil
+	}
+
+	fields, err := exp.getFields()
+	if err != nil {
+		return err
+	}
+	sampleSize, err := exp.OutputOpts.parquetSampleSize()
+	if err != nil {
+		return err
+	}
+	// the fields given by --fields are exported in their order, as groups
+	// of the dotted fields sharing a prefix
+	root := &parquetField{kind: parquetDocument, prefix: len(fields) > 0}
+	leaves := make([]*parquetField, len(fields))
+	for i, field := range fields {
+		parent := root
+		names := strings.Split(field, ".")
+		for _, name := range names[:len(names)-1] {
+			parent = parent.field(name)
+			parent.prefix = true
+		}
+		leaves[i] = parent.field(names[len(names)-1])
+	}
+	for i, leaf := range leaves {
+		if leaf.prefix {
+			return fmt.Errorf("cannot export both the field '%v' and its subfields", fields[i])
+		}
+	}
+
+	cursor, session, err := exp.getCursor()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer cursor.Close()
+
+	var result bson.D
+	numScanned := 0
+	for ; (sampleSize == 0 || numScanned < sampleSize) && cursor.Next(&result); numScanned++ {
+		result = exp.stripIncremental(result)
+		if len(fields) == 0 {
+			root.observe(result)
+			continue
+		}
+		for i, field := range fields {
+			if value, ok := lookupBSONValue(result, field); ok {
+				leaves[i].observe(value)
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("error scanning documents for the Parquet schema: %v", err)
+	}
+	log.Logf(log.Info, "inferred the Parquet schema from %v %v", numScanned,
+		util.Pluralize(numScanned, "document", "documents"))
+
+	if len(root.fields) == 0 {
+		return fmt.Errorf("no fields to export were found; give them with --fields or --parquetSchemaFile")
+	}
+	schema, err := root.schema(exp.ToolOptions.Namespace.Collection)
+	if err != nil {
+		return err
+	}
+	log.Logf(log.DebugLow, "Parquet schema:\n%v", schema)
+	exp.parquetSchema = schema
+	return nil
+}
+
+// ParquetExportOutput is an implementation of ExportOutput that writes
+// documents as the records of a Parquet file.
+type ParquetExportOutput struct {
+	// NumExported maintains a running total of the number of documents written.
+	NumExported int64
+
+	schema *parquetSchema
+	writer *parquet.Writer
+	out    *bufio.Writer
+}
+
+// NewParquetExportOutput returns a ParquetExportOutput writing records of the
+// given schema to out.
+func NewParquetExportOutput(schema *parquetSchema, out io.Writer) *ParquetExportOutput {
+	bufferedOut := bufio.NewWriter(out)
+	return &ParquetExportOutput{
+		schema: schema,
+		writer: parquet.NewWriter(bufferedOut, schema.Schema),
+		out:    bufferedOut,
+	}
+}
+
+// WriteHeader does nothing; the schema is written in the footer.
+func (parquetExporter *ParquetExportOutput) WriteHeader() error {
+	return nil
+}
+
+// WriteFooter writes the buffered records and the footer of the file.
+func (parquetExporter *ParquetExportOutput) WriteFooter() error {
+	return parquetExporter.writer.Close()
+}
+
+// Flush writes any pending data to the underlying I/O stream.
+func (parquetExporter *ParquetExportOutput) Flush() error {
+	return parquetExporter.out.Flush()
+}
+
+// ExportDocument converts the given document to a record and writes it.
+func (parquetExporter *ParquetExportOutput) ExportDocument(document bson.D) error {
+	record, err := parquetExporter.group(parquetExporter.schema.Root, document, "")
+	if err != nil {
+		return err
+	}
+	if err := parquetExporter.writer.Write(record); err != nil {
+		return err
+	}
+	parquetExporter.NumExported++
+	return nil
+}
+
+// parquetTypeError returns the error for a value that doesn't fit its field.
+func parquetTypeError(path string, value interface{}) error {
+	return fmt.Errorf("field '%v' can't hold a value of type %T; infer the schema from more documents "+
+		"with --parquetSampleSize or give it with --parquetSchemaFile", path, value)
+}
+
+// group converts a document, or an array for groups made of dotted fields, to
+// the values of the fields of a group.
+func (parquetExporter *ParquetExportOutput) group(node *parquet.Node, value interface{}, path string) (parquet.Group, error) {
+	group := make(parquet.Group, len(node.Children))
+	strict := parquetExporter.schema.strict[node]
+	indexes := parquetExporter.schema.indexes[node]
+	lookup := func(name string, value interface{}) error {
+		i, ok := indexes[name]
+		if !ok {
+			if strict {
+				return fmt.Errorf("field '%v' wasn't in the documents the schema was inferred from; infer "+
+					"it from more documents with --parquetSampleSize or give it with --parquetSchemaFile",
+					path+name)
+			}
+			return nil
+		}
+		var err error
+		group[i], err = parquetExporter.field(node.Children[i], value, path+name)
+		return err
+	}
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			if err := lookup(elem.Name, elem.Value); err != nil {
+				return nil, err
+			}
+		}
+	case bson.M:
+		for name, subvalue := range v {
+			if err := lookup(name, subvalue); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if strict || node == parquetExporter.schema.Root {
+			return nil, parquetTypeError(strings.TrimSuffix(path, "."), value)
+		}
+		// as with other output types, dotted fields may index arrays and
+		// are missing from other values
+		for i, child := range node.Children {
+			if subvalue, ok := lookupBSONChild(value, child.Name); ok {
+				var err error
+				if group[i], err = parquetExporter.field(child, subvalue, path+child.Name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return group, nil
+}
+
+// field converts a BSON value to the value of a field, which is a list of
+// elements for repeated fields.
+func (parquetExporter *ParquetExportOutput) field(node *parquet.Node, value interface{}, path string) (interface{}, error) {
+	if value == nil || value == bson.Undefined {
+		return nil, nil
+	}
+	if node.Repetition != parquet.Repeated {
+		return parquetExporter.value(node, value, path)
+	}
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, parquetTypeError(path, value)
+	}
+	elements := make([]interface{}, len(array))
+	for i, element := range array {
+		var err error
+		if elements[i], err = parquetExporter.value(node, element, path); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// value converts a non-null BSON value to the value of a field.
+func (parquetExporter *ParquetExportOutput) value(node *parquet.Node, value interface{}, path string) (interface{}, error) {
+	if node.IsLeaf() {
+		return parquetLeafValue(node, value, path)
+	}
+	if len(node.Children) == 1 && node.Children[0].Repetition == parquet.Repeated {
+		repeated := node.Children[0]
+		if node.Is(parquet.Map) && len(repeated.Children) == 2 {
+			return parquetExporter.mapEntries(repeated, value, path)
+		}
+		if node.Is(parquet.List) {
+			return parquetExporter.list(node, repeated, value, path)
+		}
+	}
+	return parquetExporter.group(node, value, path+".")
+}
+
+// list converts an array to the elements of a LIST group, which are wrapped
+// in a group of a single field unless the list has only two levels.
+func (parquetExporter *ParquetExportOutput) list(node, repeated *parquet.Node, value interface{}, path string) (interface{}, error) {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, parquetTypeError(path, value)
+	}
+	threeLevels := len(repeated.Children) == 1 && repeated.Name != "array" && repeated.Name != node.Name+"_tuple"
+	elements := make([]interface{}, len(array))
+	for i, element := range array {
+		if !threeLevels {
+			if element == nil {
+				return nil, fmt.Errorf("field '%v' can't hold null elements", path)
+			}
+			var err error
+			if elements[i], err = parquetExporter.value(repeated, element, path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		converted, err := parquetExporter.field(repeated.Children[0], element, path)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = parquet.Group{converted}
+	}
+	return parquet.Group{elements}, nil
+}
+
+// mapEntries converts a document to the key-value entries of a MAP group.
+func (parquetExporter *ParquetExportOutput) mapEntries(repeated *parquet.Node, value interface{}, path string) (interface{}, error) {
+	var document bson.D
+	switch v := value.(type) {
+	case bson.D:
+		document = v
+	case bson.M:
+		for name, subvalue := range v {
+			document = append(document, bson.DocElem{name, subvalue})
+		}
+	default:
+		return nil, parquetTypeError(path, value)
+	}
+	entries := make([]interface{}, len(document))
+	for i, elem := range document {
+		key, err := parquetExporter.field(repeated.Children[0], elem.Name, path)
+		if err != nil {
+			return nil, err
+		}
+		entryValue, err := parquetExporter.field(repeated.Children[1], elem.Value, path+"."+elem.Name)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = parquet.Group{key, entryValue}
+	}
+	return parquet.Group{entries}, nil
+}
+
+// parquetLeafValue converts a non-null BSON value to the value of a leaf
+// field, according to its physical and logical types.
+func parquetLeafValue(node *parquet.Node, value interface{}, path string) (interface{}, error) {
+	logicalType := node.LogicalType
+	if logicalType == nil {
+		logicalType = &parquet.LogicalType{}
+	}
+	if logicalType.Kind == parquet.Decimal {
+		return parquetDecimalValue(node, value, path)
+	}
+	switch node.Type {
+	case parquet.Boolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case parquet.Int32:
+		if logicalType.Kind == parquet.Date {
+			if v, ok := value.(time.Time); ok {
+				return int32(math.Floor(float64(v.Unix()) / 86400)), nil
+			}
+			break
+		}
+		if v, ok := parquetInteger(value); ok {
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, fmt.Errorf("value %v of field '%v' overflows int32", v, path)
+			}
+			return int32(v), nil
+		}
+	case parquet.Int64:
+		if logicalType.Kind == parquet.Timestamp {
+			if v, ok := value.(time.Time); ok {
+				switch logicalType.Unit {
+				case parquet.Micros:
+					return v.Unix()*1e6 + int64(v.Nanosecond()/1e3), nil
+				case parquet.Nanos:
+					return v.UnixNano(), nil
+				}
+				return v.Unix()*1e3 + int64(v.Nanosecond()/1e6), nil
+			}
+			break
+		}
+		if v, ok := parquetInteger(value); ok {
+			return v, nil
+		}
+	case parquet.Float, parquet.Double:
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case float32:
+			f = float64(v)
+		default:
+			integer, ok := parquetInteger(value)
+			if !ok {
+				return nil, parquetTypeError(path, value)
+			}
+			f = float64(integer)
+		}
+		if node.Type == parquet.Float {
+			return float32(f), nil
+		}
+		return f, nil
+	case parquet.Int96:
+		// legacy timestamps hold the nanoseconds of the day and the Julian day
+		if v, ok := value.(time.Time); ok {
+			v = v.UTC()
+			day := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)
+			data := make([]byte, 12)
+			binary.LittleEndian.PutUint64(data, uint64(v.Sub(day).Nanoseconds()))
+			binary.LittleEndian.PutUint32(data[8:], uint32(day.Unix()/86400+2440588))
+			return data, nil
+		}
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return parquetBytesValue(node, logicalType, value, path)
+	}
+	return nil, parquetTypeError(path, value)
+}
+
+// parquetInteger returns the value of a BSON integer.
+func parquetInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// parquetBytesValue converts a BSON value to the value of a byte array field.
+func parquetBytesValue(node *parquet.Node, logicalType *parquet.LogicalType, value interface{}, path string) (interface{}, error) {
+	var data []byte
+	switch logicalType.Kind {
+	case parquet.String, parquet.Enum:
+		// values of other types are written as text, as in string columns
+		// inferred from values of several types
+		s, err := sqlTextValue(value)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(s)
+	case parquet.JSON:
+		s, err := sqlJSONValue(value)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(s)
+	case parquet.BSON:
+		document, ok := value.(bson.D)
+		if !ok {
+			return nil, parquetTypeError(path, value)
+		}
+		var err error
+		if data, err = bson.Marshal(document); err != nil {
+			return nil, err
+		}
+	default:
+		switch v := value.(type) {
+		case []byte:
+			data = v
+		case bson.Binary:
+			data = v.Data
+		case string:
+			data = []byte(v)
+		default:
+			return nil, parquetTypeError(path, value)
+		}
+	}
+	if node.Type == parquet.FixedLenByteArray && len(data) != int(node.TypeLength) {
+		return nil, fmt.Errorf("value of %v bytes can't be written to field '%v' of %v bytes",
+			len(data), path, node.TypeLength)
+	}
+	return data, nil
+}
+
+// parquetDecimalValue converts a BSON number to the unscaled value of a
+// DECIMAL field, stored as an integer or a big-endian two's complement byte
+// array. NaN and infinite decimals are written as null.
+func parquetDecimalValue(node *parquet.Node, value interface{}, path string) (interface{}, error) {
+	var coefficient *big.Int
+	var exponent int
+	switch v := value.(type) {
+	case bson.Decimal128:
+		var err error
+		if coefficient, exponent, err = v.BigInt(); err != nil {
+			return nil, nil
+		}
+	default:
+		integer, ok := parquetInteger(value)
+		if !ok {
+			return nil, parquetTypeError(path, value)
+		}
+		coefficient = big.NewInt(integer)
+	}
+
+	// scale the coefficient to the scale of the field, which must leave at
+	// most as many digits as its precision
+	unscaled := new(big.Int).Set(coefficient)
+	ten := big.NewInt(10)
+	precision := int(node.LogicalType.Precision)
+	shift := exponent + int(node.LogicalType.Scale)
+	if coefficient.Sign() == 0 {
+		shift = 0
+	}
+	if shift > precision {
+		return nil, fmt.Errorf("decimal %v needs more than the %v digits of field '%v'", value, precision, path)
+	}
+	for ; shift > 0; shift-- {
+		unscaled.Mul(unscaled, ten)
+	}
+	for remainder := new(big.Int); shift < 0; shift++ {
+		if unscaled.QuoRem(unscaled, ten, remainder); remainder.Sign() != 0 {
+			return nil, fmt.Errorf("decimal %v of field '%v' has more than %v decimal places",
+				value, path, node.LogicalType.Scale)
+		}
+	}
+	if len(new(big.Int).Abs(unscaled).String()) > precision {
+		return nil, fmt.Errorf("decimal %v needs more than the %v digits of field '%v'", value, precision, path)
+	}
+
+	var size int
+	switch node.Type {
+	case parquet.Int32:
+		size = 4
+	case parquet.Int64:
+		size = 8
+	case parquet.FixedLenByteArray:
+		size = int(node.TypeLength)
+	case parquet.ByteArray:
+		size = unscaled.BitLen()/8 + 1
+	default:
+		return nil, fmt.Errorf("field '%v' of type %v can't be a DECIMAL", path, node.Type)
+	}
+	if unscaled.BitLen() >= size*8 {
+		return nil, fmt.Errorf("decimal %v overflows field '%v'", value, path)
+	}
+	switch node.Type {
+	case parquet.Int32:
+		return int32(unscaled.Int64()), nil
+	case parquet.Int64:
+		return unscaled.Int64(), nil
+	}
+	// two's complement of negative values
+	if unscaled.Sign() < 0 {
+		unscaled.Add(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	}
+	data := make([]byte, size)
+	bytes := unscaled.Bytes()
+	copy(data[size-len(bytes):], bytes)
+	if coefficient.Sign() < 0 {
+		for i := 0; i < size-len(bytes); i++ {
+			data[i] = 0xff
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,411 @@
+package mongoexport
+
+import (
+	"bytes"
+	"flag"
+	"github.com/mongodb/mongo-tools/common/options"
+	"github.com/mongodb/mongo-tools/common/parquet"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// updateParquetGolden rewrites the golden files of the Parquet output, which
+// must then be checked with an external reader, as in testdata/README.md.
+var updateParquetGolden = flag.Bool("updateParquetGolden", false, "rewrite the golden Parquet files")
+
+// inferParquetSchema infers the schema of the given documents, as when no
+// fields are given.
+func inferParquetSchema(documents ...bson.D) (*parquetSchema, error) {
+	root := &parquetField{kind: parquetDocument}
+	for _, document := range documents {
+		root.observe(document)
+	}
+	return root.schema("c")
+}
+
+// exportParquet exports documents to a Parquet file of the given schema and
+// returns its records.
+func exportParquet(schema *parquetSchema, documents ...bson.D) ([]parquet.Group, error) {
+	out := &bytes.Buffer{}
+	parquetExporter := NewParquetExportOutput(schema, out)
+	for _, document := range documents {
+		if err := parquetExporter.ExportDocument(document); err != nil {
+			return nil, err
+		}
+	}
+	if err := parquetExporter.WriteFooter(); err != nil {
+		return nil, err
+	}
+	if err := parquetExporter.Flush(); err != nil {
+		return nil, err
+	}
+	reader, err := parquet.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		return nil, err
+	}
+	var records []parquet.Group
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+func TestParquetSchemaInference(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("BSON values should be mapped to kinds of fields", t, func() {
+		So(bsonParquetKind(nil), ShouldEqual, parquetNull)
+		So(bsonParquetKind(bson.Undefined), ShouldEqual, parquetNull)
+		So(bsonParquetKind(1), ShouldEqual, parquetInt32)
+		So(bsonParquetKind(int64(1)), ShouldEqual, parquetInt64)
+		So(bsonParquetKind(bson.NewObjectId()), ShouldEqual, parquetString)
+		So(bsonParquetKind(bson.Binary{Kind: 0x80, Data: []byte{1}}), ShouldEqual, parquetBinary)
+		So(bsonParquetKind(bson.M{"a": 1}), ShouldEqual, parquetDocument)
+		So(bsonParquetKind([]interface{}{1}), ShouldEqual, parquetArray)
+		So(bsonParquetKind(bson.MongoTimestamp(1)), ShouldEqual, parquetString)
+	})
+
+	Convey("Kinds should be widened to hold every value", t, func() {
+		So(mergeParquetKinds(parquetNull, parquetBoolean), ShouldEqual, parquetBoolean)
+		So(mergeParquetKinds(parquetInt64, parquetNull), ShouldEqual, parquetInt64)
+		So(mergeParquetKinds(parquetInt32, parquetInt64), ShouldEqual, parquetInt64)
+		So(mergeParquetKinds(parquetInt64, parquetDecimal), ShouldEqual, parquetDecimal)
+		So(mergeParquetKinds(parquetInt32, parquetDouble), ShouldEqual, parquetDouble)
+		So(mergeParquetKinds(parquetDouble, parquetDecimal), ShouldEqual, parquetString)
+		So(mergeParquetKinds(parquetDecimal, parquetDouble), ShouldEqual, parquetString)
+		So(mergeParquetKinds(parquetInt32, parquetBoolean), ShouldEqual, parquetString)
+		So(mergeParquetKinds(parquetDocument, parquetArray), ShouldEqual, parquetString)
+	})
+
+	Convey("The schema should be inferred from the sampled documents", t, func() {
+		price, err := bson.ParseDecimal128("1.25")
+		So(err, ShouldBeNil)
+		schema, err := inferParquetSchema(
+			bson.D{
+				{"_id", 1},
+				{"name", "Ann"},
+				{"price", price},
+				{"tags", []interface{}{"a", nil}},
+				{"address", bson.D{{"city", "Dublin"}, {"zip", nil}}},
+				{"born", time.Now()},
+				{"score", int64(2)},
+				{"empty", bson.D{}},
+			},
+			bson.D{{"_id", 2}, {"score", 2.5}, {"name", 3}, {"data", []byte{1}}, {"list", []interface{}{}}},
+		)
+		So(err, ShouldBeNil)
+		So(schema.String(), ShouldEqual, `message c {
+  optional int32 _id;
+  optional binary name (STRING);
+  optional fixed_len_byte_array(16) price (DECIMAL(38,2));
+  optional group tags (LIST) {
+    repeated group list {
+      optional binary element (STRING);
+    }
+  }
+  optional group address {
+    optional binary city (STRING);
+    optional binary zip (STRING);
+  }
+  optional int64 born (TIMESTAMP(MILLIS,true));
+  optional double score;
+  optional binary empty (STRING);
+  optional binary data;
+  optional group list (LIST) {
+    repeated group list {
+      optional binary element (STRING);
+    }
+  }
+}
+`)
+		So(schema.strict[schema.Root], ShouldBeTrue)
+		So(schema.strict[schema.Root.Children[4]], ShouldBeTrue)
+	})
+
+	Convey("Decimals needing more than 38 digits should be written as text", t, func() {
+		small, err := bson.ParseDecimal128("0.00000000000000000001")
+		So(err, ShouldBeNil)
+		large, err := bson.ParseDecimal128("1E+20")
+		So(err, ShouldBeNil)
+		schema, err := inferParquetSchema(bson.D{{"a", small}, {"b", large}}, bson.D{{"a", large}, {"b", 1}})
+		So(err, ShouldBeNil)
+		So(schema.String(), ShouldEqual, `message c {
+  optional binary a (STRING);
+  optional fixed_len_byte_array(16) b (DECIMAL(38,0));
+}
+`)
+	})
+}
+
+func TestParquetExportOutput(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Documents should be converted to the records of the inferred schema", t, func() {
+		price, err := bson.ParseDecimal128("-1.5")
+		So(err, ShouldBeNil)
+		born := time.Date(1990, 1, 2, 3, 4, 5, 6000000, time.UTC)
+		id := bson.ObjectIdHex("5a934e000102030405000000")
+		documents := []bson.D{
+			{
+				{"_id", id},
+				{"price", price},
+				{"tags", []interface{}{"a", nil}},
+				{"address", bson.D{{"city", "Dublin"}}},
+				{"born", born},
+				{"score", 1},
+			},
+			{{"_id", id}, {"price", 2}, {"tags", []interface{}{}}, {"address", nil}, {"score", 0.5}},
+		}
+		schema, err := inferParquetSchema(documents...)
+		So(err, ShouldBeNil)
+		records, err := exportParquet(schema, documents...)
+		So(err, ShouldBeNil)
+
+		minusOneAndHalf := bytes.Repeat([]byte{0xff}, 16)
+		minusOneAndHalf[15] = 0xf1
+		two := make([]byte, 16)
+		two[15] = 20
+		So(records, ShouldResemble, []parquet.Group{
+			{
+				[]byte("5a934e000102030405000000"),
+				minusOneAndHalf,
+				parquet.Group{[]interface{}{parquet.Group{[]byte("a")}, parquet.Group{nil}}},
+				parquet.Group{[]byte("Dublin")},
+				born.UnixNano() / 1e6,
+				1.0,
+			},
+			{
+				[]byte("5a934e000102030405000000"),
+				two,
+				parquet.Group{[]interface{}{}},
+				nil,
+				nil,
+				0.5,
+			},
+		})
+
+		Convey("and fields that weren't sampled should be rejected", func() {
+			_, err := exportParquet(schema, bson.D{{"other", 1}})
+			So(err, ShouldNotBeNil)
+			_, err = exportParquet(schema, bson.D{{"address", bson.D{{"zip", 1}}}})
+			So(err, ShouldNotBeNil)
+			_, err = exportParquet(schema, bson.D{{"score", true}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Documents should be converted to the records of a given schema", t, func() {
+		schema, err := parquet.ParseSchema(`message m {
+			required int32 a;
+			optional int64 t (TIMESTAMP(MICROS,true));
+			optional int32 d (DATE);
+			optional int64 cents (DECIMAL(18,2));
+			optional binary j (JSON);
+			optional group m (MAP) {
+				repeated group key_value {
+					required binary key (STRING);
+					optional int32 value;
+				}
+			}
+			repeated int32 r;
+			optional group o {
+				optional binary x (STRING);
+			}
+		}`)
+		So(err, ShouldBeNil)
+		t := time.Date(1970, 1, 3, 0, 0, 0, 5000, time.UTC)
+		records, err := exportParquet(newParquetSchema(schema, nil), bson.D{
+			{"a", 1},
+			{"t", t},
+			{"d", t},
+			{"cents", 3},
+			{"j", bson.D{{"k", "v"}}},
+			{"m", bson.D{{"x", 1}, {"y", nil}}},
+			{"r", []interface{}{1, 2}},
+			{"o", bson.D{{"x", "y"}, {"ignored", 1}}},
+			{"ignored", 1},
+		})
+		So(err, ShouldBeNil)
+		So(records, ShouldResemble, []parquet.Group{{
+			int32(1),
+			int64(2*86400*1e6 + 5),
+			int32(2),
+			int64(300),
+			[]byte(`{"k":"v"}`),
+			parquet.Group{[]interface{}{
+				parquet.Group{[]byte("x"), int32(1)},
+				parquet.Group{[]byte("y"), nil},
+			}},
+			[]interface{}{int32(1), int32(2)},
+			parquet.Group{[]byte("y")},
+		}})
+
+		Convey("and values that don't fit the fields should be rejected", func() {
+			for _, document := range []bson.D{
+				{},
+				{{"a", int64(1) << 40}},
+				{{"a", 1}, {"cents", 2.5}},
+				{{"a", 1}, {"r", 1}},
+				{{"a", 1}, {"m", []interface{}{1}}},
+			} {
+				_, err := exportParquet(newParquetSchema(schema, nil), document)
+				So(err, ShouldNotBeNil)
+			}
+			for _, value := range []string{"0.001", "1E+16", "1E+6000", "-123456789012345678"} {
+				decimal, err := bson.ParseDecimal128(value)
+				So(err, ShouldBeNil)
+				_, err = exportParquet(newParquetSchema(schema, nil), bson.D{{"a", 1}, {"cents", decimal}})
+				So(err, ShouldNotBeNil)
+			}
+			for _, value := range []string{"0E+6000", "1E+15", "-1234567890123456.78"} {
+				decimal, err := bson.ParseDecimal128(value)
+				So(err, ShouldBeNil)
+				_, err = exportParquet(newParquetSchema(schema, nil), bson.D{{"a", 1}, {"cents", decimal}})
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+
+	Convey("Decimals with more digits than the 38 of an inferred field should be rejected", t, func() {
+		sampled, err := bson.ParseDecimal128("1.5")
+		So(err, ShouldBeNil)
+		schema, err := inferParquetSchema(bson.D{{"a", sampled}})
+		So(err, ShouldBeNil)
+		large, err := bson.ParseDecimal128("1E+37")
+		So(err, ShouldBeNil)
+		_, err = exportParquet(schema, bson.D{{"a", large}})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// goldenParquetDocuments are the documents of the golden Parquet files, which
+// hold a value of each kind of field inferred.
+func goldenParquetDocuments() []bson.D {
+	price, _ := bson.ParseDecimal128("-12.345")
+	return []bson.D{
+		{
+			{"_id", bson.ObjectIdHex("5a934e000102030405000000")},
+			{"flag", true},
+			{"small", 1},
+			{"large", int64(1) << 40},
+			{"price", price},
+			{"score", 2.5},
+			{"name", "Ann"},
+			{"born", time.Date(1990, 1, 2, 3, 4, 5, 6000000, time.UTC)},
+			{"data", []byte{0, 1, 2}},
+			{"address", bson.D{{"city", "Dublin"}, {"zip", nil}}},
+			{"tags", []interface{}{"a", nil, "b"}},
+		},
+		{
+			{"_id", bson.ObjectIdHex("5a934e000102030405000001")},
+			{"flag", false},
+			{"small", -1},
+			{"large", nil},
+			{"price", 7},
+			{"tags", []interface{}{}},
+		},
+	}
+}
+
+func TestParquetGoldenFiles(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	for _, compression := range []string{ParquetCompressionNone, ParquetCompressionSnappy} {
+		compression := compression
+		Convey("The output compressed with "+compression+" should match its golden file", t, func() {
+			documents := goldenParquetDocuments()
+			schema, err := inferParquetSchema(documents...)
+			So(err, ShouldBeNil)
+			out := &bytes.Buffer{}
+			parquetExporter := NewParquetExportOutput(schema, out)
+			parquetExporter.writer.Codec = parquetCodecs[compression]
+			parquetExporter.writer.CreatedBy = "mongoexport"
+			for _, document := range documents {
+				So(parquetExporter.ExportDocument(document), ShouldBeNil)
+			}
+			So(parquetExporter.WriteFooter(), ShouldBeNil)
+			So(parquetExporter.Flush(), ShouldBeNil)
+
+			golden := filepath.Join("testdata", "golden_"+compression+".parquet")
+			if *updateParquetGolden {
+				So(ioutil.WriteFile(golden, out.Bytes(), 0644), ShouldBeNil)
+			}
+			expected, err := ioutil.ReadFile(golden)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(out.Bytes(), expected), ShouldBeTrue)
+		})
+	}
+}
+
+func TestParquetValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a Parquet export", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: Parquet},
+			InputOpts:  &InputOptions{},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldBeNil)
+
+		Convey("the compression should be validated", func() {
+			exp.OutputOpts.ParquetCompression = ParquetCompressionGzip
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.OutputOpts.ParquetCompression = "lz4"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("the sample size and the row group size should be validated", func() {
+			exp.OutputOpts.ParquetSampleSize = "all"
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.OutputOpts.ParquetSampleSize = "none"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+			exp.OutputOpts.ParquetSampleSize = ""
+			exp.OutputOpts.ParquetRowGroupSize = -1
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("a schema file should exclude a field list", func() {
+			exp.OutputOpts.ParquetSchemaFile = "schema.txt"
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.OutputOpts.Fields = "a"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("the output should not be encoded", func() {
+			exp.OutputOpts.Encoding = "utf-16"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("the writer should be configured by the options", func() {
+			schema, err := parquet.ParseSchema("message c { optional int32 a; }")
+			So(err, ShouldBeNil)
+			exp.parquetSchema = newParquetSchema(schema, nil)
+			exp.OutputOpts.ParquetRowGroupSize = 2
+			exportOutput, err := exp.getExportOutput(&bytes.Buffer{})
+			So(err, ShouldBeNil)
+			parquetOutput := exportOutput.(*ParquetExportOutput)
+			So(parquetOutput.writer.Codec, ShouldEqual, parquet.Snappy)
+			So(parquetOutput.writer.RowGroupSize, ShouldEqual, 2*1024*1024)
+		})
+
+		Convey("Parquet options should be rejected for other output types", func() {
+			exp.OutputOpts.Type = JSON
+			exp.OutputOpts.ParquetCompression = ParquetCompressionNone
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+}
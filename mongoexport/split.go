@@ -0,0 +1,396 @@
+package mongoexport
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/progress"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// defaultPartitionField is the field used to partition the collection between
+// parallel readers when --partitionField isn't set.
+const defaultPartitionField = "_id"
+
+// splitFiles hands out the numbered files of a split export, in the order
+// they are created by any of the readers.
+type splitFiles struct {
+	base, ext string
+
+	lock  sync.Mutex
+	count int
+}
+
+// newSplitFiles returns a splitFiles naming its files after outputFile, with
+// the extension of the output type if outputFile has none.
+func newSplitFiles(outputFile, outputType string) *splitFiles {
+	ext := filepath.Ext(outputFile)
+	if ext == "" {
+		ext = "." + outputType
+	}
+	return &splitFiles{base: strings.TrimSuffix(outputFile, ext), ext: ext}
+}
+
+// splitFileName returns the name of the n-th file of a split export, counting
+// from 1; e.g. out.json becomes out-0001.json.
+func splitFileName(base, ext string, n int) string {
+	return fmt.Sprintf("%v-%04d%v", base, n, ext)
+}
+
+// create creates the next numbered file.
+func (files *splitFiles) create() (*os.File, error) {
+	files.lock.Lock()
+	files.count++
+	name := splitFileName(files.base, files.ext, files.count)
+	files.lock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0750); err != nil {
+		return nil, err
+	}
+	return os.Create(util.ToUniversalPath(name))
+}
+
+// partitionFilters returns the filters selecting each range of values of the
+// partition field delimited by the given boundaries, which must be sorted,
+// distinct and of the same type. Range queries only match values of the type
+// they are given, so the first range also holds the documents whose value is
+// missing or of another type, so that every document is in exactly one range.
+func partitionFilters(field string, boundaries []interface{}) []bson.M {
+	if len(boundaries) == 0 {
+		return []bson.M{{}}
+	}
+	filters := []bson.M{{field: bson.M{"$not": bson.M{"$gte": boundaries[0]}}}}
+	for i := 1; i < len(boundaries); i++ {
+		filters = append(filters, bson.M{field: bson.M{"$gte": boundaries[i-1], "$lt": boundaries[i]}})
+	}
+	return append(filters, bson.M{field: bson.M{"$gte": boundaries[len(boundaries)-1]}})
+}
+
+// boundaryTypeClass returns the name of the set of types that range queries
+// compare a value with; all numeric types are compared with each other.
+func boundaryTypeClass(value interface{}) string {
+	switch value.(type) {
+	case int, int32, int64, float32, float64, bson.Decimal128:
+		return "number"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+// lookupFieldValue returns the value of a dot-delimited field of a document,
+// or nil if it is missing.
+func lookupFieldValue(document bson.M, field string) interface{} {
+	var value interface{} = document
+	for _, name := range strings.Split(field, ".") {
+		subdoc, ok := value.(bson.M)
+		if !ok {
+			return nil
+		}
+		value = subdoc[name]
+	}
+	return value
+}
+
+// samplesPerPartition is the number of documents sampled for each range of
+// the partition field, to find the boundaries of the ranges.
+const samplesPerPartition = 20
+
+// pickBoundaries returns up to n-1 values splitting sorted sample values into
+// n ranges of roughly the same number of values. Only values of the most
+// common type are used, since range queries only match values of the type
+// they are given, and values equal to the previous boundary are skipped, so
+// fewer ranges may be returned.
+func pickBoundaries(samples []interface{}, n int) []interface{} {
+	counts := map[string]int{}
+	class := ""
+	for _, value := range samples {
+		if value == nil {
+			continue
+		}
+		c := boundaryTypeClass(value)
+		counts[c]++
+		if counts[c] > counts[class] {
+			class = c
+		}
+	}
+	values := []interface{}{}
+	for _, value := range samples {
+		if value != nil && boundaryTypeClass(value) == class {
+			values = append(values, value)
+		}
+	}
+
+	boundaries := []interface{}{}
+	for i := 1; i < n && len(values) != 0; i++ {
+		value := values[i*len(values)/n]
+		if len(boundaries) != 0 && reflect.DeepEqual(value, boundaries[len(boundaries)-1]) {
+			continue
+		}
+		boundaries = append(boundaries, value)
+	}
+	return boundaries
+}
+
+// getPartitionBoundaries returns up to n-1 values of the partition field that
+// split the documents matching the query into n ranges of roughly the same
+// size, estimated from a random sample of the documents, which takes a single
+// pass over them at most.
+func (exp *MongoExport) getPartitionBoundaries(query map[string]interface{}, field string, n int) ([]interface{}, error) {
+	session, err := exp.SessionProvider.GetSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	collection := session.DB(exp.ToolOptions.Namespace.DB).C(exp.ToolOptions.Namespace.Collection)
+
+	// $sample only avoids a collection scan as the first stage
+	pipeline := []bson.M{
+		{"$sample": bson.M{"size": n * samplesPerPartition}},
+		{"$project": bson.M{field: 1}},
+		{"$sort": bson.M{field: 1}},
+	}
+	if len(query) != 0 {
+		pipeline = append([]bson.M{{"$match": query}}, pipeline...)
+	}
+	iter := collection.Pipe(pipeline).AllowDiskUse().Iter()
+	samples := []interface{}{}
+	var document bson.M
+	for iter.Next(&document) {
+		samples = append(samples, lookupFieldValue(document, field))
+		document = nil
+	}
+	if err = iter.Close(); err != nil {
+		return nil, fmt.Errorf("error sampling partition boundaries: %v", err)
+	}
+	return pickBoundaries(samples, n), nil
+}
+
+// getPartitionCursor returns a cursor over the documents matching both the
+// query given to mongoexport and a partition filter, along with the associated
+// session.
+func (exp *MongoExport) getPartitionCursor(query map[string]interface{}, filter bson.M) (*mgo.Iter, *mgo.Session, error) {
+	session, err := exp.SessionProvider.GetSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	var selector interface{} = filter
+	if len(query) != 0 {
+		selector = bson.M{"$and": []interface{}{query, filter}}
+	}
+	q := session.DB(exp.ToolOptions.Namespace.DB).
+		C(exp.ToolOptions.Namespace.Collection).Find(selector)
+	if len(exp.OutputOpts.Fields) > 0 {
+		q.Select(makeFieldSelector(exp.OutputOpts.Fields))
+	}
+	return q.Iter(), session, nil
+}
+
+// errExportStopped is returned by the parallel readers stopped because
+// another one failed.
+var errExportStopped = errors.New("export stopped")
+
+// exportToFiles writes the documents of a cursor to numbered files, starting a
+// new file after every --maxDocsPerFile documents. Files are only created
+// once there is a document to write to them. It returns errExportStopped
+// once stop is closed, if set.
+func (exp *MongoExport) exportToFiles(cursor *mgo.Iter, files *splitFiles, stop <-chan struct{},
+	counters ...progress.Updateable) (int64, error) {
+	var file *os.File
+	var exportOutput ExportOutput
+	var docsCount, docsInFile int64
+
+	closeFile := func() error {
+		if file == nil {
+			return nil
+		}
+		err := exportOutput.WriteFooter()
+		if err == nil {
+			err = exportOutput.Flush()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			exp.commitIncremental()
+		}
+		file, exportOutput = nil, nil
+		return err
+	}
+	defer closeFile()
+
+	var result bson.D
+	for cursor.Next(&result) {
+		select {
+		case <-stop:
+			return docsCount, errExportStopped
+		default:
+		}
+		maxDocs := int64(exp.OutputOpts.MaxDocsPerFile)
+		if file == nil || (maxDocs > 0 && docsInFile == maxDocs) {
+			if err := closeFile(); err != nil {
+				return docsCount, err
+			}
+			var err error
+			if file, err = files.create(); err != nil {
+				return docsCount, err
+			}
+			if exportOutput, err = exp.getExportOutput(file); err != nil {
+				return docsCount, err
+			}
+			if err = exportOutput.WriteHeader(); err != nil {
+				return docsCount, err
+			}
+			docsInFile = 0
+		}
+		exp.observeIncremental(result)
+		result = exp.stripIncremental(result)
+		if err := exportOutput.ExportDocument(result); err != nil {
+			return docsCount, err
+		}
+		docsCount++
+		docsInFile++
+		if docsCount%watchProgressorUpdateFrequency == 0 {
+			for _, counter := range counters {
+				counter.Inc(watchProgressorUpdateFrequency)
+			}
+		}
+	}
+	for _, counter := range counters {
+		counter.Inc(docsCount % watchProgressorUpdateFrequency)
+	}
+	if err := cursor.Err(); err != nil {
+		return docsCount, err
+	}
+	return docsCount, closeFile()
+}
+
+// ExportSplit executes an export to the numbered files named after --out. With
+// --numParallelReaders, the collection is partitioned into ranges of the
+// partition field, each exported concurrently by its own reader. It returns
+// the number of documents exported.
+func (exp *MongoExport) ExportSplit() (int64, error) {
+	ns := fmt.Sprintf("%v.%v", exp.ToolOptions.Namespace.DB, exp.ToolOptions.Namespace.Collection)
+	files := newSplitFiles(exp.OutputOpts.OutputFile, exp.OutputOpts.Type)
+	if err := exp.scanCSVColumns(); err != nil {
+		return 0, err
+	}
+	if err := exp.scanSQLColumns(); err != nil {
+		return 0, err
+	}
+	if err := exp.scanParquetSchema(); err != nil {
+		return 0, err
+	}
+
+	progressManager := progress.NewProgressBarManager(log.Writer(0), progressBarWaitTime)
+	progressManager.Start()
+	defer progressManager.Stop()
+
+	numReaders := 1
+	if exp.InputOpts != nil && exp.InputOpts.NumParallelReaders > 1 {
+		numReaders = exp.InputOpts.NumParallelReaders
+	}
+	if numReaders == 1 {
+		max, err := exp.getCount()
+		if err != nil {
+			return 0, err
+		}
+		watchProgressor := progress.NewCounter(int64(max))
+		bar := &progress.Bar{Name: ns, Watching: watchProgressor, BarLength: progressBarLength}
+		progressManager.Attach(bar)
+		defer progressManager.Detach(bar)
+
+		cursor, session, err := exp.getCursor()
+		if err != nil {
+			return 0, err
+		}
+		defer session.Close()
+		defer cursor.Close()
+		count, err := exp.exportToFiles(cursor, files, nil, watchProgressor)
+		log.Logf(log.Always, "wrote %v %v", files.count, util.Pluralize(files.count, "file", "files"))
+		return count, err
+	}
+
+	query, err := exp.getQuery()
+	if err != nil {
+		return 0, err
+	}
+	field := exp.InputOpts.PartitionField
+	if field == "" {
+		field = defaultPartitionField
+	}
+	session, err := exp.SessionProvider.GetSession()
+	if err != nil {
+		return 0, err
+	}
+	total, err := session.DB(exp.ToolOptions.Namespace.DB).C(exp.ToolOptions.Namespace.Collection).
+		Find(query).Count()
+	session.Close()
+	if err != nil {
+		return 0, err
+	}
+	boundaries, err := exp.getPartitionBoundaries(query, field, numReaders)
+	if err != nil {
+		return 0, err
+	}
+	filters := partitionFilters(field, boundaries)
+	log.Logf(log.Info, "exporting %v with %v parallel %v on '%v'", ns, len(filters),
+		util.Pluralize(len(filters), "reader", "readers"), field)
+
+	totalProgressor := progress.NewCounter(int64(total))
+	totalBar := &progress.Bar{Name: ns, Watching: totalProgressor, BarLength: progressBarLength}
+	progressManager.Attach(totalBar)
+	defer progressManager.Detach(totalBar)
+
+	// the first reader to fail stops the others, whose cursors are closed
+	var docsCount int64
+	var countLock sync.Mutex
+	var stopOnce sync.Once
+	stop := make(chan struct{})
+	errChan := make(chan error, len(filters))
+	for i, filter := range filters {
+		progressor := progress.NewCounter(int64(total / len(filters)))
+		bar := &progress.Bar{
+			Name:      fmt.Sprintf("%v [%v/%v]", ns, i+1, len(filters)),
+			Watching:  progressor,
+			BarLength: progressBarLength,
+		}
+		progressManager.Attach(bar)
+		defer progressManager.Detach(bar)
+
+		go func(filter bson.M) {
+			err := func() error {
+				cursor, session, err := exp.getPartitionCursor(query, filter)
+				if err != nil {
+					return err
+				}
+				defer session.Close()
+				defer cursor.Close()
+				count, err := exp.exportToFiles(cursor, files, stop, progressor, totalProgressor)
+				countLock.Lock()
+				docsCount += count
+				countLock.Unlock()
+				return err
+			}()
+			if err != nil && err != errExportStopped {
+				stopOnce.Do(func() { close(stop) })
+			}
+			errChan <- err
+		}(filter)
+	}
+
+	// wait for every reader, so that all files are complete
+	var firstErr error
+	for range filters {
+		if err := <-errChan; err != nil && err != errExportStopped && firstErr == nil {
+			firstErr = err
+		}
+	}
+	log.Logf(log.Always, "wrote %v %v", files.count, util.Pluralize(files.count, "file", "files"))
+	return docsCount, firstErr
+}
@@ -0,0 +1,117 @@
+package mongoexport
+
+import (
+	"github.com/mongodb/mongo-tools/common/options"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+func TestSplitFileNames(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Split files should be numbered before the extension", t, func() {
+		files := newSplitFiles("dir/out.json", JSON)
+		So(splitFileName(files.base, files.ext, 1), ShouldEqual, "dir/out-0001.json")
+		So(splitFileName(files.base, files.ext, 12345), ShouldEqual, "dir/out-12345.json")
+
+		Convey("and take the extension of the output type if there is none", func() {
+			files := newSplitFiles("out", CSV)
+			So(splitFileName(files.base, files.ext, 2), ShouldEqual, "out-0002.csv")
+		})
+	})
+}
+
+func TestPartitionFilters(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Without boundaries there should be a single unfiltered partition", t, func() {
+		So(partitionFilters("_id", nil), ShouldResemble, []bson.M{{}})
+	})
+
+	Convey("Boundaries should delimit consecutive ranges", t, func() {
+		So(partitionFilters("a.b", []interface{}{10, 20}), ShouldResemble, []bson.M{
+			{"a.b": bson.M{"$not": bson.M{"$gte": 10}}},
+			{"a.b": bson.M{"$gte": 10, "$lt": 20}},
+			{"a.b": bson.M{"$gte": 20}},
+		})
+	})
+
+	Convey("Numeric boundaries should be compared with each other", t, func() {
+		So(boundaryTypeClass(int32(1)), ShouldEqual, boundaryTypeClass(2.5))
+		So(boundaryTypeClass("1"), ShouldNotEqual, boundaryTypeClass(1))
+	})
+
+	Convey("Boundaries should split sorted samples into ranges of the same size", t, func() {
+		samples := []interface{}{1, 2, 3, 4, 5, 6, 7, 8}
+		So(pickBoundaries(samples, 4), ShouldResemble, []interface{}{3, 5, 7})
+		So(pickBoundaries(samples, 1), ShouldBeEmpty)
+		So(pickBoundaries(nil, 4), ShouldBeEmpty)
+	})
+
+	Convey("Boundaries should be distinct values of the most common type", t, func() {
+		samples := []interface{}{nil, 1, 1, 1, 1, 2.5, 3, "a", "b"}
+		So(pickBoundaries(samples, 3), ShouldResemble, []interface{}{1, 2.5})
+		So(pickBoundaries(samples, 6), ShouldResemble, []interface{}{1, 2.5, 3})
+	})
+
+	Convey("Partition field values should be found in subdocuments", t, func() {
+		document := bson.M{"a": bson.M{"b": 3}, "c": 4}
+		So(lookupFieldValue(document, "a.b"), ShouldEqual, 3)
+		So(lookupFieldValue(document, "c"), ShouldEqual, 4)
+		So(lookupFieldValue(document, "c.d"), ShouldBeNil)
+		So(lookupFieldValue(document, "e"), ShouldBeNil)
+	})
+}
+
+func TestSplitValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a parallel split export", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: JSON, OutputFile: "out.json", SplitOutput: true},
+			InputOpts:  &InputOptions{NumParallelReaders: 4},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldBeNil)
+
+		Convey("a maximum number of documents per file should be allowed", func() {
+			exp.OutputOpts.MaxDocsPerFile = 1000
+			So(exp.ValidateSettings(), ShouldBeNil)
+		})
+
+		Convey("an output file should be required", func() {
+			exp.OutputOpts.OutputFile = ""
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("--splitOutput should be required", func() {
+			exp.OutputOpts.SplitOutput = false
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("options that order the documents should be rejected", func() {
+			exp.InputOpts.Sort = `{"a": 1}`
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("a partition field should require several readers", func() {
+			exp.InputOpts.PartitionField = "a"
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.InputOpts.NumParallelReaders = 1
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+
+	Convey("--maxDocsPerFile should require --splitOutput", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: JSON, MaxDocsPerFile: 10},
+			InputOpts:  &InputOptions{},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,482 @@
+package mongoexport
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/json"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQL dialects supported by the SQL output type.
+const (
+	SQLDialectPostgres = "postgres"
+	SQLDialectMySQL    = "mysql"
+	SQLDialectSQLite   = "sqlite"
+)
+
+const (
+	// defaultSQLBatchSize is the number of rows inserted by each INSERT
+	// statement if --sqlBatchSize isn't set.
+	defaultSQLBatchSize = 100
+
+	// defaultSQLSampleSize is the number of documents from which the column
+	// types are inferred if --sqlSampleSize isn't set.
+	defaultSQLSampleSize = 1000
+)
+
+// sqlType is a class of BSON values stored in the same type of SQL column.
+type sqlType int
+
+const (
+	// sqlNull is the type of a column for which only missing or null values
+	// were seen; it is created as a text column.
+	sqlNull sqlType = iota
+	sqlBoolean
+	// numeric types, in increasing order of the values they can hold
+	sqlInteger
+	sqlBigInt
+	sqlDouble
+	sqlDecimal
+	sqlText
+	sqlTimestamp
+	sqlObjectId
+	sqlBinary
+	// sqlJSON holds documents and arrays
+	sqlJSON
+)
+
+// sqlColumnTypes maps the type of a column to its name in each dialect.
+var sqlColumnTypes = map[string]map[sqlType]string{
+	SQLDialectPostgres: {
+		sqlNull:      "TEXT",
+		sqlBoolean:   "BOOLEAN",
+		sqlInteger:   "INTEGER",
+		sqlBigInt:    "BIGINT",
+		sqlDouble:    "DOUBLE PRECISION",
+		sqlDecimal:   "NUMERIC",
+		sqlText:      "TEXT",
+		sqlTimestamp: "TIMESTAMP WITH TIME ZONE",
+		sqlObjectId:  "CHAR(24)",
+		sqlBinary:    "BYTEA",
+		sqlJSON:      "JSONB",
+	},
+	SQLDialectMySQL: {
+		sqlNull:      "LONGTEXT",
+		sqlBoolean:   "BOOLEAN",
+		sqlInteger:   "INT",
+		sqlBigInt:    "BIGINT",
+		sqlDouble:    "DOUBLE",
+		sqlDecimal:   "DECIMAL(65,30)",
+		sqlText:      "LONGTEXT",
+		sqlTimestamp: "DATETIME(3)",
+		sqlObjectId:  "CHAR(24)",
+		sqlBinary:    "LONGBLOB",
+		sqlJSON:      "JSON",
+	},
+	SQLDialectSQLite: {
+		sqlNull:      "TEXT",
+		sqlBoolean:   "INTEGER",
+		sqlInteger:   "INTEGER",
+		sqlBigInt:    "INTEGER",
+		sqlDouble:    "REAL",
+		sqlDecimal:   "NUMERIC",
+		sqlText:      "TEXT",
+		sqlTimestamp: "TEXT",
+		sqlObjectId:  "TEXT",
+		sqlBinary:    "BLOB",
+		sqlJSON:      "TEXT",
+	},
+}
+
+// bsonSQLType returns the class of column that holds a BSON value. Values of
+// types without a column type of their own are stored as text.
+func bsonSQLType(value interface{}) sqlType {
+	switch value.(type) {
+	case nil:
+		return sqlNull
+	case bool:
+		return sqlBoolean
+	case int, int32:
+		return sqlInteger
+	case int64:
+		return sqlBigInt
+	case float32, float64:
+		return sqlDouble
+	case bson.Decimal128:
+		return sqlDecimal
+	case time.Time:
+		return sqlTimestamp
+	case bson.ObjectId:
+		return sqlObjectId
+	case []byte, bson.Binary:
+		return sqlBinary
+	case bson.D, bson.M, []interface{}:
+		return sqlJSON
+	}
+	if value == bson.Undefined {
+		return sqlNull
+	}
+	return sqlText
+}
+
+// mergeSQLTypes returns the type of a column holding values of both types:
+// the wider of two numeric types, or text if the types differ otherwise.
+func mergeSQLTypes(left, right sqlType) sqlType {
+	switch {
+	case left == right || right == sqlNull:
+		return left
+	case left == sqlNull:
+		return right
+	case left >= sqlInteger && left <= sqlDecimal && right >= sqlInteger && right <= sqlDecimal:
+		if left > right {
+			return left
+		}
+		return right
+	}
+	return sqlText
+}
+
+// SQLExportOutput is an implementation of ExportOutput that writes documents
+// to the output as SQL statements: a CREATE TABLE statement followed by
+// batched INSERT statements.
+type SQLExportOutput struct {
+	// Table is the name of the table created and inserted into.
+	Table string
+
+	// Fields is a list of field names in the bson documents to be exported,
+	// each written to the column of the same name. A field can also use
+	// dot-delimited modifiers to address nested structures.
+	Fields []string
+
+	// Dialect is the SQL dialect written, and is one of the SQLDialect
+	// constants.
+	Dialect string
+
+	// BatchSize is the maximum number of rows inserted by each INSERT statement.
+	BatchSize int
+
+	// NumExported maintains a running total of the number of documents written.
+	NumExported int64
+
+	// types holds the types of the columns of the fields
+	types []sqlType
+
+	rowsInBatch int
+	out         *bufio.Writer
+}
+
+// NewSQLExportOutput returns a SQLExportOutput configured to write statements
+// in the given dialect to the given io.Writer, inserting the specified fields
+// into a table. The columns are created as text columns until their types
+// are set.
+func NewSQLExportOutput(table string, fields []string, dialect string, out io.Writer) *SQLExportOutput {
+	return &SQLExportOutput{
+		Table:     table,
+		Fields:    fields,
+		Dialect:   dialect,
+		BatchSize: defaultSQLBatchSize,
+		types:     make([]sqlType, len(fields)),
+		out:       bufio.NewWriter(out),
+	}
+}
+
+// setColumnTypes sets the types of the columns, one for each field.
+func (sqlExporter *SQLExportOutput) setColumnTypes(types []sqlType) {
+	sqlExporter.types = types
+}
+
+// quoteIdentifier returns a table or column name quoted for the dialect.
+func (sqlExporter *SQLExportOutput) quoteIdentifier(name string) string {
+	if sqlExporter.Dialect == SQLDialectMySQL {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// mysqlEscaper escapes the characters that have a special meaning in MySQL
+// string literals, as mysqldump does.
+var mysqlEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+// quoteString returns a string literal for the dialect.
+func (sqlExporter *SQLExportOutput) quoteString(s string) string {
+	if sqlExporter.Dialect == SQLDialectMySQL {
+		return "'" + mysqlEscaper.Replace(s) + "'"
+	}
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// WriteHeader writes the CREATE TABLE statement. The table is only created if
+// it doesn't exist, so that the files of a split export can be loaded in any
+// order.
+func (sqlExporter *SQLExportOutput) WriteHeader() error {
+	columnTypes := sqlColumnTypes[sqlExporter.Dialect]
+	columns := make([]string, len(sqlExporter.Fields))
+	for i, field := range sqlExporter.Fields {
+		columns[i] = fmt.Sprintf("  %v %v", sqlExporter.quoteIdentifier(field), columnTypes[sqlExporter.types[i]])
+	}
+	_, err := fmt.Fprintf(sqlExporter.out, "CREATE TABLE IF NOT EXISTS %v (\n%v\n);\n",
+		sqlExporter.quoteIdentifier(sqlExporter.Table), strings.Join(columns, ",\n"))
+	return err
+}
+
+// WriteFooter ends the last INSERT statement.
+func (sqlExporter *SQLExportOutput) WriteFooter() error {
+	if sqlExporter.rowsInBatch == 0 {
+		return nil
+	}
+	sqlExporter.rowsInBatch = 0
+	_, err := sqlExporter.out.WriteString(";\n")
+	return err
+}
+
+// Flush writes any pending data to the underlying I/O stream.
+func (sqlExporter *SQLExportOutput) Flush() error {
+	return sqlExporter.out.Flush()
+}
+
+// ExportDocument writes a row with the SQL representation of a document,
+// starting a new INSERT statement after every BatchSize rows. Missing fields
+// are written as NULL.
+func (sqlExporter *SQLExportOutput) ExportDocument(document bson.D) error {
+	values := make([]string, len(sqlExporter.Fields))
+	for i, field := range sqlExporter.Fields {
+		value, _ := lookupBSONValue(document, field)
+		literal, err := sqlExporter.formatValue(sqlExporter.types[i], value)
+		if err != nil {
+			return fmt.Errorf("error exporting field '%v': %v", field, err)
+		}
+		values[i] = literal
+	}
+
+	var statement string
+	if sqlExporter.rowsInBatch == 0 {
+		columns := make([]string, len(sqlExporter.Fields))
+		for i, field := range sqlExporter.Fields {
+			columns[i] = sqlExporter.quoteIdentifier(field)
+		}
+		statement = fmt.Sprintf("INSERT INTO %v (%v) VALUES\n", sqlExporter.quoteIdentifier(sqlExporter.Table),
+			strings.Join(columns, ", "))
+	} else {
+		statement = ",\n"
+	}
+	statement += "(" + strings.Join(values, ", ") + ")"
+	sqlExporter.rowsInBatch++
+	if sqlExporter.BatchSize > 0 && sqlExporter.rowsInBatch == sqlExporter.BatchSize {
+		statement += ";\n"
+		sqlExporter.rowsInBatch = 0
+	}
+	if _, err := sqlExporter.out.WriteString(statement); err != nil {
+		return err
+	}
+	sqlExporter.NumExported++
+	return nil
+}
+
+// formatValue returns the SQL literal of a value written to a column of the
+// given type. Every value can be written to a text column; values of other
+// types must be convertible to the type of the column without loss.
+func (sqlExporter *SQLExportOutput) formatValue(columnType sqlType, value interface{}) (string, error) {
+	if bsonSQLType(value) == sqlNull {
+		return "NULL", nil
+	}
+	switch columnType {
+	case sqlNull, sqlText:
+		text, err := sqlTextValue(value)
+		if err != nil {
+			return "", err
+		}
+		return sqlExporter.quoteString(text), nil
+	case sqlJSON:
+		text, err := sqlJSONValue(value)
+		if err != nil {
+			return "", err
+		}
+		return sqlExporter.quoteString(text), nil
+	case sqlBoolean:
+		if v, ok := value.(bool); ok {
+			if sqlExporter.Dialect == SQLDialectSQLite {
+				if v {
+					return "1", nil
+				}
+				return "0", nil
+			}
+			if v {
+				return "TRUE", nil
+			}
+			return "FALSE", nil
+		}
+	case sqlInteger, sqlBigInt:
+		switch v := value.(type) {
+		case int:
+			if columnType == sqlBigInt || int64(v) == int64(int32(v)) {
+				return strconv.Itoa(v), nil
+			}
+		case int32:
+			return strconv.FormatInt(int64(v), 10), nil
+		case int64:
+			if columnType == sqlBigInt || v == int64(int32(v)) {
+				return strconv.FormatInt(v, 10), nil
+			}
+		}
+	case sqlDouble, sqlDecimal:
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case int32:
+			return strconv.FormatInt(int64(v), 10), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float32:
+			return sqlExporter.formatFloat(float64(v)), nil
+		case float64:
+			return sqlExporter.formatFloat(v), nil
+		case bson.Decimal128:
+			if columnType == sqlDecimal {
+				return sqlExporter.formatDecimal(v), nil
+			}
+		}
+	case sqlTimestamp:
+		if v, ok := value.(time.Time); ok {
+			if sqlExporter.Dialect == SQLDialectPostgres {
+				return sqlExporter.quoteString(v.UTC().Format("2006-01-02 15:04:05.000-07")), nil
+			}
+			return sqlExporter.quoteString(v.UTC().Format("2006-01-02 15:04:05.000")), nil
+		}
+	case sqlObjectId:
+		if v, ok := value.(bson.ObjectId); ok {
+			return sqlExporter.quoteString(v.Hex()), nil
+		}
+	case sqlBinary:
+		data, ok := value.([]byte)
+		if binary, isBinary := value.(bson.Binary); isBinary {
+			data, ok = binary.Data, true
+		}
+		if ok {
+			if sqlExporter.Dialect == SQLDialectPostgres {
+				return `'\x` + hex.EncodeToString(data) + "'", nil
+			}
+			return "X'" + hex.EncodeToString(data) + "'", nil
+		}
+	}
+	return "", fmt.Errorf("cannot write a value of type %T to a %v column; the column type was "+
+		"inferred from a sample of the documents, which --sqlSampleSize can enlarge",
+		value, sqlColumnTypes[sqlExporter.Dialect][columnType])
+}
+
+// formatFloat returns the literal of a double. Only PostgreSQL supports
+// infinite and NaN values, which are written as NULL in the other dialects.
+func (sqlExporter *SQLExportOutput) formatFloat(f float64) string {
+	switch {
+	case !math.IsInf(f, 0) && !math.IsNaN(f):
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case sqlExporter.Dialect != SQLDialectPostgres:
+		return "NULL"
+	case math.IsNaN(f):
+		return "'NaN'"
+	case f > 0:
+		return "'Infinity'"
+	}
+	return "'-Infinity'"
+}
+
+// formatDecimal returns the literal of a decimal, writing infinite and NaN
+// values as formatFloat does.
+func (sqlExporter *SQLExportOutput) formatDecimal(d bson.Decimal128) string {
+	switch {
+	case d.IsNaN():
+		return sqlExporter.formatFloat(math.NaN())
+	case d.IsInf() && strings.HasPrefix(d.String(), "-"):
+		return sqlExporter.formatFloat(math.Inf(-1))
+	case d.IsInf():
+		return sqlExporter.formatFloat(math.Inf(1))
+	}
+	return d.String()
+}
+
+// sqlTextValue returns the text stored in a text column for a value: strings are
+// stored as they are, ObjectIds as their hex representation and other values
+// as relaxed extended JSON.
+func sqlTextValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bson.Symbol:
+		return string(v), nil
+	case bson.ObjectId:
+		return v.Hex(), nil
+	}
+	return sqlJSONValue(value)
+}
+
+// sqlJSONValue returns the relaxed extended JSON representation of a value.
+func sqlJSONValue(value interface{}) (string, error) {
+	extendedValue, err := bsonutil.ConvertBSONValueToExtendedJSON(value, bsonutil.RelaxedJSONFormat)
+	if err != nil {
+		return "", err
+	}
+	jsonOut, err := json.Marshal(extendedValue)
+	if err != nil {
+		return "", fmt.Errorf("error converting BSON to extended JSON: %v", err)
+	}
+	return string(jsonOut), nil
+}
+
+// scanSQLColumns infers the types of the SQL columns from a sample of the
+// documents to export, before the export.
+func (exp *MongoExport) scanSQLColumns() error {
+	if exp.OutputOpts.Type != SQL {
+		return nil
+	}
+	fields, err := exp.getFields()
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("SQL mode requires a field list")
+	}
+	sampleSize, err := exp.OutputOpts.sqlSampleSize()
+	if err != nil {
+		return err
+	}
+
+	cursor, session, err := exp.getCursor()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer cursor.Close()
+
+	types := make([]sqlType, len(fields))
+	var result bson.D
+	numScanned := 0
+	for ; (sampleSize == 0 || numScanned < sampleSize) && cursor.Next(&result); numScanned++ {
+		for i, field := range fields {
+			if value, ok := lookupBSONValue(result, field); ok {
+				types[i] = mergeSQLTypes(types[i], bsonSQLType(value))
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("error scanning documents for SQL column types: %v", err)
+	}
+	log.Logf(log.Info, "inferred SQL column types from %v %v", numScanned,
+		util.Pluralize(numScanned, "document", "documents"))
+	exp.sqlTypes = types
+	return nil
+}
@@ -0,0 +1,197 @@
+package mongoexport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/options"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSQLColumnTypes(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("BSON values should be mapped to column types", t, func() {
+		So(bsonSQLType(nil), ShouldEqual, sqlNull)
+		So(bsonSQLType(bson.Undefined), ShouldEqual, sqlNull)
+		So(bsonSQLType(1), ShouldEqual, sqlInteger)
+		So(bsonSQLType(int64(1)), ShouldEqual, sqlBigInt)
+		So(bsonSQLType(bson.NewObjectId()), ShouldEqual, sqlObjectId)
+		So(bsonSQLType(bson.Binary{Kind: 0x80, Data: []byte{1}}), ShouldEqual, sqlBinary)
+		So(bsonSQLType(bson.D{{"a", 1}}), ShouldEqual, sqlJSON)
+		So(bsonSQLType([]interface{}{1}), ShouldEqual, sqlJSON)
+		So(bsonSQLType(bson.MongoTimestamp(1)), ShouldEqual, sqlText)
+	})
+
+	Convey("Column types should be widened to hold every value", t, func() {
+		So(mergeSQLTypes(sqlNull, sqlBoolean), ShouldEqual, sqlBoolean)
+		So(mergeSQLTypes(sqlBigInt, sqlNull), ShouldEqual, sqlBigInt)
+		So(mergeSQLTypes(sqlInteger, sqlBigInt), ShouldEqual, sqlBigInt)
+		So(mergeSQLTypes(sqlDouble, sqlInteger), ShouldEqual, sqlDouble)
+		So(mergeSQLTypes(sqlDouble, sqlDecimal), ShouldEqual, sqlDecimal)
+		So(mergeSQLTypes(sqlInteger, sqlBoolean), ShouldEqual, sqlText)
+		So(mergeSQLTypes(sqlJSON, sqlTimestamp), ShouldEqual, sqlText)
+	})
+}
+
+func TestSQLExportOutput(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a PostgreSQL export output", t, func() {
+		out := &bytes.Buffer{}
+		sqlExporter := NewSQLExportOutput("people", []string{"_id", "name", "age", "born", "address"},
+			SQLDialectPostgres, out)
+		sqlExporter.setColumnTypes([]sqlType{sqlObjectId, sqlText, sqlInteger, sqlTimestamp, sqlJSON})
+		sqlExporter.BatchSize = 2
+
+		Convey("the table should be created with the column types", func() {
+			So(sqlExporter.WriteHeader(), ShouldBeNil)
+			So(sqlExporter.Flush(), ShouldBeNil)
+			So(out.String(), ShouldEqual, "CREATE TABLE IF NOT EXISTS \"people\" (\n"+
+				"  \"_id\" CHAR(24),\n"+
+				"  \"name\" TEXT,\n"+
+				"  \"age\" INTEGER,\n"+
+				"  \"born\" TIMESTAMP WITH TIME ZONE,\n"+
+				"  \"address\" JSONB\n"+
+				");\n")
+		})
+
+		Convey("rows should be inserted in batches", func() {
+			id := bson.ObjectIdHex("5a934e000102030405000000")
+			born := time.Date(1990, 1, 2, 3, 4, 5, 6000000, time.UTC)
+			So(sqlExporter.ExportDocument(bson.D{
+				{"_id", id},
+				{"name", "O'Brien"},
+				{"age", 27},
+				{"born", born},
+				{"address", bson.D{{"city", "Dublin"}}},
+			}), ShouldBeNil)
+			So(sqlExporter.ExportDocument(bson.D{{"_id", id}}), ShouldBeNil)
+			So(sqlExporter.ExportDocument(bson.D{{"_id", id}, {"age", nil}}), ShouldBeNil)
+			So(sqlExporter.WriteFooter(), ShouldBeNil)
+			So(sqlExporter.Flush(), ShouldBeNil)
+			So(sqlExporter.NumExported, ShouldEqual, 3)
+
+			insert := "INSERT INTO \"people\" (\"_id\", \"name\", \"age\", \"born\", \"address\") VALUES\n"
+			So(out.String(), ShouldEqual, insert+
+				"('5a934e000102030405000000', 'O''Brien', 27, '1990-01-02 03:04:05.006+00', '{\"city\":\"Dublin\"}'),\n"+
+				"('5a934e000102030405000000', NULL, NULL, NULL, NULL);\n"+
+				insert+
+				"('5a934e000102030405000000', NULL, NULL, NULL, NULL);\n")
+		})
+
+		Convey("values that don't fit the column type should be rejected", func() {
+			So(sqlExporter.ExportDocument(bson.D{{"age", "old"}}), ShouldNotBeNil)
+			So(sqlExporter.ExportDocument(bson.D{{"age", int64(math.MaxInt32 + 1)}}), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Literals should be written in each dialect", t, func() {
+		postgres := NewSQLExportOutput("t", nil, SQLDialectPostgres, &bytes.Buffer{})
+		mysql := NewSQLExportOutput("t", nil, SQLDialectMySQL, &bytes.Buffer{})
+		sqlite := NewSQLExportOutput("t", nil, SQLDialectSQLite, &bytes.Buffer{})
+
+		literal := func(sqlExporter *SQLExportOutput, columnType sqlType, value interface{}) string {
+			s, err := sqlExporter.formatValue(columnType, value)
+			So(err, ShouldBeNil)
+			return s
+		}
+
+		Convey("for identifiers", func() {
+			So(postgres.quoteIdentifier(`a"b`), ShouldEqual, `"a""b"`)
+			So(mysql.quoteIdentifier("a`b"), ShouldEqual, "`a``b`")
+		})
+
+		Convey("for strings", func() {
+			So(literal(postgres, sqlText, `it's a\b`), ShouldEqual, `'it''s a\b'`)
+			So(literal(sqlite, sqlText, `it's a\b`), ShouldEqual, `'it''s a\b'`)
+			So(literal(mysql, sqlText, "it's a\\b\n"), ShouldEqual, `'it\'s a\\b\n'`)
+		})
+
+		Convey("for booleans", func() {
+			So(literal(postgres, sqlBoolean, true), ShouldEqual, "TRUE")
+			So(literal(mysql, sqlBoolean, false), ShouldEqual, "FALSE")
+			So(literal(sqlite, sqlBoolean, true), ShouldEqual, "1")
+		})
+
+		Convey("for binary data", func() {
+			So(literal(postgres, sqlBinary, []byte{0xde, 0xad}), ShouldEqual, `'\xdead'`)
+			So(literal(mysql, sqlBinary, bson.Binary{Kind: 0x80, Data: []byte{0xbe, 0xef}}), ShouldEqual, "X'beef'")
+		})
+
+		Convey("for dates", func() {
+			date := time.Date(2017, 6, 1, 12, 30, 0, 0, time.FixedZone("EST", -5*3600))
+			So(literal(mysql, sqlTimestamp, date), ShouldEqual, "'2017-06-01 17:30:00.000'")
+			So(literal(sqlite, sqlTimestamp, date), ShouldEqual, "'2017-06-01 17:30:00.000'")
+		})
+
+		Convey("for numbers", func() {
+			decimal, err := bson.ParseDecimal128("1.50")
+			So(err, ShouldBeNil)
+			So(literal(postgres, sqlDecimal, decimal), ShouldEqual, "1.50")
+			So(literal(postgres, sqlDouble, 2), ShouldEqual, "2")
+			So(literal(postgres, sqlDouble, 0.25), ShouldEqual, "0.25")
+			So(literal(postgres, sqlDouble, math.Inf(-1)), ShouldEqual, "'-Infinity'")
+			So(literal(mysql, sqlDouble, math.NaN()), ShouldEqual, "NULL")
+			So(literal(sqlite, sqlBigInt, int64(1)<<40), ShouldEqual, "1099511627776")
+		})
+
+		Convey("for values of other types in text columns", func() {
+			So(literal(postgres, sqlText, 5), ShouldEqual, "'5'")
+			So(literal(postgres, sqlText, bson.ObjectIdHex("5a934e000102030405000000")),
+				ShouldEqual, "'5a934e000102030405000000'")
+			So(literal(postgres, sqlText, []interface{}{"a", 1}), ShouldEqual, `'["a",1]'`)
+		})
+	})
+}
+
+func TestSQLValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a SQL export", t, func() {
+		exp := MongoExport{
+			OutputOpts: &OutputFormatOptions{Type: SQL, Fields: "a,b"},
+			InputOpts:  &InputOptions{},
+		}
+		exp.ToolOptions.Namespace = &options.Namespace{DB: "db", Collection: "c"}
+		exp.ToolOptions.HiddenOptions = &options.HiddenOptions{}
+		So(exp.ValidateSettings(), ShouldBeNil)
+
+		Convey("the dialect should be validated", func() {
+			exp.OutputOpts.SQLDialect = SQLDialectMySQL
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.OutputOpts.SQLDialect = "oracle"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("the sample size should be validated", func() {
+			exp.OutputOpts.SQLSampleSize = "all"
+			So(exp.ValidateSettings(), ShouldBeNil)
+			exp.OutputOpts.SQLSampleSize = "0"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("the table should default to the collection name", func() {
+			exportOutput, err := exp.getExportOutput(&bytes.Buffer{})
+			So(err, ShouldBeNil)
+			sqlOutput := exportOutput.(*SQLExportOutput)
+			So(sqlOutput.Table, ShouldEqual, "c")
+			So(sqlOutput.Dialect, ShouldEqual, SQLDialectPostgres)
+			So(sqlOutput.BatchSize, ShouldEqual, defaultSQLBatchSize)
+		})
+
+		Convey("SQL options should be rejected for other output types", func() {
+			exp.OutputOpts.Type = CSV
+			exp.OutputOpts.SQLTable = "t"
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+
+		Convey("CSV options should be rejected", func() {
+			exp.OutputOpts.ArrayMode = ArrayModeJoin
+			So(exp.ValidateSettings(), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,298 @@
+package mongofiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/db"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"hash"
+	"strings"
+)
+
+// gridFSCopy copies files between GridFS buckets, chunk by chunk.
+type gridFSCopy struct {
+	from, to *mgo.GridFS
+
+	// chunkSize, if set, is the size of the chunks of the copies
+	chunkSize int
+
+	// overwrite replaces the files of the destination that conflict with
+	// those copied
+	overwrite bool
+}
+
+// destination returns a session of the --to host of 'copy', configured as the
+// session of the source, or a copy of the source session if none is given.
+func (mf *MongoFiles) destination(source *mgo.Session) (*mgo.Session, error) {
+	if mf.StorageOptions.To == "" {
+		return source.Copy(), nil
+	}
+	opts := *mf.ToolOptions
+	connection := *opts.Connection
+	connection.Host = mf.StorageOptions.To
+	connection.Port = ""
+	opts.Connection = &connection
+
+	// connect directly, unless a replica set name is explicitly specified
+	_, setName := util.ParseConnectionString(connection.Host)
+	opts.Direct = (setName == "")
+	opts.ReplicaSetName = setName
+
+	provider, err := db.NewSessionProvider(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to destination host: %v", err)
+	}
+	provider.SetFlags(db.DisableSocketTimeout)
+	session, err := provider.GetSession()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to destination host: %v", err)
+	}
+	nodeType, err := provider.GetNodeType()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("error determining type of destination node: %v", err)
+	}
+	safety, err := db.BuildWriteConcern(mf.StorageOptions.WriteConcern, nodeType)
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("error parsing write concern: %v", err)
+	}
+	session.SetSafe(safety)
+	return session, nil
+}
+
+// handle logic for 'copy' command
+func (mf *MongoFiles) handleCopy(session *mgo.Session) (string, error) {
+	destination, err := mf.destination(session)
+	if err != nil {
+		return "", err
+	}
+	defer destination.Close()
+
+	toDB, toPrefix := mf.StorageOptions.ToDB, mf.StorageOptions.ToPrefix
+	if toDB == "" {
+		toDB = mf.StorageOptions.DB
+	}
+	if toPrefix == "" {
+		toPrefix = mf.StorageOptions.GridFSPrefix
+	}
+	c := gridFSCopy{
+		from:      session.DB(mf.StorageOptions.DB).GridFS(mf.StorageOptions.GridFSPrefix),
+		to:        destination.DB(toDB).GridFS(toPrefix),
+		chunkSize: mf.StorageOptions.ChunkSize,
+		overwrite: mf.StorageOptions.Overwrite,
+	}
+
+	var query interface{}
+	if mf.filter != nil {
+		query = mf.filter
+	}
+	cursor := c.from.Find(query).Sort("_id").Iter()
+	var raw bson.Raw
+	copied, skipped, conflicts := 0, 0, 0
+	var total int64
+	for cursor.Next(&raw) {
+		var file fsckFile
+		if err = raw.Unmarshal(&file); err != nil {
+			cursor.Close()
+			return "", fmt.Errorf("error reading GridFS file: %v", err)
+		}
+		done, conflict, err := c.copied(file)
+		if err == nil && !done && conflict == "" {
+			err = c.copyFile(raw, file)
+		}
+		if err != nil {
+			cursor.Close()
+			return "", fmt.Errorf("error copying GridFS file '%v' (_id %v): %v", file.Name, idString(file.Id), err)
+		}
+		switch {
+		case conflict != "":
+			log.Logf(log.Always, "skipped '%v' (_id %v): %v", file.Name, idString(file.Id), conflict)
+			conflicts++
+		case done:
+			log.Logf(log.DebugLow, "skipped '%v', already copied", file.Name)
+			skipped++
+		default:
+			log.Logf(log.Info, "copied '%v'", file.Name)
+			copied++
+			total += file.Length
+		}
+		raw = bson.Raw{}
+	}
+	if err = cursor.Close(); err != nil {
+		return "", fmt.Errorf("error retrieving list of GridFS files: %v", err)
+	}
+
+	output := fmt.Sprintf("copied %v %v (%v)\nskipped %v %v already copied\n",
+		copied, util.Pluralize(copied, "file", "files"), text.FormatByteAmount(total),
+		skipped, util.Pluralize(skipped, "file", "files"))
+	if conflicts > 0 {
+		output += fmt.Sprintf("skipped %v conflicting %v, which --overwrite replaces\n",
+			conflicts, util.Pluralize(conflicts, "file", "files"))
+	}
+	return output, nil
+}
+
+// copied returns whether a file was already copied, that is whether the
+// destination holds a file of the same _id, length and md5. Otherwise, it
+// describes the conflict if the destination holds another file of the same
+// _id, or chunks of this _id but no file: files are only added once their
+// chunks are, so those chunks are left by an interrupted copy or belong to
+// an upload in progress. With overwrite, conflicting files and chunks are
+// removed instead.
+func (c gridFSCopy) copied(file fsckFile) (done bool, conflict string, err error) {
+	var existing fsckFile
+	err = c.to.Files.FindId(file.Id).One(&existing)
+	switch {
+	case err == mgo.ErrNotFound:
+		count, err := c.to.Chunks.Find(bson.M{"files_id": file.Id}).Count()
+		if err != nil || count == 0 {
+			return false, "", err
+		}
+		if !c.overwrite {
+			return false, fmt.Sprintf("the destination holds %v %v of this _id but no file, "+
+				"left by an interrupted copy or by an upload in progress", count, util.Pluralize(count, "chunk", "chunks")), nil
+		}
+		_, err = c.to.Chunks.RemoveAll(bson.M{"files_id": file.Id})
+		return false, "", err
+	case err != nil:
+		return false, "", err
+	case existing.Length == file.Length && strings.EqualFold(existing.Md5, file.Md5):
+		return true, "", nil
+	case !c.overwrite:
+		return false, fmt.Sprintf("the destination holds another file of this _id, '%v' (%v)",
+			existing.Name, text.FormatByteAmount(existing.Length)), nil
+	}
+	return false, "", c.to.RemoveId(file.Id)
+}
+
+// copyFile copies the chunks of a file, then the file itself, preserving its
+// fields. Chunks are checked against the length and md5 of the file.
+func (c gridFSCopy) copyFile(raw bson.Raw, file fsckFile) error {
+	var sum hash.Hash
+	if file.Md5 != "" {
+		sum = md5.New()
+	}
+	var rechunk *rechunker
+	if c.chunkSize > 0 && c.chunkSize != file.ChunkSize {
+		rechunk = &rechunker{chunks: c.to.Chunks, id: file.Id, chunkSize: c.chunkSize}
+	}
+	var length int64
+	n := 0
+
+	chunks := c.from.Chunks.Find(bson.M{"files_id": file.Id}).Sort("n").Iter()
+	var rawChunk bson.Raw
+	for chunks.Next(&rawChunk) {
+		var chunk fsckChunk
+		if err := rawChunk.Unmarshal(&chunk); err != nil {
+			chunks.Close()
+			return err
+		}
+		if chunk.N != n {
+			chunks.Close()
+			return fmt.Errorf("chunk %v is missing", n)
+		}
+		n++
+		length += int64(len(chunk.Data))
+		if sum != nil {
+			sum.Write(chunk.Data)
+		}
+		var err error
+		if rechunk != nil {
+			_, err = rechunk.Write(chunk.Data)
+		} else {
+			err = c.to.Chunks.Insert(rawChunk)
+		}
+		if err != nil {
+			chunks.Close()
+			return err
+		}
+		rawChunk = bson.Raw{}
+	}
+	if err := chunks.Close(); err != nil {
+		return err
+	}
+	if rechunk != nil {
+		if err := rechunk.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if length != file.Length {
+		return fmt.Errorf("chunks hold %v bytes instead of %v", length, file.Length)
+	}
+	if sum != nil {
+		if computed := hex.EncodeToString(sum.Sum(nil)); !strings.EqualFold(computed, file.Md5) {
+			return fmt.Errorf("chunks have md5 %v instead of %v", computed, file.Md5)
+		}
+	}
+
+	if rechunk == nil {
+		return c.to.Files.Insert(raw)
+	}
+	var document bson.D
+	if err := raw.Unmarshal(&document); err != nil {
+		return err
+	}
+	for i := range document {
+		if document[i].Name == "chunkSize" {
+			document[i].Value = c.chunkSize
+		}
+	}
+	return c.to.Files.Insert(document)
+}
+
+// inserter inserts documents, as collections do.
+type inserter interface {
+	Insert(docs ...interface{}) error
+}
+
+// rechunker writes the data of a file in chunks of another size.
+type rechunker struct {
+	chunks    inserter
+	id        interface{}
+	chunkSize int
+
+	// n is the number of the next chunk, and buffer the data that doesn't
+	// fill a chunk yet
+	n      int
+	buffer []byte
+}
+
+// Write buffers data, writing the chunks it fills.
+func (r *rechunker) Write(data []byte) (int, error) {
+	r.buffer = append(r.buffer, data...)
+	written := 0
+	for len(r.buffer)-written >= r.chunkSize {
+		if err := r.writeChunk(r.buffer[written : written+r.chunkSize]); err != nil {
+			return 0, err
+		}
+		written += r.chunkSize
+	}
+	r.buffer = append(r.buffer[:0], r.buffer[written:]...)
+	return len(data), nil
+}
+
+// Flush writes the last chunk of the file, shorter than the others.
+func (r *rechunker) Flush() error {
+	if len(r.buffer) == 0 {
+		return nil
+	}
+	err := r.writeChunk(r.buffer)
+	r.buffer = nil
+	return err
+}
+
+func (r *rechunker) writeChunk(data []byte) error {
+	chunk := bson.D{{"_id", bson.NewObjectId()}, {"files_id", r.id}, {"n", r.n}, {"data", data}}
+	if err := r.chunks.Insert(chunk); err != nil {
+		return err
+	}
+	r.n++
+	return nil
+}
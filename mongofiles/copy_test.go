@@ -0,0 +1,154 @@
+package mongofiles
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+// chunkCollection holds the chunks inserted into it.
+type chunkCollection []bson.D
+
+func (c *chunkCollection) Insert(docs ...interface{}) error {
+	for _, doc := range docs {
+		chunk := doc.(bson.D)
+		// chunks are only valid while being inserted
+		chunk[3].Value = append([]byte(nil), chunk[3].Value.([]byte)...)
+		*c = append(*c, chunk)
+	}
+	return nil
+}
+
+func TestRechunker(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Data should be written in chunks of the new size", t, func() {
+		chunks := &chunkCollection{}
+		r := &rechunker{chunks: chunks, id: 1, chunkSize: 3}
+		for _, data := range []string{"ab", "cdefg", "h", "", "ij"} {
+			n, err := r.Write([]byte(data))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(data))
+		}
+		So(r.Flush(), ShouldBeNil)
+		So(r.Flush(), ShouldBeNil)
+
+		var data []string
+		for i, chunk := range *chunks {
+			So(chunk[1].Value, ShouldEqual, 1)
+			So(chunk[2].Value, ShouldEqual, i)
+			data = append(data, string(chunk[3].Value.([]byte)))
+		}
+		So(data, ShouldResemble, []string{"abc", "def", "ghi", "j"})
+	})
+}
+
+func TestCopyArguments(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a MongoFiles instance", t, func() {
+		mf, err := simpleMongoFilesInstance([]string{"search", "file"})
+		So(err, ShouldBeNil)
+
+		Convey("copy should need another destination", func() {
+			So(mf.ValidateCommand([]string{"copy"}), ShouldNotBeNil)
+			mf.StorageOptions.ToPrefix = "fs"
+			mf.StorageOptions.ToDB = testDB
+			So(mf.ValidateCommand([]string{"copy"}), ShouldNotBeNil)
+			mf.StorageOptions.ToPrefix = "newfs"
+			So(mf.ValidateCommand([]string{"copy"}), ShouldBeNil)
+			mf.StorageOptions.ToPrefix = ""
+			mf.StorageOptions.To = "localhost:27018"
+			So(mf.ValidateCommand([]string{"copy"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"copy", "file"}), ShouldNotBeNil)
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+		})
+
+		Convey("--overwrite should only be accepted by copy", func() {
+			mf.StorageOptions.ToPrefix = "newfs"
+			mf.StorageOptions.Overwrite = true
+			So(mf.ValidateCommand([]string{"copy"}), ShouldBeNil)
+			mf.StorageOptions.ToPrefix = ""
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+		})
+
+		Convey("copy should accept --filter and --chunkSize", func() {
+			mf.StorageOptions.ToPrefix = "newfs"
+			mf.StorageOptions.ChunkSize = 1024
+			mf.InputOptions.Filter = `{"metadata.tag": "logo"}`
+			So(mf.ValidateCommand([]string{"copy"}), ShouldBeNil)
+			So(mf.filter, ShouldResemble, bson.D{{"metadata.tag", "logo"}})
+			mf.InputOptions.Sort = `{"filename": 1}`
+			So(mf.ValidateCommand([]string{"copy"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestCopy(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With files in GridFS", t, func() {
+		_, err := setUpGridFSTestData()
+		So(err, ShouldBeNil)
+		defer tearDownGridFSTestData()
+
+		mf, err := simpleMongoFilesInstance([]string{"copy", ""})
+		So(err, ShouldBeNil)
+		mf.StorageOptions.ToPrefix = "newfs"
+		mf.StorageOptions.ChunkSize = 4
+		mf.InputOptions.Filter = `{"filename": {"$ne": "testfile1"}}`
+		So(mf.ValidateCommand([]string{"copy"}), ShouldBeNil)
+
+		Convey("copy should copy them in chunks of the new size", func() {
+			output, err := mf.Run(false)
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual, "copied 2 files (25.0 B)\nskipped 0 files already copied\n")
+
+			session, err := mf.SessionProvider.GetSession()
+			So(err, ShouldBeNil)
+			defer session.Close()
+			from := session.DB(testDB).GridFS("fs")
+			to := session.DB(testDB).GridFS("newfs")
+			var original, copied GFSFile
+			So(from.Find(bson.M{"filename": "testfile3"}).One(&original), ShouldBeNil)
+			So(to.Find(bson.M{"_id": original.Id}).One(&copied), ShouldBeNil)
+			So(copied.Md5, ShouldEqual, original.Md5)
+			So(copied.UploadDate.Equal(original.UploadDate), ShouldBeTrue)
+			So(copied.ChunkSize, ShouldEqual, 4)
+			count, err := to.Chunks.Find(bson.M{"files_id": original.Id}).Count()
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 4)
+
+			Convey("and skip them when resumed", func() {
+				output, err := mf.Run(false)
+				So(err, ShouldBeNil)
+				So(output, ShouldEqual, "copied 0 files (0.0 B)\nskipped 2 files already copied\n")
+			})
+
+			Convey("but not replace other files or chunks of their _ids without --overwrite", func() {
+				So(to.Files.UpdateId(original.Id, bson.M{"$set": bson.M{"length": 5, "md5": "other"}}), ShouldBeNil)
+				var other GFSFile
+				So(from.Find(bson.M{"filename": "testfile2"}).One(&other), ShouldBeNil)
+				So(to.Files.RemoveId(other.Id), ShouldBeNil)
+
+				output, err := mf.Run(false)
+				So(err, ShouldBeNil)
+				So(output, ShouldEqual, "copied 0 files (0.0 B)\nskipped 0 files already copied\n"+
+					"skipped 2 conflicting files, which --overwrite replaces\n")
+				So(to.Find(bson.M{"_id": original.Id}).One(&copied), ShouldBeNil)
+				So(copied.Md5, ShouldEqual, "other")
+				count, err := to.Chunks.Find(bson.M{"files_id": other.Id}).Count()
+				So(err, ShouldBeNil)
+				So(count, ShouldBeGreaterThan, 0)
+
+				mf.StorageOptions.Overwrite = true
+				output, err = mf.Run(false)
+				So(err, ShouldBeNil)
+				So(output, ShouldEqual, "copied 2 files (25.0 B)\nskipped 0 files already copied\n")
+				So(to.Find(bson.M{"_id": original.Id}).One(&copied), ShouldBeNil)
+				So(copied.Md5, ShouldEqual, original.Md5)
+			})
+		})
+	})
+}
@@ -0,0 +1,367 @@
+package mongofiles
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"hash"
+	"strings"
+	"time"
+)
+
+// Kinds of problems found by the 'fsck' command.
+const (
+	ProblemOrphanedChunks   = "orphaned chunks"
+	ProblemInvalidChunkSize = "invalid chunkSize"
+	ProblemMissingChunks    = "missing chunks"
+	ProblemExtraChunks      = "extra chunks"
+	ProblemChunkLength      = "wrong chunk length"
+	ProblemLength           = "length mismatch"
+	ProblemMD5              = "md5 mismatch"
+)
+
+// fsckFile holds the fields of a GridFS file checked by 'fsck'; unlike
+// GFSFile, it accepts files of any type of _id.
+type fsckFile struct {
+	Id        interface{} `bson:"_id"`
+	Name      string      `bson:"filename"`
+	Length    int64       `bson:"length"`
+	ChunkSize int         `bson:"chunkSize"`
+	Md5       string      `bson:"md5"`
+}
+
+// fsckChunk holds the fields of a GridFS chunk checked by 'fsck'.
+type fsckChunk struct {
+	Id      interface{} `bson:"_id"`
+	FilesId interface{} `bson:"files_id"`
+	N       int         `bson:"n"`
+	Data    []byte      `bson:"data"`
+}
+
+// fsckProblem is a problem found in a GridFS bucket.
+type fsckProblem struct {
+	// id is the _id of the file, or the files_id of orphaned chunks
+	id       interface{}
+	filename string
+	kind     string
+	detail   string
+}
+
+// fsckOrphan identifies a group of chunks that belong to no file.
+type fsckOrphan struct {
+	filesId interface{}
+	// newest is the creation time of the newest chunk, taken from its _id
+	newest time.Time
+	// unknownAge is set when some chunk has no ObjectId _id
+	unknownAge bool
+}
+
+// chunkIterator iterates over the chunks of a file, in order.
+type chunkIterator interface {
+	Next(result interface{}) bool
+}
+
+// checkFile checks the chunks of a file against its length, chunkSize and
+// md5, returning the problems found.
+func checkFile(file fsckFile, chunks chunkIterator) []fsckProblem {
+	var problems []fsckProblem
+	report := func(kind, detail string, args ...interface{}) {
+		problems = append(problems, fsckProblem{file.Id, file.Name, kind, fmt.Sprintf(detail, args...)})
+	}
+
+	// without a valid chunkSize, only the total length can be checked
+	validChunkSize := file.ChunkSize > 0
+	if !validChunkSize {
+		report(ProblemInvalidChunkSize, "chunkSize is %v", file.ChunkSize)
+	}
+	expected := 0
+	if validChunkSize {
+		expected = int((file.Length + int64(file.ChunkSize) - 1) / int64(file.ChunkSize))
+	}
+
+	var sum hash.Hash
+	if file.Md5 != "" {
+		sum = md5.New()
+	}
+	var length int64
+	next, missing, extra, wrongLength, firstWrongLength := 0, 0, 0, 0, 0
+	var chunk fsckChunk
+	for chunks.Next(&chunk) {
+		if chunk.N < next || validChunkSize && chunk.N >= expected {
+			// duplicated chunks and chunks past the end are not part of the file
+			extra++
+			continue
+		}
+		missing += chunk.N - next
+		next = chunk.N + 1
+		if validChunkSize {
+			want := int64(file.ChunkSize)
+			if chunk.N == expected-1 {
+				want = file.Length - int64(expected-1)*int64(file.ChunkSize)
+			}
+			if int64(len(chunk.Data)) != want {
+				if wrongLength == 0 {
+					firstWrongLength = chunk.N
+				}
+				wrongLength++
+			}
+		}
+		length += int64(len(chunk.Data))
+		if sum != nil {
+			sum.Write(chunk.Data)
+		}
+	}
+	if next < expected {
+		missing += expected - next
+	}
+
+	if missing > 0 {
+		report(ProblemMissingChunks, "%v of %v %v missing", missing, expected, util.Pluralize(expected, "chunk", "chunks"))
+	}
+	if extra > 0 {
+		report(ProblemExtraChunks, "%v %v beyond or duplicating the %v expected",
+			extra, util.Pluralize(extra, "chunk", "chunks"), expected)
+	}
+	if wrongLength > 0 {
+		report(ProblemChunkLength, "%v %v of the wrong length, from chunk %v",
+			wrongLength, util.Pluralize(wrongLength, "chunk", "chunks"), firstWrongLength)
+	}
+	if missing == 0 && wrongLength == 0 && length != file.Length {
+		report(ProblemLength, "chunks hold %v bytes instead of %v", length, file.Length)
+	}
+	if len(problems) == 0 && sum != nil {
+		if computed := hex.EncodeToString(sum.Sum(nil)); !strings.EqualFold(computed, file.Md5) {
+			report(ProblemMD5, "chunks have md5 %v instead of %v", computed, file.Md5)
+		}
+	}
+	return problems
+}
+
+// idKey returns a key identifying an _id of any type.
+func idKey(id interface{}) string {
+	data, err := bson.Marshal(bson.D{{"_id", id}})
+	if err != nil {
+		return fmt.Sprintf("%#v", id)
+	}
+	return string(data)
+}
+
+// fsck scans a bucket for broken files and orphaned chunks. It returns the
+// problems found, the _ids of the broken files and the orphaned chunks.
+func fsck(gfs *mgo.GridFS) (problems []fsckProblem, broken []interface{}, orphans []fsckOrphan, err error) {
+	fileIds := map[string]bool{}
+	numFiles := 0
+	files := gfs.Files.Find(nil).Sort("_id").Iter()
+	var file fsckFile
+	for files.Next(&file) {
+		numFiles++
+		fileIds[idKey(file.Id)] = true
+		chunks := gfs.Chunks.Find(bson.M{"files_id": file.Id}).Sort("n").Iter()
+		fileProblems := checkFile(file, chunks)
+		if err = chunks.Close(); err != nil {
+			files.Close()
+			return nil, nil, nil, fmt.Errorf("error reading the chunks of GridFS file '%v': %v", file.Name, err)
+		}
+		if len(fileProblems) > 0 {
+			problems = append(problems, fileProblems...)
+			broken = append(broken, file.Id)
+		}
+		file = fsckFile{}
+	}
+	if err = files.Close(); err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading GridFS files: %v", err)
+	}
+
+	// chunks are sorted by files_id, which groups those of each file
+	numChunks := 0
+	chunks := gfs.Chunks.Find(nil).Select(bson.M{"_id": 1, "files_id": 1}).Sort("files_id", "n").Iter()
+	var chunk fsckChunk
+	var orphan *fsckProblem
+	orphanChunks := 0
+	lastKey := ""
+	for chunks.Next(&chunk) {
+		numChunks++
+		key := idKey(chunk.FilesId)
+		if key != lastKey {
+			if orphan != nil {
+				orphan.detail = fmt.Sprintf("%v %v of no file", orphanChunks, util.Pluralize(orphanChunks, "chunk", "chunks"))
+				problems = append(problems, *orphan)
+				orphan = nil
+			}
+			if !fileIds[key] {
+				orphan = &fsckProblem{id: chunk.FilesId, kind: ProblemOrphanedChunks}
+				orphans = append(orphans, fsckOrphan{filesId: chunk.FilesId})
+				orphanChunks = 0
+			}
+			lastKey = key
+		}
+		if orphan != nil {
+			last := &orphans[len(orphans)-1]
+			oid, ok := chunk.Id.(bson.ObjectId)
+			switch {
+			case !ok || !oid.Valid():
+				last.unknownAge = true
+			case oid.Time().After(last.newest):
+				last.newest = oid.Time()
+			}
+		}
+		orphanChunks++
+		chunk = fsckChunk{}
+	}
+	if err = chunks.Close(); err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading GridFS chunks: %v", err)
+	}
+	if orphan != nil {
+		orphan.detail = fmt.Sprintf("%v %v of no file", orphanChunks, util.Pluralize(orphanChunks, "chunk", "chunks"))
+		problems = append(problems, *orphan)
+	}
+
+	log.Logf(log.Always, "checked %v %v and %v %v, found %v %v",
+		numFiles, util.Pluralize(numFiles, "file", "files"),
+		numChunks, util.Pluralize(numChunks, "chunk", "chunks"),
+		len(problems), util.Pluralize(len(problems), "problem", "problems"))
+	return problems, broken, orphans, nil
+}
+
+// deleteOrphans deletes the orphaned chunks created before a cutoff time,
+// returning the number of files whose chunks were deleted. GridFS writers
+// insert the chunks of a file before its files document, so recent orphans
+// may belong to an upload in progress; they are kept, as are those whose
+// age can't be told from an ObjectId _id. A files document is looked for
+// again just before deleting, but an upload that stalled for longer than
+// the cutoff can still lose its chunks.
+func deleteOrphans(gfs *mgo.GridFS, orphans []fsckOrphan, cutoff time.Time) (int, error) {
+	deleted := 0
+	for _, orphan := range orphans {
+		if orphan.unknownAge || !orphan.newest.Before(cutoff) {
+			continue
+		}
+		count, err := gfs.Files.FindId(orphan.filesId).Count()
+		if err != nil {
+			return deleted, fmt.Errorf("error while checking orphaned chunks: %v", err)
+		}
+		if count > 0 {
+			continue
+		}
+		// chunks inserted since the scan are left alone
+		query := bson.M{"files_id": orphan.filesId, "_id": bson.M{"$lt": bson.NewObjectIdWithTime(cutoff)}}
+		if _, err = gfs.Chunks.RemoveAll(query); err != nil {
+			return deleted, fmt.Errorf("error while deleting orphaned chunks: %v", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// quarantine moves a GridFS file and its chunks to another bucket.
+func quarantine(gfs, to *mgo.GridFS, id interface{}) error {
+	var file bson.D
+	if err := gfs.Files.FindId(id).One(&file); err != nil {
+		return err
+	}
+	if err := to.Files.Insert(file); err != nil {
+		return err
+	}
+	chunks := gfs.Chunks.Find(bson.M{"files_id": id}).Iter()
+	var chunk bson.D
+	for chunks.Next(&chunk) {
+		if err := to.Chunks.Insert(chunk); err != nil {
+			chunks.Close()
+			return err
+		}
+		chunk = nil
+	}
+	if err := chunks.Close(); err != nil {
+		return err
+	}
+	return gfs.RemoveId(id)
+}
+
+// handle logic for 'fsck' command
+func (mf *MongoFiles) handleFsck(gfs *mgo.GridFS) (string, error) {
+	problems, broken, orphans, err := fsck(gfs)
+	if err != nil {
+		return "", err
+	}
+	output, err := formatProblems(problems, mf.InputOptions.Format)
+	if err != nil {
+		return "", err
+	}
+
+	if mf.StorageOptions.DeleteOrphans {
+		deleted, err := deleteOrphans(gfs, orphans, time.Now().Add(-mf.StorageOptions.OrphanAge))
+		if err != nil {
+			return "", err
+		}
+		log.Logf(log.Always, "deleted the chunks of %v missing %v", deleted, util.Pluralize(deleted, "file", "files"))
+		if kept := len(orphans) - deleted; kept > 0 {
+			log.Logf(log.Always, "kept the chunks of %v missing %v, which may belong to uploads in progress",
+				kept, util.Pluralize(kept, "file", "files"))
+		}
+	}
+	if mf.StorageOptions.Quarantine != "" {
+		to := gfs.Files.Database.GridFS(mf.StorageOptions.Quarantine)
+		for _, id := range broken {
+			if err = quarantine(gfs, to, id); err != nil {
+				return "", fmt.Errorf("error while quarantining GridFS file %v: %v", idString(id), err)
+			}
+		}
+		log.Logf(log.Always, "moved %v broken %v to GridFS prefix '%v'",
+			len(broken), util.Pluralize(len(broken), "file", "files"), mf.StorageOptions.Quarantine)
+	}
+	return output, nil
+}
+
+// idString displays an _id, in extended JSON unless it's an ObjectId.
+func idString(id interface{}) string {
+	if oid, ok := id.(bson.ObjectId); ok {
+		return oid.Hex()
+	}
+	out, err := fileJSON(id)
+	if err != nil {
+		return fmt.Sprintf("%v", id)
+	}
+	return string(out)
+}
+
+// formatProblems displays the problems found by 'fsck' as a table, or as
+// one JSON document per line.
+func formatProblems(problems []fsckProblem, format string) (string, error) {
+	display := &bytes.Buffer{}
+	if format == FormatJSON {
+		for _, problem := range problems {
+			document := bson.D{{"_id", problem.id}}
+			if problem.filename != "" {
+				document = append(document, bson.DocElem{"filename", problem.filename})
+			}
+			document = append(document,
+				bson.DocElem{"problem", problem.kind},
+				bson.DocElem{"detail", problem.detail})
+			out, err := fileJSON(document)
+			if err != nil {
+				return "", err
+			}
+			display.Write(out)
+			display.WriteByte('\n')
+		}
+		return display.String(), nil
+	}
+	if len(problems) == 0 {
+		return "", nil
+	}
+	gw := &text.GridWriter{ColumnPadding: 2}
+	gw.WriteCells("_id", "filename", "problem")
+	gw.Feed("detail")
+	for _, problem := range problems {
+		gw.WriteCells(idString(problem.id), problem.filename, problem.kind)
+		gw.Feed(problem.detail)
+	}
+	gw.Flush(display)
+	return display.String(), nil
+}
@@ -0,0 +1,229 @@
+package mongofiles
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkSlice iterates over chunks held in memory.
+type chunkSlice []fsckChunk
+
+func (chunks *chunkSlice) Next(result interface{}) bool {
+	if len(*chunks) == 0 {
+		return false
+	}
+	*result.(*fsckChunk) = (*chunks)[0]
+	*chunks = (*chunks)[1:]
+	return true
+}
+
+// problemKinds returns the kinds of the problems found in a file.
+func problemKinds(file fsckFile, chunks ...fsckChunk) []string {
+	iter := chunkSlice(chunks)
+	kinds := []string{}
+	for _, problem := range checkFile(file, &iter) {
+		kinds = append(kinds, problem.kind)
+	}
+	return kinds
+}
+
+func TestCheckFile(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a file of 5 bytes in chunks of 2 bytes", t, func() {
+		// md5 of "aabbc"
+		file := fsckFile{Id: 1, Name: "f", Length: 5, ChunkSize: 2, Md5: "eac3d041c90973a9eff80bd4c0b0bd62"}
+		a := fsckChunk{N: 0, Data: []byte("aa")}
+		b := fsckChunk{N: 1, Data: []byte("bb")}
+		c := fsckChunk{N: 2, Data: []byte("c")}
+
+		Convey("complete chunks should pass", func() {
+			So(problemKinds(file, a, b, c), ShouldBeEmpty)
+			file.Md5 = ""
+			So(problemKinds(file, a, b, c), ShouldBeEmpty)
+		})
+
+		Convey("missing, extra and short chunks should be found", func() {
+			So(problemKinds(file, a, c), ShouldResemble, []string{ProblemMissingChunks})
+			So(problemKinds(file, a, b), ShouldResemble, []string{ProblemMissingChunks})
+			So(problemKinds(file, a, b, b, c, fsckChunk{N: 3, Data: []byte("d")}), ShouldResemble,
+				[]string{ProblemExtraChunks})
+			So(problemKinds(file, a, fsckChunk{N: 1, Data: []byte("b")}, c), ShouldResemble,
+				[]string{ProblemChunkLength})
+		})
+
+		Convey("an md5 mismatch should be found", func() {
+			So(problemKinds(file, a, b, fsckChunk{N: 2, Data: []byte("d")}), ShouldResemble,
+				[]string{ProblemMD5})
+		})
+
+		Convey("a file without a valid chunkSize should be checked against its length", func() {
+			file.ChunkSize = 0
+			So(problemKinds(file, a, b, c), ShouldResemble, []string{ProblemInvalidChunkSize})
+			So(problemKinds(file, a, b), ShouldResemble, []string{ProblemInvalidChunkSize, ProblemLength})
+		})
+
+		Convey("problems should describe the file", func() {
+			iter := chunkSlice{a, c}
+			So(checkFile(file, &iter), ShouldResemble, []fsckProblem{
+				{1, "f", ProblemMissingChunks, "1 of 3 chunks missing"},
+			})
+		})
+	})
+}
+
+func TestFormatProblems(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With problems found by fsck", t, func() {
+		problems := []fsckProblem{
+			{bson.ObjectIdHex("5a934e000102030405000000"), "f", ProblemMissingChunks, "1 of 3 chunks missing"},
+			{"orphan", "", ProblemOrphanedChunks, "2 chunks of no file"},
+		}
+
+		Convey("they should be listed in a table", func() {
+			output, err := formatProblems(problems, "")
+			So(err, ShouldBeNil)
+			lines := strings.Split(output, "\n")
+			So(len(lines), ShouldEqual, 4)
+			So(strings.Fields(lines[0]), ShouldResemble, []string{"_id", "filename", "problem", "detail"})
+			So(strings.TrimSpace(lines[1]), ShouldStartWith, "5a934e000102030405000000")
+			So(lines[1], ShouldEndWith, "missing chunks  1 of 3 chunks missing")
+			So(strings.TrimSpace(lines[2]), ShouldStartWith, `"orphan"`)
+			So(lines[2], ShouldEndWith, "orphaned chunks  2 chunks of no file")
+			output, err = formatProblems(nil, FormatTable)
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual, "")
+		})
+
+		Convey("they should be listed one JSON document per line", func() {
+			output, err := formatProblems(problems, FormatJSON)
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual,
+				`{"_id":{"$oid":"5a934e000102030405000000"},"filename":"f","problem":"missing chunks","detail":"1 of 3 chunks missing"}`+"\n"+
+					`{"_id":"orphan","problem":"orphaned chunks","detail":"2 chunks of no file"}`+"\n")
+		})
+	})
+}
+
+func TestFsckArguments(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a MongoFiles instance", t, func() {
+		mf, err := simpleMongoFilesInstance([]string{"search", "file"})
+		So(err, ShouldBeNil)
+
+		Convey("fsck should take no argument", func() {
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"fsck", "file"}), ShouldNotBeNil)
+		})
+
+		Convey("repair options should only be accepted by fsck", func() {
+			mf.StorageOptions.DeleteOrphans = true
+			mf.StorageOptions.Quarantine = "broken"
+			mf.InputOptions.Format = FormatJSON
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+			mf.StorageOptions.Quarantine = "fs"
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldNotBeNil)
+			mf.StorageOptions.Quarantine = "broken"
+			mf.StorageOptions.OrphanAge = -time.Hour
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldNotBeNil)
+		})
+
+		Convey("--format should be accepted by fsck, but not --filter", func() {
+			mf.InputOptions.Format = FormatTable
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldNotBeNil)
+			mf.InputOptions.Filter = "{}"
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFsck(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With a bucket of a broken file and orphaned chunks", t, func() {
+		root, err := ioutil.TempDir("", "mongofiles")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		So(writeTree(root, map[string]string{"a.txt": "aaa", "b.txt": "bbb"}), ShouldBeNil)
+		defer tearDownGridFSTestData()
+
+		mf, err := simpleMongoFilesInstance([]string{"put", root})
+		So(err, ShouldBeNil)
+		mf.StorageOptions.Recursive = true
+		_, err = mf.Run(false)
+		So(err, ShouldBeNil)
+
+		session, err := mf.SessionProvider.GetSession()
+		So(err, ShouldBeNil)
+		defer session.Close()
+		gfs := session.DB(testDB).GridFS("fs")
+		defer session.DB(testDB).GridFS("broken").Files.DropCollection()
+		defer session.DB(testDB).GridFS("broken").Chunks.DropCollection()
+		var file GFSFile
+		So(gfs.Find(bson.M{"filename": "a.txt"}).One(&file), ShouldBeNil)
+		So(gfs.Chunks.Update(bson.M{"files_id": file.Id}, bson.M{"$set": bson.M{"data": []byte("abc")}}), ShouldBeNil)
+		old := bson.NewObjectIdWithTime(time.Now().Add(-48 * time.Hour))
+		So(gfs.Chunks.Insert(bson.M{"_id": old, "files_id": "orphan", "n": 0, "data": []byte("x")}), ShouldBeNil)
+		So(gfs.Chunks.Insert(bson.M{"files_id": "upload", "n": 0, "data": []byte("y")}), ShouldBeNil)
+
+		Convey("get and get_id should fail on the md5 mismatch, keeping the local file", func() {
+			copyPath := filepath.Join(root, "copy.txt")
+			So(ioutil.WriteFile(copyPath, []byte("old"), 0644), ShouldBeNil)
+			for _, args := range [][]string{{"get", "a.txt"}, {"get_id", `{"$oid":"` + file.Id.Hex() + `"}`}} {
+				mf, err := simpleMongoFilesInstance(args)
+				So(err, ShouldBeNil)
+				mf.StorageOptions.LocalFileName = copyPath
+				_, err = mf.Run(false)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "md5")
+				content, err := ioutil.ReadFile(copyPath)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "old")
+			}
+		})
+
+		Convey("fsck should report and repair the problems", func() {
+			mf, err := simpleMongoFilesInstance([]string{"fsck", ""})
+			So(err, ShouldBeNil)
+			mf.InputOptions.Format = FormatJSON
+			mf.StorageOptions.DeleteOrphans = true
+			mf.StorageOptions.OrphanAge = time.Hour
+			mf.StorageOptions.Quarantine = "broken"
+			So(mf.ValidateCommand([]string{"fsck"}), ShouldBeNil)
+			output, err := mf.Run(false)
+			So(err, ShouldBeNil)
+			lines := strings.Split(strings.TrimSpace(output), "\n")
+			So(len(lines), ShouldEqual, 3)
+			So(lines[0], ShouldContainSubstring, `"problem":"md5 mismatch"`)
+			So(lines[1], ShouldContainSubstring, `"problem":"orphaned chunks"`)
+			So(lines[2], ShouldContainSubstring, `"problem":"orphaned chunks"`)
+
+			// only the orphans older than --orphanAge are deleted, since the
+			// others may belong to an upload in progress
+			So(gfs.Chunks.Find(bson.M{"files_id": "orphan"}).One(nil), ShouldNotBeNil)
+			So(gfs.Chunks.Find(bson.M{"files_id": "upload"}).One(nil), ShouldBeNil)
+			count, err := gfs.Find(nil).Count()
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			count, err = session.DB(testDB).GridFS("broken").Chunks.Find(bson.M{"files_id": file.Id}).Count()
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+
+			output, err = mf.Run(false)
+			So(err, ShouldBeNil)
+			So(output, ShouldContainSubstring, `"_id":"upload"`)
+			So(output, ShouldNotContainSubstring, `"_id":"orphan"`)
+		})
+	})
+}
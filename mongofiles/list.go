@@ -0,0 +1,110 @@
+package mongofiles
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/json"
+	"github.com/mongodb/mongo-tools/common/text"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+)
+
+// Formats of the files listed by 'list' and 'search'.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// uploadDateFormat is the format of upload dates in tables.
+const uploadDateFormat = "2006-01-02T15:04:05.000Z"
+
+// formatFiles displays GridFS files in the given format; by default, only
+// their names and lengths are displayed.
+func formatFiles(files []GFSFile, format string) (string, error) {
+	display := &bytes.Buffer{}
+	switch format {
+	case FormatTable:
+		if len(files) == 0 {
+			return "", nil
+		}
+		gw := &text.GridWriter{ColumnPadding: 2}
+		gw.WriteCells("_id", "filename", "length", "uploadDate", "md5", "contentType", "aliases")
+		gw.Feed("metadata")
+		for _, file := range files {
+			metadata := ""
+			if file.Metadata != nil {
+				out, err := fileJSON(file.Metadata)
+				if err != nil {
+					return "", err
+				}
+				metadata = string(out)
+			}
+			gw.WriteCells(
+				file.Id.Hex(),
+				file.Name,
+				fmt.Sprintf("%d", file.Length),
+				file.UploadDate.UTC().Format(uploadDateFormat),
+				file.Md5,
+				file.ContentType,
+				strings.Join(file.Aliases, ","),
+			)
+			gw.Feed(metadata)
+		}
+		gw.Flush(display)
+	case FormatJSON:
+		// one document per line, as with mongoexport
+		for _, file := range files {
+			out, err := fileJSON(fileDocument(file))
+			if err != nil {
+				return "", err
+			}
+			display.Write(out)
+			display.WriteByte('\n')
+		}
+	default:
+		for _, file := range files {
+			fmt.Fprintf(display, "%s\t%d\n", file.Name, file.Length)
+		}
+	}
+	return display.String(), nil
+}
+
+// fileDocument returns the fields of a GridFS file displayed as JSON.
+func fileDocument(file GFSFile) bson.D {
+	document := bson.D{
+		{"_id", file.Id},
+		{"filename", file.Name},
+		{"length", file.Length},
+		{"chunkSize", file.ChunkSize},
+		{"uploadDate", file.UploadDate},
+		{"md5", file.Md5},
+	}
+	if file.ContentType != "" {
+		document = append(document, bson.DocElem{"contentType", file.ContentType})
+	}
+	if len(file.Aliases) > 0 {
+		aliases := make([]interface{}, len(file.Aliases))
+		for i, alias := range file.Aliases {
+			aliases[i] = alias
+		}
+		document = append(document, bson.DocElem{"aliases", aliases})
+	}
+	if file.Metadata != nil {
+		document = append(document, bson.DocElem{"metadata", file.Metadata})
+	}
+	return document
+}
+
+// fileJSON converts a value to relaxed extended JSON.
+func fileJSON(value interface{}) ([]byte, error) {
+	extended, err := bsonutil.ConvertBSONValueToExtendedJSON(value, bsonutil.RelaxedJSONFormat)
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(extended)
+	if err != nil {
+		return nil, fmt.Errorf("error converting BSON to extended JSON: %v", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,172 @@
+package mongofiles
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListArguments(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a MongoFiles instance", t, func() {
+		mf, err := simpleMongoFilesInstance([]string{"search", "file"})
+		So(err, ShouldBeNil)
+
+		Convey("--metadata and --aliases should be parsed for put", func() {
+			mf.StorageOptions.Metadata = `{"tag": "logo", "size": {"$numberLong": "2"}}`
+			mf.StorageOptions.Aliases = "a, b,,"
+			So(mf.ValidateCommand([]string{"put", "file"}), ShouldBeNil)
+			So(mf.metadata, ShouldResemble, bson.D{{"tag", "logo"}, {"size", int64(2)}})
+			So(mf.aliases, ShouldResemble, []string{"a", "b"})
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+			mf.StorageOptions.Direction = SyncDownload
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldNotBeNil)
+		})
+
+		Convey("--metadata should be a JSON document", func() {
+			mf.StorageOptions.Metadata = `["logo"]`
+			So(mf.ValidateCommand([]string{"put", "file"}), ShouldNotBeNil)
+		})
+
+		Convey("--filter and --sort should be parsed for list and search", func() {
+			mf.InputOptions.Filter = `{"metadata.tag": "logo"}`
+			mf.InputOptions.Sort = `{"uploadDate": -1, "filename": 1}`
+			mf.InputOptions.Limit = 2
+			So(mf.ValidateCommand([]string{"list"}), ShouldBeNil)
+			So(mf.filter, ShouldResemble, bson.D{{"metadata.tag", "logo"}})
+			So(mf.sort, ShouldResemble, []string{"-uploadDate", "+filename"})
+			So(mf.ValidateCommand([]string{"search", "file"}), ShouldBeNil)
+			err := mf.ValidateCommand([]string{"get", "file"})
+			So(err, ShouldNotBeNil)
+			So(strings.Contains(err.Error(), "--filter"), ShouldBeTrue)
+		})
+
+		Convey("invalid listing options should be rejected", func() {
+			mf.InputOptions.Filter = `{"metadata.tag": `
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+			mf.InputOptions.Filter = ""
+			mf.InputOptions.Sort = `{"uploadDate": "down"}`
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+			mf.InputOptions.Sort = ""
+			mf.InputOptions.Limit = -1
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+			mf.InputOptions.Limit = 0
+			mf.InputOptions.Format = "xml"
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFormatFiles(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With GridFS files", t, func() {
+		files := []GFSFile{
+			{
+				Id:          bson.ObjectIdHex("5a934e000102030405000000"),
+				ChunkSize:   261120,
+				Name:        "logo.png",
+				Length:      3,
+				Md5:         "47bce5c74f589f4867dbd57e9ca9f808",
+				UploadDate:  time.Date(2018, 2, 25, 23, 0, 0, 0, time.UTC),
+				ContentType: "image/png",
+				Aliases:     []string{"icon"},
+				Metadata:    bson.D{{"tag", "logo"}},
+			},
+			{
+				Id:         bson.ObjectIdHex("5a934e000102030405000001"),
+				ChunkSize:  261120,
+				Name:       "a.txt",
+				UploadDate: time.Date(2018, 2, 26, 0, 0, 0, 0, time.UTC),
+			},
+		}
+
+		Convey("their names and lengths should be listed by default", func() {
+			output, err := formatFiles(files, "")
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual, "logo.png\t3\na.txt\t0\n")
+		})
+
+		Convey("they should be listed one JSON document per line", func() {
+			output, err := formatFiles(files, FormatJSON)
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual,
+				`{"_id":{"$oid":"5a934e000102030405000000"},"filename":"logo.png","length":3,"chunkSize":261120,`+
+					`"uploadDate":{"$date":"2018-02-25T23:00:00.000Z"},"md5":"47bce5c74f589f4867dbd57e9ca9f808",`+
+					`"contentType":"image/png","aliases":["icon"],"metadata":{"tag":"logo"}}`+"\n"+
+					`{"_id":{"$oid":"5a934e000102030405000001"},"filename":"a.txt","length":0,"chunkSize":261120,`+
+					`"uploadDate":{"$date":"2018-02-26T00:00:00.000Z"},"md5":""}`+"\n")
+		})
+
+		Convey("they should be listed in a table", func() {
+			output, err := formatFiles(files, FormatTable)
+			So(err, ShouldBeNil)
+			lines := strings.Split(output, "\n")
+			So(len(lines), ShouldEqual, 4)
+			So(strings.Fields(lines[0]), ShouldResemble, []string{
+				"_id", "filename", "length", "uploadDate", "md5", "contentType", "aliases", "metadata"})
+			So(strings.Fields(lines[1]), ShouldResemble, []string{
+				"5a934e000102030405000000", "logo.png", "3", "2018-02-25T23:00:00.000Z",
+				"47bce5c74f589f4867dbd57e9ca9f808", "image/png", "icon", `{"tag":"logo"}`})
+			So(strings.Fields(lines[2]), ShouldResemble, []string{
+				"5a934e000102030405000001", "a.txt", "0", "2018-02-26T00:00:00.000Z"})
+		})
+	})
+}
+
+func TestTaggedFiles(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With files put with metadata and aliases", t, func() {
+		root, err := ioutil.TempDir("", "mongofiles")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		So(writeTree(root, map[string]string{"logo.png": "png", "a.txt": "aaa"}), ShouldBeNil)
+		defer tearDownGridFSTestData()
+
+		for _, name := range []string{"logo.png", "a.txt"} {
+			mf, err := simpleMongoFilesInstance([]string{"put", name})
+			So(err, ShouldBeNil)
+			mf.StorageOptions.LocalFileName = filepath.Join(root, name)
+			if name == "logo.png" {
+				mf.StorageOptions.Metadata = `{"tag": "logo"}`
+				mf.StorageOptions.Aliases = "icon"
+			}
+			So(mf.ValidateCommand([]string{"put", name}), ShouldBeNil)
+			_, err = mf.Run(false)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("list should find them by tag", func() {
+			mf, err := simpleMongoFilesInstance([]string{"list", ""})
+			So(err, ShouldBeNil)
+			mf.InputOptions.Filter = `{"metadata.tag": "logo"}`
+			mf.InputOptions.Format = FormatJSON
+			So(mf.ValidateCommand([]string{"list"}), ShouldBeNil)
+			output, err := mf.Run(false)
+			So(err, ShouldBeNil)
+			So(strings.Count(output, "\n"), ShouldEqual, 1)
+			So(output, ShouldContainSubstring, `"filename":"logo.png"`)
+			So(output, ShouldContainSubstring, `"aliases":["icon"],"metadata":{"tag":"logo"}`)
+		})
+
+		Convey("search should sort and limit them", func() {
+			mf, err := simpleMongoFilesInstance([]string{"search", "."})
+			So(err, ShouldBeNil)
+			mf.InputOptions.Sort = `{"filename": 1}`
+			mf.InputOptions.Limit = 1
+			So(mf.ValidateCommand([]string{"search", "."}), ShouldBeNil)
+			output, err := mf.Run(false)
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual, "a.txt\t3\n")
+		})
+	})
+}
@@ -2,6 +2,8 @@
 package mongofiles
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"github.com/mongodb/mongo-tools/common/bsonutil"
 	"github.com/mongodb/mongo-tools/common/db"
@@ -11,12 +13,24 @@ import (
 	"github.com/mongodb/mongo-tools/common/util"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"hash"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 )
 
+// copyBufferSize is the size of the buffer of reads from GridFS, that of the
+// default chunks.
+const copyBufferSize = 255 * 1024
+
+// maxChunkSize is the largest --chunkSize, leaving room in the 16MB chunk
+// documents for fields other than their data.
+const maxChunkSize = 16*1024*1024 - 1024
+
 // List of possible commands for mongofiles.
 const (
 	List     = "list"
@@ -26,6 +40,11 @@ const (
 	GetID    = "get_id"
 	Delete   = "delete"
 	DeleteID = "delete_id"
+	Sync     = "sync"
+	Fsck     = "fsck"
+	Cat      = "cat"
+	Serve    = "serve"
+	Copy     = "copy"
 )
 
 // MongoFiles is a container for the user-specified options and
@@ -48,6 +67,14 @@ type MongoFiles struct {
 
 	// filename in GridFS
 	FileName string
+
+	// parsed --metadata and --aliases of added files
+	metadata bson.D
+	aliases  []string
+
+	// parsed --filter and --sort of listed files
+	filter bson.D
+	sort   []string
 }
 
 // GFSFile represents a GridFS file.
@@ -59,6 +86,8 @@ type GFSFile struct {
 	Md5         string        `bson:"md5"`
 	UploadDate  time.Time     `bson:"uploadDate"`
 	ContentType string        `bson:"contentType,omitempty"`
+	Aliases     []string      `bson:"aliases,omitempty"`
+	Metadata    bson.D        `bson:"metadata,omitempty"`
 }
 
 // ValidateCommand ensures the arguments supplied are valid.
@@ -79,13 +108,17 @@ func (mf *MongoFiles) ValidateCommand(args []string) error {
 		} else {
 			fileName = args[1]
 		}
-	case Search, Put, Get, Delete, GetID, DeleteID:
+	case Search, Put, Get, Delete, GetID, DeleteID, Sync, Cat:
 		// also make sure the supporting argument isn't literally an
 		// empty string for example, mongofiles get ""
 		if len(args) == 1 || args[1] == "" {
 			return fmt.Errorf("'%v' argument missing", args[0])
 		}
 		fileName = args[1]
+	case Fsck, Serve, Copy:
+		if len(args) > 1 {
+			return fmt.Errorf("'%v' takes no argument", args[0])
+		}
 	default:
 		return fmt.Errorf("'%v' is not a valid command", args[0])
 	}
@@ -94,28 +127,275 @@ func (mf *MongoFiles) ValidateCommand(args []string) error {
 		return fmt.Errorf("--prefix can not be blank")
 	}
 
+	if err := mf.validateTransferOptions(args[0]); err != nil {
+		return err
+	}
+
+	if err := mf.validateFileOptions(args[0]); err != nil {
+		return err
+	}
+
+	if err := mf.validateListOptions(args[0]); err != nil {
+		return err
+	}
+
+	if err := mf.validateFsckOptions(args[0]); err != nil {
+		return err
+	}
+
+	if err := mf.validateStreamOptions(args[0]); err != nil {
+		return err
+	}
+
+	if args[0] != Serve && (mf.StorageOptions.Listen != "" || mf.StorageOptions.AllowWrites) {
+		return fmt.Errorf("--listen and --allowWrites can only be used with serve")
+	}
+
+	if err := mf.validateCopyOptions(args[0]); err != nil {
+		return err
+	}
+
 	// set the mongofiles command and file name
 	mf.Command = args[0]
 	mf.FileName = fileName
 	return nil
 }
 
-// Query GridFS for files and display the results.
+// validateTransferOptions checks the options of recursive transfers and of
+// the 'sync' command.
+func (mf *MongoFiles) validateTransferOptions(command string) error {
+	recursive := mf.StorageOptions.Recursive
+	if recursive && command != Put && command != Get {
+		return fmt.Errorf("--recursive can only be used with put or get")
+	}
+	if recursive && command == Put && mf.StorageOptions.LocalFileName != "" {
+		return fmt.Errorf("--local can not be used with put --recursive")
+	}
+	if recursive && command == Get && mf.StorageOptions.LocalFileName == "-" {
+		return fmt.Errorf("can not write files to stdout with get --recursive")
+	}
+	if command == Sync {
+		switch mf.StorageOptions.Direction {
+		case "", SyncUpload, SyncDownload:
+		default:
+			return fmt.Errorf("invalid --direction '%v', choose '%v' or '%v'",
+				mf.StorageOptions.Direction, SyncUpload, SyncDownload)
+		}
+		if mf.StorageOptions.LocalFileName != "" {
+			return fmt.Errorf("--local can not be used with sync")
+		}
+	} else if mf.StorageOptions.Direction != "" {
+		return fmt.Errorf("--direction can only be used with sync")
+	}
+	if !recursive && command != Sync {
+		if mf.StorageOptions.Delete {
+			return fmt.Errorf("--delete can only be used with sync or --recursive")
+		}
+		if mf.StorageOptions.NumParallelTransfers != 0 {
+			return fmt.Errorf("--numParallelTransfers can only be used with sync or --recursive")
+		}
+	}
+	if mf.StorageOptions.NumParallelTransfers < 0 {
+		return fmt.Errorf("--numParallelTransfers must be positive")
+	}
+
+	// uploads are named after paths relative to the local directory, so
+	// without a prefix, the GridFS files missing from it are all the others
+	uploads := command == Sync && mf.StorageOptions.Direction != SyncDownload || recursive && command == Put
+	if mf.StorageOptions.NamePrefix != "" {
+		if command != Sync && !(recursive && command == Put) {
+			return fmt.Errorf("--namePrefix can only be used with sync or put --recursive")
+		}
+		if !strings.HasSuffix(mf.StorageOptions.NamePrefix, "/") {
+			mf.StorageOptions.NamePrefix += "/"
+		}
+	} else if uploads && mf.StorageOptions.Delete {
+		return fmt.Errorf("--delete needs --namePrefix when uploading, to limit the GridFS files deleted to those of the directory")
+	}
+	return nil
+}
+
+// validateFileOptions checks and parses the --metadata and --aliases
+// options of the files added to GridFS.
+func (mf *MongoFiles) validateFileOptions(command string) error {
+	uploads := command == Put || command == Sync && mf.StorageOptions.Direction != SyncDownload
+	if mf.StorageOptions.Metadata != "" {
+		if !uploads {
+			return fmt.Errorf("--metadata can only be used with put or sync")
+		}
+		metadata, err := parseJSONDocument(mf.StorageOptions.Metadata)
+		if err != nil {
+			return fmt.Errorf("error parsing --metadata: %v", err)
+		}
+		mf.metadata = metadata
+	}
+	if mf.StorageOptions.Aliases != "" {
+		if !uploads {
+			return fmt.Errorf("--aliases can only be used with put or sync")
+		}
+		mf.aliases = nil
+		for _, alias := range strings.Split(mf.StorageOptions.Aliases, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				mf.aliases = append(mf.aliases, alias)
+			}
+		}
+	}
+	return nil
+}
+
+// validateListOptions checks and parses the options of the 'list' and
+// 'search' commands.
+func (mf *MongoFiles) validateListOptions(command string) error {
+	listing := command == List || command == Search
+	if !listing {
+		if mf.InputOptions.Filter != "" && command != Copy {
+			return fmt.Errorf("--filter can only be used with list, search or copy")
+		}
+		if mf.InputOptions.Sort != "" || mf.InputOptions.Limit != 0 {
+			return fmt.Errorf("--sort and --limit can only be used with list or search")
+		}
+	}
+	switch mf.InputOptions.Format {
+	case "":
+	case FormatTable, FormatJSON:
+		if !listing && command != Fsck {
+			return fmt.Errorf("--format can only be used with list, search or fsck")
+		}
+	default:
+		return fmt.Errorf("invalid --format '%v', choose '%v' or '%v'",
+			mf.InputOptions.Format, FormatTable, FormatJSON)
+	}
+	if mf.InputOptions.Limit < 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+	if mf.InputOptions.Filter != "" {
+		filter, err := parseJSONDocument(mf.InputOptions.Filter)
+		if err != nil {
+			return fmt.Errorf("error parsing --filter: %v", err)
+		}
+		mf.filter = filter
+	}
+	if mf.InputOptions.Sort != "" {
+		sortD, err := json.UnmarshalBsonD([]byte(mf.InputOptions.Sort))
+		if err != nil {
+			return fmt.Errorf("error parsing --sort: %v", err)
+		}
+		if mf.sort, err = bsonutil.MakeSortString(sortD); err != nil {
+			return fmt.Errorf("error parsing --sort: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateFsckOptions checks the repair options of the 'fsck' command.
+func (mf *MongoFiles) validateFsckOptions(command string) error {
+	if command != Fsck {
+		if mf.StorageOptions.DeleteOrphans || mf.StorageOptions.Quarantine != "" {
+			return fmt.Errorf("--deleteOrphans and --quarantine can only be used with fsck")
+		}
+		return nil
+	}
+	if mf.StorageOptions.Quarantine == mf.StorageOptions.GridFSPrefix {
+		return fmt.Errorf("--quarantine must be another prefix than --prefix")
+	}
+	if mf.StorageOptions.OrphanAge < 0 {
+		return fmt.Errorf("--orphanAge can't be negative")
+	}
+	if mf.StorageOptions.Quarantine != "" {
+		return util.ValidateFullNamespace(fmt.Sprintf("%s.%s.chunks", mf.StorageOptions.DB,
+			mf.StorageOptions.Quarantine))
+	}
+	return nil
+}
+
+// validateStreamOptions checks the options of ranged reads and the
+// --chunkSize of added files.
+func (mf *MongoFiles) validateStreamOptions(command string) error {
+	ranged := mf.StorageOptions.Offset != 0 || mf.StorageOptions.Length != 0
+	if ranged && (command != Get && command != GetID && command != Cat || mf.StorageOptions.Recursive) {
+		return fmt.Errorf("--offset and --length can only be used with get, get_id or cat")
+	}
+	if mf.StorageOptions.Offset < 0 {
+		return fmt.Errorf("--offset must be positive")
+	}
+	if mf.StorageOptions.Length < 0 {
+		return fmt.Errorf("--length must be positive")
+	}
+	chunkSize := mf.StorageOptions.ChunkSize
+	if chunkSize != 0 {
+		if command != Put && command != Copy && (command != Sync || mf.StorageOptions.Direction == SyncDownload) {
+			return fmt.Errorf("--chunkSize can only be used with put, sync or copy")
+		}
+		if chunkSize < 0 || chunkSize > maxChunkSize {
+			return fmt.Errorf("--chunkSize must be between 1 and %v bytes", maxChunkSize)
+		}
+	}
+	return nil
+}
+
+// validateCopyOptions checks the destination of the 'copy' command.
+func (mf *MongoFiles) validateCopyOptions(command string) error {
+	options := mf.StorageOptions
+	if command != Copy {
+		if options.To != "" || options.ToDB != "" || options.ToPrefix != "" || options.Overwrite {
+			return fmt.Errorf("--to, --toDB, --toPrefix and --overwrite can only be used with copy")
+		}
+		return nil
+	}
+	sameDB := options.ToDB == "" || options.ToDB == options.DB
+	samePrefix := options.ToPrefix == "" || options.ToPrefix == options.GridFSPrefix
+	if options.To == "" && sameDB && samePrefix {
+		return fmt.Errorf("copy needs another destination than the source, given by --to, --toDB or --toPrefix")
+	}
+	toDB, toPrefix := options.ToDB, options.ToPrefix
+	if toDB == "" {
+		toDB = options.DB
+	}
+	if toPrefix == "" {
+		toPrefix = options.GridFSPrefix
+	}
+	return util.ValidateFullNamespace(fmt.Sprintf("%s.%s.chunks", toDB, toPrefix))
+}
+
+// parseJSONDocument parses a document in extended JSON, preserving the
+// order of its fields.
+func parseJSONDocument(value string) (bson.D, error) {
+	document, err := json.UnmarshalBsonD([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("'%v' is not a valid JSON document: %v", value, err)
+	}
+	return bsonutil.GetExtendedBsonD(document)
+}
+
+// Query GridFS for files and display the results, restricted by the
+// --filter, --sort and --limit options.
 func (mf *MongoFiles) findAndDisplay(gfs *mgo.GridFS, query bson.M) (string, error) {
-	display := ""
+	var filter interface{} = query
+	if mf.filter != nil {
+		filter = bson.M{"$and": []interface{}{query, mf.filter}}
+	}
+	q := gfs.Find(filter)
+	if len(mf.sort) > 0 {
+		q = q.Sort(mf.sort...)
+	}
+	if mf.InputOptions.Limit > 0 {
+		q = q.Limit(mf.InputOptions.Limit)
+	}
 
-	cursor := gfs.Find(query).Iter()
+	cursor := q.Iter()
 	defer cursor.Close()
 
+	var files []GFSFile
 	var file GFSFile
 	for cursor.Next(&file) {
-		display += fmt.Sprintf("%s\t%d\n", file.Name, file.Length)
+		files = append(files, file)
+		file = GFSFile{}
 	}
 	if err := cursor.Err(); err != nil {
 		return "", fmt.Errorf("error retrieving list of GridFS files: %v", err)
 	}
 
-	return display, nil
+	return formatFiles(files, mf.InputOptions.Format)
 }
 
 // Return the local filename, as specified by the --local flag. Defaults to
@@ -165,6 +445,19 @@ func (mf *MongoFiles) handleGetID(gfs *mgo.GridFS) (string, error) {
 	return fmt.Sprintf("finished writing to: %s\n", mf.getLocalFileName(gFile)), nil
 }
 
+// handle logic for 'cat' command
+func (mf *MongoFiles) handleCat(gfs *mgo.GridFS) error {
+	gFile, err := gfs.Open(mf.FileName)
+	if err != nil {
+		return fmt.Errorf("error opening GridFS file '%s': %v", mf.FileName, err)
+	}
+	defer gFile.Close()
+	if err = mf.copyRange(os.Stdout, gFile); err != nil {
+		return fmt.Errorf("error while writing '%v' to stdout: %v", mf.FileName, err)
+	}
+	return nil
+}
+
 // logic for deleting a file with 'delete_id'
 func (mf *MongoFiles) handleDeleteID(gfs *mgo.GridFS) (string, error) {
 	id, err := mf.parseID()
@@ -196,19 +489,97 @@ func (mf *MongoFiles) parseID() (interface{}, error) {
 // writeFile writes a file from gridFS to stdout or the filesystem.
 func (mf *MongoFiles) writeFile(gridFile *mgo.GridFile) (err error) {
 	localFileName := mf.getLocalFileName(gridFile)
-	var localFile io.WriteCloser
 	if localFileName == "-" {
-		localFile = os.Stdout
-	} else {
-		if localFile, err = os.Create(localFileName); err != nil {
-			return fmt.Errorf("error while opening local file '%v': %v\n", localFileName, err)
+		if err = mf.copyRange(os.Stdout, gridFile); err != nil {
+			return fmt.Errorf("error while writing data to stdout: %v\n", err)
 		}
-		defer localFile.Close()
-		log.Logf(log.DebugLow, "created local file '%v'", localFileName)
+		return nil
+	}
+	return writeLocalFile(localFileName, func(w io.Writer) error {
+		if err := mf.copyRange(w, gridFile); err != nil {
+			return fmt.Errorf("error while writing data into local file '%v': %v\n", localFileName, err)
+		}
+		return nil
+	})
+}
+
+// writeLocalFile writes a local file through a temporary file in the same
+// directory, which replaces it only once write succeeds, so that a failed or
+// corrupt download leaves the file as it was.
+func writeLocalFile(path string, write func(w io.Writer) error) (err error) {
+	localFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return fmt.Errorf("error while opening local file '%v': %v", path, err)
+	}
+	defer func() {
+		localFile.Close()
+		if err != nil {
+			os.Remove(localFile.Name())
+		}
+	}()
+	log.Logf(log.DebugLow, "created temporary file '%v'", localFile.Name())
+
+	if err = write(localFile); err != nil {
+		return err
+	}
+
+	// keep the mode of the file replaced, temporary files being private
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = localFile.Chmod(mode); err == nil {
+		err = localFile.Close()
+	}
+	if err == nil {
+		err = os.Rename(localFile.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("error while writing data into local file '%v': %v", path, err)
 	}
+	return nil
+}
+
+// copyRange copies the range of a GridFS file given by --offset and --length
+// to w, fetching only the chunks it spans. Whole files are checked against
+// their md5.
+func (mf *MongoFiles) copyRange(w io.Writer, gridFile *mgo.GridFile) error {
+	offset, length := mf.StorageOptions.Offset, mf.StorageOptions.Length
+	if offset > 0 {
+		if _, err := gridFile.Seek(offset, os.SEEK_SET); err != nil {
+			return fmt.Errorf("error seeking to offset %v of %v bytes: %v", offset, gridFile.Size(), err)
+		}
+	}
+	var r io.Reader = gridFile
+	if length > 0 {
+		r = io.LimitReader(gridFile, length)
+	}
+	whole := offset == 0 && (length == 0 || length >= gridFile.Size())
+
+	hash := md5.New()
+	if whole {
+		w = io.MultiWriter(w, hash)
+	}
+	// reading whole chunks at once saves copies and writes
+	if _, err := io.CopyBuffer(w, r, make([]byte, copyBufferSize)); err != nil {
+		return err
+	}
+	if whole {
+		return verifyMD5(gridFile, hash)
+	}
+	return nil
+}
 
-	if _, err = io.Copy(localFile, gridFile); err != nil {
-		return fmt.Errorf("error while writing data into local file '%v': %v\n", localFileName, err)
+// verifyMD5 checks the md5 of the data read from a GridFS file against the one
+// stored with it, unless it has none.
+func verifyMD5(gridFile *mgo.GridFile, hash hash.Hash) error {
+	if gridFile.MD5() == "" {
+		return nil
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(sum, gridFile.MD5()) {
+		return fmt.Errorf("md5 of the data read from GridFS file '%v' is %v, but %v is stored with it",
+			gridFile.Name(), sum, gridFile.MD5())
 	}
 	return nil
 }
@@ -250,13 +621,13 @@ func (mf *MongoFiles) handlePut(gfs *mgo.GridFS) (output string, err error) {
 		if closeErr := gFile.Close(); err == nil && closeErr != nil {
 			log.Logf(log.DebugHigh, "error occurred while closing GridFS file handler")
 			err = fmt.Errorf("error while storing '%v' into GridFS: %v\n", localFileName, closeErr)
+		} else if err == nil {
+			err = mf.setAliases(gfs, gFile)
 		}
 	}()
 
-	// set optional mime type
-	if mf.StorageOptions.ContentType != "" {
-		gFile.SetContentType(mf.StorageOptions.ContentType)
-	}
+	// set optional mime type and metadata
+	mf.describeFile(gFile)
 
 	n, err := io.Copy(gFile, localFile)
 	if err != nil {
@@ -268,6 +639,33 @@ func (mf *MongoFiles) handlePut(gfs *mgo.GridFS) (output string, err error) {
 	return output, nil
 }
 
+// describeFile sets the optional chunk size, content type and metadata of a
+// GridFS file being written.
+func (mf *MongoFiles) describeFile(gFile *mgo.GridFile) {
+	if mf.StorageOptions.ChunkSize != 0 {
+		gFile.SetChunkSize(mf.StorageOptions.ChunkSize)
+	}
+	if mf.StorageOptions.ContentType != "" {
+		gFile.SetContentType(mf.StorageOptions.ContentType)
+	}
+	if mf.metadata != nil {
+		gFile.SetMeta(mf.metadata)
+	}
+}
+
+// setAliases stores the --aliases of a GridFS file once it's closed, since
+// the driver doesn't write the 'aliases' field itself.
+func (mf *MongoFiles) setAliases(gfs *mgo.GridFS, gFile *mgo.GridFile) error {
+	if len(mf.aliases) == 0 {
+		return nil
+	}
+	err := gfs.Files.UpdateId(gFile.Id(), bson.M{"$set": bson.M{"aliases": mf.aliases}})
+	if err != nil {
+		return fmt.Errorf("error while setting the aliases of '%v': %v", gFile.Name(), err)
+	}
+	return nil
+}
+
 // Run the mongofiles utility. If displayHost is true, the connected host/port is
 // displayed.
 func (mf *MongoFiles) Run(displayHost bool) (string, error) {
@@ -367,7 +765,15 @@ func (mf *MongoFiles) Run(displayHost bool) (string, error) {
 
 	case Get:
 
-		output, err = mf.handleGet(gfs)
+		if mf.StorageOptions.Recursive {
+			root := mf.StorageOptions.LocalFileName
+			if root == "" {
+				root = "."
+			}
+			output, err = mf.handleTransfer(session, transfer{root: root, prefix: mf.FileName})
+		} else {
+			output, err = mf.handleGet(gfs)
+		}
 		if err != nil {
 			return "", err
 		}
@@ -381,7 +787,16 @@ func (mf *MongoFiles) Run(displayHost bool) (string, error) {
 
 	case Put:
 
-		output, err = mf.handlePut(gfs)
+		if mf.StorageOptions.Recursive {
+			output, err = mf.handleTransfer(session, transfer{
+				upload:     true,
+				root:       mf.FileName,
+				namePrefix: mf.StorageOptions.NamePrefix,
+				replace:    mf.StorageOptions.Replace,
+			})
+		} else {
+			output, err = mf.handlePut(gfs)
+		}
 		if err != nil {
 			return "", err
 		}
@@ -401,6 +816,48 @@ func (mf *MongoFiles) Run(displayHost bool) (string, error) {
 			return "", err
 		}
 
+	case Sync:
+
+		// sync replaces the GridFS files it uploads, so that GridFS mirrors
+		// the local directory
+		output, err = mf.handleTransfer(session, transfer{
+			upload:      mf.StorageOptions.Direction != SyncDownload,
+			root:        mf.FileName,
+			namePrefix:  mf.StorageOptions.NamePrefix,
+			onlyChanged: true,
+			replace:     true,
+		})
+		if err != nil {
+			return "", err
+		}
+
+	case Cat:
+
+		// the file is the output, which nothing else is written to
+		if err = mf.handleCat(gfs); err != nil {
+			return "", err
+		}
+
+	case Serve:
+
+		if err = mf.handleServe(session); err != nil {
+			return "", err
+		}
+
+	case Copy:
+
+		output, err = mf.handleCopy(session)
+		if err != nil {
+			return "", err
+		}
+
+	case Fsck:
+
+		output, err = mf.handleFsck(gfs)
+		if err != nil {
+			return "", err
+		}
+
 	}
 
 	return output, nil
@@ -13,6 +13,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -211,6 +212,46 @@ func TestValidArguments(t *testing.T) {
 	})
 }
 
+func TestWriteLocalFile(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a local file", t, func() {
+		dir, err := ioutil.TempDir("", "mongofiles_local")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "a.txt")
+		So(ioutil.WriteFile(path, []byte("old"), 0640), ShouldBeNil)
+
+		Convey("a failed write should leave it untouched", func() {
+			err := writeLocalFile(path, func(w io.Writer) error {
+				w.Write([]byte("corrupt"))
+				return fmt.Errorf("md5 mismatch")
+			})
+			So(err, ShouldNotBeNil)
+			content, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "old")
+			entries, err := ioutil.ReadDir(dir)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+		})
+
+		Convey("a successful write should replace it, keeping its mode", func() {
+			err := writeLocalFile(path, func(w io.Writer) error {
+				_, err := w.Write([]byte("new"))
+				return err
+			})
+			So(err, ShouldBeNil)
+			content, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "new")
+			info, err := os.Stat(path)
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, os.FileMode(0640))
+		})
+	})
+}
+
 // Test that the output from mongofiles is actually correct
 func TestMongoFilesCommands(t *testing.T) {
 	testutil.VerifyTestType(t, testutil.IntegrationTestType)
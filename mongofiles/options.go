@@ -1,17 +1,26 @@
 package mongofiles
 
+import (
+	"time"
+)
+
 var Usage = `<options> <command> <filename or _id>
 
 Manipulate gridfs files using the command line.
 
 Possible commands include:
-	list      - list all files; 'filename' is an optional prefix which listed filenames must begin with
-	search    - search all files; 'filename' is a substring which listed filenames must contain
-	put       - add a file with filename 'filename'
-	get       - get a file with filename 'filename'
+	list      - list all files; 'filename' is an optional prefix which listed filenames must begin with; with --filter, only the files matching a query
+	search    - search all files; 'filename' is a substring which listed filenames must contain; with --filter, only the files matching a query
+	put       - add a file with filename 'filename'; with --recursive, add the files of the directory 'filename', named after their paths relative to it
+	get       - get a file with filename 'filename'; with --recursive, get the files whose names begin with 'filename' into the --local directory
 	get_id    - get a file with the given '_id'
+	cat       - write the content of a file with filename 'filename' to stdout
 	delete    - delete all files with filename 'filename'
 	delete_id - delete a file with the given '_id'
+	fsck      - check that the chunks of all files match their length, chunkSize and md5, and find orphaned chunks
+	serve     - serve the files over HTTP by filename, listing names ending with '/' like directories
+	copy      - copy the files, or those matching --filter, to the bucket given by --to, --toDB and --toPrefix, skipping those already copied and, without --overwrite, those conflicting with other files
+	sync      - upload the files of the directory 'filename' whose size or md5 differ from those in GridFS, named after --namePrefix and their relative paths, or download them with --direction=download
 
 See http://docs.mongodb.org/manual/reference/program/mongofiles/ for more information.`
 
@@ -23,15 +32,73 @@ type StorageOptions struct {
 	// 'LocalFileName' is an option that specifies what filename to use for (put|get)
 	LocalFileName string `long:"local" value-name:"<filename>" short:"l" description:"local filename for put|get"`
 
+	// Offset is the offset of the range of files read by 'get' and 'cat'.
+	Offset int64 `long:"offset" value-name:"<bytes>" description:"offset of the range of the file to read with get, get_id or cat"`
+
+	// Length is the length of the range of files read by 'get' and 'cat'.
+	Length int64 `long:"length" value-name:"<bytes>" description:"length of the range of the file to read with get, get_id or cat (defaults to the rest of the file)"`
+
+	// ChunkSize is the size of the chunks of files added with 'put' or copied with 'copy'.
+	ChunkSize int `long:"chunkSize" value-name:"<bytes>" description:"size of the chunks of the files added by put or sync (defaults to 261120), or of the files copied by copy (defaults to that of each file)"`
+
 	// 'ContentType' is an option that specifies the Content/MIME type to use for 'put'
 	ContentType string `long:"type" value-nane:"<content-type>" short:"t" description:"content/MIME type for put (optional)"`
 
+	// Metadata is a JSON document stored in the 'metadata' field of files added with 'put'.
+	Metadata string `long:"metadata" value-name:"<json>" description:"metadata document to store with the files added by put or sync, e.g. '{\"tag\": \"logo\"}'"`
+
+	// Aliases are stored in the 'aliases' field of files added with 'put'.
+	Aliases string `long:"aliases" value-name:"<alias>[,<alias>]*" description:"comma-separated list of aliases to store with the files added by put or sync"`
+
 	// if set, 'Replace' will remove other files with same name after 'put'
 	Replace bool `long:"replace" short:"r" description:"remove other files with same name after put"`
 
 	// GridFSPrefix specifies what GridFS prefix to use; defaults to 'fs'
 	GridFSPrefix string `long:"prefix" value-name:"<prefix>" default:"fs" default-mask:"-" description:"GridFS prefix to use (default is 'fs')"`
 
+	// Recursive transfers directory trees with 'put' and 'get'.
+	Recursive bool `long:"recursive" description:"put the files of a directory, named after their paths relative to it, or get the files whose names begin with a prefix into the --local directory (defaults to the current directory)"`
+
+	// Direction is the direction of the 'sync' command.
+	Direction string `long:"direction" value-name:"<direction>" description:"direction of sync: upload copies the local directory to GridFS and download copies GridFS to the local directory (defaults to 'upload')"`
+
+	// Delete removes the files missing from the source of recursive transfers.
+	Delete bool `long:"delete" description:"with sync or --recursive, remove the files missing from the source; uploads need --namePrefix, and only remove the GridFS files whose names begin with it"`
+
+	// NamePrefix is the prefix of the GridFS names of the files of the local
+	// directory transferred by 'sync' and 'put --recursive'.
+	NamePrefix string `long:"namePrefix" value-name:"<prefix>" description:"with sync or put --recursive, prefix of the GridFS names of the files of the local directory, as a directory ending with '/', e.g. 'site/'; sync only transfers and deletes the GridFS files whose names begin with it"`
+
+	// NumParallelTransfers is the number of files transferred at once by recursive transfers.
+	NumParallelTransfers int `long:"numParallelTransfers" value-name:"<number>" description:"number of files to transfer at once with sync or --recursive (defaults to 4)"`
+
+	// DeleteOrphans removes the chunks found by 'fsck' that belong to no file.
+	DeleteOrphans bool `long:"deleteOrphans" description:"with fsck, delete the chunks that belong to no file and are older than --orphanAge; chunks are written before their file, so younger chunks may belong to an upload in progress and are kept"`
+
+	// OrphanAge is the age below which 'fsck' keeps orphaned chunks.
+	OrphanAge time.Duration `long:"orphanAge" value-name:"<duration>" default:"24h" default-mask:"-" description:"with fsck --deleteOrphans, only delete the orphaned chunks older than this, e.g. 1h30m; an upload stalled for longer loses its chunks (default is 24h)"`
+
+	// Quarantine is the GridFS prefix to which 'fsck' moves broken files.
+	Quarantine string `long:"quarantine" value-name:"<prefix>" description:"with fsck, move the broken files and their chunks to the GridFS bucket of this prefix"`
+
+	// To is the destination host of 'copy'.
+	To string `long:"to" value-name:"<hostname>" description:"host to copy files to with copy, as with --host (defaults to the source host)"`
+
+	// ToDB is the destination database of 'copy'.
+	ToDB string `long:"toDB" value-name:"<database-name>" description:"database to copy files to with copy (defaults to --db)"`
+
+	// ToPrefix is the destination GridFS prefix of 'copy'.
+	ToPrefix string `long:"toPrefix" value-name:"<prefix>" description:"GridFS prefix to copy files to with copy (defaults to --prefix)"`
+
+	// Overwrite replaces the conflicting files of the destination of 'copy'.
+	Overwrite bool `long:"overwrite" description:"with copy, replace the files of the destination that have the _id of a copied file but another length or md5, and the chunks of such an _id that belong to no file, which may be those of an upload in progress"`
+
+	// Listen is the address on which 'serve' listens.
+	Listen string `long:"listen" value-name:"<host>:<port>" description:"address on which serve listens (defaults to 127.0.0.1:8080)"`
+
+	// AllowWrites enables PUT and DELETE requests to 'serve'.
+	AllowWrites bool `long:"allowWrites" description:"with serve, allow PUT requests to replace files and DELETE requests to delete them"`
+
 	// Specifies the write concern for each write operation that mongofiles writes to the target database.
 	// By default, mongofiles waits for a majority of members from the replica set to respond before returning.
 	WriteConcern string `long:"writeConcern" value-name:"<write-concern>" default:"majority" default-mask:"-" description:"write concern options e.g. --writeConcern majority, --writeConcern '{w: 3, wtimeout: 500, fsync: true, j: true}' (defaults to 'majority')"`
@@ -45,6 +112,18 @@ func (_ *StorageOptions) Name() string {
 // InputOptions defines the set of options to use in retrieving data from the server.
 type InputOptions struct {
 	ReadPreference string `long:"readPreference" value-name:"<string>|<json>" description:"specify either a preference name or a preference json object"`
+
+	// Filter is a query over the files collection which listed files must match.
+	Filter string `long:"filter" value-name:"<json>" description:"query filter over the files collection for list, search and copy, as a JSON string, e.g. '{\"metadata.tag\": \"logo\"}'"`
+
+	// Sort orders the files listed.
+	Sort string `long:"sort" value-name:"<json>" description:"sort order of the files listed by list and search, as a JSON string, e.g. '{\"uploadDate\": -1}'"`
+
+	// Limit is the maximum number of files listed.
+	Limit int `long:"limit" value-name:"<number>" description:"limit the number of files listed by list and search"`
+
+	// Format is the format of the files listed, or of the problems found by 'fsck'.
+	Format string `long:"format" value-name:"<format>" description:"format of the files listed by list and search, or of the problems found by fsck, either table or json; by default, only the names and lengths of files are listed"`
 }
 
 // Name returns a human-readable group name for input options.
@@ -0,0 +1,240 @@
+package mongofiles
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultListenAddress is the address 'serve' listens on by default, only
+// reachable locally.
+const defaultListenAddress = "127.0.0.1:8080"
+
+// gridFSHandler serves the files of a GridFS bucket over HTTP, by filename.
+// Names ending with a slash are listed like directories.
+type gridFSHandler struct {
+	// session is copied for each request
+	session *mgo.Session
+
+	db     string
+	prefix string
+
+	// allowWrites enables PUT and DELETE requests
+	allowWrites bool
+}
+
+// newGridFSHandler returns a handler of the bucket of the given prefix.
+func newGridFSHandler(session *mgo.Session, db, prefix string, allowWrites bool) *gridFSHandler {
+	return &gridFSHandler{
+		session:     session,
+		db:          db,
+		prefix:      prefix,
+		allowWrites: allowWrites,
+	}
+}
+
+func (h *gridFSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Logf(log.Info, "%v %v", r.Method, r.URL.Path)
+	session := h.session.Copy()
+	defer session.Close()
+	gfs := session.DB(h.db).GridFS(h.prefix)
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch r.Method {
+	case "GET", "HEAD":
+		if name == "" || strings.HasSuffix(name, "/") {
+			h.serveListing(w, r, gfs, name)
+		} else {
+			h.serveFile(w, r, gfs, name)
+		}
+	case "PUT", "DELETE":
+		if !h.allowWrites {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "writes are disabled, see --allowWrites", http.StatusMethodNotAllowed)
+			return
+		}
+		if name == "" || strings.HasSuffix(name, "/") {
+			http.Error(w, "not a file name", http.StatusBadRequest)
+			return
+		}
+		if r.Method == "PUT" {
+			h.putFile(w, r, gfs, name)
+		} else {
+			h.deleteFile(w, gfs, name)
+		}
+	default:
+		if h.allowWrites {
+			w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		} else {
+			w.Header().Set("Allow", "GET, HEAD")
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveFile serves the latest file of a name, whose md5 is its ETag. Ranges
+// only fetch the chunks they span.
+func (h *gridFSHandler) serveFile(w http.ResponseWriter, r *http.Request, gfs *mgo.GridFS, name string) {
+	gFile, err := gfs.Open(name)
+	if err == mgo.ErrNotFound {
+		// redirect to the listing of names that have it as a directory
+		if n, err := gfs.Find(prefixQuery(name + "/")).Limit(1).Count(); err == nil && n > 0 {
+			http.Redirect(w, r, (&url.URL{Path: "/" + name + "/"}).EscapedPath(), http.StatusMovedPermanently)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	defer gFile.Close()
+
+	if gFile.MD5() != "" {
+		w.Header().Set("ETag", `"`+gFile.MD5()+`"`)
+	}
+	if gFile.ContentType() != "" {
+		w.Header().Set("Content-Type", gFile.ContentType())
+	}
+	http.ServeContent(w, r, name, gFile.UploadDate(), gFile)
+}
+
+// serveListing serves an HTML list of the files and directories directly
+// under a prefix.
+func (h *gridFSHandler) serveListing(w http.ResponseWriter, r *http.Request, gfs *mgo.GridFS, prefix string) {
+	var names []string
+	cursor := gfs.Find(prefixQuery(prefix)).Select(bson.M{"filename": 1}).Sort("filename").Iter()
+	var file GFSFile
+	for cursor.Next(&file) {
+		names = append(names, file.Name)
+	}
+	if err := cursor.Close(); err != nil {
+		h.serverError(w, err)
+		return
+	}
+	entries := listEntries(prefix, names)
+	if prefix != "" && len(entries) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	listing := &bytes.Buffer{}
+	fmt.Fprintf(listing, "<pre>\n")
+	for _, entry := range entries {
+		// as with http.FileServer, names are escaped so that colons aren't
+		// taken for URL schemes
+		href := (&url.URL{Path: entry}).String()
+		fmt.Fprintf(listing, "<a href=\"%v\">%v</a>\n", html.EscapeString(href), html.EscapeString(entry))
+	}
+	fmt.Fprintf(listing, "</pre>\n")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method != "HEAD" {
+		w.Write(listing.Bytes())
+	}
+}
+
+// putFile stores the request body as the file of a name, replacing its
+// earlier versions once stored.
+func (h *gridFSHandler) putFile(w http.ResponseWriter, r *http.Request, gfs *mgo.GridFS, name string) {
+	gFile, err := gfs.Create(name)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		gFile.SetContentType(contentType)
+	}
+	if _, err = io.Copy(gFile, r.Body); err != nil {
+		gFile.Abort()
+		gFile.Close()
+		http.Error(w, fmt.Sprintf("error reading the request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err = gFile.Close(); err != nil {
+		h.serverError(w, err)
+		return
+	}
+	query := bson.M{"filename": name, "_id": bson.M{"$ne": gFile.Id()}}
+	cursor := gfs.Find(query).Select(bson.M{"_id": 1}).Iter()
+	var file GFSFile
+	for cursor.Next(&file) {
+		if err = gfs.RemoveId(file.Id); err != nil {
+			cursor.Close()
+			h.serverError(w, err)
+			return
+		}
+	}
+	if err = cursor.Close(); err != nil {
+		h.serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// deleteFile removes all the files of a name.
+func (h *gridFSHandler) deleteFile(w http.ResponseWriter, gfs *mgo.GridFS, name string) {
+	n, err := gfs.Find(bson.M{"filename": name}).Count()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err = gfs.Remove(name); err != nil {
+		h.serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *gridFSHandler) serverError(w http.ResponseWriter, err error) {
+	log.Logf(log.Always, "error serving GridFS request: %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// prefixQuery returns a query of the files whose names begin with prefix.
+func prefixQuery(prefix string) bson.M {
+	return bson.M{"filename": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}
+}
+
+// listEntries returns the sorted names of the files and directories directly
+// under a prefix, relative to it, given the sorted names beginning with it.
+// Directories end with a slash.
+func listEntries(prefix string, names []string) []string {
+	var entries []string
+	for _, name := range names {
+		entry := strings.TrimPrefix(name, prefix)
+		if i := strings.Index(entry, "/"); i >= 0 {
+			entry = entry[:i+1]
+		}
+		if entry == "" || len(entries) > 0 && entries[len(entries)-1] == entry {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// handle logic for 'serve' command
+func (mf *MongoFiles) handleServe(session *mgo.Session) error {
+	address := mf.StorageOptions.Listen
+	if address == "" {
+		address = defaultListenAddress
+	}
+	handler := newGridFSHandler(session, mf.StorageOptions.DB, mf.StorageOptions.GridFSPrefix,
+		mf.StorageOptions.AllowWrites)
+	log.Logf(log.Always, "serving GridFS prefix '%v' of database '%v' on http://%v/",
+		mf.StorageOptions.GridFSPrefix, mf.StorageOptions.DB, address)
+	return http.ListenAndServe(address, handler)
+}
@@ -0,0 +1,120 @@
+package mongofiles
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListEntries(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Names should be listed like directories", t, func() {
+		names := []string{"a.txt", "docs/b.txt", "docs/c/d.md", "docs/e.txt", "z"}
+		So(listEntries("", names), ShouldResemble, []string{"a.txt", "docs/", "z"})
+		So(listEntries("docs/", names[1:4]), ShouldResemble, []string{"b.txt", "c/", "e.txt"})
+		So(listEntries("docs/c/", names[2:3]), ShouldResemble, []string{"d.md"})
+		So(listEntries("x/", nil), ShouldBeEmpty)
+	})
+}
+
+func TestServeArguments(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a MongoFiles instance", t, func() {
+		mf, err := simpleMongoFilesInstance([]string{"search", "file"})
+		So(err, ShouldBeNil)
+
+		Convey("serve should take no argument", func() {
+			So(mf.ValidateCommand([]string{"serve"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"serve", "file"}), ShouldNotBeNil)
+		})
+
+		Convey("--listen and --allowWrites should only be accepted by serve", func() {
+			mf.StorageOptions.Listen = "localhost:8000"
+			mf.StorageOptions.AllowWrites = true
+			So(mf.ValidateCommand([]string{"serve"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"list"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestServe(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With files served over HTTP", t, func() {
+		_, err := setUpGridFSTestData()
+		So(err, ShouldBeNil)
+		defer tearDownGridFSTestData()
+
+		mf, err := simpleMongoFilesInstance([]string{"serve", ""})
+		So(err, ShouldBeNil)
+		session, err := mf.SessionProvider.GetSession()
+		So(err, ShouldBeNil)
+		defer session.Close()
+		handler := newGridFSHandler(session, testDB, "fs", false)
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		request := func(method, path, body string, headers ...string) (*http.Response, string) {
+			req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+			So(err, ShouldBeNil)
+			for i := 0; i < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			content, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			return resp, string(content)
+		}
+
+		Convey("files should be served with their md5 as ETag", func() {
+			resp, content := request("GET", "/testfile2", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(content, ShouldEqual, strings.Repeat("a", 10))
+			etag := resp.Header.Get("ETag")
+			So(etag, ShouldNotBeEmpty)
+			So(resp.Header.Get("Last-Modified"), ShouldNotBeEmpty)
+
+			resp, _ = request("GET", "/testfile2", "", "If-None-Match", etag)
+			So(resp.StatusCode, ShouldEqual, http.StatusNotModified)
+
+			resp, content = request("GET", "/testfile2", "", "Range", "bytes=2-4")
+			So(resp.StatusCode, ShouldEqual, http.StatusPartialContent)
+			So(content, ShouldEqual, "aaa")
+
+			resp, _ = request("GET", "/missing", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("the root should list the files", func() {
+			resp, content := request("GET", "/", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(content, ShouldContainSubstring, `<a href="testfile1">testfile1</a>`)
+		})
+
+		Convey("writes should only be allowed with --allowWrites", func() {
+			resp, _ := request("PUT", "/new.txt", "new")
+			So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+			handler.allowWrites = true
+			resp, _ = request("PUT", "/docs/new.txt", "new", "Content-Type", "text/x-new")
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			resp, content := request("GET", "/docs/new.txt", "")
+			So(content, ShouldEqual, "new")
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "text/x-new")
+			_, content = request("GET", "/docs/", "")
+			So(content, ShouldContainSubstring, `<a href="new.txt">new.txt</a>`)
+
+			resp, _ = request("DELETE", "/docs/new.txt", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			resp, _ = request("DELETE", "/docs/new.txt", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
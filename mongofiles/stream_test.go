@@ -0,0 +1,106 @@
+package mongofiles
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamArguments(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a MongoFiles instance", t, func() {
+		mf, err := simpleMongoFilesInstance([]string{"search", "file"})
+		So(err, ShouldBeNil)
+
+		Convey("cat should require a file name", func() {
+			So(mf.ValidateCommand([]string{"cat", "file"}), ShouldBeNil)
+			So(mf.Command, ShouldEqual, Cat)
+			So(mf.ValidateCommand([]string{"cat"}), ShouldNotBeNil)
+		})
+
+		Convey("ranges should only be read by get, get_id and cat", func() {
+			mf.StorageOptions.Offset = 10
+			mf.StorageOptions.Length = 5
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"get_id", "1"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"cat", "file"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"put", "file"}), ShouldNotBeNil)
+			mf.StorageOptions.Recursive = true
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldNotBeNil)
+			mf.StorageOptions.Recursive = false
+			mf.StorageOptions.Offset = -1
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldNotBeNil)
+		})
+
+		Convey("--chunkSize should only be accepted by uploads", func() {
+			mf.StorageOptions.ChunkSize = 1024
+			So(mf.ValidateCommand([]string{"put", "file"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldNotBeNil)
+			mf.StorageOptions.ChunkSize = maxChunkSize + 1
+			So(mf.ValidateCommand([]string{"put", "file"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRangedReads(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With a file put in small chunks", t, func() {
+		root, err := ioutil.TempDir("", "mongofiles")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		content := strings.Repeat("0123456789", 100)
+		So(writeTree(root, map[string]string{"digits.txt": content}), ShouldBeNil)
+		defer tearDownGridFSTestData()
+
+		mf, err := simpleMongoFilesInstance([]string{"put", "digits.txt"})
+		So(err, ShouldBeNil)
+		mf.StorageOptions.LocalFileName = filepath.Join(root, "digits.txt")
+		mf.StorageOptions.ChunkSize = 64
+		So(mf.ValidateCommand([]string{"put", "digits.txt"}), ShouldBeNil)
+		_, err = mf.Run(false)
+		So(err, ShouldBeNil)
+
+		session, err := mf.SessionProvider.GetSession()
+		So(err, ShouldBeNil)
+		defer session.Close()
+		count, err := session.DB(testDB).GridFS("fs").Chunks.Find(nil).Count()
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 16)
+
+		get := func(offset, length int64) (string, error) {
+			mf, err := simpleMongoFilesInstance([]string{"get", "digits.txt"})
+			if err != nil {
+				return "", err
+			}
+			mf.StorageOptions.LocalFileName = filepath.Join(root, "range.txt")
+			mf.StorageOptions.Offset = offset
+			mf.StorageOptions.Length = length
+			if _, err = mf.Run(false); err != nil {
+				return "", err
+			}
+			data, err := ioutil.ReadFile(mf.StorageOptions.LocalFileName)
+			return string(data), err
+		}
+
+		Convey("get should read the range given", func() {
+			data, err := get(125, 10)
+			So(err, ShouldBeNil)
+			So(data, ShouldEqual, content[125:135])
+			data, err = get(990, 0)
+			So(err, ShouldBeNil)
+			So(data, ShouldEqual, content[990:])
+			data, err = get(0, 0)
+			So(err, ShouldBeNil)
+			So(data, ShouldEqual, content)
+			_, err = get(1001, 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,424 @@
+package mongofiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/progress"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Values of the --direction option of the 'sync' command.
+const (
+	SyncUpload   = "upload"
+	SyncDownload = "download"
+)
+
+const (
+	// defaultNumParallelTransfers is the number of files transferred at once
+	// if --numParallelTransfers isn't set.
+	defaultNumParallelTransfers = 4
+
+	progressBarLength = 24
+)
+
+// syncFile is a file of a local directory tree or of GridFS, named after its
+// slash-separated path relative to the root of the tree.
+type syncFile struct {
+	name string
+	size int64
+
+	// md5 is the hex MD5 checksum of the contents, which is computed when
+	// first needed for local files
+	md5 string
+
+	// path is the path of a local file
+	path string
+
+	// ids are the _ids of the GridFS files of the name, the latest last
+	ids []interface{}
+}
+
+// checksum returns the MD5 checksum of the file.
+func (f *syncFile) checksum() (string, error) {
+	if f.md5 != "" || f.path == "" {
+		return f.md5, nil
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := md5.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	f.md5 = hex.EncodeToString(hash.Sum(nil))
+	return f.md5, nil
+}
+
+// listLocalFiles returns the regular files of the directory tree at root
+// whose names start with prefix, by name. A missing root holds no files.
+func listLocalFiles(root, prefix string) (map[string]*syncFile, error) {
+	files := map[string]*syncFile{}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return files, nil
+	}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			log.Logf(log.Info, "skipping '%v', which is not a regular file", path)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			files[name] = &syncFile{name: name, size: info.Size(), path: path}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing local files in '%v': %v", root, err)
+	}
+	return files, nil
+}
+
+// listGridFSFiles returns the GridFS files whose names start with namePrefix
+// followed by prefix, by name stripped of namePrefix. The size and checksum
+// of a name are those of its latest file.
+func listGridFSFiles(gfs *mgo.GridFS, namePrefix, prefix string) (map[string]*syncFile, error) {
+	query := bson.M{}
+	if namePrefix+prefix != "" {
+		query["filename"] = bson.M{"$regex": "^" + regexp.QuoteMeta(namePrefix+prefix)}
+	}
+	cursor := gfs.Find(query).Sort("uploadDate").Iter()
+	defer cursor.Close()
+
+	files := map[string]*syncFile{}
+	var file GFSFile
+	for cursor.Next(&file) {
+		name := strings.TrimPrefix(file.Name, namePrefix)
+		f, ok := files[name]
+		if !ok {
+			f = &syncFile{name: name}
+			files[name] = f
+		}
+		f.size, f.md5 = file.Length, file.Md5
+		f.ids = append(f.ids, file.Id)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("error retrieving list of GridFS files: %v", err)
+	}
+	return files, nil
+}
+
+// byName sorts files by name.
+type byName []*syncFile
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].name < b[j].name }
+
+// sortedFiles returns files sorted by name.
+func sortedFiles(files map[string]*syncFile) []*syncFile {
+	sorted := make([]*syncFile, 0, len(files))
+	for _, file := range files {
+		sorted = append(sorted, file)
+	}
+	sort.Sort(byName(sorted))
+	return sorted
+}
+
+// planTransfers returns the source files to transfer to the destination, and
+// the destination files missing from the source. If onlyChanged is set, files
+// of the same size and checksum on both sides aren't transferred.
+func planTransfers(source, destination map[string]*syncFile, onlyChanged bool) (transfers, missing []*syncFile, err error) {
+	for _, file := range sortedFiles(source) {
+		existing, ok := destination[file.name]
+		if onlyChanged && ok && existing.size == file.size {
+			sourceMD5, err := file.checksum()
+			if err != nil {
+				return nil, nil, err
+			}
+			destinationMD5, err := existing.checksum()
+			if err != nil {
+				return nil, nil, err
+			}
+			if sourceMD5 == destinationMD5 {
+				continue
+			}
+		}
+		transfers = append(transfers, file)
+	}
+	for _, file := range sortedFiles(destination) {
+		if _, ok := source[file.name]; !ok {
+			missing = append(missing, file)
+		}
+	}
+	return transfers, missing, nil
+}
+
+// localPath returns the path of the file of the given GridFS name in the
+// directory tree at root, which must not be outside of it.
+func localPath(root, name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if name == "" || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" ||
+		rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("GridFS file name '%v' is not a relative path within the local directory", name)
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// progressWriter counts the bytes written through it.
+type progressWriter struct {
+	w       io.Writer
+	counter progress.Updateable
+}
+
+func (pw progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.counter.Inc(int64(n))
+	return n, err
+}
+
+// transfer describes the transfer of a directory tree between the local
+// file system and GridFS.
+type transfer struct {
+	// upload is set for transfers from the local directory to GridFS
+	upload bool
+
+	// root is the local directory, and prefix the prefix of the names of
+	// the files transferred
+	root   string
+	prefix string
+
+	// namePrefix is the prefix of the GridFS names of the files of the
+	// local directory, which also limits the GridFS files deleted
+	namePrefix string
+
+	// onlyChanged is set to skip the files that are the same on both sides
+	onlyChanged bool
+
+	// replace is set to remove the earlier GridFS files of the names
+	// uploaded
+	replace bool
+}
+
+// handleTransfer transfers the files of a directory tree between the local
+// file system and GridFS, with --numParallelTransfers workers, and removes
+// the files missing from the source if --delete is set.
+func (mf *MongoFiles) handleTransfer(session *mgo.Session, t transfer) (string, error) {
+	if t.upload {
+		// a missing directory would otherwise be mirrored as an empty one
+		info, err := os.Stat(t.root)
+		if err != nil {
+			return "", fmt.Errorf("error while opening local directory '%v': %v", t.root, err)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("'%v' is not a directory", t.root)
+		}
+	}
+	gfs := session.DB(mf.StorageOptions.DB).GridFS(mf.StorageOptions.GridFSPrefix)
+	localFiles, err := listLocalFiles(t.root, t.prefix)
+	if err != nil {
+		return "", err
+	}
+	gridFSFiles, err := listGridFSFiles(gfs, t.namePrefix, t.prefix)
+	if err != nil {
+		return "", err
+	}
+	source, destination := localFiles, gridFSFiles
+	if !t.upload {
+		source, destination = gridFSFiles, localFiles
+	}
+	transfers, missing, err := planTransfers(source, destination, t.onlyChanged)
+	if err != nil {
+		return "", err
+	}
+
+	var total int64
+	for _, file := range transfers {
+		total += file.size
+	}
+	counter := progress.NewCounter(total)
+	bar := &progress.Bar{
+		Name:      fmt.Sprintf("%v.%v", mf.StorageOptions.DB, mf.StorageOptions.GridFSPrefix),
+		Watching:  counter,
+		Writer:    log.Writer(0),
+		BarLength: progressBarLength,
+		IsBytes:   true,
+	}
+	bar.Start()
+	err = mf.transferFiles(session, t, transfers, destination, counter)
+	bar.Stop()
+	if err != nil {
+		return "", err
+	}
+
+	verb := "uploaded"
+	if !t.upload {
+		verb = "downloaded"
+	}
+	output := fmt.Sprintf("%v %v %v (%v)\n", verb, len(transfers),
+		util.Pluralize(len(transfers), "file", "files"), text.FormatByteAmount(total))
+	if t.onlyChanged {
+		unchanged := len(source) - len(transfers)
+		output += fmt.Sprintf("skipped %v unchanged %v\n", unchanged, util.Pluralize(unchanged, "file", "files"))
+	}
+	if !mf.StorageOptions.Delete {
+		return output, nil
+	}
+
+	for _, file := range missing {
+		if t.upload {
+			for _, id := range file.ids {
+				if err = gfs.RemoveId(id); err != nil {
+					return "", fmt.Errorf("error while removing '%v' from GridFS: %v", file.name, err)
+				}
+			}
+		} else if err = os.Remove(file.path); err != nil {
+			return "", fmt.Errorf("error while removing local file '%v': %v", file.path, err)
+		}
+		log.Logf(log.Info, "deleted '%v'", file.name)
+	}
+	output += fmt.Sprintf("deleted %v %v\n", len(missing), util.Pluralize(len(missing), "file", "files"))
+	return output, nil
+}
+
+// transferFiles transfers files in parallel, each worker with its own
+// session, returning the first error.
+func (mf *MongoFiles) transferFiles(session *mgo.Session, t transfer, files []*syncFile,
+	destination map[string]*syncFile, counter progress.Updateable) error {
+
+	numWorkers := mf.StorageOptions.NumParallelTransfers
+	if numWorkers == 0 {
+		numWorkers = defaultNumParallelTransfers
+	}
+	fileChan := make(chan *syncFile, len(files))
+	for _, file := range files {
+		fileChan <- file
+	}
+	close(fileChan)
+
+	errChan := make(chan error, numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			workerSession := session.Copy()
+			defer workerSession.Close()
+			gfs := workerSession.DB(mf.StorageOptions.DB).GridFS(mf.StorageOptions.GridFSPrefix)
+			for file := range fileChan {
+				var err error
+				if t.upload {
+					err = mf.uploadFile(gfs, file, destination[file.name], t, counter)
+				} else {
+					err = mf.downloadFile(gfs, file, t.root, counter)
+				}
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+			errChan <- nil
+		}()
+	}
+
+	var firstErr error
+	for i := 0; i < numWorkers; i++ {
+		if err := <-errChan; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// uploadFile stores a local file into GridFS, then removes the earlier GridFS
+// files of its name if the transfer replaces them.
+func (mf *MongoFiles) uploadFile(gfs *mgo.GridFS, file, existing *syncFile, t transfer,
+	counter progress.Updateable) (err error) {
+
+	localFile, err := os.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("error while opening local file '%v': %v", file.path, err)
+	}
+	defer localFile.Close()
+
+	gFile, err := gfs.Create(t.namePrefix + file.name)
+	if err != nil {
+		return fmt.Errorf("error while creating '%v' in GridFS: %v", t.namePrefix+file.name, err)
+	}
+	mf.describeFile(gFile)
+	if _, err = io.Copy(progressWriter{gFile, counter}, localFile); err != nil {
+		gFile.Abort()
+		gFile.Close()
+		return fmt.Errorf("error while storing '%v' into GridFS: %v", file.path, err)
+	}
+	if err = gFile.Close(); err != nil {
+		return fmt.Errorf("error while storing '%v' into GridFS: %v", file.path, err)
+	}
+	if err = mf.setAliases(gfs, gFile); err != nil {
+		return err
+	}
+	log.Logf(log.Info, "uploaded '%v'", file.name)
+
+	if !t.replace || existing == nil {
+		return nil
+	}
+	for _, id := range existing.ids {
+		if err = gfs.RemoveId(id); err != nil {
+			return fmt.Errorf("error while removing earlier versions of '%v' from GridFS: %v", file.name, err)
+		}
+	}
+	return nil
+}
+
+// downloadFile writes the latest GridFS file of a name to its path in the
+// directory tree at root, creating the directories needed. The data is
+// written to a temporary file of the same directory, which only replaces
+// the local file once its md5 is verified.
+func (mf *MongoFiles) downloadFile(gfs *mgo.GridFS, file *syncFile, root string,
+	counter progress.Updateable) (err error) {
+
+	path, err := localPath(root, file.name)
+	if err != nil {
+		return err
+	}
+	gFile, err := gfs.OpenId(file.ids[len(file.ids)-1])
+	if err != nil {
+		return fmt.Errorf("error opening GridFS file '%v': %v", file.name, err)
+	}
+	defer gFile.Close()
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error while creating local directory for '%v': %v", path, err)
+	}
+	err = writeLocalFile(path, func(w io.Writer) error {
+		hash := md5.New()
+		if _, err := io.Copy(progressWriter{io.MultiWriter(w, hash), counter}, gFile); err != nil {
+			return fmt.Errorf("error while writing data into local file '%v': %v", path, err)
+		}
+		return verifyMD5(gFile, hash)
+	})
+	if err != nil {
+		return err
+	}
+	log.Logf(log.Info, "downloaded '%v'", file.name)
+	return nil
+}
@@ -0,0 +1,242 @@
+package mongofiles
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files of the given contents, by slash-separated name, to
+// the directory tree at root.
+func writeTree(root string, contents map[string]string) error {
+	for name, content := range contents {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the names of files.
+func names(files []*syncFile) []string {
+	var result []string
+	for _, file := range files {
+		result = append(result, file.name)
+	}
+	return result
+}
+
+func TestTransferPlanning(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a local directory tree", t, func() {
+		root, err := ioutil.TempDir("", "mongofiles")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		So(writeTree(root, map[string]string{
+			"a.txt":       "aaa",
+			"docs/b.txt":  "bb",
+			"docs/c/d.md": "dddd",
+		}), ShouldBeNil)
+
+		Convey("its files should be named after their relative paths", func() {
+			files, err := listLocalFiles(root, "")
+			So(err, ShouldBeNil)
+			So(names(sortedFiles(files)), ShouldResemble, []string{"a.txt", "docs/b.txt", "docs/c/d.md"})
+			So(files["docs/b.txt"].size, ShouldEqual, 2)
+
+			files, err = listLocalFiles(root, "docs/")
+			So(err, ShouldBeNil)
+			So(names(sortedFiles(files)), ShouldResemble, []string{"docs/b.txt", "docs/c/d.md"})
+
+			files, err = listLocalFiles(filepath.Join(root, "missing"), "")
+			So(err, ShouldBeNil)
+			So(files, ShouldBeEmpty)
+		})
+
+		Convey("only files whose size or checksum differ should be transferred when syncing", func() {
+			source, err := listLocalFiles(root, "")
+			So(err, ShouldBeNil)
+			destination := map[string]*syncFile{
+				// md5 of "aaa"
+				"a.txt":      {name: "a.txt", size: 3, md5: "47bce5c74f589f4867dbd57e9ca9f808"},
+				"docs/b.txt": {name: "docs/b.txt", size: 2, md5: "00000000000000000000000000000000"},
+				"old.txt":    {name: "old.txt", size: 1},
+			}
+			transfers, missing, err := planTransfers(source, destination, true)
+			So(err, ShouldBeNil)
+			So(names(transfers), ShouldResemble, []string{"docs/b.txt", "docs/c/d.md"})
+			So(names(missing), ShouldResemble, []string{"old.txt"})
+
+			transfers, _, err = planTransfers(source, destination, false)
+			So(err, ShouldBeNil)
+			So(names(transfers), ShouldResemble, []string{"a.txt", "docs/b.txt", "docs/c/d.md"})
+		})
+	})
+
+	Convey("GridFS names should be mapped to paths within the local directory", t, func() {
+		path, err := localPath("out", "docs/b.txt")
+		So(err, ShouldBeNil)
+		So(path, ShouldEqual, filepath.Join("out", "docs", "b.txt"))
+		path, err = localPath("out", "docs/../b.txt")
+		So(err, ShouldBeNil)
+		So(path, ShouldEqual, filepath.Join("out", "b.txt"))
+
+		for _, name := range []string{"", "..", "../b.txt", "docs/../../b.txt", "/etc/passwd"} {
+			_, err := localPath("out", name)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestTransferArguments(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a MongoFiles instance", t, func() {
+		mf, err := simpleMongoFilesInstance([]string{"search", "file"})
+		So(err, ShouldBeNil)
+
+		Convey("--recursive should only be accepted by put and get", func() {
+			mf.StorageOptions.Recursive = true
+			So(mf.ValidateCommand([]string{"put", "dir"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"get", "prefix"}), ShouldBeNil)
+			So(mf.ValidateCommand([]string{"delete", "file"}), ShouldNotBeNil)
+			mf.StorageOptions.LocalFileName = "-"
+			So(mf.ValidateCommand([]string{"get", "prefix"}), ShouldNotBeNil)
+		})
+
+		Convey("sync should accept a direction", func() {
+			So(mf.ValidateCommand([]string{"sync"}), ShouldNotBeNil)
+			mf.StorageOptions.Direction = SyncDownload
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldBeNil)
+			So(mf.FileName, ShouldEqual, "dir")
+			mf.StorageOptions.Direction = "sideways"
+			err := mf.ValidateCommand([]string{"sync", "dir"})
+			So(err, ShouldNotBeNil)
+			So(strings.Contains(err.Error(), "--direction"), ShouldBeTrue)
+		})
+
+		Convey("transfer options should be rejected for single files", func() {
+			mf.StorageOptions.Delete = true
+			So(mf.ValidateCommand([]string{"put", "file"}), ShouldNotBeNil)
+			mf.StorageOptions.Direction = SyncDownload
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldBeNil)
+			mf.StorageOptions.Direction = ""
+			mf.StorageOptions.Delete = false
+			mf.StorageOptions.NumParallelTransfers = 2
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldNotBeNil)
+			mf.StorageOptions.Direction = SyncUpload
+			So(mf.ValidateCommand([]string{"get", "file"}), ShouldNotBeNil)
+		})
+
+		Convey("uploads should need --namePrefix to delete files", func() {
+			mf.StorageOptions.Delete = true
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldNotBeNil)
+			mf.StorageOptions.Recursive = true
+			So(mf.ValidateCommand([]string{"put", "dir"}), ShouldNotBeNil)
+			mf.StorageOptions.NamePrefix = "site"
+			So(mf.ValidateCommand([]string{"put", "dir"}), ShouldBeNil)
+			So(mf.StorageOptions.NamePrefix, ShouldEqual, "site/")
+			So(mf.ValidateCommand([]string{"get", "prefix"}), ShouldNotBeNil)
+			mf.StorageOptions.Recursive = false
+			So(mf.ValidateCommand([]string{"sync", "dir"}), ShouldBeNil)
+		})
+	})
+}
+
+func TestRecursiveTransfers(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.IntegrationTestType)
+
+	Convey("With a local directory tree", t, func() {
+		root, err := ioutil.TempDir("", "mongofiles")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		So(writeTree(root, map[string]string{"a.txt": "aaa", "docs/b.txt": "bb"}), ShouldBeNil)
+		defer tearDownGridFSTestData()
+
+		mf, err := simpleMongoFilesInstance([]string{"put", root})
+		So(err, ShouldBeNil)
+		mf.StorageOptions.Recursive = true
+		output, err := mf.Run(false)
+		So(err, ShouldBeNil)
+		So(output, ShouldStartWith, "uploaded 2 files")
+
+		Convey("sync should only upload the files that changed", func() {
+			mf.StorageOptions.NamePrefix = "site/"
+			_, err := mf.Run(false)
+			So(err, ShouldBeNil)
+
+			So(writeTree(root, map[string]string{"docs/b.txt": "bbb"}), ShouldBeNil)
+			So(os.Remove(filepath.Join(root, "a.txt")), ShouldBeNil)
+			mf, err := simpleMongoFilesInstance([]string{"sync", root})
+			So(err, ShouldBeNil)
+			mf.StorageOptions.Delete = true
+			mf.StorageOptions.NamePrefix = "site/"
+			output, err := mf.Run(false)
+			So(err, ShouldBeNil)
+			So(output, ShouldEqual, "uploaded 1 file (3.0 B)\nskipped 0 unchanged files\ndeleted 1 file\n")
+
+			Convey("and only delete the files under --namePrefix", func() {
+				session, err := mf.SessionProvider.GetSession()
+				So(err, ShouldBeNil)
+				defer session.Close()
+				gfs := session.DB(testDB).GridFS("fs")
+				var file GFSFile
+				So(gfs.Find(bson.M{"filename": "a.txt"}).One(&file), ShouldBeNil)
+				So(gfs.Find(bson.M{"filename": "site/a.txt"}).One(&file), ShouldNotBeNil)
+				So(gfs.Find(bson.M{"filename": "site/docs/b.txt"}).One(&file), ShouldBeNil)
+				So(file.Length, ShouldEqual, 3)
+			})
+		})
+
+		Convey("a download failing its md5 check should keep the local file", func() {
+			session, err := mf.SessionProvider.GetSession()
+			So(err, ShouldBeNil)
+			defer session.Close()
+			gfs := session.DB(testDB).GridFS("fs")
+			var file GFSFile
+			So(gfs.Find(bson.M{"filename": "a.txt"}).One(&file), ShouldBeNil)
+			So(gfs.Chunks.Update(bson.M{"files_id": file.Id}, bson.M{"$set": bson.M{"data": []byte("abc")}}), ShouldBeNil)
+
+			So(writeTree(root, map[string]string{"a.txt": "old"}), ShouldBeNil)
+			mf, err := simpleMongoFilesInstance([]string{"sync", root})
+			So(err, ShouldBeNil)
+			mf.StorageOptions.Direction = SyncDownload
+			_, err = mf.Run(false)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "md5")
+			content, err := ioutil.ReadFile(filepath.Join(root, "a.txt"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "old")
+			entries, err := ioutil.ReadDir(root)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 2)
+		})
+
+		Convey("get --recursive should write the files under the local directory", func() {
+			out, err := ioutil.TempDir("", "mongofiles")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(out)
+			mf, err := simpleMongoFilesInstance([]string{"get", "docs/"})
+			So(err, ShouldBeNil)
+			mf.StorageOptions.Recursive = true
+			mf.StorageOptions.LocalFileName = out
+			_, err = mf.Run(false)
+			So(err, ShouldBeNil)
+			content, err := ioutil.ReadFile(filepath.Join(out, "docs", "b.txt"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "bb")
+			_, err = os.Stat(filepath.Join(out, "a.txt"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
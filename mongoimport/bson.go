@@ -0,0 +1,318 @@
+package mongoimport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/db"
+	"github.com/mongodb/mongo-tools/common/log"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// BSONInputReader is an implementation of InputReader that reads documents
+// from a stream of BSON documents, such as a .bson file written by mongodump.
+type BSONInputReader struct {
+	// bsonSource is used to read the raw BSON documents from the input source
+	bsonSource *db.BSONSource
+
+	// numProcessed indicates the number of BSON documents processed
+	numProcessed uint64
+
+	// numDecoders is the number of concurrent goroutines to use for decoding
+	numDecoders int
+
+	// objCheck indicates if each document should be validated before it is
+	// imported
+	objCheck bool
+
+	// recordPosition, if set, is called with the input position following
+	// each document read
+	recordPosition func(inputPosition)
+
+	// mapping, if set, is applied to each converted document
+	mapping *fieldMapping
+
+	// offsetBase is the input offset from which bsonSource started reading
+	offsetBase int64
+
+	// embedded sizeTracker exposes the Size() method to check the number of bytes read so far
+	sizeTracker
+}
+
+// BSONConverter implements the Converter interface for BSON input.
+type BSONConverter struct {
+	data     []byte
+	index    uint64
+	objCheck bool
+	mapping  *fieldMapping
+}
+
+// NewBSONInputReader returns a BSONInputReader configured to read documents
+// from the given io.Reader using exactly "numDecoders" goroutines.
+func NewBSONInputReader(in io.Reader, numDecoders int) *BSONInputReader {
+	szCount := newSizeTrackingReader(in)
+	return &BSONInputReader{
+		// each document must have its own buffer since documents are
+		// decoded concurrently
+		bsonSource:  db.NewBufferlessBSONSource(ioutil.NopCloser(szCount)),
+		numDecoders: numDecoders,
+		sizeTracker: szCount,
+	}
+}
+
+// ReadAndValidateHeader is a no-op for BSON imports; always returns nil.
+func (r *BSONInputReader) ReadAndValidateHeader() error {
+	return nil
+}
+
+// StreamDocument takes a boolean indicating if the documents should be streamed
+// in read order and a channel on which to stream the documents processed from
+// the underlying reader. Returns a non-nil error if encountered
+func (r *BSONInputReader) StreamDocument(ordered bool, readChan chan bson.D) (retErr error) {
+	rawChan := make(chan Converter, r.numDecoders)
+	bsonErrChan := make(chan error)
+
+	// begin reading from source
+	go func() {
+		for {
+			rawBytes := r.bsonSource.LoadNext()
+			if rawBytes == nil {
+				close(rawChan)
+				if err := r.bsonSource.Err(); err != nil {
+					r.numProcessed++
+					bsonErrChan <- fmt.Errorf("error reading document #%v: %v", r.numProcessed, err)
+				} else {
+					bsonErrChan <- nil
+				}
+				return
+			}
+			if r.recordPosition != nil {
+				// BSONSource reads exactly one document at a time
+				r.recordPosition(inputPosition{
+					Offset:    r.offsetBase + r.Size(),
+					Documents: r.numProcessed + 1,
+				})
+			}
+			rawChan <- BSONConverter{
+				data:     rawBytes,
+				index:    r.numProcessed,
+				objCheck: r.objCheck,
+				mapping:  r.mapping,
+			}
+			r.numProcessed++
+		}
+	}()
+
+	// begin processing read bytes
+	go func() {
+		bsonErrChan <- streamDocuments(ordered, r.numDecoders, rawChan, readChan)
+	}()
+
+	return channelQuorumError(bsonErrChan, 2)
+}
+
+// setPositionRecorder is part of the resumableInputReader interface.
+func (r *BSONInputReader) setPositionRecorder(record func(inputPosition)) {
+	r.recordPosition = record
+}
+
+// resume is part of the resumableInputReader interface.
+func (r *BSONInputReader) resume(in io.Reader, pos inputPosition) {
+	szCount := newSizeTrackingReader(in)
+	r.bsonSource = db.NewBufferlessBSONSource(ioutil.NopCloser(szCount))
+	r.sizeTracker = szCount
+	r.offsetBase = pos.Offset
+	r.numProcessed = pos.Documents
+}
+
+// Convert implements the Converter interface for BSON input. It decodes a
+// BSONConverter struct to a BSON document, validating it first if objCheck
+// is set.
+func (c BSONConverter) Convert() (bson.D, error) {
+	if c.objCheck {
+		if err := validateBSON(c.data); err != nil {
+			return nil, fmt.Errorf("failed to validate bson during objcheck on document #%v: %v", c.index, err)
+		}
+	}
+	document := bson.D{}
+	if err := bson.Unmarshal(c.data, &document); err != nil {
+		return nil, fmt.Errorf("error unmarshaling bytes on document #%v: %v", c.index, err)
+	}
+	log.Logf(log.DebugHigh, "got document: %v", document)
+	if c.mapping == nil {
+		return document, nil
+	}
+	// BSON documents are identified by their position in the input source
+	return c.mapping.apply(document, c.index+1)
+}
+
+// validateBSON checks that data is a valid BSON document, walking its
+// structure rather than comparing it with a re-encoding, which needn't be
+// identical: lengths must match their contents, element types must be
+// known, and every field name and string must be valid UTF-8.
+func validateBSON(data []byte) error {
+	n, err := validateDocument(data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("document contains %v bytes that are not part of any field", len(data)-n)
+	}
+	return nil
+}
+
+// bsonInt32 reads the little-endian int32 at the start of data, which must
+// be at least 4 bytes long.
+func bsonInt32(data []byte) int {
+	return int(int32(binary.LittleEndian.Uint32(data)))
+}
+
+// validateDocument checks the document, or array, at the start of data,
+// returning its length.
+func validateDocument(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, fmt.Errorf("document is truncated")
+	}
+	length := bsonInt32(data)
+	if length < 5 || length > len(data) {
+		return 0, fmt.Errorf("invalid document length %v", length)
+	}
+	if data[length-1] != 0 {
+		return 0, fmt.Errorf("document is not terminated by a null byte")
+	}
+	body := data[4 : length-1]
+	for len(body) > 0 {
+		kind := body[0]
+		name, err := validateCString(body[1:])
+		if err != nil {
+			return 0, fmt.Errorf("invalid field name: %v", err)
+		}
+		value := body[1+len(name)+1:]
+		n, err := validateElement(kind, value)
+		if err != nil {
+			return 0, fmt.Errorf("in field '%v': %v", name, err)
+		}
+		body = value[n:]
+	}
+	return length, nil
+}
+
+// validateCString checks the null-terminated UTF-8 string at the start of
+// data, returning it without its terminator.
+func validateCString(data []byte) (string, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", fmt.Errorf("string is not terminated by a null byte")
+	}
+	if !utf8.Valid(data[:end]) {
+		return "", fmt.Errorf("string %q is not valid UTF-8", data[:end])
+	}
+	return string(data[:end]), nil
+}
+
+// validateString checks the length-prefixed UTF-8 string at the start of
+// data, returning its length.
+func validateString(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, fmt.Errorf("string is truncated")
+	}
+	length := bsonInt32(data)
+	if length < 1 || length > len(data)-4 {
+		return 0, fmt.Errorf("invalid string length %v", length)
+	}
+	if data[4+length-1] != 0 {
+		return 0, fmt.Errorf("string is not terminated by a null byte")
+	}
+	if value := data[4 : 4+length-1]; !utf8.Valid(value) {
+		return 0, fmt.Errorf("string %q is not valid UTF-8", value)
+	}
+	return 4 + length, nil
+}
+
+// validateElement checks the value of the given BSON type at the start of
+// data, returning its length.
+func validateElement(kind byte, data []byte) (int, error) {
+	fixed := -1
+	switch kind {
+	case 0x06, 0x0A, 0x7F, 0xFF: // undefined, null, max and min keys
+		fixed = 0
+	case 0x08: // boolean
+		if len(data) > 0 && data[0] > 1 {
+			return 0, fmt.Errorf("invalid boolean value %v", data[0])
+		}
+		fixed = 1
+	case 0x10: // int32
+		fixed = 4
+	case 0x01, 0x09, 0x11, 0x12: // double, datetime, timestamp and int64
+		fixed = 8
+	case 0x07: // ObjectId
+		fixed = 12
+	case 0x13: // decimal128
+		fixed = 16
+	case 0x02, 0x0D, 0x0E: // string, JavaScript and symbol
+		return validateString(data)
+	case 0x03, 0x04: // document and array
+		return validateDocument(data)
+	case 0x05: // binary
+		if len(data) < 5 {
+			return 0, fmt.Errorf("binary is truncated")
+		}
+		length := bsonInt32(data)
+		if length < 0 || length > len(data)-5 {
+			return 0, fmt.Errorf("invalid binary length %v", length)
+		}
+		// the old binary subtype holds the length again
+		if data[4] == 0x02 && (length < 4 || bsonInt32(data[5:]) != length-4) {
+			return 0, fmt.Errorf("invalid length in binary of subtype 0x02")
+		}
+		return 5 + length, nil
+	case 0x0B: // regular expression
+		pattern, err := validateCString(data)
+		if err != nil {
+			return 0, err
+		}
+		options, err := validateCString(data[len(pattern)+1:])
+		if err != nil {
+			return 0, err
+		}
+		return len(pattern) + len(options) + 2, nil
+	case 0x0C: // DBPointer
+		n, err := validateString(data)
+		if err != nil {
+			return 0, err
+		}
+		if len(data) < n+12 {
+			return 0, fmt.Errorf("DBPointer is truncated")
+		}
+		return n + 12, nil
+	case 0x0F: // JavaScript with scope
+		if len(data) < 4 {
+			return 0, fmt.Errorf("JavaScript with scope is truncated")
+		}
+		length := bsonInt32(data)
+		if length < 4 || length > len(data) {
+			return 0, fmt.Errorf("invalid JavaScript with scope length %v", length)
+		}
+		code, err := validateString(data[4:length])
+		if err != nil {
+			return 0, err
+		}
+		scope, err := validateDocument(data[4+code : length])
+		if err != nil {
+			return 0, err
+		}
+		if 4+code+scope != length {
+			return 0, fmt.Errorf("invalid JavaScript with scope length %v", length)
+		}
+		return length, nil
+	default:
+		return 0, fmt.Errorf("unknown BSON type 0x%02X", kind)
+	}
+	if len(data) < fixed {
+		return 0, fmt.Errorf("value of BSON type 0x%02X is truncated", kind)
+	}
+	return fixed, nil
+}
@@ -0,0 +1,118 @@
+package mongoimport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+// bsonStream returns the concatenated BSON encoding of documents.
+func bsonStream(documents ...interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for _, document := range documents {
+		data, err := bson.Marshal(document)
+		if err != nil {
+			panic(err)
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func TestBSONStreamDocument(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+	Convey("With a BSON input reader", t, func() {
+		Convey("all documents in the stream should be read in order", func() {
+			contents := bsonStream(
+				bson.D{{"a", 1}, {"b", bson.D{{"c", "x"}}}},
+				bson.D{{"a", 2}, {"d", []interface{}{"y", 3}}},
+			)
+			r := NewBSONInputReader(bytes.NewReader(contents), 1)
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, bson.D{{"a", 1}, {"b", bson.D{{"c", "x"}}}})
+			So(<-docChan, ShouldResemble, bson.D{{"a", 2}, {"d", []interface{}{"y", 3}}})
+		})
+
+		Convey("a truncated document should result in an error", func() {
+			contents := bsonStream(bson.D{{"a", 1}}, bson.D{{"a", 2}})
+			r := NewBSONInputReader(bytes.NewReader(contents[:len(contents)-3]), 1)
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldNotBeNil)
+		})
+
+		Convey("the position after each document should be recorded", func() {
+			first := bsonStream(bson.D{{"a", 1}})
+			contents := bsonStream(bson.D{{"a", 1}}, bson.D{{"a", 2}})
+			r := NewBSONInputReader(bytes.NewReader(contents), 1)
+			positions, _, err := readPositions(r)
+			So(err, ShouldBeNil)
+			So(positions, ShouldResemble, []inputPosition{
+				{Offset: int64(len(first)), Documents: 1},
+				{Offset: int64(len(contents)), Documents: 2},
+			})
+		})
+	})
+}
+
+func TestBSONObjCheck(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+	Convey("With --objcheck", t, func() {
+		Convey("valid documents should be accepted", func() {
+			data := bsonStream(bson.D{{"a", "é"}, {"b", bson.D{{"c", []interface{}{1, "x"}}}}})
+			_, err := BSONConverter{data: data, objCheck: true}.Convert()
+			So(err, ShouldBeNil)
+		})
+
+		Convey("strings that are not valid UTF-8 should be rejected", func() {
+			data := bsonStream(bson.D{{"a", "\xff"}})
+			_, err := BSONConverter{data: data}.Convert()
+			So(err, ShouldBeNil)
+			_, err = BSONConverter{data: data, objCheck: true}.Convert()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("bytes that are not part of any field should be rejected", func() {
+			data := bsonStream(bson.D{{"a", 1}})
+			// grow the document by one byte before its terminating null
+			padded := append(append([]byte{}, data[:len(data)-1]...), 0, 0)
+			padded[0]++
+			_, err := BSONConverter{data: padded, objCheck: true}.Convert()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("binaries of the old subtype 0x02 should be accepted", func() {
+			data := []byte{
+				20, 0, 0, 0, // document length
+				0x05, 'b', 0, // binary field 'b'
+				7, 0, 0, 0, 0x02, // length and subtype
+				3, 0, 0, 0, 'a', 'b', 'c', // length of the data, and the data
+				0,
+			}
+			document, err := BSONConverter{data: data, objCheck: true}.Convert()
+			So(err, ShouldBeNil)
+			So(document[0].Value, ShouldResemble, []byte("abc"))
+
+			// whose inner length must match
+			data[12] = 4
+			_, err = BSONConverter{data: data, objCheck: true}.Convert()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("fields of unknown types or wrong lengths should be rejected", func() {
+			data := bsonStream(bson.D{{"a", bson.D{{"b", "xyz"}}}})
+			unknown := append([]byte{}, data...)
+			unknown[11] = 0x42
+			_, err := BSONConverter{data: unknown, objCheck: true}.Convert()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unknown BSON type")
+
+			long := append([]byte{}, data...)
+			long[14]++
+			_, err = BSONConverter{data: long, objCheck: true}.Convert()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,236 @@
+package mongoimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointInterval is the minimum amount of time between two writes of
+// the checkpoint file while an import is in progress.
+const checkpointInterval = time.Second
+
+// inputPosition identifies the point in an input source immediately
+// following a document.
+type inputPosition struct {
+	// Offset is the number of bytes of the input source consumed
+	Offset int64 `json:"offset"`
+
+	// Line is the number of lines of the input source consumed; it is
+	// only tracked for CSV and TSV input
+	Line uint64 `json:"line,omitempty"`
+
+	// Documents is the number of documents read from the input source
+	Documents uint64 `json:"documents"`
+}
+
+// checkpoint is the content of a checkpoint file. It records the position in
+// an input file up to which all documents have been acknowledged by the
+// server.
+type checkpoint struct {
+	File string `json:"file"`
+	Type string `json:"type"`
+	inputPosition
+}
+
+// resumableInputReader is implemented by InputReaders that can report the
+// position of each document they read, and restart reading from such a
+// position.
+type resumableInputReader interface {
+	InputReader
+
+	// setPositionRecorder registers a function that is called, in read
+	// order, with the position following each document read.
+	setPositionRecorder(record func(inputPosition))
+
+	// resume makes the reader continue reading from in, which has already
+	// been positioned at pos.
+	resume(in io.Reader, pos inputPosition)
+}
+
+// checkpointer keeps track of the positions of documents that have been read
+// from the input source but not yet acknowledged by the server, and records
+// the position of the last acknowledged document in the checkpoint file.
+//
+// It relies on documents being inserted in the order in which they are read,
+// which is why checkpointing implies --maintainInsertionOrder.
+type checkpointer struct {
+	path string
+
+	// mutex guards the fields below, which are updated by both the
+	// reading and the inserting goroutines
+	mutex sync.Mutex
+
+	// pending holds the positions of documents that have been read but not
+	// acknowledged, in read order
+	pending []inputPosition
+
+	// numAcknowledged is the number of documents acknowledged in this run
+	numAcknowledged uint64
+
+	// state is the checkpoint as of the last acknowledged document
+	state    checkpoint
+	dirty    bool
+	lastSave time.Time
+}
+
+// newCheckpointer returns a checkpointer that writes to path, starting from
+// the given checkpoint.
+func newCheckpointer(path string, start checkpoint) *checkpointer {
+	return &checkpointer{
+		path:     path,
+		state:    start,
+		lastSave: time.Now(),
+	}
+}
+
+// read records the position following a document read from the input source.
+func (c *checkpointer) read(pos inputPosition) {
+	c.mutex.Lock()
+	c.pending = append(c.pending, pos)
+	c.mutex.Unlock()
+}
+
+// acknowledge marks the first numAcknowledged documents of this run as
+// acknowledged by the server, and writes the checkpoint file if it hasn't
+// been written in the last checkpointInterval.
+func (c *checkpointer) acknowledge(numAcknowledged uint64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if numAcknowledged <= c.numAcknowledged {
+		return nil
+	}
+	n := numAcknowledged - c.numAcknowledged
+	if n > uint64(len(c.pending)) {
+		return fmt.Errorf("checkpoint error: %v documents acknowledged but only %v read",
+			numAcknowledged, c.numAcknowledged+uint64(len(c.pending)))
+	}
+	c.state.inputPosition = c.pending[n-1]
+	c.pending = c.pending[n:]
+	c.numAcknowledged = numAcknowledged
+	c.dirty = true
+	if time.Since(c.lastSave) < checkpointInterval {
+		return nil
+	}
+	return c.save()
+}
+
+// flush writes the checkpoint file if it has changed since it was last
+// written.
+func (c *checkpointer) flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.save()
+}
+
+// save writes the checkpoint file; it must be called with the mutex held.
+// The checkpoint is written to a temporary file first, so that the previous
+// checkpoint survives a crash in the middle of the write.
+func (c *checkpointer) save() error {
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+	tmpPath := c.path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing checkpoint file: %v", err)
+	}
+	if err = os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("error writing checkpoint file: %v", err)
+	}
+	log.Logf(log.DebugLow, "checkpoint: %v documents imported, up to byte %v of %v",
+		c.state.Documents, c.state.Offset, c.state.File)
+	c.dirty = false
+	c.lastSave = time.Now()
+	return nil
+}
+
+// readCheckpointFile reads the checkpoint stored at path. It returns nil if
+// the file does not exist.
+func readCheckpointFile(path string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(util.ToUniversalPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading checkpoint file: %v", err)
+	}
+	cp := &checkpoint{}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint file '%v': %v", path, err)
+	}
+	return cp, nil
+}
+
+// setUpCheckpoint prepares inputReader for a checkpointed import. If --resume
+// is set, it positions source and inputReader to continue from the position
+// stored in the checkpoint file. It returns the number of bytes already
+// imported from the input source.
+func (imp *MongoImport) setUpCheckpoint(source io.Reader, inputReader InputReader) (int64, error) {
+	resumable, ok := inputReader.(resumableInputReader)
+	if !ok {
+		return 0, fmt.Errorf("--checkpointFile is not supported for input type %v", imp.InputOptions.Type)
+	}
+	absPath, err := filepath.Abs(imp.InputOptions.File)
+	if err != nil {
+		return 0, err
+	}
+	start := checkpoint{
+		File: absPath,
+		Type: imp.InputOptions.Type,
+	}
+
+	if imp.IngestOptions.Resume {
+		cp, err := readCheckpointFile(imp.IngestOptions.CheckpointFile)
+		if err != nil {
+			return 0, err
+		}
+		if cp == nil {
+			log.Logf(log.Always, "no checkpoint found in '%v', starting from the beginning of the input",
+				imp.IngestOptions.CheckpointFile)
+		} else {
+			if cp.File != start.File || cp.Type != start.Type {
+				return 0, fmt.Errorf("checkpoint file '%v' was written for %v input '%v'",
+					imp.IngestOptions.CheckpointFile, cp.Type, cp.File)
+			}
+			seeker, ok := source.(io.Seeker)
+			if !ok {
+				return 0, fmt.Errorf("can not resume: input source is not seekable")
+			}
+			if _, err = seeker.Seek(cp.Offset, os.SEEK_SET); err != nil {
+				return 0, fmt.Errorf("error seeking to checkpoint: %v", err)
+			}
+			resumable.resume(source, cp.inputPosition)
+			start = *cp
+			log.Logf(log.Always, "resuming import after document #%v (byte %v)", cp.Documents, cp.Offset)
+		}
+	}
+
+	imp.checkpoint = newCheckpointer(imp.IngestOptions.CheckpointFile, start)
+	resumable.setPositionRecorder(imp.checkpoint.read)
+	return start.Offset, nil
+}
+
+// finishCheckpoint records the outcome of a checkpointed import. The
+// checkpoint file is removed once the whole input source has been imported,
+// so that it can't be used to resume a completed import.
+func (imp *MongoImport) finishCheckpoint(importErr error) error {
+	if importErr != nil {
+		return imp.checkpoint.flush()
+	}
+	err := os.Remove(imp.IngestOptions.CheckpointFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing checkpoint file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package mongoimport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readPositions streams all documents from r and returns the input position
+// recorded for each of them.
+func readPositions(r resumableInputReader) ([]inputPosition, []bson.D, error) {
+	positions := []inputPosition{}
+	r.setPositionRecorder(func(pos inputPosition) {
+		positions = append(positions, pos)
+	})
+	docChan := make(chan bson.D, 100)
+	if err := r.StreamDocument(true, docChan); err != nil {
+		return nil, nil, err
+	}
+	docs := []bson.D{}
+	for doc := range docChan {
+		docs = append(docs, doc)
+	}
+	return positions, docs, nil
+}
+
+func TestInputPositions(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a CSV input reader", t, func() {
+		contents := "a,b\r\n1,\"x\ny\"\n2,z\n"
+		r := NewCSVInputReader(nil, bytes.NewReader([]byte(contents)), 1)
+		So(r.ReadAndValidateHeader(), ShouldBeNil)
+
+		Convey("the position after each record should be recorded", func() {
+			positions, _, err := readPositions(r)
+			So(err, ShouldBeNil)
+			So(positions, ShouldResemble, []inputPosition{
+				{Offset: 13, Line: 3, Documents: 1},
+				{Offset: 17, Line: 4, Documents: 2},
+			})
+		})
+
+		Convey("reading should resume from a recorded position", func() {
+			r.resume(bytes.NewReader([]byte(contents[13:])), inputPosition{Offset: 13, Line: 3, Documents: 1})
+			positions, docs, err := readPositions(r)
+			So(err, ShouldBeNil)
+			So(docs, ShouldResemble, []bson.D{{{"a", 2}, {"b", "z"}}})
+			So(positions, ShouldResemble, []inputPosition{{Offset: 17, Line: 4, Documents: 2}})
+		})
+	})
+
+	Convey("With a TSV input reader", t, func() {
+		contents := "a\tb\n1\tx\n2\ty\n"
+		r := NewTSVInputReader(nil, bytes.NewReader([]byte(contents)), 1)
+		So(r.ReadAndValidateHeader(), ShouldBeNil)
+
+		Convey("the position after each record should be recorded", func() {
+			positions, _, err := readPositions(r)
+			So(err, ShouldBeNil)
+			So(positions, ShouldResemble, []inputPosition{
+				{Offset: 8, Line: 2, Documents: 1},
+				{Offset: 12, Line: 3, Documents: 2},
+			})
+		})
+	})
+
+	Convey("With a JSON array input reader", t, func() {
+		contents := `[{"a":"x"}, {"a":"y"},{"a":"z"}]`
+		r := NewJSONInputReader(true, bytes.NewReader([]byte(contents)), 1)
+
+		Convey("the position after each document should be recorded", func() {
+			positions, _, err := readPositions(r)
+			So(err, ShouldBeNil)
+			So(positions, ShouldResemble, []inputPosition{
+				{Offset: 10, Documents: 1},
+				{Offset: 21, Documents: 2},
+				{Offset: 31, Documents: 3},
+			})
+		})
+
+		Convey("reading should resume in the middle of the array", func() {
+			r.resume(bytes.NewReader([]byte(contents[10:])), inputPosition{Offset: 10, Documents: 1})
+			positions, docs, err := readPositions(r)
+			So(err, ShouldBeNil)
+			So(docs, ShouldResemble, []bson.D{{{"a", "y"}}, {{"a", "z"}}})
+			So(positions[1], ShouldResemble, inputPosition{Offset: 31, Documents: 3})
+		})
+	})
+}
+
+func TestCheckpointer(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a checkpointer", t, func() {
+		dir, err := ioutil.TempDir("", "mongoimport_checkpoint")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "checkpoint")
+		c := newCheckpointer(path, checkpoint{File: "/data/in.csv", Type: CSV})
+		for i := 1; i <= 3; i++ {
+			c.read(inputPosition{Offset: int64(10 * i), Line: uint64(i), Documents: uint64(i)})
+		}
+
+		Convey("the last acknowledged position should be written on flush", func() {
+			So(c.acknowledge(2), ShouldBeNil)
+			So(c.flush(), ShouldBeNil)
+			cp, err := readCheckpointFile(path)
+			So(err, ShouldBeNil)
+			So(*cp, ShouldResemble, checkpoint{
+				File:          "/data/in.csv",
+				Type:          CSV,
+				inputPosition: inputPosition{Offset: 20, Line: 2, Documents: 2},
+			})
+		})
+
+		Convey("acknowledging more documents than were read should fail", func() {
+			So(c.acknowledge(4), ShouldNotBeNil)
+		})
+
+		Convey("a missing checkpoint file should not be an error", func() {
+			cp, err := readCheckpointFile(path)
+			So(err, ShouldBeNil)
+			So(cp, ShouldBeNil)
+		})
+	})
+}
@@ -213,6 +213,9 @@ func removeBlankFields(document bson.D) (newDocument bson.D) {
 	for _, keyVal := range document {
 		if val, ok := keyVal.Value.(*bson.D); ok {
 			keyVal.Value = removeBlankFields(*val)
+		} else if val, ok := keyVal.Value.(bson.D); ok && val != nil {
+			// subdocuments read from BSON input are not pointers
+			keyVal.Value = removeBlankFields(val)
 		}
 		if val, ok := keyVal.Value.(string); ok && val == "" {
 			continue
@@ -301,14 +304,36 @@ func streamDocuments(ordered bool, numDecoders int, readDocs chan Converter, out
 	return
 }
 
+// trimTokens returns a copy of tokens with leading and trailing white space
+// removed from each token.
+func trimTokens(tokens []string) []string {
+	trimmed := make([]string, len(tokens))
+	for i, token := range tokens {
+		trimmed[i] = strings.TrimSpace(token)
+	}
+	return trimmed
+}
+
 // tokensToBSON reads in slice of records - along with ordered fields names -
 // and returns a BSON document for the record.
 func tokensToBSON(fields, tokens []string, numProcessed uint64) (bson.D, error) {
+	return typedTokensToBSON(fields, nil, tokens, numProcessed)
+}
+
+// typedTokensToBSON is like tokensToBSON, parsing the values of the fields
+// with the given types, if any, instead of guessing their types.
+func typedTokensToBSON(fields []string, types []fieldType, tokens []string, numProcessed uint64) (bson.D, error) {
 	log.Logf(log.DebugHigh, "got line: %v", tokens)
-	var parsedValue interface{}
 	document := bson.D{}
 	for index, token := range tokens {
-		parsedValue = getParsedValue(token)
+		var typ *fieldType
+		if index < len(types) {
+			typ = &types[index]
+		}
+		parsedValue, err := typedValue(token, typ)
+		if err != nil {
+			return nil, fmt.Errorf("error in field '%v' of document #%v: %v", fields[index], numProcessed, err)
+		}
 		if index < len(fields) {
 			if strings.Index(fields[index], ".") != -1 {
 				setNestedValue(fields[index], parsedValue, &document)
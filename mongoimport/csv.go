@@ -2,6 +2,7 @@ package mongoimport
 
 import (
 	"fmt"
+	"github.com/mongodb/mongo-tools/common/util"
 	"github.com/mongodb/mongo-tools/mongoimport/csv"
 	"gopkg.in/mgo.v2/bson"
 	"io"
@@ -13,6 +14,11 @@ type CSVInputReader struct {
 	// fields is a list of field names in the BSON documents to be imported
 	fields []string
 
+	// types, if set, are the types of the values of the fields, and
+	// columnsHaveTypes indicates that the header gives them
+	types            []fieldType
+	columnsHaveTypes bool
+
 	// csvReader is the underlying reader used to read data in from the CSV or CSV file
 	csvReader *csv.Reader
 
@@ -25,6 +31,25 @@ type CSVInputReader struct {
 	// numDecoders is the number of concurrent goroutines to use for decoding
 	numDecoders int
 
+	// recordPosition, if set, is called with the input position following
+	// each record read
+	recordPosition func(inputPosition)
+
+	// mapping, if set, is applied to each converted document
+	mapping *fieldMapping
+
+	// dialect describes the characters that structure the input
+	dialect csvDialect
+
+	// trimSpace indicates if white space surrounding each value, quoted or
+	// not, should be removed
+	trimSpace bool
+
+	// offsetBase and lineBase are the input position from which csvReader
+	// started reading
+	offsetBase int64
+	lineBase   uint64
+
 	// embedded sizeTracker exposes the Size() method to check the number of bytes read so far
 	sizeTracker
 }
@@ -32,7 +57,84 @@ type CSVInputReader struct {
 // CSVConverter implements the Converter interface for CSV input.
 type CSVConverter struct {
 	fields, data []string
+	types        []fieldType
 	index        uint64
+	line         uint64
+	mapping      *fieldMapping
+	trimSpace    bool
+}
+
+// csvDialect describes the characters that structure CSV input. The escape
+// and comment characters are optional and disabled when 0.
+type csvDialect struct {
+	delimiter rune
+	quote     rune
+	escape    rune
+	comment   rune
+
+	// bareCR indicates that lines may be terminated by a lone '\r'
+	bareCR bool
+}
+
+// defaultCSVDialect is the dialect used unless one is configured.
+var defaultCSVDialect = csvDialect{delimiter: ',', quote: '"'}
+
+// parseCSVDialect returns the dialect described by the CSV options in opts.
+func parseCSVDialect(opts *InputOptions) (csvDialect, error) {
+	dialect := defaultCSVDialect
+	chars := []struct {
+		name  string
+		value string
+		char  *rune
+	}{
+		{"delimiter", opts.Delimiter, &dialect.delimiter},
+		{"quote", opts.Quote, &dialect.quote},
+		{"escape", opts.Escape, &dialect.escape},
+		{"comment", opts.Comment, &dialect.comment},
+	}
+	seen := map[rune]string{}
+	for _, c := range chars {
+		if c.value != "" {
+			char, err := util.ParseCharOption(c.name, c.value)
+			if err != nil {
+				return dialect, err
+			}
+			*c.char = char
+		}
+		// an escape character equal to the quote character is the same as
+		// doubling quotes
+		if *c.char == 0 || (c.char == &dialect.escape && dialect.escape == dialect.quote) {
+			continue
+		}
+		if other, ok := seen[*c.char]; ok {
+			return dialect, fmt.Errorf("--%v and --%v can not both be '%c'", other, c.name, *c.char)
+		}
+		seen[*c.char] = c.name
+	}
+	terminator, err := util.ParseLineTerminator(opts.LineTerminator)
+	if err != nil {
+		return dialect, err
+	}
+	dialect.bareCR = terminator == "\r"
+	return dialect, nil
+}
+
+// dialectOption returns the name of the first CSV dialect option set in
+// opts, or an empty string if there is none.
+func (opts *InputOptions) dialectOption() string {
+	switch {
+	case opts.Delimiter != "":
+		return "delimiter"
+	case opts.Quote != "":
+		return "quote"
+	case opts.Escape != "":
+		return "escape"
+	case opts.Comment != "":
+		return "comment"
+	case opts.LineTerminator != "":
+		return "lineTerminator"
+	}
+	return ""
 }
 
 // NewCSVInputReader returns a CSVInputReader configured to read data from the
@@ -40,19 +142,43 @@ type CSVConverter struct {
 // goroutines.
 func NewCSVInputReader(fields []string, in io.Reader, numDecoders int) *CSVInputReader {
 	szCount := newSizeTrackingReader(in)
-	csvReader := csv.NewReader(szCount)
-	// allow variable number of fields in document
-	csvReader.FieldsPerRecord = -1
-	csvReader.TrimLeadingSpace = true
 	return &CSVInputReader{
 		fields:       fields,
-		csvReader:    csvReader,
+		csvReader:    newCSVReader(szCount, defaultCSVDialect),
 		numProcessed: uint64(0),
 		numDecoders:  numDecoders,
+		dialect:      defaultCSVDialect,
 		sizeTracker:  szCount,
 	}
 }
 
+// newCSVReader returns a csv.Reader configured to read mongoimport input
+// in the given dialect from in.
+func newCSVReader(in io.Reader, dialect csvDialect) *csv.Reader {
+	csvReader := csv.NewReader(in)
+	// allow variable number of fields in document
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	dialect.apply(csvReader)
+	return csvReader
+}
+
+// apply configures csvReader to parse input in the dialect.
+func (dialect csvDialect) apply(csvReader *csv.Reader) {
+	csvReader.Comma = dialect.delimiter
+	csvReader.Quote = dialect.quote
+	csvReader.Escape = dialect.escape
+	csvReader.Comment = dialect.comment
+	csvReader.BareCR = dialect.bareCR
+}
+
+// setDialect configures the reader to parse input in the given dialect. It
+// must be called before any input is read.
+func (r *CSVInputReader) setDialect(dialect csvDialect) {
+	r.dialect = dialect
+	dialect.apply(r.csvReader)
+}
+
 // ReadAndValidateHeader reads the header from the underlying reader and validates
 // the header fields. It sets err if the read/validation fails.
 func (r *CSVInputReader) ReadAndValidateHeader() (err error) {
@@ -61,6 +187,11 @@ func (r *CSVInputReader) ReadAndValidateHeader() (err error) {
 		return err
 	}
 	r.fields = fields
+	if r.columnsHaveTypes {
+		if r.fields, r.types, err = parseTypedFields(fields); err != nil {
+			return err
+		}
+	}
 	return validateReaderFields(r.fields)
 }
 
@@ -86,10 +217,21 @@ func (r *CSVInputReader) StreamDocument(ordered bool, readDocs chan bson.D) (ret
 				}
 				return
 			}
+			if r.recordPosition != nil {
+				r.recordPosition(inputPosition{
+					Offset:    r.offsetBase + r.csvReader.Offset(),
+					Line:      r.lineBase + uint64(r.csvReader.Line()),
+					Documents: r.numProcessed + 1,
+				})
+			}
 			csvRecordChan <- CSVConverter{
-				fields: r.fields,
-				data:   r.csvRecord,
-				index:  r.numProcessed,
+				fields:    r.fields,
+				types:     r.types,
+				data:      r.csvRecord,
+				index:     r.numProcessed,
+				line:      r.lineBase + uint64(r.csvReader.Line()),
+				mapping:   r.mapping,
+				trimSpace: r.trimSpace,
 			}
 			r.numProcessed++
 		}
@@ -102,12 +244,36 @@ func (r *CSVInputReader) StreamDocument(ordered bool, readDocs chan bson.D) (ret
 	return channelQuorumError(csvErrChan, 2)
 }
 
+// setPositionRecorder is part of the resumableInputReader interface.
+func (r *CSVInputReader) setPositionRecorder(record func(inputPosition)) {
+	r.recordPosition = record
+}
+
+// resume is part of the resumableInputReader interface.
+func (r *CSVInputReader) resume(in io.Reader, pos inputPosition) {
+	szCount := newSizeTrackingReader(in)
+	r.csvReader = newCSVReader(szCount, r.dialect)
+	r.sizeTracker = szCount
+	r.offsetBase = pos.Offset
+	r.lineBase = pos.Line
+	r.numProcessed = pos.Documents
+}
+
 // Convert implements the Converter interface for CSV input. It converts a
 // CSVConverter struct to a BSON document.
 func (c CSVConverter) Convert() (bson.D, error) {
-	return tokensToBSON(
+	data := c.data
+	if c.trimSpace {
+		data = trimTokens(data)
+	}
+	document, err := typedTokensToBSON(
 		c.fields,
-		c.data,
+		c.types,
+		data,
 		c.index,
 	)
+	if err != nil || c.mapping == nil {
+		return document, err
+	}
+	return c.mapping.apply(document, c.line)
 }
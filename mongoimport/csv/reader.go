@@ -99,15 +99,28 @@ var (
 // non-doubled quote may appear in a quoted field.
 //
 // If TrimLeadingSpace is true, leading white space in a field is ignored.
+//
+// Quote is the character used to quote fields. It defaults to '"'.
+//
+// Escape, if not 0 and different from Quote, is a character that causes the
+// character following it to be read literally. Otherwise a quote within a
+// quoted field is escaped by doubling it.
+//
+// If BareCR is true, a \r that is not followed by \n terminates a line.
 type Reader struct {
 	Comma            rune // field delimiter (set to ',' by NewReader)
+	Quote            rune // quote character (set to '"' by NewReader)
+	Escape           rune // escape character
 	Comment          rune // comment character for start of line
+	BareCR           bool // treat a bare \r as a line terminator
 	FieldsPerRecord  int  // number of expected fields per record
 	LazyQuotes       bool // allow lazy quotes
 	TrailingComma    bool // ignored; here for backwards compatibility
 	TrimLeadingSpace bool // trim leading space
 	line             int
 	column           int
+	offset           int64
+	lastRuneSize     int
 	r                *bufio.Reader
 	field            bytes.Buffer
 }
@@ -116,10 +129,23 @@ type Reader struct {
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
 		Comma: ',',
+		Quote: '"',
 		r:     bufio.NewReader(r),
 	}
 }
 
+// Line returns the number of input lines consumed by the records read so far.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Offset returns the number of bytes of input consumed by the records read
+// so far. Unlike the number of bytes read from the underlying io.Reader, it
+// does not include data that has been buffered but not yet parsed.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
 // error creates a new ParseError based on err.
 func (r *Reader) error(err error) error {
 	return &ParseError{
@@ -175,17 +201,20 @@ func (r *Reader) ReadAll() (records [][]string, err error) {
 // of how far into the line we have read.  r.column will point to the start
 // of this rune, not the end of this rune.
 func (r *Reader) readRune() (rune, error) {
-	r1, _, err := r.r.ReadRune()
+	r1, err := r.readRawRune()
 
 	// Handle \r\n here.  We make the simplifying assumption that
 	// anytime \r is followed by \n that it can be folded to \n.
 	// We will not detect files which contain both \r\n and bare \n.
 	if r1 == '\r' {
-		r1, _, err = r.r.ReadRune()
+		r1, err = r.readRawRune()
 		if err == nil {
 			if r1 != '\n' {
-				r.r.UnreadRune()
+				r.unreadRawRune()
 				r1 = '\r'
+				if r.BareCR {
+					r1 = '\n'
+				}
 			}
 		}
 	}
@@ -193,6 +222,27 @@ func (r *Reader) readRune() (rune, error) {
 	return r1, err
 }
 
+// readRawRune reads one rune from the underlying reader, keeping track of
+// the number of bytes consumed.
+func (r *Reader) readRawRune() (rune, error) {
+	r1, size, err := r.r.ReadRune()
+	r.offset += int64(size)
+	r.lastRuneSize = size
+	return r1, err
+}
+
+// unreadRawRune unreads the last rune read by readRawRune.
+func (r *Reader) unreadRawRune() {
+	if r.r.UnreadRune() == nil {
+		r.offset -= int64(r.lastRuneSize)
+	}
+}
+
+// isEscape reports whether r1 is the escape character.
+func (r *Reader) isEscape(r1 rune) bool {
+	return r.Escape != 0 && r.Escape != r.Quote && r1 == r.Escape
+}
+
 // skip reads runes up to and including the rune delim or until error.
 func (r *Reader) skip(delim rune) error {
 	for {
@@ -218,7 +268,7 @@ func (r *Reader) parseRecord() (fields []string, err error) {
 	// If we are support comments and it is the comment character
 	// then skip to the end of line.
 
-	r1, _, err := r.r.ReadRune()
+	r1, err := r.readRawRune()
 	if err != nil {
 		return nil, err
 	}
@@ -226,7 +276,7 @@ func (r *Reader) parseRecord() (fields []string, err error) {
 	if r.Comment != 0 && r1 == r.Comment {
 		return nil, r.skip('\n')
 	}
-	r.r.UnreadRune()
+	r.unreadRawRune()
 
 	// At this point we have at least one field.
 	for {
@@ -273,11 +323,21 @@ func (r *Reader) parseField() (haveField bool, delim rune, err error) {
 		}
 		return true, r1, nil
 
-	case '"':
+	case r.Quote:
 		// quoted field
 	Quoted:
 		for {
 			r1, err = r.readRune()
+			if err == nil && r.isEscape(r1) {
+				if r1, err = r.readRune(); err == nil {
+					if r1 == '\n' {
+						r.line++
+						r.column = -1
+					}
+					r.field.WriteRune(r1)
+					continue
+				}
+			}
 			if err != nil {
 				if err == io.EOF {
 					if r.LazyQuotes {
@@ -288,7 +348,7 @@ func (r *Reader) parseField() (haveField bool, delim rune, err error) {
 				return false, 0, err
 			}
 			switch r1 {
-			case '"':
+			case r.Quote:
 				r1, err = r.readRune()
 				if err == nil && r.TrimLeadingSpace && r1 != '\n' && unicode.IsSpace(r1) {
 					for err == nil && r.TrimLeadingSpace && r1 != '\n' && unicode.IsSpace(r1) {
@@ -298,7 +358,7 @@ func (r *Reader) parseField() (haveField bool, delim rune, err error) {
 					// which evaluates to 'foo"bar'
 					// so we explicitly test for the case that the trimed whitespace isn't
 					// followed by a '"'
-					if err == nil && r1 == '"' {
+					if err == nil && r1 == r.Quote {
 						r.column--
 						return false, 0, r.error(ErrQuote)
 					}
@@ -309,13 +369,13 @@ func (r *Reader) parseField() (haveField bool, delim rune, err error) {
 				if r1 == '\n' {
 					return true, r1, nil
 				}
-				if r1 != '"' {
+				if r1 != r.Quote {
 					if !r.LazyQuotes {
 						r.column--
 						return false, 0, r.error(ErrQuote)
 					}
 					// accept the bare quote
-					r.field.WriteRune('"')
+					r.field.WriteRune(r.Quote)
 				}
 			case '\n':
 				r.line++
@@ -327,8 +387,20 @@ func (r *Reader) parseField() (haveField bool, delim rune, err error) {
 	default:
 		// unquoted field
 		for {
-			// only write sections of whitespace if it's followed by non-whitespace
-			if unicode.IsSpace(r1) {
+			if r.isEscape(r1) {
+				// the escaped character is kept as is, even if it's whitespace
+				if r1, err = r.readRune(); err != nil {
+					break
+				}
+				if r1 == '\n' {
+					r.line++
+					r.column = -1
+				}
+				r.field.WriteString(ws.String())
+				ws.Reset()
+				r.field.WriteRune(r1)
+			} else if unicode.IsSpace(r1) {
+				// only write sections of whitespace if it's followed by non-whitespace
 				ws.WriteRune(r1)
 			} else {
 				r.field.WriteString(ws.String())
@@ -342,7 +414,7 @@ func (r *Reader) parseField() (haveField bool, delim rune, err error) {
 			if r1 == '\n' {
 				return true, r1, nil
 			}
-			if !r.LazyQuotes && r1 == '"' {
+			if !r.LazyQuotes && r1 == r.Quote {
 				return false, 0, r.error(ErrBareQuote)
 			}
 		}
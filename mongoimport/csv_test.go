@@ -135,6 +135,69 @@ func TestCSVStreamDocument(t *testing.T) {
 	})
 }
 
+func TestCSVDialect(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+	Convey("With a CSV input reader using a custom dialect", t, func() {
+		fields := []string{"a", "b", "c"}
+
+		Convey("the delimiter, quote and comment characters should be honored", func() {
+			contents := "# ignored\n1;'x;y';'it''s'\n"
+			r := NewCSVInputReader(fields, bytes.NewReader([]byte(contents)), 1)
+			r.setDialect(csvDialect{delimiter: ';', quote: '\'', comment: '#'})
+			docChan := make(chan bson.D, 1)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, bson.D{{"a", 1}, {"b", "x;y"}, {"c", "it's"}})
+		})
+
+		Convey("escaped characters should be read literally", func() {
+			contents := `"a\"b",c\,d,"e""f"`
+			r := NewCSVInputReader(fields, bytes.NewReader([]byte(contents)), 1)
+			r.setDialect(csvDialect{delimiter: ',', quote: '"', escape: '\\'})
+			docChan := make(chan bson.D, 1)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, bson.D{{"a", `a"b`}, {"b", "c,d"}, {"c", `e"f`}})
+		})
+
+		Convey("a lone carriage return should end a line if enabled", func() {
+			contents := "1,2\r3,4\r"
+			r := NewCSVInputReader(fields, bytes.NewReader([]byte(contents)), 1)
+			r.setDialect(csvDialect{delimiter: ',', quote: '"', bareCR: true})
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, bson.D{{"a", 1}, {"b", 2}})
+			So(<-docChan, ShouldResemble, bson.D{{"a", 3}, {"b", 4}})
+		})
+
+		Convey("white space inside quotes should be removed with trimSpace", func() {
+			contents := `" 1 ", " x "`
+			r := NewCSVInputReader(fields, bytes.NewReader([]byte(contents)), 1)
+			r.trimSpace = true
+			docChan := make(chan bson.D, 1)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, bson.D{{"a", 1}, {"b", "x"}})
+		})
+	})
+
+	Convey("When parsing CSV dialect options", t, func() {
+		Convey("a tab delimiter should be accepted", func() {
+			dialect, err := parseCSVDialect(&InputOptions{Delimiter: `\t`, LineTerminator: "cr"})
+			So(err, ShouldBeNil)
+			So(dialect.delimiter, ShouldEqual, '\t')
+			So(dialect.bareCR, ShouldBeTrue)
+		})
+		Convey("conflicting characters should be rejected", func() {
+			_, err := parseCSVDialect(&InputOptions{Delimiter: "'", Quote: "'"})
+			So(err, ShouldNotBeNil)
+			_, err = parseCSVDialect(&InputOptions{Comment: ","})
+			So(err, ShouldNotBeNil)
+		})
+		Convey("multi-character values should be rejected", func() {
+			_, err := parseCSVDialect(&InputOptions{Delimiter: "||"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func TestCSVReadAndValidateHeader(t *testing.T) {
 	testutil.VerifyTestType(t, testutil.UnitTestType)
 	var err error
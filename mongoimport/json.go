@@ -47,12 +47,23 @@ type JSONInputReader struct {
 
 	// numDecoders is the number of concurrent goroutines to use for decoding
 	numDecoders int
+
+	// recordPosition, if set, is called with the input position following
+	// each document read
+	recordPosition func(inputPosition)
+
+	// mapping, if set, is applied to each converted document
+	mapping *fieldMapping
+
+	// offsetBase is the input offset from which decoder started reading
+	offsetBase int64
 }
 
 // JSONConverter implements the Converter interface for JSON input.
 type JSONConverter struct {
-	data  []byte
-	index uint64
+	data    []byte
+	index   uint64
+	mapping *fieldMapping
 }
 
 var (
@@ -120,9 +131,17 @@ func (r *JSONInputReader) StreamDocument(ordered bool, readChan chan bson.D) (re
 				}
 				return
 			}
+			if r.recordPosition != nil {
+				// bytes still held in the decoder's buffer have not been consumed
+				r.recordPosition(inputPosition{
+					Offset:    r.offsetBase + r.Size() - int64(len(r.decoder.Buf)),
+					Documents: r.numProcessed + 1,
+				})
+			}
 			rawChan <- JSONConverter{
-				data:  rawBytes,
-				index: r.numProcessed,
+				data:    rawBytes,
+				index:   r.numProcessed,
+				mapping: r.mapping,
 			}
 			r.numProcessed++
 		}
@@ -136,6 +155,22 @@ func (r *JSONInputReader) StreamDocument(ordered bool, readChan chan bson.D) (re
 	return channelQuorumError(jsonErrChan, 2)
 }
 
+// setPositionRecorder is part of the resumableInputReader interface.
+func (r *JSONInputReader) setPositionRecorder(record func(inputPosition)) {
+	r.recordPosition = record
+}
+
+// resume is part of the resumableInputReader interface. When resuming a JSON
+// array import, the opening bracket has already been consumed.
+func (r *JSONInputReader) resume(in io.Reader, pos inputPosition) {
+	szCount := newSizeTrackingReader(in)
+	r.decoder = json.NewDecoder(szCount)
+	r.sizeTracker = szCount
+	r.offsetBase = pos.Offset
+	r.numProcessed = pos.Documents
+	r.readOpeningBracket = pos.Documents > 0
+}
+
 // Convert implements the Converter interface for JSON input. It converts a
 // JSONConverter struct to a BSON document.
 func (c JSONConverter) Convert() (bson.D, error) {
@@ -150,7 +185,11 @@ func (c JSONConverter) Convert() (bson.D, error) {
 		return nil, fmt.Errorf("error getting extended BSON for document #%v: %v", c.index, err)
 	}
 	log.Logf(log.DebugHigh, "got extended line: %#v", bsonD)
-	return bsonD, nil
+	if c.mapping == nil {
+		return bsonD, nil
+	}
+	// JSON documents are identified by their position in the input source
+	return c.mapping.apply(bsonD, c.index+1)
 }
 
 // readJSONArraySeparator is a helper method used to process JSON arrays. It is
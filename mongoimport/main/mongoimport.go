@@ -47,13 +47,16 @@ func main() {
 	opts.Direct = (setName == "")
 	opts.ReplicaSetName = setName
 
-	// create a session provider to connect to the db
-	sessionProvider, err := db.NewSessionProvider(*opts)
-	if err != nil {
-		log.Logf(log.Always, "error connecting to host: %v", err)
-		os.Exit(util.ExitError)
+	// create a session provider to connect to the db, unless this is a dry run
+	var sessionProvider *db.SessionProvider
+	if !ingestOpts.DryRun {
+		sessionProvider, err = db.NewSessionProvider(*opts)
+		if err != nil {
+			log.Logf(log.Always, "error connecting to host: %v", err)
+			os.Exit(util.ExitError)
+		}
+		sessionProvider.SetBypassDocumentValidation(ingestOpts.BypassDocumentValidation)
 	}
-	sessionProvider.SetBypassDocumentValidation(ingestOpts.BypassDocumentValidation)
 
 	m := mongoimport.MongoImport{
 		ToolOptions:     opts,
@@ -77,6 +80,9 @@ func main() {
 		if numDocs != 1 {
 			message = fmt.Sprintf("imported %v documents", numDocs)
 		}
+		if ingestOpts.DryRun {
+			message = fmt.Sprintf("read %v %v", numDocs, util.Pluralize(int(numDocs), "document", "documents"))
+		}
 		log.Logf(log.Always, message)
 	}
 	if err != nil {
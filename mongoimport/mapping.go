@@ -0,0 +1,419 @@
+package mongoimport
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/json"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Sections of a mapping file, each of which defines one kind of
+// transformation. Sections are applied in the order in which they appear in
+// the mapping file.
+const (
+	mappingRename  = "rename"
+	mappingDrop    = "drop"
+	mappingSet     = "set"
+	mappingSplit   = "split"
+	mappingCombine = "combine"
+)
+
+// Derived values that can be assigned to fields in the "set" section of a
+// mapping file.
+const (
+	derivedNow        = "$now"
+	derivedFileName   = "$fileName"
+	derivedLineNumber = "$lineNumber"
+)
+
+// fieldMapping is a list of transformations applied to each document after
+// it is converted from the input source and before it is inserted. It is
+// loaded from a JSON mapping file such as:
+//
+//	{
+//		"split":   {"tags": "|"},
+//		"combine": {"location": ["lat", "lng"]},
+//		"rename":  {"first_name": "name.first"},
+//		"drop":    ["internal_id"],
+//		"set":     {"importedAt": "$now", "source": "$fileName", "tenant": "acme"}
+//	}
+type fieldMapping struct {
+	// steps are applied to each document in order
+	steps []mappingStep
+
+	// fileName is the value of the $fileName derived field
+	fileName string
+}
+
+// mappingStep transforms a document; line is the input line number - or the
+// document number for JSON input - of the document.
+type mappingStep func(document *bson.D, line uint64) error
+
+// newFieldMapping reads the mapping file at path. fileName is the name of the
+// input source, used for the $fileName derived field.
+func newFieldMapping(path, fileName string) (*fieldMapping, error) {
+	data, err := ioutil.ReadFile(util.ToUniversalPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading mapping file: %v", err)
+	}
+	mapping, err := parseFieldMapping(data, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("error in mapping file '%v': %v", path, err)
+	}
+	return mapping, nil
+}
+
+// parseFieldMapping parses the content of a mapping file.
+func parseFieldMapping(data []byte, fileName string) (*fieldMapping, error) {
+	sections, err := json.UnmarshalBsonD(data)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &fieldMapping{fileName: fileName}
+	for _, section := range sections {
+		var steps []mappingStep
+		switch section.Name {
+		case mappingRename:
+			steps, err = parseRenameSection(section.Value)
+		case mappingDrop:
+			steps, err = parseDropSection(section.Value)
+		case mappingSet:
+			steps, err = mapping.parseSetSection(section.Value)
+		case mappingSplit:
+			steps, err = parseSplitSection(section.Value)
+		case mappingCombine:
+			steps, err = parseCombineSection(section.Value)
+		default:
+			err = fmt.Errorf("unknown section '%v'", section.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mapping.steps = append(mapping.steps, steps...)
+	}
+	return mapping, nil
+}
+
+// apply transforms document according to the mapping.
+func (m *fieldMapping) apply(document bson.D, line uint64) (bson.D, error) {
+	for _, step := range m.steps {
+		if err := step(&document, line); err != nil {
+			return nil, err
+		}
+	}
+	return document, nil
+}
+
+// sectionEntries returns the fields of a section that maps names to values.
+func sectionEntries(name string, section interface{}) (bson.D, error) {
+	switch v := section.(type) {
+	case bson.D:
+		return v, nil
+	case map[string]interface{}:
+		entries := bson.D{}
+		for key, value := range v {
+			entries = append(entries, bson.DocElem{key, value})
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("'%v' must be a document", name)
+}
+
+// sectionString returns the value of a section entry that must be a string.
+func sectionString(section, key string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("'%v.%v' must be a string", section, key)
+	}
+	return s, nil
+}
+
+// validateMappingFields returns an error if any of the given field paths is
+// not valid in a mapping file.
+func validateMappingFields(section string, fields ...string) error {
+	for _, field := range fields {
+		if field == "" {
+			return fmt.Errorf("empty field name in '%v'", section)
+		}
+	}
+	if err := validateFields(fields); err != nil {
+		return fmt.Errorf("invalid field in '%v': %v", section, err)
+	}
+	return nil
+}
+
+// parseRenameSection parses {"<source>": "<target>", ...}. Each source field
+// is moved to the target path.
+func parseRenameSection(section interface{}) ([]mappingStep, error) {
+	entries, err := sectionEntries(mappingRename, section)
+	if err != nil {
+		return nil, err
+	}
+	var steps []mappingStep
+	for _, entry := range entries {
+		source := entry.Name
+		target, err := sectionString(mappingRename, source, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		if err = validateMappingFields(mappingRename, source, target); err != nil {
+			return nil, err
+		}
+		steps = append(steps, func(document *bson.D, _ uint64) error {
+			value, ok := removeFieldPath(document, source)
+			if !ok {
+				return nil
+			}
+			return setFieldPath(document, target, value)
+		})
+	}
+	return steps, nil
+}
+
+// parseDropSection parses ["<field>", ...]. Each field is removed.
+func parseDropSection(section interface{}) ([]mappingStep, error) {
+	fields, ok := section.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%v' must be an array", mappingDrop)
+	}
+	var steps []mappingStep
+	for _, f := range fields {
+		field, ok := f.(string)
+		if !ok {
+			return nil, fmt.Errorf("'%v' must only contain strings", mappingDrop)
+		}
+		if err := validateMappingFields(mappingDrop, field); err != nil {
+			return nil, err
+		}
+		steps = append(steps, func(document *bson.D, _ uint64) error {
+			removeFieldPath(document, field)
+			return nil
+		})
+	}
+	return steps, nil
+}
+
+// parseSetSection parses {"<field>": <value>, ...}. Each field is set to a
+// constant - which may use extended JSON - or to one of the derived values
+// $now, $fileName or $lineNumber.
+func (m *fieldMapping) parseSetSection(section interface{}) ([]mappingStep, error) {
+	entries, err := sectionEntries(mappingSet, section)
+	if err != nil {
+		return nil, err
+	}
+	var steps []mappingStep
+	for _, entry := range entries {
+		field := entry.Name
+		if err = validateMappingFields(mappingSet, field); err != nil {
+			return nil, err
+		}
+		var valueFunc func(line uint64) interface{}
+		switch entry.Value {
+		case derivedNow:
+			valueFunc = func(_ uint64) interface{} { return time.Now() }
+		case derivedFileName:
+			valueFunc = func(_ uint64) interface{} { return m.fileName }
+		case derivedLineNumber:
+			valueFunc = func(line uint64) interface{} { return int64(line) }
+		default:
+			if s, ok := entry.Value.(string); ok && strings.HasPrefix(s, "$") {
+				return nil, fmt.Errorf("unknown derived value '%v' for '%v.%v'", s, mappingSet, field)
+			}
+			value, err := bsonutil.ParseJSONValue(entry.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for '%v.%v': %v", mappingSet, field, err)
+			}
+			valueFunc = func(_ uint64) interface{} { return value }
+		}
+		steps = append(steps, func(document *bson.D, line uint64) error {
+			return setFieldPath(document, field, valueFunc(line))
+		})
+	}
+	return steps, nil
+}
+
+// parseSplitSection parses {"<field>": "<separator>", ...}. Each string field
+// is replaced with the array of substrings separated by the separator.
+func parseSplitSection(section interface{}) ([]mappingStep, error) {
+	entries, err := sectionEntries(mappingSplit, section)
+	if err != nil {
+		return nil, err
+	}
+	var steps []mappingStep
+	for _, entry := range entries {
+		field := entry.Name
+		separator, err := sectionString(mappingSplit, field, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		if separator == "" {
+			return nil, fmt.Errorf("'%v.%v' must not be empty", mappingSplit, field)
+		}
+		if err = validateMappingFields(mappingSplit, field); err != nil {
+			return nil, err
+		}
+		steps = append(steps, func(document *bson.D, _ uint64) error {
+			value, ok := lookupFieldPath(*document, field)
+			if !ok {
+				return nil
+			}
+			s, ok := value.(string)
+			if !ok {
+				return nil
+			}
+			elements := []interface{}{}
+			if s != "" {
+				for _, element := range strings.Split(s, separator) {
+					elements = append(elements, element)
+				}
+			}
+			return setFieldPath(document, field, elements)
+		})
+	}
+	return steps, nil
+}
+
+// parseCombineSection parses {"<target>": ["<field>", ...], ...}. The fields
+// are moved into a subdocument at the target path, keyed by the last
+// component of their name.
+func parseCombineSection(section interface{}) ([]mappingStep, error) {
+	entries, err := sectionEntries(mappingCombine, section)
+	if err != nil {
+		return nil, err
+	}
+	var steps []mappingStep
+	for _, entry := range entries {
+		target := entry.Name
+		list, ok := entry.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%v.%v' must be an array", mappingCombine, target)
+		}
+		var sources []string
+		for _, s := range list {
+			source, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("'%v.%v' must only contain strings", mappingCombine, target)
+			}
+			sources = append(sources, source)
+		}
+		if err = validateMappingFields(mappingCombine, append([]string{target}, sources...)...); err != nil {
+			return nil, err
+		}
+		steps = append(steps, func(document *bson.D, _ uint64) error {
+			combined := bson.D{}
+			for _, source := range sources {
+				if value, ok := removeFieldPath(document, source); ok {
+					combined = append(combined, bson.DocElem{source[strings.LastIndex(source, ".")+1:], value})
+				}
+			}
+			if len(combined) == 0 {
+				return nil
+			}
+			return setFieldPath(document, target, combined)
+		})
+	}
+	return steps, nil
+}
+
+// subdocument returns the document held by value, which may be a bson.D or
+// a *bson.D as created by tokensToBSON.
+func subdocument(value interface{}) (*bson.D, bool) {
+	switch v := value.(type) {
+	case bson.D:
+		return &v, true
+	case *bson.D:
+		return v, true
+	}
+	return nil, false
+}
+
+// storeSubdocument writes back a subdocument obtained from the i-th element
+// of document, unless it was modified in place through a *bson.D.
+func storeSubdocument(document *bson.D, i int, subDoc *bson.D) {
+	if _, ok := (*document)[i].Value.(*bson.D); !ok {
+		(*document)[i].Value = *subDoc
+	}
+}
+
+// lookupFieldPath returns the value of the dotted field path in document.
+func lookupFieldPath(document bson.D, path string) (interface{}, bool) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		value, err := bsonutil.FindValueByKey(name, &document)
+		if err != nil {
+			return nil, false
+		}
+		if i == len(names)-1 {
+			return value, true
+		}
+		subDoc, ok := subdocument(value)
+		if !ok {
+			return nil, false
+		}
+		document = *subDoc
+	}
+	return nil, false
+}
+
+// removeFieldPath removes the dotted field path from document and returns its
+// value.
+func removeFieldPath(document *bson.D, path string) (interface{}, bool) {
+	index := strings.Index(path, ".")
+	for i, elem := range *document {
+		if index == -1 && elem.Name == path {
+			*document = append((*document)[:i:i], (*document)[i+1:]...)
+			return elem.Value, true
+		}
+		if index != -1 && elem.Name == path[:index] {
+			subDoc, ok := subdocument(elem.Value)
+			if !ok {
+				return nil, false
+			}
+			value, removed := removeFieldPath(subDoc, path[index+1:])
+			storeSubdocument(document, i, subDoc)
+			return value, removed
+		}
+	}
+	return nil, false
+}
+
+// setFieldPath sets the dotted field path in document to value, creating
+// intermediate subdocuments as needed.
+func setFieldPath(document *bson.D, path string, value interface{}) error {
+	index := strings.Index(path, ".")
+	if index == -1 {
+		for i := range *document {
+			if (*document)[i].Name == path {
+				(*document)[i].Value = value
+				return nil
+			}
+		}
+		*document = append(*document, bson.DocElem{path, value})
+		return nil
+	}
+	name := path[:index]
+	for i, elem := range *document {
+		if elem.Name != name {
+			continue
+		}
+		subDoc, ok := subdocument(elem.Value)
+		if !ok {
+			return fmt.Errorf("can not set field '%v': '%v' is not a document", path, name)
+		}
+		if err := setFieldPath(subDoc, path[index+1:], value); err != nil {
+			return err
+		}
+		storeSubdocument(document, i, subDoc)
+		return nil
+	}
+	subDoc := bson.D{}
+	if err := setFieldPath(&subDoc, path[index+1:], value); err != nil {
+		return err
+	}
+	*document = append(*document, bson.DocElem{name, subDoc})
+	return nil
+}
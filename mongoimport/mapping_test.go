@@ -0,0 +1,109 @@
+package mongoimport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+	"time"
+)
+
+func TestParseFieldMapping(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("When parsing a mapping file", t, func() {
+		Convey("unknown sections should be rejected", func() {
+			_, err := parseFieldMapping([]byte(`{"move": {"a": "b"}}`), "")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("unknown derived values should be rejected", func() {
+			_, err := parseFieldMapping([]byte(`{"set": {"a": "$today"}}`), "")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("invalid target fields should be rejected", func() {
+			_, err := parseFieldMapping([]byte(`{"rename": {"a": "$b"}}`), "")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("empty split separators should be rejected", func() {
+			_, err := parseFieldMapping([]byte(`{"split": {"a": ""}}`), "")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("a drop section that is not an array should be rejected", func() {
+			_, err := parseFieldMapping([]byte(`{"drop": "a"}`), "")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestApplyFieldMapping(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a field mapping", t, func() {
+		mapping, err := parseFieldMapping([]byte(`{
+			"split": {"tags": "|"},
+			"combine": {"location": ["lat", "lng"]},
+			"rename": {"first": "name.first", "last": "name.last"},
+			"drop": ["internal"],
+			"set": {"source": "$fileName", "line": "$lineNumber", "tenant": "acme",
+				"since": {"$date": 0}, "importedAt": "$now"}
+		}`), "people.csv")
+		So(err, ShouldBeNil)
+
+		Convey("each transformation should be applied in order", func() {
+			document := bson.D{
+				{"first", "Ada"},
+				{"last", "Lovelace"},
+				{"internal", 7},
+				{"lat", 1.5},
+				{"lng", 2.5},
+				{"tags", "a|b"},
+			}
+			mapped, err := mapping.apply(document, 12)
+			So(err, ShouldBeNil)
+			So(len(mapped), ShouldEqual, 8)
+			So(mapped[:6], ShouldResemble, bson.D{
+				{"tags", []interface{}{"a", "b"}},
+				{"location", bson.D{{"lat", 1.5}, {"lng", 2.5}}},
+				{"name", bson.D{{"first", "Ada"}, {"last", "Lovelace"}}},
+				{"source", "people.csv"},
+				{"line", int64(12)},
+				{"tenant", "acme"},
+			})
+			So(mapped[6].Name, ShouldEqual, "since")
+			So(mapped[6].Value.(time.Time).Unix(), ShouldEqual, 0)
+			So(mapped[7].Name, ShouldEqual, "importedAt")
+			So(mapped[7].Value, ShouldHaveSameTypeAs, time.Time{})
+		})
+
+		Convey("missing fields should be left alone", func() {
+			mapped, err := mapping.apply(bson.D{{"other", 1}}, 1)
+			So(err, ShouldBeNil)
+			So(mapped[0], ShouldResemble, bson.DocElem{"other", 1})
+			So(mapped[1].Name, ShouldEqual, "source")
+		})
+
+		Convey("setting a field below a non-document value should fail", func() {
+			_, err := mapping.apply(bson.D{{"name", "Ada"}, {"first", "Ada"}}, 1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("With a CSV input reader using a field mapping", t, func() {
+		mapping, err := parseFieldMapping([]byte(`{"rename": {"a": "x.y"}, "set": {"n": "$lineNumber"}}`), "")
+		So(err, ShouldBeNil)
+		r := NewCSVInputReader([]string{"a", "b.c"}, bytes.NewReader([]byte("1,2\n3,4\n")), 1)
+		r.mapping = mapping
+
+		Convey("documents should be transformed with their line number", func() {
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			<-docChan
+			So(<-docChan, ShouldResemble, bson.D{
+				{"b", &bson.D{{"c", 4}}},
+				{"x", bson.D{{"y", 3}}},
+				{"n", int64(2)},
+			})
+		})
+	})
+}
@@ -1,4 +1,4 @@
-// Package mongoimport allows importing content from a JSON, CSV, or TSV into a MongoDB instance.
+// Package mongoimport allows importing content from a JSON, CSV, TSV, BSON or Parquet into a MongoDB instance.
 package mongoimport
 
 import (
@@ -6,6 +6,7 @@ import (
 	"github.com/mongodb/mongo-tools/common/log"
 	"github.com/mongodb/mongo-tools/common/options"
 	"github.com/mongodb/mongo-tools/common/progress"
+	"github.com/mongodb/mongo-tools/common/text"
 	"github.com/mongodb/mongo-tools/common/util"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -22,9 +23,11 @@ import (
 
 // Input format types accepted by mongoimport.
 const (
-	CSV  = "csv"
-	TSV  = "tsv"
-	JSON = "json"
+	CSV     = "csv"
+	TSV     = "tsv"
+	JSON    = "json"
+	BSON    = "bson"
+	Parquet = "parquet"
 )
 
 const (
@@ -60,6 +63,10 @@ type MongoImport struct {
 
 	// type of node the SessionProvider is connected to
 	nodeType db.NodeType
+
+	// checkpoint tracks the input position of acknowledged documents when
+	// --checkpointFile is set
+	checkpoint *checkpointer
 }
 
 type InputReader interface {
@@ -96,7 +103,9 @@ func (imp *MongoImport) ValidateSettings(args []string) error {
 	} else {
 		if !(imp.InputOptions.Type == TSV ||
 			imp.InputOptions.Type == JSON ||
-			imp.InputOptions.Type == CSV) {
+			imp.InputOptions.Type == CSV ||
+			imp.InputOptions.Type == BSON ||
+			imp.InputOptions.Type == Parquet) {
 			return fmt.Errorf("unknown type %v", imp.InputOptions.Type)
 		}
 	}
@@ -126,19 +135,52 @@ func (imp *MongoImport) ValidateSettings(args []string) error {
 			}
 		}
 	} else {
-		// input type is JSON
+		// input type is JSON, BSON or Parquet
+		inputType := strings.ToUpper(imp.InputOptions.Type)
 		if imp.InputOptions.HeaderLine {
-			return fmt.Errorf("can not use --headerline when input type is JSON")
+			return fmt.Errorf("can not use --headerline when input type is %v", inputType)
 		}
 		if imp.InputOptions.Fields != nil {
-			return fmt.Errorf("can not use --fields when input type is JSON")
+			return fmt.Errorf("can not use --fields when input type is %v", inputType)
 		}
 		if imp.InputOptions.FieldFile != nil {
-			return fmt.Errorf("can not use --fieldFile when input type is JSON")
+			return fmt.Errorf("can not use --fieldFile when input type is %v", inputType)
 		}
-		if imp.IngestOptions.IgnoreBlanks {
+		if imp.IngestOptions.IgnoreBlanks && imp.InputOptions.Type == JSON {
 			return fmt.Errorf("can not use --ignoreBlanks when input type is JSON")
 		}
+		if imp.InputOptions.JSONArray && imp.InputOptions.Type != JSON {
+			return fmt.Errorf("can not use --jsonArray when input type is %v", inputType)
+		}
+	}
+
+	if imp.InputOptions.ObjCheck && imp.InputOptions.Type != BSON {
+		return fmt.Errorf("can only use --objcheck when input type is BSON")
+	}
+
+	if imp.InputOptions.Type == CSV {
+		if _, err = parseCSVDialect(imp.InputOptions); err != nil {
+			return err
+		}
+	} else if option := imp.InputOptions.dialectOption(); option != "" {
+		return fmt.Errorf("can only use --%v when input type is CSV", option)
+	}
+	if imp.InputOptions.TrimSpace &&
+		imp.InputOptions.Type != CSV &&
+		imp.InputOptions.Type != TSV {
+		return fmt.Errorf("can only use --trimSpace when input type is CSV or TSV")
+	}
+	if imp.InputOptions.ColumnsHaveTypes &&
+		imp.InputOptions.Type != CSV &&
+		imp.InputOptions.Type != TSV {
+		return fmt.Errorf("can only use --columnsHaveTypes when input type is CSV or TSV")
+	}
+
+	if imp.InputOptions.Encoding, err = text.ParseEncoding(imp.InputOptions.Encoding); err != nil {
+		return err
+	}
+	if imp.InputOptions.Encoding != text.UTF8 && (imp.InputOptions.Type == BSON || imp.InputOptions.Type == Parquet) {
+		return fmt.Errorf("can not use --encoding when input type is %v", strings.ToUpper(imp.InputOptions.Type))
 	}
 
 	if imp.IngestOptions.UpsertFields != "" {
@@ -156,6 +198,37 @@ func (imp *MongoImport) ValidateSettings(args []string) error {
 		log.Logf(log.Info, "using upsert fields: %v", imp.upsertFields)
 	}
 
+	// documents must be inserted in the order in which they are read for the
+	// checkpoint to reflect a contiguous portion of the input
+	if imp.IngestOptions.CheckpointFile != "" {
+		imp.IngestOptions.MaintainInsertionOrder = true
+	} else if imp.IngestOptions.Resume {
+		return fmt.Errorf("must specify --checkpointFile to use --resume")
+	}
+	if imp.IngestOptions.Resume && imp.IngestOptions.Drop {
+		return fmt.Errorf("incompatible options: --resume and --drop")
+	}
+
+	if imp.IngestOptions.DryRun {
+		if imp.IngestOptions.CheckpointFile != "" {
+			return fmt.Errorf("incompatible options: --dryRun and --checkpointFile")
+		}
+		if imp.IngestOptions.SampleSize < 0 {
+			return fmt.Errorf("--sampleSize can not be negative")
+		}
+	} else {
+		if imp.IngestOptions.InferSchema {
+			return fmt.Errorf("must specify --dryRun to use --inferSchema")
+		}
+		if imp.IngestOptions.SampleSize != 0 {
+			return fmt.Errorf("must specify --dryRun to use --sampleSize")
+		}
+	}
+	// checkpoints record offsets into the decoded input
+	if imp.IngestOptions.CheckpointFile != "" && imp.InputOptions.Encoding != text.UTF8 {
+		return fmt.Errorf("can only use --checkpointFile with %v input", text.UTF8)
+	}
+
 	// set the number of decoding workers to use for imports
 	if imp.ToolOptions.NumDecodingWorkers <= 0 {
 		imp.ToolOptions.NumDecodingWorkers = imp.ToolOptions.MaxProcs
@@ -197,6 +270,10 @@ func (imp *MongoImport) ValidateSettings(args []string) error {
 		}
 	}
 
+	if imp.IngestOptions.CheckpointFile != "" && imp.InputOptions.File == "" {
+		return fmt.Errorf("--checkpointFile can only be used when importing from a file")
+	}
+
 	// ensure we have a valid string to use for the collection
 	if imp.ToolOptions.Collection == "" {
 		log.Logf(log.Always, "no collection specified")
@@ -259,10 +336,25 @@ func (imp *MongoImport) ImportDocuments() (uint64, error) {
 	}
 	defer source.Close()
 
-	inputReader, err := imp.getInputReader(source)
+	// the progress bar follows the raw input, since input readers only see
+	// the decoded text
+	in := io.Reader(source)
+	var rawTracker sizeTracker
+	if imp.InputOptions.Encoding != text.UTF8 {
+		szCount := newSizeTrackingReader(source)
+		if in, err = text.NewDecodingReader(szCount, imp.InputOptions.Encoding); err != nil {
+			return 0, err
+		}
+		rawTracker = szCount
+	}
+
+	inputReader, err := imp.getInputReader(in)
 	if err != nil {
 		return 0, err
 	}
+	if rawTracker == nil {
+		rawTracker = inputReader
+	}
 
 	if imp.InputOptions.HeaderLine {
 		if err = inputReader.ReadAndValidateHeader(); err != nil {
@@ -270,16 +362,34 @@ func (imp *MongoImport) ImportDocuments() (uint64, error) {
 		}
 	}
 
+	// when resuming, the header is read before skipping to the checkpoint
+	if imp.IngestOptions.CheckpointFile != "" {
+		skipped, err := imp.setUpCheckpoint(source, inputReader)
+		if err != nil {
+			return 0, err
+		}
+		fileSize -= skipped
+	}
+
 	bar := &progress.Bar{
 		Name:      fmt.Sprintf("%v.%v", imp.ToolOptions.DB, imp.ToolOptions.Collection),
-		Watching:  &fileSizeProgressor{fileSize, inputReader},
+		Watching:  &fileSizeProgressor{fileSize, rawTracker},
 		Writer:    log.Writer(0),
 		BarLength: progressBarLength,
 		IsBytes:   true,
 	}
 	bar.Start()
 	defer bar.Stop()
-	return imp.importDocuments(inputReader)
+	if imp.IngestOptions.DryRun {
+		return imp.dryRun(inputReader, os.Stdout)
+	}
+	numImported, err := imp.importDocuments(inputReader)
+	if imp.checkpoint != nil {
+		if checkpointErr := imp.finishCheckpoint(err); checkpointErr != nil && err == nil {
+			err = checkpointErr
+		}
+	}
+	return numImported, err
 }
 
 // importDocuments is a helper to ImportDocuments and does all the ingestion
@@ -409,6 +519,7 @@ func (imp *MongoImport) configureSession(session *mgo.Session) error {
 type flushInserter interface {
 	Insert(doc interface{}) error
 	Flush() error
+	Pending() int
 }
 
 // runInsertionWorker is a helper to InsertDocuments - it reads document off
@@ -426,6 +537,7 @@ func (imp *MongoImport) runInsertionWorker(readDocs chan bson.D) (err error) {
 	ignoreBlanks := imp.IngestOptions.IgnoreBlanks && imp.InputOptions.Type != JSON
 
 	var inserter flushInserter
+	var numInserted uint64
 	if imp.IngestOptions.Upsert {
 		inserter = imp.newUpserter(collection)
 	} else {
@@ -451,12 +563,24 @@ readLoop:
 				return err
 			}
 			atomic.AddUint64(&imp.insertionCount, 1)
+			numInserted++
+			if imp.checkpoint != nil {
+				// documents still buffered by the inserter are not acknowledged yet
+				err = imp.checkpoint.acknowledge(numInserted - uint64(inserter.Pending()))
+				if err != nil {
+					return err
+				}
+			}
 		case <-imp.Dying():
 			return nil
 		}
 	}
 
-	return filterIngestError(imp.IngestOptions.StopOnError, inserter.Flush())
+	err = filterIngestError(imp.IngestOptions.StopOnError, inserter.Flush())
+	if err != nil || imp.checkpoint == nil {
+		return err
+	}
+	return imp.checkpoint.acknowledge(numInserted)
 }
 
 type upserter struct {
@@ -491,6 +615,12 @@ func (up *upserter) Flush() error {
 	return nil
 }
 
+// Pending is part of the flushInserter interface; upserter writes each
+// document as it is inserted so none is ever pending.
+func (up *upserter) Pending() int {
+	return 0
+}
+
 // getInputReader returns an implementation of InputReader based on the input type
 func (imp *MongoImport) getInputReader(in io.Reader) (InputReader, error) {
 	var fields []string
@@ -505,16 +635,56 @@ func (imp *MongoImport) getInputReader(in io.Reader) (InputReader, error) {
 	}
 
 	// header fields validation can only happen once we have an input reader
+	var types []fieldType
 	if !imp.InputOptions.HeaderLine {
+		if imp.InputOptions.ColumnsHaveTypes {
+			if fields, types, err = parseTypedFields(fields); err != nil {
+				return nil, err
+			}
+		}
 		if err = validateReaderFields(fields); err != nil {
 			return nil, err
 		}
 	}
 
+	var mapping *fieldMapping
+	if imp.InputOptions.MappingFile != "" {
+		mapping, err = newFieldMapping(imp.InputOptions.MappingFile, imp.InputOptions.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if imp.InputOptions.Type == CSV {
-		return NewCSVInputReader(fields, in, imp.ToolOptions.NumDecodingWorkers), nil
+		dialect, err := parseCSVDialect(imp.InputOptions)
+		if err != nil {
+			return nil, err
+		}
+		r := NewCSVInputReader(fields, in, imp.ToolOptions.NumDecodingWorkers)
+		r.setDialect(dialect)
+		r.types = types
+		r.columnsHaveTypes = imp.InputOptions.ColumnsHaveTypes
+		r.mapping = mapping
+		r.trimSpace = imp.InputOptions.TrimSpace
+		return r, nil
 	} else if imp.InputOptions.Type == TSV {
-		return NewTSVInputReader(fields, in, imp.ToolOptions.NumDecodingWorkers), nil
-	}
-	return NewJSONInputReader(imp.InputOptions.JSONArray, in, imp.ToolOptions.NumDecodingWorkers), nil
+		r := NewTSVInputReader(fields, in, imp.ToolOptions.NumDecodingWorkers)
+		r.types = types
+		r.columnsHaveTypes = imp.InputOptions.ColumnsHaveTypes
+		r.mapping = mapping
+		r.trimSpace = imp.InputOptions.TrimSpace
+		return r, nil
+	} else if imp.InputOptions.Type == BSON {
+		r := NewBSONInputReader(in, imp.ToolOptions.NumDecodingWorkers)
+		r.objCheck = imp.InputOptions.ObjCheck
+		r.mapping = mapping
+		return r, nil
+	} else if imp.InputOptions.Type == Parquet {
+		r := NewParquetInputReader(in, imp.ToolOptions.NumDecodingWorkers)
+		r.mapping = mapping
+		return r, nil
+	}
+	r := NewJSONInputReader(imp.InputOptions.JSONArray, in, imp.ToolOptions.NumDecodingWorkers)
+	r.mapping = mapping
+	return r, nil
 }
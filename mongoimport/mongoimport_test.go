@@ -154,6 +154,13 @@ func TestMongoImportValidateSettings(t *testing.T) {
 			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
 		})
 
+		Convey("an error should be thrown if --columnsHaveTypes is used with JSON input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.ColumnsHaveTypes = true
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
 		Convey("an error should be thrown if --fields is used with JSON input", func() {
 			imp, err := NewMongoImport()
 			So(err, ShouldBeNil)
@@ -183,6 +190,65 @@ func TestMongoImportValidateSettings(t *testing.T) {
 			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
 		})
 
+		Convey("no error should be thrown if --ignoreBlanks and --objcheck are used with BSON input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.Type = BSON
+			imp.InputOptions.ObjCheck = true
+			imp.IngestOptions.IgnoreBlanks = true
+			So(imp.ValidateSettings([]string{}), ShouldBeNil)
+		})
+
+		Convey("an error should be thrown if --jsonArray is used with BSON input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.Type = BSON
+			imp.InputOptions.JSONArray = true
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
+		Convey("an error should be thrown if --objcheck is used with JSON input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.ObjCheck = true
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
+		Convey("an error should be thrown if CSV dialect options are used with TSV input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.Type = TSV
+			imp.InputOptions.HeaderLine = true
+			imp.InputOptions.Delimiter = ";"
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
+		Convey("an error should be thrown if --inferSchema is used without --dryRun", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.IngestOptions.InferSchema = true
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+			imp.IngestOptions.DryRun = true
+			So(imp.ValidateSettings([]string{}), ShouldBeNil)
+		})
+
+		Convey("an error should be thrown if --encoding is used with BSON input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.Type = BSON
+			imp.InputOptions.Encoding = "latin1"
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
+		Convey("an error should be thrown if --checkpointFile is used with non UTF-8 input", func() {
+			imp, err := NewMongoImport()
+			So(err, ShouldBeNil)
+			imp.InputOptions.Encoding = "utf-16"
+			imp.InputOptions.File = "test.json"
+			imp.IngestOptions.CheckpointFile = "test.checkpoint"
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
 		Convey("no error should be thrown if --headerline is not supplied "+
 			"but --fieldFile is supplied", func() {
 			imp, err := NewMongoImport()
@@ -2,7 +2,7 @@ package mongoimport
 
 var Usage = `<options> <file>
 
-Import CSV, TSV or JSON data into MongoDB. If no file is provided, mongoimport reads from stdin.
+Import CSV, TSV, JSON, BSON or Parquet data into MongoDB. If no file is provided, mongoimport reads from stdin.
 
 See http://docs.mongodb.org/manual/reference/program/mongoimport/ for more information.`
 
@@ -20,11 +20,41 @@ type InputOptions struct {
 	// Treats the input source's first line as field list (csv and tsv only).
 	HeaderLine bool `long:"headerline" description:"use first line in input source as the field list (CSV and TSV only)"`
 
+	// Indicates that the field names end with the type of their values (csv and tsv only).
+	ColumnsHaveTypes bool `long:"columnsHaveTypes" description:"the fields of --fields, --fieldFile or --headerline are of the form <field>.<type>(), where the type is auto(), string(), int32(), int64(), double(), decimal(), boolean(), date(<Go layout>), e.g. date(2006-01-02), or binary(<encoding>) with base64 or hex encoding; blank values are left as empty strings (CSV and TSV only)"`
+
 	// Indicates that the underlying input source contains a single JSON array with the documents to import.
 	JSONArray bool `long:"jsonArray" description:"treat input source as a JSON array"`
 
-	// Specifies the file type to import. The default format is JSON, but it’s possible to import CSV and TSV files.
-	Type string `long:"type" value-name:"<type>" default:"json" default-mask:"-" description:"input format to import: json, csv, or tsv (defaults to 'json')"`
+	// Specifies a file describing how to rename, drop, add, split and combine fields of the imported documents.
+	MappingFile string `long:"mappingFile" value-name:"<filename>" description:"JSON file describing field transformations (rename, drop, set, split, combine) to apply to each document before it is inserted"`
+
+	// Specifies the file type to import. The default format is JSON, but it’s possible to import CSV, TSV and BSON files.
+	Type string `long:"type" value-name:"<type>" default:"json" default-mask:"-" description:"input format to import: json, csv, tsv, bson or parquet (defaults to 'json')"`
+
+	// Validates each document of a BSON input source before it is imported.
+	ObjCheck bool `long:"objcheck" description:"validate BSON documents before importing them (BSON only)"`
+
+	// Specifies the character separating the fields of a CSV input source.
+	Delimiter string `long:"delimiter" value-name:"<char>" description:"character separating fields, use '\\t' for a tab (CSV only; defaults to ',')"`
+
+	// Specifies the character used to quote the fields of a CSV input source.
+	Quote string `long:"quote" value-name:"<char>" description:"character used to quote fields (CSV only; defaults to '\"')"`
+
+	// Specifies a character that causes the character following it to be read literally.
+	Escape string `long:"escape" value-name:"<char>" description:"character that escapes the character following it, instead of doubling quotes (CSV only)"`
+
+	// Specifies a character that marks lines to be ignored when it starts them.
+	Comment string `long:"comment" value-name:"<char>" description:"ignore lines starting with this character (CSV only)"`
+
+	// Specifies how lines of a CSV input source are terminated.
+	LineTerminator string `long:"lineTerminator" value-name:"<terminator>" description:"line terminator of the input: lf, crlf or cr (CSV only; lf and crlf are always accepted)"`
+
+	// Removes white space surrounding each value, even if it is quoted.
+	TrimSpace bool `long:"trimSpace" description:"remove leading and trailing white space from each value, including quoted values (CSV and TSV only)"`
+
+	// Specifies the character encoding of the input source.
+	Encoding string `long:"encoding" value-name:"<encoding>" description:"character encoding of the input: utf-8, latin1, windows-1252, utf-16, utf-16le or utf-16be; utf-16 detects the byte order from a byte order mark (CSV, TSV and JSON only; defaults to utf-8)"`
 }
 
 // Name returns a description of the InputOptions struct.
@@ -37,8 +67,8 @@ type IngestOptions struct {
 	// Drops target collection before importing.
 	Drop bool `long:"drop" description:"drop collection before inserting documents"`
 
-	// Ignores fields with empty values in CSV and TSV imports.
-	IgnoreBlanks bool `long:"ignoreBlanks" description:"ignore fields with empty values in CSV and TSV"`
+	// Ignores fields with empty values in CSV, TSV and BSON imports.
+	IgnoreBlanks bool `long:"ignoreBlanks" description:"ignore fields with empty values in CSV, TSV and BSON"`
 
 	// Indicates that documents will be inserted in the order of their appearance in the input source.
 	MaintainInsertionOrder bool `long:"maintainInsertionOrder" description:"insert documents in the order of their appearance in the input source"`
@@ -58,6 +88,21 @@ type IngestOptions struct {
 	// Sets write concern level for write operations.
 	WriteConcern string `long:"writeConcern" default:"majority" value-name:"<write-concern-specifier>" default-mask:"-" description:"write concern options e.g. --writeConcern majority, --writeConcern '{w: 3, wtimeout: 500, fsync: true, j: true}' (defaults to 'majority')"`
 
+	// Specifies a file in which to periodically record how far into the input file the import has progressed.
+	CheckpointFile string `long:"checkpointFile" value-name:"<filename>" description:"periodically record in this file the input position up to which documents have been imported, so an interrupted import can be resumed (implies --maintainInsertionOrder)"`
+
+	// Resumes an interrupted import from the position recorded in the checkpoint file.
+	Resume bool `long:"resume" description:"resume an interrupted import from the input position recorded in --checkpointFile"`
+
+	// Reads and converts the input without connecting to the server or inserting any documents.
+	DryRun bool `long:"dryRun" description:"read and convert the input without connecting to the server, reporting the number of documents that would be imported"`
+
+	// Reports the inferred schema of the input in a dry run.
+	InferSchema bool `long:"inferSchema" description:"with --dryRun, report the types, null and blank rates, string lengths and example values of each field, along with a suggested typed field list and $jsonSchema validator"`
+
+	// Limits the number of documents read in a dry run.
+	SampleSize int `long:"sampleSize" value-name:"<count>" description:"with --dryRun, only read the first <count> documents of the input"`
+
 	// Indicates that the server should bypass document validation on import.
 	BypassDocumentValidation bool `long:"bypassDocumentValidation" description:"bypass document validation"`
 }
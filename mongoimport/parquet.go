@@ -0,0 +1,324 @@
+package mongoimport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/parquet"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ParquetInputReader is an implementation of InputReader that reads the
+// records of a Parquet file as documents.
+type ParquetInputReader struct {
+	// in is the input source, which is read entirely into memory unless it
+	// is a file
+	in io.Reader
+
+	// numProcessed indicates the number of records processed
+	numProcessed uint64
+
+	// numDecoders is the number of concurrent goroutines to use for decoding
+	numDecoders int
+
+	// mapping, if set, is applied to each converted document
+	mapping *fieldMapping
+
+	// bytesRead is the number of bytes of the file read so far, accessed
+	// atomically
+	bytesRead int64
+}
+
+// ParquetConverter implements the Converter interface for Parquet input.
+type ParquetConverter struct {
+	record  parquet.Group
+	schema  *parquet.Schema
+	index   uint64
+	mapping *fieldMapping
+}
+
+// NewParquetInputReader returns a ParquetInputReader configured to read
+// records from the given io.Reader using exactly "numDecoders" goroutines.
+func NewParquetInputReader(in io.Reader, numDecoders int) *ParquetInputReader {
+	return &ParquetInputReader{
+		in:          in,
+		numDecoders: numDecoders,
+	}
+}
+
+// ReadAndValidateHeader is a no-op for Parquet imports; always returns nil.
+func (r *ParquetInputReader) ReadAndValidateHeader() error {
+	return nil
+}
+
+// Size returns the number of bytes of the file read so far.
+func (r *ParquetInputReader) Size() int64 {
+	return atomic.LoadInt64(&r.bytesRead)
+}
+
+// open returns a reader of the Parquet file, whose footer is at its end.
+func (r *ParquetInputReader) open() (*parquet.Reader, error) {
+	if file, ok := r.in.(*os.File); ok {
+		if stat, err := file.Stat(); err == nil && stat.Mode().IsRegular() {
+			return parquet.NewReader(file, stat.Size())
+		}
+	}
+	data, err := ioutil.ReadAll(r.in)
+	if err != nil {
+		return nil, err
+	}
+	return parquet.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// StreamDocument takes a boolean indicating if the documents should be streamed
+// in read order and a channel on which to stream the documents processed from
+// the underlying reader. Returns a non-nil error if encountered
+func (r *ParquetInputReader) StreamDocument(ordered bool, readChan chan bson.D) (retErr error) {
+	reader, err := r.open()
+	if err != nil {
+		close(readChan)
+		return fmt.Errorf("error reading Parquet file: %v", err)
+	}
+	log.Logf(log.DebugLow, "Parquet schema:\n%v", reader.Schema())
+
+	rawChan := make(chan Converter, r.numDecoders)
+	parquetErrChan := make(chan error)
+
+	// begin reading from source
+	go func() {
+		for {
+			record, err := reader.Read()
+			atomic.StoreInt64(&r.bytesRead, reader.BytesRead())
+			if err != nil {
+				close(rawChan)
+				if err != io.EOF {
+					r.numProcessed++
+					parquetErrChan <- fmt.Errorf("error reading record #%v: %v", r.numProcessed, err)
+				} else {
+					parquetErrChan <- nil
+				}
+				return
+			}
+			rawChan <- ParquetConverter{
+				record:  record,
+				schema:  reader.Schema(),
+				index:   r.numProcessed,
+				mapping: r.mapping,
+			}
+			r.numProcessed++
+		}
+	}()
+
+	// begin processing read records
+	go func() {
+		parquetErrChan <- streamDocuments(ordered, r.numDecoders, rawChan, readChan)
+	}()
+
+	return channelQuorumError(parquetErrChan, 2)
+}
+
+// Convert implements the Converter interface for Parquet input. It converts
+// a record to a BSON document, leaving out its null fields.
+func (c ParquetConverter) Convert() (bson.D, error) {
+	document, err := parquetDocument(c.schema.Root, c.record)
+	if err != nil {
+		return nil, fmt.Errorf("error converting record #%v: %v", c.index+1, err)
+	}
+	log.Logf(log.DebugHigh, "got document: %v", document)
+	if c.mapping == nil {
+		return document, nil
+	}
+	// Parquet records are identified by their position in the file
+	return c.mapping.apply(document, c.index+1)
+}
+
+// parquetDocument converts the values of the fields of a group to a document.
+func parquetDocument(node *parquet.Node, group parquet.Group) (bson.D, error) {
+	document := bson.D{}
+	for i, child := range node.Children {
+		if group[i] == nil {
+			continue
+		}
+		value, err := parquetFieldValue(child, group[i])
+		if err != nil {
+			return nil, fmt.Errorf("in field '%v': %v", child.Name, err)
+		}
+		document = append(document, bson.DocElem{child.Name, value})
+	}
+	return document, nil
+}
+
+// parquetFieldValue converts the value of a field, which is a list of
+// elements for repeated fields, to a BSON value.
+func parquetFieldValue(node *parquet.Node, value interface{}) (interface{}, error) {
+	if node.Repetition != parquet.Repeated {
+		return parquetValue(node, value)
+	}
+	elements := value.([]interface{})
+	array := make([]interface{}, len(elements))
+	for i, element := range elements {
+		var err error
+		if array[i], err = parquetValue(node, element); err != nil {
+			return nil, err
+		}
+	}
+	return array, nil
+}
+
+// parquetValue converts a value of a field to a BSON value.
+func parquetValue(node *parquet.Node, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if node.IsLeaf() {
+		return parquetLeafValue(node, value)
+	}
+	group := value.(parquet.Group)
+	if len(node.Children) != 1 || node.Children[0].Repetition != parquet.Repeated {
+		return parquetDocument(node, group)
+	}
+	repeated := node.Children[0]
+	entries := group[0].([]interface{})
+	switch {
+	case (node.Is(parquet.Map) || repeated.Is(parquet.Map)) && len(repeated.Children) == 2:
+		// the entries of maps hold their key and value
+		document := make(bson.D, 0, len(entries))
+		for _, entry := range entries {
+			entry := entry.(parquet.Group)
+			key, err := parquetValue(repeated.Children[0], entry[0])
+			if err != nil {
+				return nil, err
+			}
+			if entry[1] == nil {
+				continue
+			}
+			value, err := parquetValue(repeated.Children[1], entry[1])
+			if err != nil {
+				return nil, err
+			}
+			document = append(document, bson.DocElem{fmt.Sprint(key), value})
+		}
+		return document, nil
+	case node.Is(parquet.List):
+		// the elements of lists of three levels are wrapped in a group of a
+		// single field; for backward compatibility, lists of two levels are
+		// recognized as in the format specification
+		array := make([]interface{}, len(entries))
+		threeLevels := len(repeated.Children) == 1 && repeated.Name != "array" && repeated.Name != node.Name+"_tuple"
+		for i, entry := range entries {
+			var err error
+			if threeLevels {
+				array[i], err = parquetValue(repeated.Children[0], entry.(parquet.Group)[0])
+			} else {
+				array[i], err = parquetValue(repeated, entry)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	}
+	return parquetDocument(node, group)
+}
+
+// parquetLeafValue converts a value of a leaf field to a BSON value,
+// according to its logical type.
+func parquetLeafValue(node *parquet.Node, value interface{}) (interface{}, error) {
+	logicalType := node.LogicalType
+	if logicalType == nil {
+		logicalType = &parquet.LogicalType{}
+	}
+	// values of fields whose logical type doesn't match their physical type
+	// are imported as is
+	switch logicalType.Kind {
+	case parquet.Decimal:
+		return parquetDecimal(value, int(logicalType.Scale))
+	case parquet.Date:
+		if v, ok := value.(int32); ok {
+			return time.Unix(int64(v)*86400, 0).UTC(), nil
+		}
+	case parquet.Timestamp:
+		if v, ok := value.(int64); ok {
+			switch logicalType.Unit {
+			case parquet.Micros:
+				return time.Unix(v/1e6, v%1e6*1e3).UTC(), nil
+			case parquet.Nanos:
+				return time.Unix(0, v).UTC(), nil
+			}
+			return time.Unix(v/1e3, v%1e3*1e6).UTC(), nil
+		}
+	case parquet.String, parquet.Enum, parquet.JSON:
+		if v, ok := value.([]byte); ok {
+			return string(v), nil
+		}
+	case parquet.BSON:
+		if v, ok := value.([]byte); ok {
+			document := bson.D{}
+			if err := bson.Unmarshal(v, &document); err != nil {
+				return nil, err
+			}
+			return document, nil
+		}
+	case parquet.UUID:
+		if v, ok := value.([]byte); ok {
+			return bson.Binary{Kind: 0x04, Data: v}, nil
+		}
+	case parquet.Integer:
+		if !logicalType.IsSigned {
+			// unsigned integers are stored in the signed types of their width
+			switch v := value.(type) {
+			case int32:
+				return int64(uint32(v)), nil
+			case int64:
+				if v < 0 {
+					d, _ := bson.ParseDecimal128FromBigInt(new(big.Int).SetUint64(uint64(v)), 0)
+					return d, nil
+				}
+			}
+		}
+	}
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case []byte:
+		if node.Type == parquet.Int96 && len(v) == 12 {
+			// legacy timestamps hold the nanoseconds of the day and the
+			// Julian day
+			nanos := int64(binary.LittleEndian.Uint64(v))
+			days := int64(binary.LittleEndian.Uint32(v[8:])) - 2440588
+			return time.Unix(days*86400, nanos).UTC(), nil
+		}
+	}
+	return value, nil
+}
+
+// parquetDecimal converts the unscaled value of a DECIMAL field, stored as an
+// integer or a big-endian two's complement byte array, to a decimal.
+func parquetDecimal(value interface{}, scale int) (interface{}, error) {
+	unscaled := new(big.Int)
+	switch v := value.(type) {
+	case int32:
+		unscaled.SetInt64(int64(v))
+	case int64:
+		unscaled.SetInt64(v)
+	case []byte:
+		unscaled.SetBytes(v)
+		if len(v) > 0 && v[0]&0x80 != 0 {
+			unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(v)*8)))
+		}
+	default:
+		return nil, fmt.Errorf("DECIMAL field can't be of type %T", value)
+	}
+	d, ok := bson.ParseDecimal128FromBigInt(unscaled, -scale)
+	if !ok {
+		return nil, fmt.Errorf("decimal %vE%v can't be represented exactly as a decimal128", unscaled, -scale)
+	}
+	return d, nil
+}
@@ -0,0 +1,194 @@
+package mongoimport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/parquet"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const testParquetSchema = `message doc {
+  required int32 id;
+  optional binary name (STRING);
+  optional group address {
+    optional binary city (STRING);
+    optional int32 zip (INTEGER(32,false));
+  }
+  optional group tags (LIST) {
+    repeated group list {
+      optional binary element (STRING);
+    }
+  }
+  optional group legacy (LIST) {
+    repeated int32 array;
+  }
+  optional group attributes (MAP) {
+    repeated group key_value {
+      required binary key (STRING);
+      optional double value;
+    }
+  }
+  repeated int64 scores;
+  optional fixed_len_byte_array(16) price (DECIMAL(38,2));
+  optional int32 cents (DECIMAL(9,2));
+  optional int64 created (TIMESTAMP(MICROS,true));
+  optional int32 day (DATE);
+  optional int96 old;
+  optional float ratio;
+  optional fixed_len_byte_array(16) uuid (UUID);
+  optional binary data;
+}
+`
+
+// parquetFile returns a Parquet file of the given schema holding records.
+func parquetFile(schemaText string, records ...parquet.Group) []byte {
+	schema, err := parquet.ParseSchema(schemaText)
+	if err != nil {
+		panic(err)
+	}
+	out := &bytes.Buffer{}
+	writer := parquet.NewWriter(out, schema)
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			panic(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	return out.Bytes()
+}
+
+func TestParquetStreamDocument(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+	Convey("With a Parquet input reader", t, func() {
+		price := make([]byte, 16)
+		for i := range price {
+			price[i] = 0xff
+		}
+		price[15] = 0xfe
+		old := []byte{0, 0x5e, 0xd0, 0xb2, 0, 0, 0, 0, 0x8c, 0x3d, 0x25, 0}
+		uuid := []byte("0123456789abcdef")
+		contents := parquetFile(testParquetSchema,
+			parquet.Group{
+				int32(1),
+				[]byte("Ann"),
+				parquet.Group{[]byte("Dublin"), int32(-1)},
+				parquet.Group{[]interface{}{parquet.Group{[]byte("a")}, parquet.Group{nil}}},
+				parquet.Group{[]interface{}{int32(1), int32(2)}},
+				parquet.Group{[]interface{}{
+					parquet.Group{[]byte("x"), 0.5},
+					parquet.Group{[]byte("y"), nil},
+				}},
+				[]interface{}{int64(3)},
+				price,
+				int32(150),
+				int64(1500000000000001),
+				int32(2),
+				old,
+				float32(2.5),
+				uuid,
+				[]byte{1, 2},
+			},
+			parquet.Group{
+				int32(2), nil, nil, parquet.Group{[]interface{}{}}, nil, nil, []interface{}{},
+				nil, nil, nil, nil, nil, nil, nil, nil,
+			},
+		)
+		cents, err := bson.ParseDecimal128("1.50")
+		So(err, ShouldBeNil)
+		minusTwo, err := bson.ParseDecimal128("-0.02")
+		So(err, ShouldBeNil)
+		expected := []bson.D{
+			{
+				{"id", int32(1)},
+				{"name", "Ann"},
+				{"address", bson.D{{"city", "Dublin"}, {"zip", int64(4294967295)}}},
+				{"tags", []interface{}{"a", nil}},
+				{"legacy", []interface{}{int32(1), int32(2)}},
+				{"attributes", bson.D{{"x", 0.5}}},
+				{"scores", []interface{}{int64(3)}},
+				{"price", minusTwo},
+				{"cents", cents},
+				{"created", time.Unix(1500000000, 1000).UTC()},
+				{"day", time.Date(1970, 1, 3, 0, 0, 0, 0, time.UTC)},
+				{"old", time.Date(1970, 1, 1, 0, 0, 3, 0, time.UTC)},
+				{"ratio", 2.5},
+				{"uuid", bson.Binary{Kind: 0x04, Data: uuid}},
+				{"data", []byte{1, 2}},
+			},
+			{{"id", int32(2)}, {"tags", []interface{}{}}, {"scores", []interface{}{}}},
+		}
+
+		Convey("records should be converted to documents in order", func() {
+			r := NewParquetInputReader(bytes.NewReader(contents), 1)
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, expected[0])
+			So(<-docChan, ShouldResemble, expected[1])
+			So(r.Size(), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("files should be read without reading them entirely", func() {
+			file, err := ioutil.TempFile("", "mongoimport")
+			So(err, ShouldBeNil)
+			defer os.Remove(file.Name())
+			defer file.Close()
+			_, err = file.Write(contents)
+			So(err, ShouldBeNil)
+
+			r := NewParquetInputReader(file, 2)
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			So(<-docChan, ShouldResemble, expected[0])
+			So(<-docChan, ShouldResemble, expected[1])
+			So(r.Size(), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("a truncated file should result in an error", func() {
+			r := NewParquetInputReader(bytes.NewReader(contents[:len(contents)-1]), 1)
+			docChan := make(chan bson.D, 2)
+			So(r.StreamDocument(true, docChan), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Decimals that don't fit a decimal128 should be rejected", t, func() {
+		huge := bytes.Repeat([]byte{0x7f}, 16)
+		contents := parquetFile("message m { required fixed_len_byte_array(16) d (DECIMAL(38,0)); }",
+			parquet.Group{huge})
+		r := NewParquetInputReader(bytes.NewReader(contents), 1)
+		docChan := make(chan bson.D, 1)
+		So(r.StreamDocument(true, docChan), ShouldNotBeNil)
+	})
+}
+
+func TestParquetValidation(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+	Convey("With a Parquet import", t, func() {
+		imp, err := NewMongoImport()
+		So(err, ShouldBeNil)
+		imp.InputOptions.Type = Parquet
+		So(imp.ValidateSettings([]string{}), ShouldBeNil)
+
+		Convey("--jsonArray should be rejected", func() {
+			imp.InputOptions.JSONArray = true
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
+		Convey("--encoding should be rejected", func() {
+			imp.InputOptions.Encoding = "latin1"
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+
+		Convey("--fields should be rejected", func() {
+			fields := "a,b"
+			imp.InputOptions.Fields = &fields
+			So(imp.ValidateSettings([]string{}), ShouldNotBeNil)
+		})
+	})
+}
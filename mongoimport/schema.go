@@ -0,0 +1,414 @@
+package mongoimport
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/json"
+	"github.com/mongodb/mongo-tools/common/log"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxSchemaExamples is the number of distinct example values reported
+	// for each field
+	maxSchemaExamples = 3
+
+	// maxExampleLength is the number of characters beyond which example
+	// values are truncated
+	maxExampleLength = 24
+)
+
+// fieldSchema accumulates the values observed for a single field path.
+type fieldSchema struct {
+	path string
+
+	// present is the number of documents containing the field, and nulls
+	// and blanks the number of those in which it is null or an empty string
+	present, nulls, blanks uint64
+
+	// types counts the other values by their $jsonSchema bsonType alias,
+	// and typeOrder lists the aliases in the order they were first seen
+	types     map[string]uint64
+	typeOrder []string
+
+	// minLength and maxLength bound the number of characters of the
+	// non-blank string values
+	minLength, maxLength int
+
+	examples []string
+}
+
+// schemaInference infers the schema of the documents it observes.
+type schemaInference struct {
+	numDocuments uint64
+	fields       map[string]*fieldSchema
+
+	// paths lists the field paths in the order they were first seen
+	paths []string
+}
+
+// newSchemaInference returns a schemaInference that hasn't observed any
+// document.
+func newSchemaInference() *schemaInference {
+	return &schemaInference{fields: map[string]*fieldSchema{}}
+}
+
+// observe records the fields of a document.
+func (s *schemaInference) observe(document bson.D) {
+	s.numDocuments++
+	s.observeDocument("", document)
+}
+
+func (s *schemaInference) observeDocument(prefix string, document bson.D) {
+	for _, elem := range document {
+		s.observeValue(prefix+elem.Name, elem.Value)
+	}
+}
+
+func (s *schemaInference) observeValue(path string, value interface{}) {
+	field, ok := s.fields[path]
+	if !ok {
+		field = &fieldSchema{path: path, types: map[string]uint64{}}
+		s.fields[path] = field
+		s.paths = append(s.paths, path)
+	}
+	field.present++
+	switch v := value.(type) {
+	case nil:
+		field.nulls++
+		return
+	case string:
+		if v == "" {
+			field.blanks++
+			return
+		}
+		length := utf8.RuneCountInString(v)
+		if field.types["string"] == 0 || length < field.minLength {
+			field.minLength = length
+		}
+		if length > field.maxLength {
+			field.maxLength = length
+		}
+	case bson.D:
+		field.addType("object")
+		s.observeDocument(path+".", v)
+		return
+	case *bson.D:
+		field.addType("object")
+		s.observeDocument(path+".", *v)
+		return
+	case bson.M:
+		field.addType("object")
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s.observeValue(path+"."+key, v[key])
+		}
+		return
+	}
+	field.addType(bsonTypeAlias(value))
+	field.addExample(value)
+}
+
+func (field *fieldSchema) addType(alias string) {
+	if field.types[alias] == 0 {
+		field.typeOrder = append(field.typeOrder, alias)
+	}
+	field.types[alias]++
+}
+
+func (field *fieldSchema) addExample(value interface{}) {
+	if len(field.examples) == maxSchemaExamples {
+		return
+	}
+	var example string
+	if s, ok := value.(string); ok {
+		example = fmt.Sprintf("%q", s)
+	} else {
+		example = fmt.Sprintf("%v", value)
+	}
+	if runes := []rune(example); len(runes) > maxExampleLength {
+		example = string(runes[:maxExampleLength-3]) + "..."
+	}
+	for _, seen := range field.examples {
+		if seen == example {
+			return
+		}
+	}
+	field.examples = append(field.examples, example)
+}
+
+// bsonTypeAlias returns the $jsonSchema bsonType alias of a value that isn't
+// null or a document.
+func bsonTypeAlias(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		return "bool"
+	case int:
+		if int64(v) == int64(int32(v)) {
+			return "int"
+		}
+		return "long"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float32, float64:
+		return "double"
+	case bson.Decimal128:
+		return "decimal"
+	case string:
+		return "string"
+	case time.Time:
+		return "date"
+	case bson.ObjectId:
+		return "objectId"
+	case []interface{}:
+		return "array"
+	case []byte, bson.Binary:
+		return "binData"
+	case bson.RegEx:
+		return "regex"
+	case bson.MongoTimestamp:
+		return "timestamp"
+	case bson.JavaScript:
+		return "javascript"
+	case bson.Symbol:
+		return "symbol"
+	case bson.DBPointer:
+		return "dbPointer"
+	}
+	switch value {
+	case bson.MinKey:
+		return "minKey"
+	case bson.MaxKey:
+		return "maxKey"
+	case bson.Undefined:
+		return "undefined"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// percentage formats the ratio of n to total.
+func percentage(n, total uint64) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+// describeTypes lists the types of a field along with their share of the
+// field's typed values when there are several.
+func (field *fieldSchema) describeTypes() string {
+	if len(field.typeOrder) == 1 {
+		return field.typeOrder[0]
+	}
+	var total uint64
+	for _, count := range field.types {
+		total += count
+	}
+	descriptions := make([]string, 0, len(field.typeOrder))
+	for _, alias := range field.typeOrder {
+		descriptions = append(descriptions,
+			fmt.Sprintf("%v (%v)", alias, percentage(field.types[alias], total)))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// writeReport writes a table describing each field observed to w, followed
+// by a suggested $jsonSchema validator and, if typedFields is set, a
+// suggested typed field list for CSV and TSV input with --columnsHaveTypes.
+func (s *schemaInference) writeReport(w io.Writer, typedFields bool) error {
+	fmt.Fprintf(w, "inspected %v %v\n\n", s.numDocuments,
+		util.Pluralize(int(s.numDocuments), "document", "documents"))
+
+	grid := &text.GridWriter{ColumnPadding: 2}
+	grid.WriteCells("field", "types", "present", "null", "blank", "min length", "max length")
+	grid.Feed("examples")
+	for _, path := range s.paths {
+		field := s.fields[path]
+		minLength, maxLength := "-", "-"
+		if field.types["string"] != 0 {
+			minLength, maxLength = fmt.Sprint(field.minLength), fmt.Sprint(field.maxLength)
+		}
+		types := field.describeTypes()
+		if types == "" {
+			types = "-"
+		}
+		grid.WriteCells(path, types,
+			percentage(field.present, s.numDocuments),
+			percentage(field.nulls, field.present),
+			percentage(field.blanks, field.present),
+			minLength, maxLength)
+		grid.Feed(strings.Join(field.examples, ", "))
+	}
+	grid.Flush(w)
+
+	if typedFields {
+		fmt.Fprintf(w, "\nsuggested typed field list, for --fields with --columnsHaveTypes:\n%v\n",
+			strings.Join(s.typedFieldList(), ","))
+	}
+
+	validator, err := bsonutil.ConvertBSONValueToJSON(bson.D{{"$jsonSchema", s.jsonSchema("")}})
+	if err != nil {
+		return err
+	}
+	schemaJSON, err := json.MarshalIndent(validator, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\nsuggested validator:\n%s\n", schemaJSON)
+	return err
+}
+
+// typedFieldList returns each field that doesn't hold documents, suffixed
+// with the type of its values in <field>.<type>() form. Fields with values
+// of different types are suffixed with auto().
+func (s *schemaInference) typedFieldList() []string {
+	var fields []string
+	for _, path := range s.paths {
+		field := s.fields[path]
+		if field.types["object"] != 0 {
+			continue
+		}
+		columnType := "auto"
+		switch {
+		case len(field.typeOrder) == 0 && field.blanks != 0:
+			columnType = "string"
+		case len(field.typeOrder) == 1:
+			switch field.typeOrder[0] {
+			case "int":
+				columnType = "int32"
+			case "long":
+				columnType = "int64"
+			case "double":
+				columnType = "double"
+			case "decimal":
+				columnType = "decimal"
+			case "bool":
+				columnType = "boolean"
+			case "string":
+				columnType = "string"
+			}
+		case len(field.typeOrder) == 2 && field.types["double"] != 0 &&
+			(field.types["int"] != 0 || field.types["long"] != 0):
+			columnType = "double"
+		}
+		fields = append(fields, fmt.Sprintf("%v.%v()", path, columnType))
+	}
+	return fields
+}
+
+// jsonSchema returns a $jsonSchema describing the document found at the
+// given path, or the top-level document if path is empty.
+func (s *schemaInference) jsonSchema(path string) bson.D {
+	occurrences := s.numDocuments
+	prefix := ""
+	if path != "" {
+		occurrences = s.fields[path].types["object"]
+		prefix = path + "."
+	}
+	required := []interface{}{}
+	properties := bson.D{}
+	for _, childPath := range s.paths {
+		name := strings.TrimPrefix(childPath, prefix)
+		if !strings.HasPrefix(childPath, prefix) || strings.Contains(name, ".") {
+			continue
+		}
+		field := s.fields[childPath]
+		if field.present == occurrences && field.nulls == 0 {
+			required = append(required, name)
+		}
+		properties = append(properties, bson.DocElem{name, s.propertySchema(field)})
+	}
+	schema := bson.D{{"bsonType", "object"}}
+	if len(required) != 0 {
+		schema = append(schema, bson.DocElem{"required", required})
+	}
+	return append(schema, bson.DocElem{"properties", properties})
+}
+
+// propertySchema returns the $jsonSchema of a field.
+func (s *schemaInference) propertySchema(field *fieldSchema) bson.D {
+	types := []interface{}{}
+	for _, alias := range field.typeOrder {
+		types = append(types, alias)
+	}
+	if field.blanks != 0 && field.types["string"] == 0 {
+		types = append(types, "string")
+	}
+	if field.nulls != 0 {
+		types = append(types, "null")
+	}
+	if field.types["object"] != 0 {
+		// describe the object's fields, and allow its other types
+		schema := s.jsonSchema(field.path)
+		schema[0].Value = types
+		if len(types) == 1 {
+			schema[0].Value = types[0]
+		}
+		return schema
+	}
+	if len(types) == 1 {
+		return bson.D{{"bsonType", types[0]}}
+	}
+	return bson.D{{"bsonType", types}}
+}
+
+// dryRun reads the documents of the input source without inserting them,
+// stopping after --sampleSize documents if it is set. If --inferSchema is
+// set, it writes a report of the inferred schema to out. It returns the
+// number of documents read. The input source must be closed once it returns.
+func (imp *MongoImport) dryRun(inputReader InputReader, out io.Writer) (uint64, error) {
+	readDocs := make(chan bson.D, workerBufferSize)
+	processingErrChan := make(chan error, 1)
+	schema := newSchemaInference()
+	sampleSize := uint64(imp.IngestOptions.SampleSize)
+
+	// read and process from the input reader
+	go func() {
+		processingErrChan <- inputReader.StreamDocument(true, readDocs)
+	}()
+
+	// the reader stops once the caller closes the input source, but needs
+	// the documents it still sends to be received until then
+	defer func() {
+		go func() {
+			for _ = range readDocs {
+			}
+		}()
+	}()
+
+	// inspect documents instead of inserting them
+	for document := range readDocs {
+		if imp.IngestOptions.IgnoreBlanks {
+			document = removeBlankFields(document)
+		}
+		schema.observe(document)
+		if schema.numDocuments == sampleSize {
+			break
+		}
+	}
+	if schema.numDocuments != sampleSize || sampleSize == 0 {
+		if err := <-processingErrChan; err != nil {
+			return schema.numDocuments, err
+		}
+	}
+
+	log.Logf(log.Always, "dry run: no documents were inserted")
+	if !imp.IngestOptions.InferSchema {
+		return schema.numDocuments, nil
+	}
+	typedFields := imp.InputOptions.Type == CSV || imp.InputOptions.Type == TSV
+	return schema.numDocuments, schema.writeReport(out, typedFields)
+}
@@ -0,0 +1,137 @@
+package mongoimport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaInference(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a schema inferred from some documents", t, func() {
+		schema := newSchemaInference()
+		schema.observe(bson.D{{"name", "Ada"}, {"age", 36}, {"address", bson.D{{"city", "London"}}}})
+		schema.observe(bson.D{{"name", ""}, {"age", 1.5}, {"address", bson.D{{"city", nil}}}})
+		schema.observe(bson.D{{"name", "Grace"}, {"age", 85}, {"tags", []interface{}{"x"}}})
+
+		Convey("each field's statistics should be recorded", func() {
+			So(schema.numDocuments, ShouldEqual, 3)
+			So(schema.paths, ShouldResemble, []string{"name", "age", "address", "address.city", "tags"})
+			name := schema.fields["name"]
+			So(name.present, ShouldEqual, 3)
+			So(name.blanks, ShouldEqual, 1)
+			So(name.minLength, ShouldEqual, 3)
+			So(name.maxLength, ShouldEqual, 5)
+			So(name.examples, ShouldResemble, []string{`"Ada"`, `"Grace"`})
+			So(schema.fields["age"].describeTypes(), ShouldEqual, "int (66.7%), double (33.3%)")
+			So(schema.fields["address.city"].nulls, ShouldEqual, 1)
+		})
+
+		Convey("a typed field list should be suggested for fields that aren't documents", func() {
+			So(schema.typedFieldList(), ShouldResemble, []string{
+				"name.string()", "age.double()", "address.city.string()", "tags.auto()",
+			})
+		})
+
+		Convey("a $jsonSchema should be suggested", func() {
+			So(schema.jsonSchema(""), ShouldResemble, bson.D{
+				{"bsonType", "object"},
+				{"required", []interface{}{"name", "age"}},
+				{"properties", bson.D{
+					{"name", bson.D{{"bsonType", "string"}}},
+					{"age", bson.D{{"bsonType", []interface{}{"int", "double"}}}},
+					{"address", bson.D{
+						{"bsonType", "object"},
+						{"properties", bson.D{
+							{"city", bson.D{{"bsonType", []interface{}{"string", "null"}}}},
+						}},
+					}},
+					{"tags", bson.D{{"bsonType", "array"}}},
+				}},
+			})
+		})
+
+		Convey("the report should be written", func() {
+			out := &bytes.Buffer{}
+			So(schema.writeReport(out, true), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "inspected 3 documents")
+			So(out.String(), ShouldContainSubstring, "name.string(),age.double()")
+			So(out.String(), ShouldContainSubstring, `"$jsonSchema"`)
+		})
+	})
+}
+
+// eofNotifier closes eof once its reader is read to its end.
+type eofNotifier struct {
+	io.Reader
+	eof chan struct{}
+}
+
+func (r *eofNotifier) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		select {
+		case <-r.eof:
+		default:
+			close(r.eof)
+		}
+	}
+	return n, err
+}
+
+func TestDryRun(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a dry run of a CSV import", t, func() {
+		imp, err := NewMongoImport()
+		So(err, ShouldBeNil)
+		imp.InputOptions.Type = CSV
+		imp.IngestOptions.DryRun = true
+		imp.IngestOptions.InferSchema = true
+		contents := "1,a\n2,b\n3,c\n4,d\n"
+
+		Convey("all documents should be read", func() {
+			r := NewCSVInputReader([]string{"n", "s"}, bytes.NewReader([]byte(contents)), 1)
+			out := &bytes.Buffer{}
+			numRead, err := imp.dryRun(r, out)
+			So(err, ShouldBeNil)
+			So(numRead, ShouldEqual, 4)
+			So(out.String(), ShouldContainSubstring, "n.int32(),s.string()")
+		})
+
+		Convey("only --sampleSize documents should be read if it is set", func() {
+			imp.IngestOptions.SampleSize = 2
+			r := NewCSVInputReader([]string{"n", "s"}, bytes.NewReader([]byte(contents)), 1)
+			numRead, err := imp.dryRun(r, &bytes.Buffer{})
+			So(err, ShouldBeNil)
+			So(numRead, ShouldEqual, 2)
+		})
+
+		Convey("the input should still be read to its end after --sampleSize documents", func() {
+			imp.IngestOptions.SampleSize = 1
+			long := strings.Repeat(contents, 1000)
+			in := &eofNotifier{Reader: strings.NewReader(long), eof: make(chan struct{})}
+			r := NewCSVInputReader([]string{"n", "s"}, in, 1)
+			numRead, err := imp.dryRun(r, &bytes.Buffer{})
+			So(err, ShouldBeNil)
+			So(numRead, ShouldEqual, 1)
+			select {
+			case <-in.eof:
+			case <-time.After(5 * time.Second):
+				So("the input reader is blocked", ShouldBeEmpty)
+			}
+		})
+
+		Convey("input errors should be reported", func() {
+			r := NewCSVInputReader([]string{"n", "s"}, bytes.NewReader([]byte(`1,"a`)), 1)
+			_, err := imp.dryRun(r, &bytes.Buffer{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
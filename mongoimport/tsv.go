@@ -19,6 +19,11 @@ type TSVInputReader struct {
 	// fields is a list of field names in the BSON documents to be imported
 	fields []string
 
+	// types, if set, are the types of the values of the fields, and
+	// columnsHaveTypes indicates that the header gives them
+	types            []fieldType
+	columnsHaveTypes bool
+
 	// tsvReader is the underlying reader used to read data in from the TSV
 	// or TSV file
 	tsvReader *bufio.Reader
@@ -32,15 +37,35 @@ type TSVInputReader struct {
 	// numDecoders is the number of concurrent goroutines to use for decoding
 	numDecoders int
 
+	// recordPosition, if set, is called with the input position following
+	// each record read
+	recordPosition func(inputPosition)
+
+	// mapping, if set, is applied to each converted document
+	mapping *fieldMapping
+
+	// trimSpace indicates if white space surrounding each value should be
+	// removed
+	trimSpace bool
+
+	// offset and line track the number of bytes and lines consumed from
+	// the input source
+	offset int64
+	line   uint64
+
 	// embedded sizeTracker exposes the Size() method to check the number of bytes read so far
 	sizeTracker
 }
 
 // TSVConverter implements the Converter interface for TSV input.
 type TSVConverter struct {
-	fields []string
-	data   string
-	index  uint64
+	fields    []string
+	types     []fieldType
+	data      string
+	index     uint64
+	line      uint64
+	mapping   *fieldMapping
+	trimSpace bool
 }
 
 // NewTSVInputReader returns a TSVInputReader configured to read input from the
@@ -49,7 +74,7 @@ func NewTSVInputReader(fields []string, in io.Reader, numDecoders int) *TSVInput
 	szCount := newSizeTrackingReader(in)
 	return &TSVInputReader{
 		fields:       fields,
-		tsvReader:    bufio.NewReader(in),
+		tsvReader:    bufio.NewReader(szCount),
 		numProcessed: uint64(0),
 		numDecoders:  numDecoders,
 		sizeTracker:  szCount,
@@ -63,9 +88,16 @@ func (r *TSVInputReader) ReadAndValidateHeader() (err error) {
 	if err != nil {
 		return err
 	}
+	r.offset += int64(len(header))
+	r.line++
 	for _, field := range strings.Split(header, tokenSeparator) {
 		r.fields = append(r.fields, strings.TrimRight(field, "\r\n"))
 	}
+	if r.columnsHaveTypes {
+		if r.fields, r.types, err = parseTypedFields(r.fields); err != nil {
+			return err
+		}
+	}
 	return validateReaderFields(r.fields)
 }
 
@@ -91,10 +123,23 @@ func (r *TSVInputReader) StreamDocument(ordered bool, readDocs chan bson.D) (ret
 				}
 				return
 			}
+			r.offset += int64(len(r.tsvRecord))
+			r.line++
+			if r.recordPosition != nil {
+				r.recordPosition(inputPosition{
+					Offset:    r.offset,
+					Line:      r.line,
+					Documents: r.numProcessed + 1,
+				})
+			}
 			tsvRecordChan <- TSVConverter{
-				fields: r.fields,
-				data:   r.tsvRecord,
-				index:  r.numProcessed,
+				fields:    r.fields,
+				types:     r.types,
+				data:      r.tsvRecord,
+				index:     r.numProcessed,
+				line:      r.line,
+				mapping:   r.mapping,
+				trimSpace: r.trimSpace,
 			}
 			r.numProcessed++
 		}
@@ -108,12 +153,36 @@ func (r *TSVInputReader) StreamDocument(ordered bool, readDocs chan bson.D) (ret
 	return channelQuorumError(tsvErrChan, 2)
 }
 
+// setPositionRecorder is part of the resumableInputReader interface.
+func (r *TSVInputReader) setPositionRecorder(record func(inputPosition)) {
+	r.recordPosition = record
+}
+
+// resume is part of the resumableInputReader interface.
+func (r *TSVInputReader) resume(in io.Reader, pos inputPosition) {
+	szCount := newSizeTrackingReader(in)
+	r.tsvReader = bufio.NewReader(szCount)
+	r.sizeTracker = szCount
+	r.offset = pos.Offset
+	r.line = pos.Line
+	r.numProcessed = pos.Documents
+}
+
 // Convert implements the Converter interface for TSV input. It converts a
 // TSVConverter struct to a BSON document.
 func (c TSVConverter) Convert() (bson.D, error) {
-	return tokensToBSON(
+	tokens := strings.Split(strings.TrimRight(c.data, "\r\n"), tokenSeparator)
+	if c.trimSpace {
+		tokens = trimTokens(tokens)
+	}
+	document, err := typedTokensToBSON(
 		c.fields,
-		strings.Split(strings.TrimRight(c.data, "\r\n"), tokenSeparator),
+		c.types,
+		tokens,
 		c.index,
 	)
+	if err != nil || c.mapping == nil {
+		return document, err
+	}
+	return c.mapping.apply(document, c.line)
 }
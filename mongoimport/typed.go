@@ -0,0 +1,127 @@
+package mongoimport
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/mgo.v2/bson"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// typedFieldPattern matches the fields of --columnsHaveTypes, of the form
+// <field>.<type>(<argument>).
+var typedFieldPattern = regexp.MustCompile(`^(.+?)\.(\w+)\((.*)\)$`)
+
+// fieldType is the type of the values of a field of CSV or TSV input, given
+// with --columnsHaveTypes.
+type fieldType struct {
+	// name is the type as it was given, such as 'date(2006-01-02)'
+	name string
+
+	// parse converts a non-blank value of the field
+	parse func(token string) (interface{}, error)
+}
+
+// parseTypedFields splits fields of the form <field>.<type>(<argument>) into
+// their names and types.
+func parseTypedFields(fields []string) ([]string, []fieldType, error) {
+	names := make([]string, len(fields))
+	types := make([]fieldType, len(fields))
+	for i, field := range fields {
+		match := typedFieldPattern.FindStringSubmatch(field)
+		if match == nil {
+			return nil, nil, fmt.Errorf("field '%v' has no type, expected <field>.<type>()", field)
+		}
+		parse, err := fieldParser(match[2], match[3])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid type of field '%v': %v", match[1], err)
+		}
+		names[i] = match[1]
+		types[i] = fieldType{name: match[2] + "(" + match[3] + ")", parse: parse}
+	}
+	return names, types, nil
+}
+
+// fieldParser returns the function converting values of the named type,
+// given its argument.
+func fieldParser(name, argument string) (func(string) (interface{}, error), error) {
+	switch name {
+	case "date":
+		if argument == "" {
+			return nil, fmt.Errorf("date() needs a layout, in Go's format, e.g. date(2006-01-02)")
+		}
+		return func(token string) (interface{}, error) {
+			return time.Parse(argument, token)
+		}, nil
+	case "binary":
+		switch argument {
+		case "base64":
+			return func(token string) (interface{}, error) {
+				return base64.StdEncoding.DecodeString(token)
+			}, nil
+		case "hex":
+			return func(token string) (interface{}, error) {
+				return hex.DecodeString(token)
+			}, nil
+		}
+		return nil, fmt.Errorf("binary() needs an encoding, base64 or hex")
+	}
+
+	if argument != "" {
+		return nil, fmt.Errorf("%v() takes no argument", name)
+	}
+	switch name {
+	case "auto":
+		return func(token string) (interface{}, error) {
+			return getParsedValue(token), nil
+		}, nil
+	case "string":
+		return func(token string) (interface{}, error) {
+			return token, nil
+		}, nil
+	case "int32":
+		return func(token string) (interface{}, error) {
+			value, err := strconv.ParseInt(token, 10, 32)
+			return int32(value), err
+		}, nil
+	case "int64":
+		return func(token string) (interface{}, error) {
+			return strconv.ParseInt(token, 10, 64)
+		}, nil
+	case "double":
+		return func(token string) (interface{}, error) {
+			return strconv.ParseFloat(token, 64)
+		}, nil
+	case "decimal":
+		return func(token string) (interface{}, error) {
+			return bson.ParseDecimal128(token)
+		}, nil
+	case "boolean":
+		return func(token string) (interface{}, error) {
+			return strconv.ParseBool(token)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown type %v()", name)
+}
+
+// typedValue converts a token of a field of the given type, or of no type if
+// it's nil. Blank values are left as empty strings, which --ignoreBlanks
+// removes.
+func typedValue(token string, typ *fieldType) (interface{}, error) {
+	if typ == nil {
+		return getParsedValue(token), nil
+	}
+	if token == "" {
+		return token, nil
+	}
+	value, err := typ.parse(token)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok {
+			err = numErr.Err
+		}
+		return nil, fmt.Errorf("can't parse '%v' as %v: %v", token, typ.name, err)
+	}
+	return value, nil
+}
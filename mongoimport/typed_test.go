@@ -0,0 +1,98 @@
+package mongoimport
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+	"time"
+)
+
+func TestTypedFields(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Typed fields should be split into names and types", t, func() {
+		names, types, err := parseTypedFields([]string{
+			"a.b.int32()", "price.decimal()", "day.date(2006.01.02)", "data.binary(hex)",
+		})
+		So(err, ShouldBeNil)
+		So(names, ShouldResemble, []string{"a.b", "price", "day", "data"})
+		So(types[2].name, ShouldEqual, "date(2006.01.02)")
+	})
+
+	Convey("Fields without a valid type should be rejected", t, func() {
+		for _, field := range []string{"a", "a.int32", "a.int33()", "a.int32(x)", "a.date()", "a.binary(base32)"} {
+			_, _, err := parseTypedFields([]string{field})
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Values should be parsed as the type of their field", t, func() {
+		fields := []string{
+			"s.string()", "i.int32()", "l.int64()", "d.double()", "n.decimal()",
+			"b.boolean()", "t.date(2006-01-02)", "x.binary(base64)", "a.auto()",
+		}
+		names, types, err := parseTypedFields(fields)
+		So(err, ShouldBeNil)
+		document, err := typedTokensToBSON(names, types, []string{
+			"12", "12", "12", "1.5", "1.10", "true", "2016-01-02", "AQI=", "12", "extra",
+		}, 0)
+		So(err, ShouldBeNil)
+		decimal, err := bson.ParseDecimal128("1.10")
+		So(err, ShouldBeNil)
+		So(document, ShouldResemble, bson.D{
+			{"s", "12"},
+			{"i", int32(12)},
+			{"l", int64(12)},
+			{"d", 1.5},
+			{"n", decimal},
+			{"b", true},
+			{"t", time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)},
+			{"x", []byte{1, 2}},
+			{"a", 12},
+			{"field9", "extra"},
+		})
+	})
+
+	Convey("Blank values should be left as empty strings", t, func() {
+		names, types, err := parseTypedFields([]string{"i.int32()"})
+		So(err, ShouldBeNil)
+		document, err := typedTokensToBSON(names, types, []string{""}, 0)
+		So(err, ShouldBeNil)
+		So(document, ShouldResemble, bson.D{{"i", ""}})
+	})
+
+	Convey("Values that don't parse as their type should be rejected", t, func() {
+		names, types, err := parseTypedFields([]string{"i.int32()"})
+		So(err, ShouldBeNil)
+		_, err = typedTokensToBSON(names, types, []string{"3000000000"}, 4)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "error in field 'i' of document #4: can't parse '3000000000' as int32(): value out of range")
+	})
+
+	Convey("With a CSV input reader of typed columns", t, func() {
+		contents := "name.string(),price.decimal()\n007,9.99\n"
+		r := NewCSVInputReader(nil, bytes.NewReader([]byte(contents)), 1)
+		r.columnsHaveTypes = true
+		So(r.ReadAndValidateHeader(), ShouldBeNil)
+		So(r.fields, ShouldResemble, []string{"name", "price"})
+
+		Convey("the values should be parsed as their types", func() {
+			docChan := make(chan bson.D, 1)
+			So(r.StreamDocument(true, docChan), ShouldBeNil)
+			price, err := bson.ParseDecimal128("9.99")
+			So(err, ShouldBeNil)
+			So(<-docChan, ShouldResemble, bson.D{{"name", "007"}, {"price", price}})
+		})
+	})
+
+	Convey("The typed field list suggested by --inferSchema should be accepted", t, func() {
+		schema := newSchemaInference()
+		decimal, err := bson.ParseDecimal128("1.5")
+		So(err, ShouldBeNil)
+		schema.observe(bson.D{{"a", 1}, {"b", decimal}, {"c", true}, {"d", "x"}})
+		_, _, err = parseTypedFields(schema.typedFieldList())
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,293 @@
+package mongostat
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/log"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAlertCommandTimeout is the time after which alert commands are
+// killed.
+const DefaultAlertCommandTimeout = 30 * time.Second
+
+// alertOperators are the comparisons of alert rules, longest first so that
+// they are matched before their prefixes.
+var alertOperators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// AlertRule is a condition on a column of the stats of a host, given with
+// --alert, such as 'qrw>50' or 'dirty>20%'.
+type AlertRule struct {
+	// Text is the rule as it was given
+	Text string
+
+	// Column whose values are compared with the threshold
+	Column Column
+
+	Operator  string
+	Threshold float64
+}
+
+// ParseAlertRule parses a rule of the form <column><operator><value>, where
+// the column is a built-in column or a serverStatus field as with --columns,
+// the operator is one of >, >=, <, <=, == and !=, and the value is a number,
+// optionally followed by % for percentages.
+func ParseAlertRule(text string) (*AlertRule, error) {
+	position := strings.IndexAny(text, "<>=!")
+	if position < 0 {
+		return nil, fmt.Errorf("invalid alert '%v', expected <column><operator><value>", text)
+	}
+	rule := &AlertRule{Text: text}
+	for _, operator := range alertOperators {
+		if strings.HasPrefix(text[position:], operator) {
+			rule.Operator = operator
+			break
+		}
+	}
+	if rule.Operator == "" {
+		return nil, fmt.Errorf("invalid operator in alert '%v'", text)
+	}
+
+	column, err := parseColumn(strings.TrimSpace(text[:position]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid column in alert '%v': %v", text, err)
+	}
+	rule.Column = column
+
+	value := strings.TrimSuffix(strings.TrimSpace(text[position+len(rule.Operator):]), "%")
+	if rule.Threshold, err = strconv.ParseFloat(value, 64); err != nil {
+		return nil, fmt.Errorf("invalid value in alert '%v': %v", text, err)
+	}
+	return rule, nil
+}
+
+// Matches returns the value of the rule's column in a stat line, and whether
+// it meets the condition. Lines without a number in the column don't.
+func (rule *AlertRule) Matches(line *StatLine) (float64, bool) {
+	value, ok := numericValue(rule.Column.csvCell(line))
+	if !ok {
+		return 0, false
+	}
+	switch rule.Operator {
+	case ">":
+		return value, value > rule.Threshold
+	case ">=":
+		return value, value >= rule.Threshold
+	case "<":
+		return value, value < rule.Threshold
+	case "<=":
+		return value, value <= rule.Threshold
+	case "==":
+		return value, value == rule.Threshold
+	}
+	return value, value != rule.Threshold
+}
+
+// Alert is a rule met by the stats of a host, written as a line of JSON to
+// the --alertLog file.
+type Alert struct {
+	Time    time.Time `json:"time"`
+	Host    string    `json:"host"`
+	Rule    string    `json:"rule"`
+	Value   float64   `json:"value"`
+	Samples int       `json:"samples"`
+}
+
+// AlertError is the error mongostat fails with when an alert fires with
+// --exitOnAlert.
+type AlertError struct {
+	Alert *Alert
+}
+
+func (err *AlertError) Error() string {
+	return fmt.Sprintf("alert '%v' fired on %v with value %v", err.Alert.Rule, err.Alert.Host, err.Alert.Value)
+}
+
+// Alerter evaluates alert rules on each StatLine, and fires an alert when a
+// rule is met by a host for a number of consecutive samples. It fires again
+// only once the rule stopped being met.
+type Alerter struct {
+	Rules []*AlertRule
+
+	// Number of consecutive samples meeting a rule for its alert to fire
+	Samples int
+
+	// Command run with the shell for each alert, if set; the alert is in its
+	// environment
+	Command string
+
+	// Time after which the command is killed
+	CommandTimeout time.Duration
+
+	// Where to write each alert as a line of JSON, if set
+	Log io.Writer
+
+	// Fired receives the first alert fired, if set
+	Fired chan *Alert
+
+	// Mutex to handle stat lines of several hosts being checked concurrently
+	lock sync.Mutex
+
+	// streaks holds the number of consecutive samples that met each rule,
+	// by host and rule
+	streaks map[string]int
+
+	// running holds the hosts and rules whose command is running, which
+	// isn't run again for them until it exits
+	running map[string]bool
+
+	// commands tracks the commands running
+	commands sync.WaitGroup
+}
+
+// NewAlerter returns an Alerter of the rules, which fire after the given
+// number of consecutive samples.
+func NewAlerter(rules []*AlertRule, samples int) *Alerter {
+	return &Alerter{
+		Rules:          rules,
+		Samples:        samples,
+		CommandTimeout: DefaultAlertCommandTimeout,
+		streaks:        map[string]int{},
+		running:        map[string]bool{},
+	}
+}
+
+// Columns returns the columns of the rules, whose serverStatus fields must be
+// collected.
+func (alerter *Alerter) Columns() []Column {
+	columns := make([]Column, len(alerter.Rules))
+	for i, rule := range alerter.Rules {
+		columns[i] = rule.Column
+	}
+	return columns
+}
+
+// Check evaluates the rules on a stat line, firing the alerts whose rules
+// were met for enough consecutive samples. Alert commands run in the
+// background, so that they don't hold up polling.
+func (alerter *Alerter) Check(line StatLine) {
+	if line.Error != nil {
+		return
+	}
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	for _, rule := range alerter.Rules {
+		key := line.Key + "\x00" + rule.Text
+		value, matches := rule.Matches(&line)
+		if !matches {
+			if alerter.streaks[key] >= alerter.Samples {
+				log.Logf(log.Always, "alert '%v' resolved on %v", rule.Text, line.Key)
+			}
+			delete(alerter.streaks, key)
+			continue
+		}
+		alerter.streaks[key]++
+		if alerter.streaks[key] == alerter.Samples {
+			alerter.fire(key, &Alert{
+				Time:    line.Time,
+				Host:    line.Key,
+				Rule:    rule.Text,
+				Value:   value,
+				Samples: alerter.Samples,
+			})
+		}
+	}
+}
+
+// Wait waits for the alert commands running to exit.
+func (alerter *Alerter) Wait() {
+	alerter.commands.Wait()
+}
+
+// fire prints an alert, writes it to the log and starts the command if set,
+// unless the command of the same host and rule, given by key, is still
+// running. It's called with the lock held.
+func (alerter *Alerter) fire(key string, alert *Alert) {
+	log.Logf(log.Always, "alert '%v' fired on %v: value %v for %v consecutive samples",
+		alert.Rule, alert.Host, alert.Value, alert.Samples)
+
+	if alerter.Log != nil {
+		data, err := json.Marshal(alert)
+		if err == nil {
+			_, err = alerter.Log.Write(append(data, '\n'))
+		}
+		if err != nil {
+			log.Logf(log.Always, "error writing alert to log: %v", err)
+		}
+	}
+
+	if alerter.Command != "" {
+		if alerter.running[key] {
+			log.Logf(log.Always, "not running alert command for '%v' on %v, its previous run hasn't exited",
+				alert.Rule, alert.Host)
+		} else {
+			alerter.running[key] = true
+			alerter.commands.Add(1)
+			go alerter.run(key, alert)
+		}
+	}
+
+	if alerter.Fired != nil {
+		select {
+		case alerter.Fired <- alert:
+		default:
+		}
+	}
+}
+
+// run runs the command of an alert, killing it after CommandTimeout.
+func (alerter *Alerter) run(key string, alert *Alert) {
+	defer func() {
+		alerter.lock.Lock()
+		delete(alerter.running, key)
+		alerter.lock.Unlock()
+		alerter.commands.Done()
+	}()
+
+	var command *exec.Cmd
+	if runtime.GOOS == "windows" {
+		command = exec.Command("cmd", "/C", alerter.Command)
+	} else {
+		command = exec.Command("sh", "-c", alerter.Command)
+	}
+	command.Env = append(os.Environ(),
+		"MONGOSTAT_ALERT_HOST="+alert.Host,
+		"MONGOSTAT_ALERT_RULE="+alert.Rule,
+		fmt.Sprintf("MONGOSTAT_ALERT_VALUE=%v", alert.Value),
+		"MONGOSTAT_ALERT_TIME="+alert.Time.Format(time.RFC3339))
+	command.Stdout = os.Stderr
+	command.Stderr = os.Stderr
+	if err := command.Start(); err != nil {
+		log.Logf(log.Always, "error running alert command: %v", err)
+		return
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- command.Wait()
+	}()
+	var err error
+	if alerter.CommandTimeout > 0 {
+		timer := time.NewTimer(alerter.CommandTimeout)
+		defer timer.Stop()
+		select {
+		case err = <-exited:
+		case <-timer.C:
+			command.Process.Kill()
+			<-exited
+			err = fmt.Errorf("killed after %v", alerter.CommandTimeout)
+		}
+	} else {
+		err = <-exited
+	}
+	if err != nil {
+		log.Logf(log.Always, "error running alert command: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package mongostat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Alert rules should be parsed", t, func() {
+		rule, err := ParseAlertRule("qrw>50")
+		So(err, ShouldBeNil)
+		So(rule.Column.Name, ShouldEqual, "qrw")
+		So(rule.Operator, ShouldEqual, ">")
+		So(rule.Threshold, ShouldEqual, 50)
+
+		rule, err = ParseAlertRule("dirty >= 20%")
+		So(err, ShouldBeNil)
+		So(rule.Column.Name, ShouldEqual, "dirty")
+		So(rule.Operator, ShouldEqual, ">=")
+		So(rule.Threshold, ShouldEqual, 20)
+
+		rule, err = ParseAlertRule("metrics.cursor.timedOut.rate()!=0")
+		So(err, ShouldBeNil)
+		So(rule.Column.Path, ShouldResemble, []string{"metrics", "cursor", "timedOut"})
+		So(rule.Column.Modifier, ShouldEqual, ModifierRate)
+		So(rule.Operator, ShouldEqual, "!=")
+	})
+
+	Convey("Invalid alert rules should be rejected", t, func() {
+		for _, text := range []string{"", "conn", "conn!5", ">5", "conn>", "conn>many", "a.avg()>1"} {
+			_, err := ParseAlertRule(text)
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Alert rules should compare the values of their column", t, func() {
+		line := &StatLine{
+			QueuedReaders:     30,
+			QueuedWriters:     30,
+			CacheDirtyPercent: 0.1,
+			NumConnections:    10,
+			Faults:            -1,
+		}
+		for text, matches := range map[string]bool{
+			"qrw>50":          true,
+			"qrw<=50":         false,
+			"dirty>20%":       false,
+			"dirty<20%":       true,
+			"conn==10":        true,
+			"conn!=10":        false,
+			"faults>=0":       false,
+			"faults<0":        false,
+			"uptime.diff()>0": false,
+		} {
+			rule, err := ParseAlertRule(text)
+			So(err, ShouldBeNil)
+			_, ok := rule.Matches(line)
+			So(ok, ShouldEqual, matches)
+		}
+	})
+}
+
+func TestAlerter(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With an alerter firing after two samples", t, func() {
+		rule, err := ParseAlertRule("conn>100")
+		So(err, ShouldBeNil)
+		alerter := NewAlerter([]*AlertRule{rule}, 2)
+		buf := &bytes.Buffer{}
+		alerter.Log = buf
+		alerter.Fired = make(chan *Alert, 1)
+		sampleTime := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+		sample := func(host string, connections int64) StatLine {
+			return StatLine{Key: host, Time: sampleTime, NumConnections: connections}
+		}
+
+		Convey("alerts should only fire for consecutive samples meeting the rule", func() {
+			alerter.Check(sample("a:27017", 200))
+			alerter.Check(sample("b:27017", 200))
+			alerter.Check(sample("a:27017", 50))
+			alerter.Check(sample("a:27017", 200))
+			So(buf.String(), ShouldEqual, "")
+			So(len(alerter.Fired), ShouldEqual, 0)
+
+			alerter.Check(sample("a:27017", 300))
+			alerter.Check(sample("a:27017", 300))
+			alerter.Check(StatLine{Key: "a:27017", Error: errors.New("no reachable servers")})
+			So(strings.Count(buf.String(), "\n"), ShouldEqual, 1)
+			alert := Alert{}
+			So(json.Unmarshal(buf.Bytes(), &alert), ShouldBeNil)
+			So(alert, ShouldResemble, Alert{
+				Time:    sampleTime,
+				Host:    "a:27017",
+				Rule:    "conn>100",
+				Value:   300,
+				Samples: 2,
+			})
+			So(<-alerter.Fired, ShouldResemble, &alert)
+		})
+
+		Convey("alerts should fire again once their rule stopped being met", func() {
+			for _, connections := range []int64{200, 200, 50, 200, 200} {
+				alerter.Check(sample("a:27017", connections))
+			}
+			So(strings.Count(buf.String(), "\n"), ShouldEqual, 2)
+		})
+
+		Convey("the alert command should be run with the alert in its environment", func() {
+			if runtime.GOOS == "windows" {
+				return
+			}
+			dir, err := ioutil.TempDir("", "mongostat")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			out := filepath.Join(dir, "alert")
+			alerter.Command = `echo "$MONGOSTAT_ALERT_HOST $MONGOSTAT_ALERT_RULE $MONGOSTAT_ALERT_VALUE" > ` + out
+			alerter.Check(sample("a:27017", 200))
+			alerter.Check(sample("a:27017", 200))
+			alerter.Wait()
+			content, err := ioutil.ReadFile(out)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "a:27017 conn>100 200\n")
+		})
+
+		Convey("slow alert commands should neither hold up checks nor run twice at once", func() {
+			if runtime.GOOS == "windows" {
+				return
+			}
+			dir, err := ioutil.TempDir("", "mongostat")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			out := filepath.Join(dir, "alert")
+			alerter.Command = `echo run >> ` + out + `; exec sleep 10`
+			alerter.CommandTimeout = 200 * time.Millisecond
+			start := time.Now()
+			for _, connections := range []int64{200, 200, 50, 200, 200} {
+				alerter.Check(sample("a:27017", connections))
+			}
+			So(time.Since(start), ShouldBeLessThan, alerter.CommandTimeout)
+			So(strings.Count(buf.String(), "\n"), ShouldEqual, 2)
+			alerter.Wait()
+			So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+			content, err := ioutil.ReadFile(out)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "run\n")
+		})
+	})
+}
@@ -0,0 +1,369 @@
+package mongostat
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2/bson"
+	"strconv"
+	"strings"
+)
+
+// Modifiers of the values of serverStatus fields shown in custom columns.
+const (
+	// ModifierRate shows the change of the field per second between polls
+	ModifierRate = "rate"
+	// ModifierDiff shows the change of the field between polls
+	ModifierDiff = "diff"
+)
+
+// Column is an output column chosen with --columns. It is either one of the
+// built-in columns, or the value of a serverStatus field.
+type Column struct {
+	// Name identifies the column: the name of a built-in column, or the
+	// dotted path of a serverStatus field followed by its modifier
+	Name string
+
+	// Header is the text of the column's header
+	Header string
+
+	// Path is the path of the serverStatus field, empty for built-in columns
+	Path []string
+
+	// Modifier is how the field's value is shown, empty for its raw value
+	Modifier string
+}
+
+// builtinColumn is a column that can be chosen by name with --columns.
+type builtinColumn struct {
+	header string
+	value  func(line *StatLine) string
+}
+
+// formatMegabytes formats an amount of memory, blank if not reported.
+func formatMegabytes(amount int64) string {
+	if amount < 0 {
+		return ""
+	}
+	return text.FormatMegabyteAmount(amount)
+}
+
+// formatRatio formats a fraction as a percentage, blank if not reported.
+func formatRatio(ratio float64) string {
+	if ratio < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", ratio*100)
+}
+
+// builtinColumns are the built-in columns, by name.
+var builtinColumns = map[string]builtinColumn{
+	"host": {"host", func(line *StatLine) string {
+		return line.Host
+	}},
+	"insert": {"insert", func(line *StatLine) string {
+		return formatOpcount(line.Insert, line.InsertR, false)
+	}},
+	"query": {"query", func(line *StatLine) string {
+		return formatOpcount(line.Query, line.QueryR, false)
+	}},
+	"update": {"update", func(line *StatLine) string {
+		return formatOpcount(line.Update, line.UpdateR, false)
+	}},
+	"delete": {"delete", func(line *StatLine) string {
+		return formatOpcount(line.Delete, line.DeleteR, false)
+	}},
+	"getmore": {"getmore", func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.GetMore)
+	}},
+	"command": {"command", func(line *StatLine) string {
+		return formatOpcount(line.Command, line.CommandR, true)
+	}},
+	"dirty": {"% dirty", func(line *StatLine) string {
+		return formatRatio(line.CacheDirtyPercent)
+	}},
+	"used": {"% used", func(line *StatLine) string {
+		return formatRatio(line.CacheUsedPercent)
+	}},
+	"flushes": {"flushes", func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.Flushes)
+	}},
+	"mapped": {"mapped", func(line *StatLine) string {
+		if line.Mapped <= 0 {
+			return ""
+		}
+		return text.FormatMegabyteAmount(line.Mapped)
+	}},
+	"vsize": {"vsize", func(line *StatLine) string {
+		return formatMegabytes(line.Virtual)
+	}},
+	"res": {"res", func(line *StatLine) string {
+		return formatMegabytes(line.Resident)
+	}},
+	"non-mapped": {"non-mapped", func(line *StatLine) string {
+		return formatMegabytes(line.NonMapped)
+	}},
+	"faults": {"faults", func(line *StatLine) string {
+		if line.Faults < 0 {
+			return ""
+		}
+		return fmt.Sprintf("%v", line.Faults)
+	}},
+	"locked": {"locked db", func(line *StatLine) string {
+		if line.HighestLocked == nil || line.IsMongos {
+			return ""
+		}
+		return fmt.Sprintf("%v:%.1f%%", line.HighestLocked.DBName, line.HighestLocked.Percentage)
+	}},
+	"qrw": {"qr|qw", func(line *StatLine) string {
+		return fmt.Sprintf("%v|%v", line.QueuedReaders, line.QueuedWriters)
+	}},
+	"arw": {"ar|aw", func(line *StatLine) string {
+		return fmt.Sprintf("%v|%v", line.ActiveReaders, line.ActiveWriters)
+	}},
+	"netIn": {"netIn", func(line *StatLine) string {
+		return text.FormatBits(line.NetIn)
+	}},
+	"netOut": {"netOut", func(line *StatLine) string {
+		return text.FormatBits(line.NetOut)
+	}},
+	"conn": {"conn", func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.NumConnections)
+	}},
+	"set": {"set", func(line *StatLine) string {
+		return line.ReplSetName
+	}},
+	"repl": {"repl", func(line *StatLine) string {
+		return line.NodeType
+	}},
+	"time": {"time", func(line *StatLine) string {
+		return line.Time.Format("2006-01-02T15:04:05Z07:00")
+	}},
+}
+
+// ParseColumns parses the comma-separated columns given with --columns. Each
+// column is the name of a built-in column or the dotted path of a
+// serverStatus field, optionally followed by the .rate() or .diff() modifier,
+// and optionally renamed with =<header>.
+func ParseColumns(spec string) ([]Column, error) {
+	columns := []Column{}
+	headers := map[string]bool{}
+	for _, field := range strings.Split(spec, ",") {
+		column, err := parseColumn(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid column '%v': %v", field, err)
+		}
+		if headers[column.Header] {
+			return nil, fmt.Errorf("duplicate column header '%v'", column.Header)
+		}
+		headers[column.Header] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// parseColumn parses a single column given with --columns.
+func parseColumn(field string) (Column, error) {
+	column := Column{Name: field}
+	if equals := strings.LastIndex(field, "="); equals >= 0 {
+		column.Name = field[:equals]
+		column.Header = field[equals+1:]
+		if column.Header == "" {
+			return column, fmt.Errorf("header can not be empty")
+		}
+	}
+	if column.Name == "" {
+		return column, fmt.Errorf("name can not be empty")
+	}
+	if column.Header == "" {
+		column.Header = column.Name
+	}
+
+	if builtin, ok := builtinColumns[column.Name]; ok {
+		if column.Header == column.Name {
+			column.Header = builtin.header
+		}
+		return column, nil
+	}
+
+	path := column.Name
+	if strings.HasSuffix(path, "()") {
+		dot := strings.LastIndex(path, ".")
+		if dot < 0 {
+			return column, fmt.Errorf("modifier must follow a serverStatus field")
+		}
+		column.Modifier = path[dot+1 : len(path)-2]
+		if column.Modifier != ModifierRate && column.Modifier != ModifierDiff {
+			return column, fmt.Errorf("unknown modifier '%v()', expected 'rate()' or 'diff()'", column.Modifier)
+		}
+		path = path[:dot]
+	}
+	column.Path = strings.Split(path, ".")
+	for _, key := range column.Path {
+		if key == "" {
+			return column, fmt.Errorf("serverStatus field path can not have empty parts")
+		}
+	}
+	return column, nil
+}
+
+// lookup returns the value of the column's serverStatus field in a document,
+// and whether it has one.
+func (column *Column) lookup(document bson.M) (interface{}, bool) {
+	var value interface{} = document
+	for _, key := range column.Path {
+		subdocument, ok := value.(bson.M)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = subdocument[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// formatFieldValue formats the value of a serverStatus field for output.
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// toInt64 returns the value of a serverStatus field as an int64, and whether
+// it is an integer.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// value returns the formatted value of the column's serverStatus field,
+// modified according to its previous value, and whether it has one.
+func (column *Column) value(oldStat, newStat *ServerStatus) (string, bool) {
+	newValue, ok := column.lookup(newStat.Document)
+	if !ok {
+		return "", false
+	}
+	if column.Modifier == "" {
+		return formatFieldValue(newValue), true
+	}
+
+	oldValue, ok := column.lookup(oldStat.Document)
+	if !ok {
+		return "", false
+	}
+	sampleSecs := newStat.SampleTime.Sub(oldStat.SampleTime).Seconds()
+	newInt, newIsInt := toInt64(newValue)
+	oldInt, oldIsInt := toInt64(oldValue)
+	if newIsInt && oldIsInt {
+		if column.Modifier == ModifierRate {
+			return fmt.Sprintf("%v", diff(newInt, oldInt, sampleSecs)), true
+		}
+		return fmt.Sprintf("%v", newInt-oldInt), true
+	}
+
+	newFloat, err := util.ToFloat64(newValue)
+	if err != nil {
+		return "", false
+	}
+	oldFloat, err := util.ToFloat64(oldValue)
+	if err != nil {
+		return "", false
+	}
+	if column.Modifier == ModifierRate {
+		return formatFieldValue((newFloat - oldFloat) / sampleSecs), true
+	}
+	return formatFieldValue(newFloat - oldFloat), true
+}
+
+// setFields sets the values of the serverStatus fields of the columns, for
+// the stat line computed from the two server statuses.
+func (line *StatLine) setFields(columns []Column, oldStat, newStat *ServerStatus) {
+	for i := range columns {
+		column := &columns[i]
+		if column.Path == nil {
+			continue
+		}
+		if line.Fields == nil {
+			line.Fields = map[string]string{}
+		}
+		if value, ok := column.value(oldStat, newStat); ok {
+			line.Fields[column.Name] = value
+		}
+	}
+}
+
+// cell returns the formatted value of the column for a stat line.
+func (column *Column) cell(line *StatLine) string {
+	if column.Path == nil {
+		return builtinColumns[column.Name].value(line)
+	}
+	return line.Fields[column.Name]
+}
+
+// hasFields returns whether any of the columns is a serverStatus field.
+func hasFields(columns []Column) bool {
+	for _, column := range columns {
+		if column.Path != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// csvValues are the plain values of the built-in columns that are formatted
+// for reading rather than as numbers, by name.
+var csvValues = map[string]func(line *StatLine) string{
+	"insert": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.Insert)
+	},
+	"query": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.Query)
+	},
+	"update": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.Update)
+	},
+	"delete": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.Delete)
+	},
+	"command": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.Command)
+	},
+	"mapped":     csvMegabytes(func(line *StatLine) int64 { return line.Mapped }),
+	"vsize":      csvMegabytes(func(line *StatLine) int64 { return line.Virtual }),
+	"res":        csvMegabytes(func(line *StatLine) int64 { return line.Resident }),
+	"non-mapped": csvMegabytes(func(line *StatLine) int64 { return line.NonMapped }),
+	"netIn": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.NetIn)
+	},
+	"netOut": func(line *StatLine) string {
+		return fmt.Sprintf("%v", line.NetOut)
+	},
+}
+
+// csvMegabytes returns the plain value of a memory column, in megabytes.
+func csvMegabytes(amount func(line *StatLine) int64) func(line *StatLine) string {
+	return func(line *StatLine) string {
+		if amount(line) < 0 {
+			return ""
+		}
+		return fmt.Sprintf("%v", amount(line))
+	}
+}
+
+// csvCell returns the plain value of the column for a stat line.
+func (column *Column) csvCell(line *StatLine) string {
+	if csvValue, ok := csvValues[column.Name]; ok {
+		return csvValue(line)
+	}
+	return column.cell(line)
+}
@@ -0,0 +1,131 @@
+package mongostat
+
+import (
+	"encoding/json"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	"github.com/mongodb/mongo-tools/common/text"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseColumns(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Built-in columns should be chosen by name", t, func() {
+		columns, err := ParseColumns("host,qrw,conn=connections")
+		So(err, ShouldBeNil)
+		So(columns, ShouldResemble, []Column{
+			{Name: "host", Header: "host"},
+			{Name: "qrw", Header: "qr|qw"},
+			{Name: "conn", Header: "connections"},
+		})
+	})
+
+	Convey("serverStatus fields should be parsed with their modifiers", t, func() {
+		columns, err := ParseColumns("metrics.document.returned=ret, wiredTiger.cache.pages evicted.rate()=evict,uptime.diff()")
+		So(err, ShouldBeNil)
+		So(columns, ShouldResemble, []Column{
+			{Name: "metrics.document.returned", Header: "ret", Path: []string{"metrics", "document", "returned"}},
+			{
+				Name:     "wiredTiger.cache.pages evicted.rate()",
+				Header:   "evict",
+				Path:     []string{"wiredTiger", "cache", "pages evicted"},
+				Modifier: ModifierRate,
+			},
+			{Name: "uptime.diff()", Header: "uptime.diff()", Path: []string{"uptime"}, Modifier: ModifierDiff},
+		})
+	})
+
+	Convey("Invalid columns should be rejected", t, func() {
+		for _, spec := range []string{"", "insert,", "insert=", "a..b", "a.avg()", "rate()", "conn,conn", "conn,uptime=conn"} {
+			_, err := ParseColumns(spec)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestColumnValues(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With two samples of serverStatus", t, func() {
+		sampleTime := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		oldStat := &ServerStatus{
+			SampleTime: sampleTime,
+			Document: bson.M{
+				"uptime":  1000.5,
+				"metrics": bson.M{"document": bson.M{"returned": int64(100)}},
+				"wiredTiger": bson.M{
+					"cache": bson.M{"pages evicted": int32(10)},
+				},
+			},
+		}
+		newStat := &ServerStatus{
+			SampleTime: sampleTime.Add(2 * time.Second),
+			Document: bson.M{
+				"uptime":  1002.5,
+				"process": "mongod",
+				"metrics": bson.M{"document": bson.M{"returned": int64(150)}},
+				"wiredTiger": bson.M{
+					"cache": bson.M{"pages evicted": int32(30)},
+				},
+			},
+		}
+		columns, err := ParseColumns("metrics.document.returned,metrics.document.returned.diff()=d," +
+			"wiredTiger.cache.pages evicted.rate()=evict,uptime.diff()=up,process,missing.rate(),metrics.missing,conn")
+		So(err, ShouldBeNil)
+		line := &StatLine{NumConnections: 5}
+		line.setFields(columns, oldStat, newStat)
+
+		Convey("raw values, deltas and rates should be computed", func() {
+			So(line.Fields, ShouldResemble, map[string]string{
+				"metrics.document.returned":             "150",
+				"metrics.document.returned.diff()":      "50",
+				"wiredTiger.cache.pages evicted.rate()": "10",
+				"uptime.diff()":                         "2",
+				"process":                               "mongod",
+			})
+			So(columns[7].cell(line), ShouldEqual, "5")
+			So(columns[5].cell(line), ShouldEqual, "")
+		})
+	})
+}
+
+func TestCustomColumnFormatting(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With custom columns", t, func() {
+		columns, err := ParseColumns("insert,metrics.document.returned.rate()=ret")
+		So(err, ShouldBeNil)
+		lines := []StatLine{{
+			Key:    "localhost:27017",
+			Host:   "localhost:27017",
+			Insert: 3,
+			Fields: map[string]string{"metrics.document.returned.rate()": "42"},
+		}}
+
+		Convey("the grid should only have the chosen columns", func() {
+			formatter := &GridLineFormatter{
+				IncludeHeader:  true,
+				HeaderInterval: 10,
+				Writer:         &text.GridWriter{ColumnPadding: 1},
+				Columns:        columns,
+			}
+			out := strings.Split(strings.TrimSpace(formatter.FormatLines(lines, 0, false)), "\n")
+			So(len(out), ShouldEqual, 2)
+			So(strings.Fields(out[0]), ShouldResemble, []string{"insert", "ret"})
+			So(strings.Fields(out[1]), ShouldResemble, []string{"3", "42"})
+		})
+
+		Convey("the JSON output should only have the chosen columns", func() {
+			formatter := &JSONLineFormatter{Columns: columns}
+			out := map[string]map[string]string{}
+			So(json.Unmarshal([]byte(formatter.FormatLines(lines, 0, false)), &out), ShouldBeNil)
+			So(out, ShouldResemble, map[string]map[string]string{
+				"localhost:27017": {"insert": "3", "ret": "42"},
+			})
+		})
+	})
+}
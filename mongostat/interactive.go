@@ -0,0 +1,128 @@
+package mongostat
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/tui"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultInteractiveColumns are the columns of the interactive screen, unless
+// others are chosen.
+const DefaultInteractiveColumns = "insert,query,update,delete,getmore,command,dirty,used,flushes,vsize,res,faults,qrw,arw,netIn,netOut,conn,set,repl"
+
+// columnKinds are how the values of the built-in columns add up over time,
+// by name. Other built-in columns are gauges.
+var columnKinds = map[string]tui.ColumnKind{
+	"insert":  tui.Rate,
+	"query":   tui.Rate,
+	"update":  tui.Rate,
+	"delete":  tui.Rate,
+	"getmore": tui.Rate,
+	"command": tui.Rate,
+	"faults":  tui.Rate,
+	"netIn":   tui.Rate,
+	"netOut":  tui.Rate,
+	"flushes": tui.Delta,
+}
+
+// NewScreen returns an interactive screen of the given columns, highlighting
+// values at or above the thresholds, given by column name.
+func NewScreen(columns []Column, thresholds map[string]float64) (*tui.Screen, error) {
+	screenColumns := make([]tui.Column, len(columns))
+	headers := map[string]string{}
+	for i, column := range columns {
+		screenColumns[i] = tui.Column{Header: column.Header, Kind: columnKinds[column.Name]}
+		switch {
+		case column.Modifier == ModifierRate:
+			screenColumns[i].Kind = tui.Rate
+		case column.Modifier == ModifierDiff:
+			screenColumns[i].Kind = tui.Delta
+		case column.Name == "netIn" || column.Name == "netOut":
+			screenColumns[i].Format = func(total float64) string {
+				return text.FormatBits(int64(total))
+			}
+		}
+		headers[column.Name] = column.Header
+		headers[column.Header] = column.Header
+	}
+
+	screen := tui.NewScreen("mongostat", "host", screenColumns)
+	for name, threshold := range thresholds {
+		header, ok := headers[name]
+		if !ok {
+			return nil, fmt.Errorf("no column '%v' to highlight", name)
+		}
+		screen.Thresholds[header] = threshold
+	}
+	return screen, nil
+}
+
+// numericValue returns the number of a plain value of a column, the sum of
+// the numbers of values such as qr|qw, and whether it is a number.
+func numericValue(value string) (float64, bool) {
+	sum := 0.0
+	for _, part := range strings.Split(value, "|") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		sum += number
+	}
+	return sum, true
+}
+
+// screenRows converts StatLines to the rows of an interactive screen.
+func screenRows(lines []StatLine, columns []Column) []tui.Row {
+	rows := make([]tui.Row, 0, len(lines))
+	for i := range lines {
+		line := &lines[i]
+		row := tui.Row{Key: line.Key}
+		if line.Error != nil {
+			row.Error = line.Error.Error()
+			rows = append(rows, row)
+			continue
+		}
+		for j := range columns {
+			cell := tui.Cell{Text: columns[j].cell(line)}
+			cell.Value, cell.Numeric = numericValue(columns[j].csvCell(line))
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Interactive starts the goroutines that listen for incoming stat data, and
+// show the current state of all the stats collected on an interactive screen
+// at a regular interval, until it is quit. Errors polling the node the user
+// seeded with are shown on the screen rather than ending it, so that the
+// terminal is always restored.
+func (cluster *AsyncClusterMonitor) Interactive(screen *tui.Screen, columns []Column, done chan error, sleep time.Duration, startNode string) {
+	gotFirstStat := make(chan struct{}, 1)
+	failed := make(chan error, 1)
+	go cluster.collect(failed, gotFirstStat, startNode)
+	go func() {
+		screen.SetStatus(fmt.Sprintf("error: %v", <-failed))
+	}()
+
+	go func() {
+		for {
+			time.Sleep(sleep)
+			cluster.mapLock.Lock()
+			lines := make([]StatLine, 0, len(cluster.LastStatLines))
+			for _, stat := range cluster.LastStatLines {
+				lines = append(lines, *stat)
+			}
+			cluster.mapLock.Unlock()
+			screen.Update(screenRows(lines, columns), sleep)
+		}
+	}()
+
+	go func() {
+		done <- screen.Run(os.Stdin, os.Stdout)
+	}()
+}
@@ -0,0 +1,61 @@
+package mongostat
+
+import (
+	"errors"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	"github.com/mongodb/mongo-tools/common/tui"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestInteractiveScreen(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With the columns of an interactive screen", t, func() {
+		columns, err := ParseColumns("insert,qrw,vsize,flushes,metrics.document.returned.rate()=ret,set")
+		So(err, ShouldBeNil)
+
+		Convey("columns should add up over time according to their kind", func() {
+			screen, err := NewScreen(columns, map[string]float64{"qrw": 10, "ret": 5})
+			So(err, ShouldBeNil)
+			kinds := []tui.ColumnKind{}
+			for _, column := range screen.Columns {
+				kinds = append(kinds, column.Kind)
+			}
+			So(kinds, ShouldResemble, []tui.ColumnKind{tui.Rate, tui.Gauge, tui.Gauge, tui.Delta, tui.Rate, tui.Gauge})
+			So(screen.Thresholds, ShouldResemble, map[string]float64{"qr|qw": 10, "ret": 5})
+		})
+
+		Convey("thresholds of unknown columns should be rejected", func() {
+			_, err := NewScreen(columns, map[string]float64{"conn": 10})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("stat lines should be converted to rows of formatted and numeric values", func() {
+			rows := screenRows([]StatLine{
+				{
+					Key:           "a:27017",
+					Insert:        3,
+					QueuedReaders: 1,
+					QueuedWriters: 2,
+					Virtual:       2048,
+					Flushes:       1,
+					ReplSetName:   "rs0",
+					Fields:        map[string]string{"metrics.document.returned.rate()": "7"},
+				},
+				{Key: "b:27017", Error: errors.New("no reachable servers")},
+			}, columns)
+			So(rows, ShouldResemble, []tui.Row{
+				{Key: "a:27017", Cells: []tui.Cell{
+					{Text: "3", Value: 3, Numeric: true},
+					{Text: "1|2", Value: 3, Numeric: true},
+					{Text: "2.0G", Value: 2048, Numeric: true},
+					{Text: "1", Value: 1, Numeric: true},
+					{Text: "7", Value: 7, Numeric: true},
+					{Text: "rs0"},
+				}},
+				{Key: "b:27017", Error: "no reachable servers"},
+			})
+		})
+	})
+}
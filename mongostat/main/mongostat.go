@@ -7,6 +7,7 @@ import (
 	"github.com/mongodb/mongo-tools/common/password"
 	"github.com/mongodb/mongo-tools/common/signals"
 	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/tui"
 	"github.com/mongodb/mongo-tools/common/util"
 	"github.com/mongodb/mongo-tools/mongostat"
 	"os"
@@ -63,31 +64,159 @@ func main() {
 		return
 	}
 
-	if opts.Auth.Username != "" && opts.Auth.Source == "" && !opts.Auth.RequiresExternalDB() {
-		log.Logf(log.Always, "--authenticationDatabase is required when authenticating against a non $external database")
+	if statOpts.Serve != "" && (statOpts.Json || statOpts.Csv || statOpts.NoHeaders || statOpts.RowCount != 0 || statOpts.Columns != "") {
+		log.Logf(log.Always, "--serve can not be used with --json, --csv, --noheaders, --rowcount or --columns")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.Json && statOpts.Csv {
+		log.Logf(log.Always, "--json can not be used with --csv")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.Replay == "" && (statOpts.Speed != 0 || statOpts.Since != "" || statOpts.Until != "") {
+		log.Logf(log.Always, "--speed, --since and --until can only be used with --replay")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.Replay != "" && (statOpts.Record != "" || statOpts.Serve != "") {
+		log.Logf(log.Always, "--replay can not be used with --record or --serve")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.Interactive && (statOpts.Json || statOpts.Csv || statOpts.RowCount != 0 || statOpts.Serve != "" || statOpts.Replay != "") {
+		log.Logf(log.Always, "--interactive can not be used with --json, --csv, --rowcount, --serve or --replay")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.Highlight != "" && !statOpts.Interactive {
+		log.Logf(log.Always, "--highlight can only be used with --interactive")
+		os.Exit(util.ExitBadOptions)
+	}
+	if len(statOpts.Alert) == 0 && (statOpts.For != 1 || statOpts.AlertCommand != "" || statOpts.AlertLog != "" || statOpts.ExitOnAlert) {
+		log.Logf(log.Always, "--for, --alertCommand, --alertLog and --exitOnAlert can only be used with --alert")
+		os.Exit(util.ExitBadOptions)
+	}
+	if len(statOpts.Alert) > 0 && (statOpts.Interactive || statOpts.Replay != "") {
+		log.Logf(log.Always, "--alert can not be used with --interactive or --replay")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.For < 1 {
+		log.Logf(log.Always, "--for must be at least 1")
+		os.Exit(util.ExitBadOptions)
+	}
+	if statOpts.Speed < 0 {
+		log.Logf(log.Always, "--speed can not be negative")
 		os.Exit(util.ExitBadOptions)
 	}
 
-	// we have to check this here, otherwise the user will be prompted
-	// for a password for each discovered node
-	if opts.Auth.ShouldAskForPassword() {
-		opts.Auth.Password = password.Prompt()
+	var columns []mongostat.Column
+	if statOpts.Columns != "" || statOpts.Csv || statOpts.Interactive {
+		spec := statOpts.Columns
+		if spec == "" && statOpts.Interactive {
+			spec = mongostat.DefaultInteractiveColumns
+		} else if spec == "" {
+			spec = mongostat.DefaultCSVColumns
+		}
+		columns, err = mongostat.ParseColumns(spec)
+		if err != nil {
+			log.Logf(log.Always, "error parsing --columns: %v", err)
+			os.Exit(util.ExitBadOptions)
+		}
+	}
+
+	var screen *tui.Screen
+	if statOpts.Interactive {
+		thresholds := map[string]float64{}
+		if statOpts.Highlight != "" {
+			thresholds, err = tui.ParseThresholds(statOpts.Highlight)
+			if err != nil {
+				log.Logf(log.Always, "error parsing --highlight: %v", err)
+				os.Exit(util.ExitBadOptions)
+			}
+		}
+		screen, err = mongostat.NewScreen(columns, thresholds)
+		if err != nil {
+			log.Logf(log.Always, "error parsing --highlight: %v", err)
+			os.Exit(util.ExitBadOptions)
+		}
+	}
+
+	var alerter *mongostat.Alerter
+	if len(statOpts.Alert) > 0 {
+		rules := []*mongostat.AlertRule{}
+		for _, text := range statOpts.Alert {
+			rule, err := mongostat.ParseAlertRule(text)
+			if err != nil {
+				log.Logf(log.Always, "error parsing --alert: %v", err)
+				os.Exit(util.ExitBadOptions)
+			}
+			rules = append(rules, rule)
+		}
+		alerter = mongostat.NewAlerter(rules, statOpts.For)
+		alerter.Command = statOpts.AlertCommand
+		if statOpts.ExitOnAlert {
+			alerter.Fired = make(chan *mongostat.Alert, 1)
+		}
 	}
 
 	var formatter mongostat.LineFormatter
-	if statOpts.Json {
-		formatter = &mongostat.JSONLineFormatter{}
+	if statOpts.Serve != "" {
+		formatter = &mongostat.PrometheusLineFormatter{}
+	} else if statOpts.Json {
+		formatter = &mongostat.JSONLineFormatter{Columns: columns}
+	} else if statOpts.Csv {
+		formatter = &mongostat.CSVLineFormatter{
+			IncludeHeader: !statOpts.NoHeaders,
+			Columns:       columns,
+		}
 	} else {
 		formatter = &mongostat.GridLineFormatter{
 			IncludeHeader:  !statOpts.NoHeaders,
 			HeaderInterval: 10,
 			Writer:         &text.GridWriter{ColumnPadding: 1},
+			Columns:        columns,
 		}
 	}
 
+	if statOpts.Replay != "" {
+		replayer := &mongostat.Replayer{
+			Formatter: formatter,
+			Out:       os.Stdout,
+			All:       statOpts.All,
+			Columns:   columns,
+			Speed:     statOpts.Speed,
+		}
+		if statOpts.Since != "" {
+			replayer.Since, err = time.Parse(time.RFC3339, statOpts.Since)
+			if err != nil {
+				log.Logf(log.Always, "invalid --since time: %v", err)
+				os.Exit(util.ExitBadOptions)
+			}
+		}
+		if statOpts.Until != "" {
+			replayer.Until, err = time.Parse(time.RFC3339, statOpts.Until)
+			if err != nil {
+				log.Logf(log.Always, "invalid --until time: %v", err)
+				os.Exit(util.ExitBadOptions)
+			}
+		}
+		if err = replayer.ReplayFile(statOpts.Replay); err != nil {
+			log.Logf(log.Always, "Failed: %v", err)
+			os.Exit(util.ExitError)
+		}
+		return
+	}
+
+	if opts.Auth.Username != "" && opts.Auth.Source == "" && !opts.Auth.RequiresExternalDB() {
+		log.Logf(log.Always, "--authenticationDatabase is required when authenticating against a non $external database")
+		os.Exit(util.ExitBadOptions)
+	}
+
+	// we have to check this here, otherwise the user will be prompted
+	// for a password for each discovered node
+	if opts.Auth.ShouldAskForPassword() {
+		opts.Auth.Password = password.Prompt()
+	}
+
 	seedHosts := util.CreateConnectionAddrs(opts.Host, opts.Port)
 	var cluster mongostat.ClusterMonitor
-	if statOpts.Discover || len(seedHosts) > 1 {
+	if statOpts.Discover || len(seedHosts) > 1 || statOpts.Serve != "" || statOpts.Interactive {
 		cluster = &mongostat.AsyncClusterMonitor{
 			ReportChan:    make(chan mongostat.StatLine),
 			LastStatLines: map[string]*mongostat.StatLine{},
@@ -115,6 +244,28 @@ func main() {
 		Discovered:    discoverChan,
 		SleepInterval: time.Duration(sleepInterval) * time.Second,
 		Cluster:       cluster,
+		Columns:       columns,
+		Screen:        screen,
+		Alerter:       alerter,
+	}
+
+	if statOpts.AlertLog != "" {
+		alertLog, err := os.OpenFile(statOpts.AlertLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Logf(log.Always, "error opening alert log: %v", err)
+			os.Exit(util.ExitError)
+		}
+		defer alertLog.Close()
+		alerter.Log = alertLog
+	}
+
+	if statOpts.Record != "" {
+		stat.Recorder, err = mongostat.NewRecorder(statOpts.Record)
+		if err != nil {
+			log.Logf(log.Always, "error creating recording: %v", err)
+			os.Exit(util.ExitError)
+		}
+		defer stat.Recorder.Close()
 	}
 
 	for _, v := range seedHosts {
@@ -123,6 +274,11 @@ func main() {
 
 	// kick it off
 	err = stat.Run()
+	if _, ok := err.(*mongostat.AlertError); ok {
+		log.Logf(log.Always, "Exiting: %v", err)
+		alerter.Wait()
+		os.Exit(util.ExitAlert)
+	}
 	if err != nil {
 		log.Logf(log.Always, "Failed: %v", err)
 		os.Exit(util.ExitError)
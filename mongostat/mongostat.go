@@ -6,8 +6,10 @@ import (
 	"github.com/mongodb/mongo-tools/common/db"
 	"github.com/mongodb/mongo-tools/common/log"
 	"github.com/mongodb/mongo-tools/common/options"
+	"github.com/mongodb/mongo-tools/common/tui"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -41,6 +43,19 @@ type MongoStat struct {
 
 	// Internal storage of the name the user seeded with, for error checking.
 	startNode string
+
+	// Custom columns to output, if set with --columns.
+	Columns []Column
+
+	// Recorder to persist the serverStatus samples of all the nodes to, if
+	// set with --record.
+	Recorder *Recorder
+
+	// Screen to show the stats on, if set with --interactive.
+	Screen *tui.Screen
+
+	// Alerter to check the stats of all the nodes against, if set with --alert.
+	Alerter *Alerter
 }
 
 // ConfigShard holds a mapping for the format of shard hosts as they
@@ -59,6 +74,15 @@ type NodeMonitor struct {
 	// Enable/Disable collection of optional fields.
 	All bool
 
+	// Custom columns, whose serverStatus fields are collected.
+	Columns []Column
+
+	// Recorder to persist the serverStatus samples to, if set.
+	Recorder *Recorder
+
+	// Alerter to check the stats against, if set.
+	Alerter *Alerter
+
 	// The previous result of the ServerStatus command used to calculate diffs.
 	LastStatus *ServerStatus
 
@@ -179,27 +203,32 @@ func (cluster *AsyncClusterMonitor) Update(statLine StatLine) {
 	cluster.ReportChan <- statLine
 }
 
+// collect listens for incoming stat data. Once the node the user seeded with
+// is polled for the first time, it signals on gotFirstStat, or sends the
+// error on done if the poll failed.
+func (cluster *AsyncClusterMonitor) collect(done chan error, gotFirstStat chan struct{}, startNode string) {
+	receivedData := false
+	for {
+		newStat := <-cluster.ReportChan
+		cluster.updateHostInfo(newStat)
+
+		// Wait until we get an update from the node the user seeded with
+		if !receivedData && newStat.Key == startNode {
+			receivedData = true
+			if newStat.Error != nil {
+				done <- newStat.Error
+				return
+			}
+			gotFirstStat <- struct{}{}
+		}
+	}
+}
+
 // The Async implementation of Monitor starts the goroutines that listen for incoming stat data,
 // and dump snapshots at a regular interval.
 func (cluster *AsyncClusterMonitor) Monitor(maxRows int, done chan error, sleep time.Duration, startNode string) {
-	receivedData := false
 	gotFirstStat := make(chan struct{})
-	go func() {
-		for {
-			newStat := <-cluster.ReportChan
-			cluster.updateHostInfo(newStat)
-
-			// Wait until we get an update from the node the user seeded with
-			if !receivedData && newStat.Key == startNode {
-				receivedData = true
-				if newStat.Error != nil {
-					done <- newStat.Error
-					return
-				}
-				gotFirstStat <- struct{}{}
-			}
-		}
-	}()
+	go cluster.collect(done, gotFirstStat, startNode)
 
 	go func() {
 		// Wait for the first bit of data to hit the channel before printing anything:
@@ -217,6 +246,36 @@ func (cluster *AsyncClusterMonitor) Monitor(maxRows int, done chan error, sleep
 	}()
 }
 
+// ServeHTTP formats and writes the current state of all the stats collected.
+func (cluster *AsyncClusterMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cluster.mapLock.Lock()
+	lines := make([]StatLine, 0, len(cluster.LastStatLines))
+	for _, stat := range cluster.LastStatLines {
+		lines = append(lines, *stat)
+	}
+	cluster.mapLock.Unlock()
+
+	w.Header().Set("Content-Type", PrometheusContentType)
+	fmt.Fprint(w, cluster.Formatter.FormatLines(lines, 0, true))
+}
+
+// Serve starts the goroutines that listen for incoming stat data, and serve
+// the current state of all the stats collected on /metrics at the given
+// address. An error is sent on done if the server stops.
+func (cluster *AsyncClusterMonitor) Serve(address string, done chan error, startNode string) {
+	// nothing waits for the first stat, since the stats are served as they
+	// come in
+	gotFirstStat := make(chan struct{}, 1)
+	go cluster.collect(done, gotFirstStat, startNode)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", cluster)
+	go func() {
+		log.Logf(log.Always, "serving metrics on http://%v/metrics", address)
+		done <- http.ListenAndServe(address, mux)
+	}()
+}
+
 // NewNodeMonitor copies the same connection settings from an instance of
 // ToolOptions, but monitors fullHost.
 func NewNodeMonitor(opts options.ToolOptions, fullHost string, all bool) (*NodeMonitor, error) {
@@ -263,7 +322,14 @@ func (node *NodeMonitor) Poll(discover chan string, all bool, checkShards bool)
 	s.SetSocketTimeout(0)
 	defer s.Close()
 
-	err = s.DB("admin").Run(bson.D{{"serverStatus", 1}, {"recordStats", 0}}, result)
+	raw := bson.Raw{}
+	err = s.DB("admin").Run(bson.D{{"serverStatus", 1}, {"recordStats", 0}}, &raw)
+	if err == nil {
+		err = raw.Unmarshal(result)
+	}
+	if err == nil && hasFields(node.Columns) {
+		err = raw.Unmarshal(&result.Document)
+	}
 	if err != nil {
 		log.Logf(log.DebugLow, "got error calling serverStatus against server %v", node.host)
 		result = nil
@@ -278,9 +344,16 @@ func (node *NodeMonitor) Poll(discover chan string, all bool, checkShards bool)
 	node.Err = nil
 	result.SampleTime = time.Now()
 
+	if node.Recorder != nil {
+		if err := node.Recorder.Record(node.host, result.SampleTime, raw); err != nil {
+			log.Logf(log.Always, "error recording sample of %v: %v", node.host, err)
+		}
+	}
+
 	var statLine *StatLine
 	if node.LastStatus != nil && result != nil {
 		statLine = NewStatLine(*node.LastStatus, *result, node.host, all)
+		statLine.setFields(node.Columns, node.LastStatus, result)
 	}
 
 	if result.Repl != nil && discover != nil {
@@ -319,6 +392,9 @@ func (node *NodeMonitor) Watch(sleep time.Duration, discover chan string, cluste
 
 			if statLine != nil {
 				log.Logf(log.DebugHigh, "successfully got statline from host: %v", node.host)
+				if node.Alerter != nil {
+					node.Alerter.Check(*statLine)
+				}
 				cluster.Update(*statLine)
 			}
 			time.Sleep(sleep)
@@ -351,6 +427,12 @@ func (mstat *MongoStat) AddNewNode(fullhost string) error {
 		if err != nil {
 			return err
 		}
+		node.Columns = mstat.Columns
+		node.Recorder = mstat.Recorder
+		if mstat.Alerter != nil {
+			node.Columns = append(append([]Column{}, mstat.Columns...), mstat.Alerter.Columns()...)
+			node.Alerter = mstat.Alerter
+		}
 		mstat.Nodes[fullhost] = node
 		node.Watch(mstat.SleepInterval, mstat.Discovered, mstat.Cluster)
 	}
@@ -374,6 +456,25 @@ func (mstat *MongoStat) Run() error {
 
 	// Channel to wait
 	finished := make(chan error)
-	go mstat.Cluster.Monitor(mstat.StatOptions.RowCount, finished, mstat.SleepInterval, mstat.startNode)
+	if mstat.Alerter != nil && mstat.Alerter.Fired != nil {
+		go func() {
+			finished <- &AlertError{<-mstat.Alerter.Fired}
+		}()
+	}
+	if mstat.StatOptions.Serve != "" {
+		cluster, ok := mstat.Cluster.(*AsyncClusterMonitor)
+		if !ok {
+			return fmt.Errorf("--serve requires stats to be collected asynchronously")
+		}
+		cluster.Serve(mstat.StatOptions.Serve, finished, mstat.startNode)
+	} else if mstat.Screen != nil {
+		cluster, ok := mstat.Cluster.(*AsyncClusterMonitor)
+		if !ok {
+			return fmt.Errorf("--interactive requires stats to be collected asynchronously")
+		}
+		cluster.Interactive(mstat.Screen, mstat.Columns, finished, mstat.SleepInterval, mstat.startNode)
+	} else {
+		go mstat.Cluster.Monitor(mstat.StatOptions.RowCount, finished, mstat.SleepInterval, mstat.startNode)
+	}
 	return <-finished
 }
@@ -4,16 +4,33 @@ var Usage = `<options> <polling interval in seconds>
 
 Monitor basic MongoDB server statistics.
 
+With --serve, the statistics of all the monitored hosts are exposed as Prometheus metrics on /metrics instead of being printed.
+
 See http://docs.mongodb.org/manual/reference/program/mongostat/ for more information.`
 
 // StatOptions defines the set of options to use for configuring mongostat.
 type StatOptions struct {
-	NoHeaders bool `long:"noheaders" description:"don't output column names"`
-	RowCount  int  `long:"rowcount" value-name:"<count>" short:"n" description:"number of stats lines to print (0 for indefinite)"`
-	Discover  bool `long:"discover" description:"discover nodes and display stats for all"`
-	Http      bool `long:"http" description:"use HTTP instead of raw db connection"`
-	All       bool `long:"all" description:"all optional fields"`
-	Json      bool `long:"json" description:"output as JSON rather than a formatted table"`
+	NoHeaders    bool     `long:"noheaders" description:"don't output column names"`
+	RowCount     int      `long:"rowcount" value-name:"<count>" short:"n" description:"number of stats lines to print (0 for indefinite)"`
+	Discover     bool     `long:"discover" description:"discover nodes and display stats for all"`
+	Http         bool     `long:"http" description:"use HTTP instead of raw db connection"`
+	All          bool     `long:"all" description:"all optional fields"`
+	Json         bool     `long:"json" description:"output as JSON rather than a formatted table"`
+	Columns      string   `long:"columns" short:"o" value-name:"<column>[=<header>],..." description:"comma-separated columns to show, in order: built-in columns (host, insert, query, update, delete, getmore, command, dirty, used, flushes, mapped, vsize, res, non-mapped, faults, locked, qrw, arw, netIn, netOut, conn, set, repl, time) or dotted serverStatus fields, optionally followed by .rate() or .diff(), e.g. 'insert,metrics.document.returned.rate()=ret'"`
+	Csv          bool     `long:"csv" description:"output as CSV rather than a formatted table, with plain numbers and the columns chosen with --columns"`
+	Record       string   `long:"record" value-name:"<filename>" description:"record the serverStatus samples of all the monitored hosts to a compressed file, to be replayed with --replay"`
+	Replay       string   `long:"replay" value-name:"<filename>" description:"replay the stats of a file recorded with --record, rather than monitoring hosts"`
+	Speed        float64  `long:"speed" value-name:"<factor>" description:"speed of --replay relative to the recording, e.g. 1 for real time (default: as fast as possible)"`
+	Since        string   `long:"since" value-name:"<time>" description:"only replay the stats sampled from this RFC 3339 time, e.g. 2016-01-02T15:04:05Z"`
+	Until        string   `long:"until" value-name:"<time>" description:"only replay the stats sampled until this RFC 3339 time"`
+	Interactive  bool     `long:"interactive" description:"show the stats on an interactive full-screen table, a row per host updated in place, whose rows can be sorted, filtered and paused"`
+	Highlight    string   `long:"highlight" value-name:"<column>=<value>,..." description:"with --interactive, highlight the values of columns at or above these thresholds, e.g. 'qrw=50,conn=5000'"`
+	Serve        string   `long:"serve" value-name:"<host>:<port>" description:"serve the stats of all the monitored hosts as Prometheus metrics on /metrics at this address (e.g. ':9216') rather than printing them"`
+	Alert        []string `long:"alert" value-name:"<column><operator><value>" description:"alert when a column of the stats of a host meets a condition, e.g. 'qrw>50', 'dirty>20%' or 'metrics.cursor.timedOut.rate()>0', with columns as in --columns and the operators >, >=, <, <=, == and != (may be specified multiple times)"`
+	For          int      `long:"for" value-name:"<samples>" default:"1" default-mask:"-" description:"number of consecutive samples of a host meeting the condition of an alert for it to fire (1 by default)"`
+	AlertCommand string   `long:"alertCommand" value-name:"<command>" description:"shell command to run when an alert fires, with the alert in the MONGOSTAT_ALERT_HOST, MONGOSTAT_ALERT_RULE, MONGOSTAT_ALERT_VALUE and MONGOSTAT_ALERT_TIME environment variables; it runs in the background, is killed after 30 seconds and isn't run again for an alert until it exits"`
+	AlertLog     string   `long:"alertLog" value-name:"<filename>" description:"append each alert fired to this file, as a line of JSON"`
+	ExitOnAlert  bool     `long:"exitOnAlert" description:"exit with code 5 when the first alert fires"`
 }
 
 // Name returns a human-readable group name for mongostat options.
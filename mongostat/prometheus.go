@@ -0,0 +1,203 @@
+package mongostat
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PrometheusContentType is the content type of the Prometheus text format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Implementation of LineFormatter - converts the StatLines to metrics in the
+// Prometheus text format, with one series per host for each metric.
+type PrometheusLineFormatter struct{}
+
+// prometheusMetric is a metric exported for each host, of the StatLines
+// that have a value for it.
+type prometheusMetric struct {
+	name string
+	help string
+
+	// label, if set, is the name of the label telling apart the values of
+	// the metric in labelValues
+	label       string
+	labelValues []string
+
+	// values returns the values of the metric for a StatLine, in the order
+	// of labelValues, and whether the StatLine has them
+	values func(line *StatLine) ([]float64, bool)
+}
+
+// prometheusMetrics are the metrics exported for successfully polled hosts.
+var prometheusMetrics = []prometheusMetric{
+	{
+		name:        "mongostat_opcounters_per_second",
+		help:        "Operations per second, by type.",
+		label:       "type",
+		labelValues: []string{"insert", "query", "update", "delete", "getmore", "command"},
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.Insert, line.Query, line.Update, line.Delete, line.GetMore, line.Command), true
+		},
+	},
+	{
+		name:        "mongostat_opcounters_repl_per_second",
+		help:        "Replicated operations per second, by type.",
+		label:       "type",
+		labelValues: []string{"insert", "query", "update", "delete", "getmore", "command"},
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.InsertR, line.QueryR, line.UpdateR, line.DeleteR, line.GetMoreR, line.CommandR), true
+		},
+	},
+	{
+		name:        "mongostat_queued_operations",
+		help:        "Operations queued waiting for a lock, by type.",
+		label:       "type",
+		labelValues: []string{"read", "write"},
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.QueuedReaders, line.QueuedWriters), true
+		},
+	},
+	{
+		name:        "mongostat_active_clients",
+		help:        "Clients performing operations, by type.",
+		label:       "type",
+		labelValues: []string{"read", "write"},
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.ActiveReaders, line.ActiveWriters), true
+		},
+	},
+	{
+		name: "mongostat_connections",
+		help: "Open connections.",
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.NumConnections), true
+		},
+	},
+	{
+		name:        "mongostat_network_bytes_per_second",
+		help:        "Network traffic in bytes per second, by direction.",
+		label:       "direction",
+		labelValues: []string{"in", "out"},
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.NetIn, line.NetOut), true
+		},
+	},
+	{
+		name: "mongostat_flushes",
+		help: "Flushes to disk, or WiredTiger checkpoints, during the last polling interval.",
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.Flushes), true
+		},
+	},
+	{
+		name: "mongostat_page_faults_per_second",
+		help: "Page faults per second.",
+		values: func(line *StatLine) ([]float64, bool) {
+			return floats(line.Faults), line.Faults >= 0
+		},
+	},
+	{
+		name: "mongostat_cache_dirty_ratio",
+		help: "Fraction of the WiredTiger cache holding dirty data.",
+		values: func(line *StatLine) ([]float64, bool) {
+			return []float64{line.CacheDirtyPercent}, line.CacheDirtyPercent >= 0
+		},
+	},
+	{
+		name: "mongostat_cache_used_ratio",
+		help: "Fraction of the WiredTiger cache in use.",
+		values: func(line *StatLine) ([]float64, bool) {
+			return []float64{line.CacheUsedPercent}, line.CacheUsedPercent >= 0
+		},
+	},
+	{
+		name:        "mongostat_memory_bytes",
+		help:        "Memory used by the process, by type.",
+		label:       "type",
+		labelValues: []string{"virtual", "resident"},
+		values: func(line *StatLine) ([]float64, bool) {
+			// memory is reported in megabytes
+			return []float64{float64(line.Virtual) * 1024 * 1024, float64(line.Resident) * 1024 * 1024},
+				line.Virtual >= 0 && line.Resident >= 0
+		},
+	},
+	{
+		name: "mongostat_mapped_bytes",
+		help: "Memory mapped by the MMAPv1 storage engine.",
+		values: func(line *StatLine) ([]float64, bool) {
+			return []float64{float64(line.Mapped) * 1024 * 1024}, line.Mapped > 0
+		},
+	},
+}
+
+func floats(values ...int64) []float64 {
+	result := make([]float64, len(values))
+	for i, value := range values {
+		result[i] = float64(value)
+	}
+	return result
+}
+
+// escapeLabelValue escapes a label value of the Prometheus text format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writeHeader writes the HELP and TYPE lines of a gauge.
+func writeHeader(buf *bytes.Buffer, name, help string) {
+	fmt.Fprintf(buf, "# HELP %v %v\n# TYPE %v gauge\n", name, help, name)
+}
+
+// Satisfy the LineFormatter interface. Formats the StatLines as Prometheus
+// metrics, labelled by host.
+func (plf *PrometheusLineFormatter) FormatLines(lines []StatLine, index int, discover bool) string {
+	buf := &bytes.Buffer{}
+
+	// Sort the stat lines by hostname, so that series are always in the
+	// same order
+	sort.Sort(StatLines(lines))
+
+	writeHeader(buf, "mongostat_up", "Whether the last poll of the host succeeded.")
+	for _, line := range lines {
+		up := 1
+		if line.Error != nil {
+			up = 0
+		}
+		fmt.Fprintf(buf, "mongostat_up{host=\"%v\"} %v\n", escapeLabelValue(line.Key), up)
+	}
+
+	writeHeader(buf, "mongostat_node_info", "Storage engine, replica set and role of the host.")
+	for _, line := range lines {
+		if line.Error != nil {
+			continue
+		}
+		fmt.Fprintf(buf, "mongostat_node_info{host=\"%v\",storage_engine=\"%v\",set=\"%v\",repl=\"%v\"} 1\n",
+			escapeLabelValue(line.Key), escapeLabelValue(line.StorageEngine),
+			escapeLabelValue(line.ReplSetName), escapeLabelValue(line.NodeType))
+	}
+
+	for _, metric := range prometheusMetrics {
+		writeHeader(buf, metric.name, metric.help)
+		for i := range lines {
+			line := &lines[i]
+			if line.Error != nil {
+				continue
+			}
+			values, ok := metric.values(line)
+			if !ok {
+				continue
+			}
+			host := escapeLabelValue(line.Key)
+			if metric.label == "" {
+				fmt.Fprintf(buf, "%v{host=\"%v\"} %v\n", metric.name, host, values[0])
+				continue
+			}
+			for j, labelValue := range metric.labelValues {
+				fmt.Fprintf(buf, "%v{host=\"%v\",%v=\"%v\"} %v\n", metric.name, host, metric.label, labelValue, values[j])
+			}
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,119 @@
+package mongostat
+
+import (
+	"errors"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// series returns the lines of formatted metrics that aren't comments.
+func series(out string) []string {
+	var result []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func TestPrometheusLineFormatter(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With stat lines of several hosts", t, func() {
+		lines := []StatLine{
+			{
+				Key:               "b:27017",
+				Host:              "b:27017",
+				StorageEngine:     "wiredTiger",
+				ReplSetName:       "rs0",
+				NodeType:          "PRI",
+				Insert:            10,
+				InsertR:           1,
+				Command:           3,
+				QueuedReaders:     2,
+				ActiveWriters:     4,
+				NumConnections:    5,
+				NetIn:             100,
+				NetOut:            200,
+				CacheDirtyPercent: 0.25,
+				CacheUsedPercent:  0.5,
+				Faults:            -1,
+				Virtual:           2,
+				Resident:          1,
+				Mapped:            -1,
+			},
+			{Key: "a:27017", Host: "a:27017", Error: errors.New("no reachable servers")},
+		}
+		out := (&PrometheusLineFormatter{}).FormatLines(lines, 0, true)
+
+		Convey("each metric should be described as a gauge", func() {
+			So(out, ShouldStartWith, "# HELP mongostat_up ")
+			So(out, ShouldContainSubstring, "# TYPE mongostat_connections gauge\n")
+		})
+
+		Convey("every host should have a series, labelled and in order", func() {
+			So(out, ShouldContainSubstring, "mongostat_up{host=\"a:27017\"} 0\nmongostat_up{host=\"b:27017\"} 1\n")
+			So(out, ShouldContainSubstring,
+				"mongostat_node_info{host=\"b:27017\",storage_engine=\"wiredTiger\",set=\"rs0\",repl=\"PRI\"} 1\n")
+			So(out, ShouldContainSubstring, "mongostat_opcounters_per_second{host=\"b:27017\",type=\"insert\"} 10\n")
+			So(out, ShouldContainSubstring, "mongostat_opcounters_per_second{host=\"b:27017\",type=\"command\"} 3\n")
+			So(out, ShouldContainSubstring, "mongostat_opcounters_repl_per_second{host=\"b:27017\",type=\"insert\"} 1\n")
+			So(out, ShouldContainSubstring, "mongostat_queued_operations{host=\"b:27017\",type=\"read\"} 2\n")
+			So(out, ShouldContainSubstring, "mongostat_active_clients{host=\"b:27017\",type=\"write\"} 4\n")
+			So(out, ShouldContainSubstring, "mongostat_connections{host=\"b:27017\"} 5\n")
+			So(out, ShouldContainSubstring, "mongostat_network_bytes_per_second{host=\"b:27017\",direction=\"out\"} 200\n")
+			So(out, ShouldContainSubstring, "mongostat_cache_dirty_ratio{host=\"b:27017\"} 0.25\n")
+			So(out, ShouldContainSubstring, "mongostat_memory_bytes{host=\"b:27017\",type=\"virtual\"} 2.097152e+06\n")
+		})
+
+		Convey("hosts that failed to be polled should only have an up series", func() {
+			for _, line := range series(out) {
+				if strings.Contains(line, "a:27017") {
+					So(line, ShouldStartWith, "mongostat_up{")
+				}
+			}
+		})
+
+		Convey("stats missing from the server status should be left out", func() {
+			So(out, ShouldNotContainSubstring, "mongostat_page_faults_per_second{")
+			So(out, ShouldNotContainSubstring, "mongostat_mapped_bytes{")
+		})
+	})
+
+	Convey("Label values should be escaped", t, func() {
+		So(escapeLabelValue("a\"b\\c\nd"), ShouldEqual, `a\"b\\c\nd`)
+	})
+}
+
+func TestServeMetrics(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With an asynchronous cluster monitor", t, func() {
+		cluster := &AsyncClusterMonitor{
+			ReportChan:    make(chan StatLine),
+			LastStatLines: map[string]*StatLine{},
+			Formatter:     &PrometheusLineFormatter{},
+		}
+		cluster.updateHostInfo(StatLine{Key: "a:27017", NumConnections: 3})
+		cluster.updateHostInfo(StatLine{Key: "b:27017", NumConnections: 4})
+
+		Convey("the latest stats of every host should be served", func() {
+			recorder := httptest.NewRecorder()
+			cluster.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			So(recorder.Code, ShouldEqual, 200)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, PrometheusContentType)
+			body := recorder.Body.String()
+			So(body, ShouldContainSubstring, "mongostat_connections{host=\"a:27017\"} 3\n")
+			So(body, ShouldContainSubstring, "mongostat_connections{host=\"b:27017\"} 4\n")
+
+			cluster.updateHostInfo(StatLine{Key: "b:27017", NumConnections: 6})
+			recorder = httptest.NewRecorder()
+			cluster.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			So(recorder.Body.String(), ShouldContainSubstring, "mongostat_connections{host=\"b:27017\"} 6\n")
+		})
+	})
+}
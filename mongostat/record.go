@@ -0,0 +1,187 @@
+package mongostat
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/db"
+	"github.com/mongodb/mongo-tools/common/log"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// recordedSample is a serverStatus sample of a host, as stored in recordings.
+type recordedSample struct {
+	Host   string    `bson:"host"`
+	Time   time.Time `bson:"time"`
+	Status bson.Raw  `bson:"status"`
+}
+
+// Recorder persists the serverStatus samples of the monitored hosts to a
+// gzip-compressed stream of BSON documents, to be replayed later.
+type Recorder struct {
+	out  io.WriteCloser
+	gzip *gzip.Writer
+
+	// Mutex to handle samples of several hosts being recorded concurrently.
+	lock sync.Mutex
+}
+
+// NewRecorder creates the recording file at the given path.
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return newRecorder(file), nil
+}
+
+func newRecorder(out io.WriteCloser) *Recorder {
+	return &Recorder{out: out, gzip: gzip.NewWriter(out)}
+}
+
+// Record persists a serverStatus sample of a host. Samples are flushed as they
+// are recorded, so that a recording is readable even if mongostat is killed.
+func (recorder *Recorder) Record(host string, sampleTime time.Time, status bson.Raw) error {
+	data, err := bson.Marshal(recordedSample{host, sampleTime, status})
+	if err != nil {
+		return err
+	}
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	if _, err = recorder.gzip.Write(data); err != nil {
+		return err
+	}
+	return recorder.gzip.Flush()
+}
+
+// Close finishes the recording.
+func (recorder *Recorder) Close() error {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	if err := recorder.gzip.Close(); err != nil {
+		recorder.out.Close()
+		return err
+	}
+	return recorder.out.Close()
+}
+
+// Replayer computes the stat lines of the samples of a recording, as they
+// would have been when they were recorded, and writes them with a formatter.
+type Replayer struct {
+	// Used to format the StatLines for writing
+	Formatter LineFormatter
+
+	// Where to write the formatted StatLines
+	Out io.Writer
+
+	// Enable/Disable computing of optional fields
+	All bool
+
+	// Custom columns, whose serverStatus fields are computed
+	Columns []Column
+
+	// Speed of the replay relative to the recording, as fast as possible if 0
+	Speed float64
+
+	// Only the stats of samples from Since (if set) until Until (if set) are
+	// written
+	Since, Until time.Time
+
+	// Wait for the given duration between samples
+	sleep func(time.Duration)
+}
+
+// ReplayFile replays the recording at the given path.
+func (replayer *Replayer) ReplayFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return replayer.Replay(file)
+}
+
+// Replay replays a recording. Stats of several hosts are written together,
+// a block per polling round.
+func (replayer *Replayer) Replay(in io.Reader) error {
+	gzipReader, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("error reading recording: %v", err)
+	}
+	source := db.NewDecodedBSONSource(db.NewBufferlessBSONSource(gzipReader))
+	defer source.Close()
+
+	sleep := replayer.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	lastStatus := map[string]*ServerStatus{}
+	pending := map[string]StatLine{}
+	index := 0
+	writePending := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		lines := make([]StatLine, 0, len(pending))
+		for _, line := range pending {
+			lines = append(lines, line)
+		}
+		sort.Sort(StatLines(lines))
+		_, err := io.WriteString(replayer.Out, replayer.Formatter.FormatLines(lines, index, len(lastStatus) > 1))
+		index++
+		pending = map[string]StatLine{}
+		return err
+	}
+
+	var lastTime time.Time
+	sample := recordedSample{}
+	for source.Next(&sample) {
+		if !replayer.Until.IsZero() && sample.Time.After(replayer.Until) {
+			break
+		}
+		status := &ServerStatus{}
+		if err = sample.Status.Unmarshal(status); err != nil {
+			return fmt.Errorf("error reading sample of %v at %v: %v", sample.Host, sample.Time, err)
+		}
+		if hasFields(replayer.Columns) {
+			if err = sample.Status.Unmarshal(&status.Document); err != nil {
+				return fmt.Errorf("error reading sample of %v at %v: %v", sample.Host, sample.Time, err)
+			}
+		}
+		status.SampleTime = sample.Time
+
+		oldStatus := lastStatus[sample.Host]
+		lastStatus[sample.Host] = status
+		if oldStatus == nil || sample.Time.Before(replayer.Since) {
+			continue
+		}
+
+		if replayer.Speed > 0 && !lastTime.IsZero() {
+			sleep(time.Duration(float64(sample.Time.Sub(lastTime)) / replayer.Speed))
+		}
+		lastTime = sample.Time
+
+		// a host sampled again starts a new polling round
+		if _, ok := pending[sample.Host]; ok {
+			if err = writePending(); err != nil {
+				return err
+			}
+		}
+		line := NewStatLine(*oldStatus, *status, sample.Host, replayer.All)
+		line.setFields(replayer.Columns, oldStatus, status)
+		pending[sample.Host] = *line
+	}
+
+	if err = source.Err(); err == io.ErrUnexpectedEOF {
+		// the recording wasn't closed, but all its samples were flushed
+		log.Logf(log.DebugLow, "recording ends without being closed")
+	} else if err != nil {
+		return fmt.Errorf("error reading recording: %v", err)
+	}
+	return writePending()
+}
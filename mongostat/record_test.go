@@ -0,0 +1,120 @@
+package mongostat
+
+import (
+	"bytes"
+	"github.com/mongodb/mongo-tools/common/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nopCloser is a bytes.Buffer with a no-op Close.
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// statusSample returns a serverStatus document with the given insert count.
+func statusSample(host string, inserts int64) bson.Raw {
+	data, err := bson.Marshal(bson.M{
+		"host":        host,
+		"process":     "mongod",
+		"opcounters":  bson.M{"insert": inserts},
+		"mem":         bson.M{"supported": true, "resident": int64(10), "virtual": int64(20)},
+		"connections": bson.M{"current": int64(3)},
+		"metrics":     bson.M{"document": bson.M{"inserted": inserts}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return bson.Raw{Kind: 0x03, Data: data}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("With a recording of two hosts", t, func() {
+		start := time.Date(2016, 1, 2, 15, 4, 0, 0, time.UTC)
+		buf := &bytes.Buffer{}
+		recorder := newRecorder(nopCloser{buf})
+		for i := 0; i < 4; i++ {
+			sampleTime := start.Add(time.Duration(i) * time.Second)
+			So(recorder.Record("a:27017", sampleTime, statusSample("a", int64(i*10))), ShouldBeNil)
+			So(recorder.Record("b:27017", sampleTime, statusSample("b", int64(i*20))), ShouldBeNil)
+		}
+		unclosed := append([]byte{}, buf.Bytes()...)
+		So(recorder.Close(), ShouldBeNil)
+
+		columns, err := ParseColumns("insert,conn,metrics.document.inserted.diff()=ins")
+		So(err, ShouldBeNil)
+		out := &bytes.Buffer{}
+		replayer := &Replayer{
+			Formatter: &CSVLineFormatter{IncludeHeader: true, Columns: columns},
+			Out:       out,
+			Columns:   columns,
+		}
+
+		Convey("the stats of every polling round should be replayed", func() {
+			So(replayer.Replay(bytes.NewReader(buf.Bytes())), ShouldBeNil)
+			So(out.String(), ShouldEqual, "host,insert,conn,ins\n"+
+				"a:27017,10,3,10\nb:27017,20,3,20\n"+
+				"a:27017,10,3,10\nb:27017,20,3,20\n"+
+				"a:27017,10,3,10\nb:27017,20,3,20\n")
+		})
+
+		Convey("only the stats of the selected time range should be replayed", func() {
+			replayer.Since = start.Add(2 * time.Second)
+			replayer.Until = start.Add(2 * time.Second)
+			So(replayer.Replay(bytes.NewReader(buf.Bytes())), ShouldBeNil)
+			So(strings.Count(out.String(), "\n"), ShouldEqual, 3)
+		})
+
+		Convey("the replay should wait between samples according to its speed", func() {
+			var waits []time.Duration
+			replayer.Speed = 2
+			replayer.sleep = func(wait time.Duration) {
+				waits = append(waits, wait)
+			}
+			So(replayer.Replay(bytes.NewReader(buf.Bytes())), ShouldBeNil)
+			So(waits, ShouldResemble, []time.Duration{
+				0, 500 * time.Millisecond, 0, 500 * time.Millisecond, 0,
+			})
+		})
+
+		Convey("a recording that wasn't closed should be replayed", func() {
+			So(replayer.Replay(bytes.NewReader(unclosed)), ShouldBeNil)
+			So(strings.Count(out.String(), "\n"), ShouldEqual, 7)
+		})
+
+		Convey("a recording that isn't compressed should be rejected", func() {
+			So(replayer.Replay(strings.NewReader("not a recording")), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Recordings should be written to files", t, func() {
+		file, err := ioutil.TempFile("", "mongostat")
+		So(err, ShouldBeNil)
+		defer os.Remove(file.Name())
+		file.Close()
+		recorder, err := NewRecorder(file.Name())
+		So(err, ShouldBeNil)
+		start := time.Date(2016, 1, 2, 15, 4, 0, 0, time.UTC)
+		So(recorder.Record("a:27017", start, statusSample("a", 0)), ShouldBeNil)
+		So(recorder.Record("a:27017", start.Add(time.Second), statusSample("a", 5)), ShouldBeNil)
+		So(recorder.Close(), ShouldBeNil)
+
+		columns, err := ParseColumns(DefaultCSVColumns)
+		So(err, ShouldBeNil)
+		out := &bytes.Buffer{}
+		replayer := &Replayer{Formatter: &CSVLineFormatter{Columns: columns}, Out: out}
+		So(replayer.ReplayFile(file.Name()), ShouldBeNil)
+		So(out.String(), ShouldStartWith, "a:27017,5,0,0,0,0,0,,,0,20,10,")
+	})
+}
@@ -2,10 +2,12 @@ package mongostat
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"github.com/mongodb/mongo-tools/common/text"
 	"github.com/mongodb/mongo-tools/common/util"
+	"gopkg.in/mgo.v2/bson"
 	"sort"
 	"strings"
 	"time"
@@ -69,6 +71,10 @@ type ServerStatus struct {
 	ShardCursorType    map[string]interface{} `bson:"shardCursorType"`
 	StorageEngine      map[string]string      `bson:"storageEngine"`
 	WiredTiger         *WiredTiger            `bson:"wiredTiger"`
+
+	// Document is the whole serverStatus document, only kept when custom
+	// columns show its fields.
+	Document bson.M `bson:"-"`
 }
 
 // WiredTiger stores information related to the WiredTiger storage engine.
@@ -366,6 +372,10 @@ type StatLine struct {
 	NumConnections                                        int64
 	ReplSetName                                           string
 	NodeType                                              string
+
+	// Formatted values of the serverStatus fields shown in custom columns,
+	// by column name
+	Fields map[string]string
 }
 
 func parseLocks(stat ServerStatus) map[string]LockUsage {
@@ -425,7 +435,10 @@ type LineFormatter interface {
 }
 
 // Implementation of LineFormatter - converts the StatLines to JSON.
-type JSONLineFormatter struct{}
+type JSONLineFormatter struct {
+	// Columns, if set, are the only columns output, instead of the default ones
+	Columns []Column
+}
 
 // Satisfy the LineFormatter interface. Formats the StatLines as JSON.
 func (jlf *JSONLineFormatter) FormatLines(lines []StatLine, index int, discover bool) string {
@@ -447,6 +460,14 @@ func (jlf *JSONLineFormatter) FormatLines(lines []StatLine, index int, discover
 			continue
 		}
 
+		if len(jlf.Columns) > 0 {
+			for i := range jlf.Columns {
+				lineJson[jlf.Columns[i].Header] = jlf.Columns[i].cell(&line)
+			}
+			jsonFormat[line.Host] = lineJson
+			continue
+		}
+
 		// put all the appropriate values into the stat line's JSON representation
 		lineJson["insert"] = formatOpcount(line.Insert, line.InsertR, false)
 		lineJson["query"] = formatOpcount(line.Query, line.QueryR, false)
@@ -519,6 +540,54 @@ func (jlf *JSONLineFormatter) FormatLines(lines []StatLine, index int, discover
 	return string(linesAsJsonBytes) + "\n"
 }
 
+// DefaultCSVColumns are the columns written by CSVLineFormatter, unless
+// others are chosen.
+const DefaultCSVColumns = "insert,query,update,delete,getmore,command,dirty,used,flushes,vsize,res,faults,qrw,arw,netIn,netOut,conn,set,repl,time"
+
+// Implementation of LineFormatter - converts the StatLines to CSV, with a
+// row per host led by its name, and plain numbers rather than amounts
+// formatted for reading.
+type CSVLineFormatter struct {
+	// If true, the header row is written before the first rows
+	IncludeHeader bool
+
+	// Columns written after the host column
+	Columns []Column
+}
+
+// Satisfy the LineFormatter interface. Formats the StatLines as CSV rows.
+func (clf *CSVLineFormatter) FormatLines(lines []StatLine, index int, discover bool) string {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+
+	if clf.IncludeHeader && index == 0 {
+		row := []string{"host"}
+		for i := range clf.Columns {
+			row = append(row, clf.Columns[i].Header)
+		}
+		writer.Write(row)
+	}
+
+	// Sort the stat lines by hostname, so that rows are always in the same
+	// order
+	sort.Sort(StatLines(lines))
+	for _, line := range lines {
+		if line.Error != nil {
+			continue
+		}
+		row := []string{line.Key}
+		for i := range clf.Columns {
+			row = append(row, clf.Columns[i].csvCell(&line))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Sprintf("csv error: %v\n", err)
+	}
+	return buf.String()
+}
+
 // Implementation of LineFormatter - uses a common/text.GridWriter to format
 // the StatLines as a grid.
 type GridLineFormatter struct {
@@ -530,6 +599,9 @@ type GridLineFormatter struct {
 
 	// Grid writer
 	Writer *text.GridWriter
+
+	// Columns, if set, are the only columns output, instead of the default ones
+	Columns []Column
 }
 
 // describes which sets of columns are printable in a StatLine
@@ -563,8 +635,6 @@ func getLineFlags(lines []StatLine) int {
 
 // Satisfy the LineFormatter interface. Formats the StatLines as a grid.
 func (glf *GridLineFormatter) FormatLines(lines []StatLine, index int, discover bool) string {
-	buf := &bytes.Buffer{}
-
 	// Automatically turn on discover-style formatting if more than one host's
 	// output is being displayed (to include things like hostname column)
 	discover = discover || len(lines) > 1
@@ -579,6 +649,11 @@ func (glf *GridLineFormatter) FormatLines(lines []StatLine, index int, discover
 	// in the same order for each snapshot
 	sort.Sort(StatLines(lines))
 
+	if len(glf.Columns) > 0 {
+		glf.writeColumns(lines, discover)
+		return glf.flush(lines, index)
+	}
+
 	// Print the columns that are enabled
 	for _, header := range StatHeaders {
 		maskedAttrs := lineFlags & header.ActivateFlags
@@ -708,6 +783,34 @@ func (glf *GridLineFormatter) FormatLines(lines []StatLine, index int, discover
 		glf.Writer.WriteCell(fmt.Sprintf("%v", line.Time.Format("2006-01-02T15:04:05Z07:00")))
 		glf.Writer.EndRow()
 	}
+	return glf.flush(lines, index)
+}
+
+// writeColumns writes the headers and the values of the custom columns.
+func (glf *GridLineFormatter) writeColumns(lines []StatLine, discover bool) {
+	for i := range glf.Columns {
+		glf.Writer.WriteCell(glf.Columns[i].Header)
+	}
+	glf.Writer.EndRow()
+
+	for _, line := range lines {
+		if discover {
+			glf.Writer.WriteCell(line.Key)
+		}
+		if line.Error != nil {
+			glf.Writer.Feed(line.Error.Error())
+			continue
+		}
+		for i := range glf.Columns {
+			glf.Writer.WriteCell(glf.Columns[i].cell(&line))
+		}
+		glf.Writer.EndRow()
+	}
+}
+
+// flush returns the formatted grid of the stat lines, and clears it.
+func (glf *GridLineFormatter) flush(lines []StatLine, index int) string {
+	buf := &bytes.Buffer{}
 	glf.Writer.Flush(buf)
 
 	// clear the flushed data
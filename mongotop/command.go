@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/mongodb/mongo-tools/common/text"
+	"github.com/mongodb/mongo-tools/common/tui"
 	"sort"
 	"time"
 )
@@ -16,6 +17,8 @@ type FormattableDiff interface {
 	JSON() string
 	// Generate a table-like representation which can be printed to a terminal
 	Grid() string
+	// Generate the rows of an interactive screen, a row per namespace or database
+	Rows() []tui.Row
 }
 
 // ServerStatus represents the results of the "serverStatus" command.
@@ -157,6 +160,19 @@ func (td TopDiff) Grid() string {
 	return buf.String()
 }
 
+// Rows returns the rows of an interactive screen of the TopDiff.
+func (td TopDiff) Rows() []tui.Row {
+	rows := make([]tui.Row, 0, len(td.Totals))
+	for ns, diff := range td.Totals {
+		rows = append(rows, tui.Row{Key: ns, Cells: []tui.Cell{
+			millisCell(int64(diff.Total.Time)),
+			millisCell(int64(diff.Read.Time)),
+			millisCell(int64(diff.Write.Time)),
+		}})
+	}
+	return rows
+}
+
 // JSON returns a JSON representation of the TopDiff.
 func (td TopDiff) JSON() string {
 	bytes, err := json.Marshal(td)
@@ -206,6 +222,19 @@ func (ssd ServerStatusDiff) Grid() string {
 	return buf.String()
 }
 
+// Rows returns the rows of an interactive screen of the ServerStatusDiff.
+func (ssd ServerStatusDiff) Rows() []tui.Row {
+	rows := make([]tui.Row, 0, len(ssd.Totals))
+	for ns, diff := range ssd.Totals {
+		rows = append(rows, tui.Row{Key: ns, Cells: []tui.Cell{
+			millisCell(diff.Read + diff.Write),
+			millisCell(diff.Read),
+			millisCell(diff.Write),
+		}})
+	}
+	return rows
+}
+
 // Diff takes an older ServerStatus sample, and produces a ServerStatusDiff
 // representing the deltas of each metric between the two samples.
 func (ss ServerStatus) Diff(previous ServerStatus) ServerStatusDiff {
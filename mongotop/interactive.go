@@ -0,0 +1,63 @@
+package mongotop
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/tui"
+	"os"
+	"time"
+)
+
+// formatMillis formats an amount of time in milliseconds.
+func formatMillis(millis float64) string {
+	return fmt.Sprintf("%.0fms", millis)
+}
+
+// millisCell returns the cell of an amount of time in milliseconds.
+func millisCell(millis int64) tui.Cell {
+	return tui.Cell{Text: formatMillis(float64(millis)), Value: float64(millis), Numeric: true}
+}
+
+// NewScreen returns an interactive screen of the time spent in each namespace,
+// or in each database if locks is set, highlighting times at or above the
+// thresholds, given by column.
+func NewScreen(locks bool, thresholds map[string]float64) (*tui.Screen, error) {
+	keyHeader := "ns"
+	if locks {
+		keyHeader = "db"
+	}
+	screen := tui.NewScreen("mongotop", keyHeader, []tui.Column{
+		{Header: "total", Kind: tui.Delta, Format: formatMillis},
+		{Header: "read", Kind: tui.Delta, Format: formatMillis},
+		{Header: "write", Kind: tui.Delta, Format: formatMillis},
+	})
+	for header, threshold := range thresholds {
+		if header != "total" && header != "read" && header != "write" {
+			return nil, fmt.Errorf("no column '%v' to highlight, expected total, read or write", header)
+		}
+		screen.Thresholds[header] = threshold
+	}
+	return screen, nil
+}
+
+// runInteractive polls the server and shows the diffs on the screen, until it
+// is quit.
+func (mt *MongoTop) runInteractive() error {
+	// fail fast if the server can't be polled at all
+	if _, err := mt.runDiff(); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			time.Sleep(mt.Sleeptime)
+			diff, err := mt.runDiff()
+			if err != nil {
+				mt.Screen.SetStatus(fmt.Sprintf("Error: %v", err))
+				continue
+			}
+			if diff != nil {
+				mt.Screen.Update(diff.Rows(), mt.Sleeptime)
+			}
+		}
+	}()
+	return mt.Screen.Run(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,96 @@
+package mongotop
+
+import (
+	"github.com/mongodb/mongo-tools/common/testutil"
+	"github.com/mongodb/mongo-tools/common/tui"
+	. "github.com/smartystreets/goconvey/convey"
+	"sort"
+	"testing"
+)
+
+// rowsByKey sorts rows by key, as they're built from maps.
+type rowsByKey []tui.Row
+
+func (rows rowsByKey) Len() int           { return len(rows) }
+func (rows rowsByKey) Swap(i, j int)      { rows[i], rows[j] = rows[j], rows[i] }
+func (rows rowsByKey) Less(i, j int) bool { return rows[i].Key < rows[j].Key }
+
+func sortedRows(rows []tui.Row) []tui.Row {
+	sort.Sort(rowsByKey(rows))
+	return rows
+}
+
+func TestInteractiveScreen(t *testing.T) {
+	testutil.VerifyTestType(t, testutil.UnitTestType)
+
+	Convey("Top diffs should be converted to rows of times in milliseconds", t, func() {
+		diff := TopDiff{Totals: map[string]NSTopInfo{
+			"test.b": {Total: TopField{Time: 1500}, Read: TopField{Time: 1000}, Write: TopField{Time: 500}},
+			"test.a": {Total: TopField{Time: 3}, Read: TopField{Time: 0}, Write: TopField{Time: 3}},
+		}}
+		So(sortedRows(diff.Rows()), ShouldResemble, []tui.Row{
+			{Key: "test.a", Cells: []tui.Cell{
+				{Text: "3ms", Value: 3, Numeric: true},
+				{Text: "0ms", Value: 0, Numeric: true},
+				{Text: "3ms", Value: 3, Numeric: true},
+			}},
+			{Key: "test.b", Cells: []tui.Cell{
+				{Text: "1500ms", Value: 1500, Numeric: true},
+				{Text: "1000ms", Value: 1000, Numeric: true},
+				{Text: "500ms", Value: 500, Numeric: true},
+			}},
+		})
+	})
+
+	Convey("Lock diffs should be converted to rows of their total, read and write times", t, func() {
+		diff := ServerStatusDiff{Totals: map[string]LockDelta{
+			"admin": {Read: 2, Write: 5},
+			"local": {},
+		}}
+		So(sortedRows(diff.Rows()), ShouldResemble, []tui.Row{
+			{Key: "admin", Cells: []tui.Cell{
+				{Text: "7ms", Value: 7, Numeric: true},
+				{Text: "2ms", Value: 2, Numeric: true},
+				{Text: "5ms", Value: 5, Numeric: true},
+			}},
+			{Key: "local", Cells: []tui.Cell{
+				{Text: "0ms", Value: 0, Numeric: true},
+				{Text: "0ms", Value: 0, Numeric: true},
+				{Text: "0ms", Value: 0, Numeric: true},
+			}},
+		})
+	})
+
+	Convey("With an interactive screen", t, func() {
+		Convey("rows should be keyed by namespace, or by database with --locks", func() {
+			screen, err := NewScreen(false, nil)
+			So(err, ShouldBeNil)
+			So(screen.KeyHeader, ShouldEqual, "ns")
+			screen, err = NewScreen(true, nil)
+			So(err, ShouldBeNil)
+			So(screen.KeyHeader, ShouldEqual, "db")
+		})
+
+		Convey("totals should be formatted as milliseconds", func() {
+			screen, err := NewScreen(false, nil)
+			So(err, ShouldBeNil)
+			So(screen.Columns, ShouldHaveLength, 3)
+			for _, column := range screen.Columns {
+				So(column.Kind, ShouldEqual, tui.Delta)
+				So(column.Format(1234), ShouldEqual, "1234ms")
+			}
+		})
+
+		Convey("thresholds of the total, read and write columns should be accepted", func() {
+			screen, err := NewScreen(false, map[string]float64{"total": 100, "write": 50})
+			So(err, ShouldBeNil)
+			So(screen.Thresholds, ShouldResemble, map[string]float64{"total": 100, "write": 50})
+		})
+
+		Convey("thresholds of other columns should be rejected", func() {
+			_, err := NewScreen(false, map[string]float64{"read": 10, "insert": 5})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "insert")
+		})
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/mongodb/mongo-tools/common/log"
 	"github.com/mongodb/mongo-tools/common/options"
 	"github.com/mongodb/mongo-tools/common/signals"
+	"github.com/mongodb/mongo-tools/common/tui"
 	"github.com/mongodb/mongo-tools/common/util"
 	"github.com/mongodb/mongo-tools/mongotop"
 	"os"
@@ -60,6 +61,31 @@ func main() {
 		os.Exit(util.ExitBadOptions)
 	}
 
+	if outputOpts.Interactive && (outputOpts.Json || outputOpts.RowCount != 0) {
+		log.Logf(log.Always, "--interactive can not be used with --json or --rowcount")
+		os.Exit(util.ExitBadOptions)
+	}
+	if outputOpts.Highlight != "" && !outputOpts.Interactive {
+		log.Logf(log.Always, "--highlight can only be used with --interactive")
+		os.Exit(util.ExitBadOptions)
+	}
+	var screen *tui.Screen
+	if outputOpts.Interactive {
+		thresholds := map[string]float64{}
+		if outputOpts.Highlight != "" {
+			thresholds, err = tui.ParseThresholds(outputOpts.Highlight)
+			if err != nil {
+				log.Logf(log.Always, "error parsing --highlight: %v", err)
+				os.Exit(util.ExitBadOptions)
+			}
+		}
+		screen, err = mongotop.NewScreen(outputOpts.Locks, thresholds)
+		if err != nil {
+			log.Logf(log.Always, "error parsing --highlight: %v", err)
+			os.Exit(util.ExitBadOptions)
+		}
+	}
+
 	if opts.Auth.Username != "" && opts.Auth.Source == "" && !opts.Auth.RequiresExternalDB() {
 		log.Logf(log.Always, "--authenticationDatabase is required when authenticating against a non $external database")
 		os.Exit(util.ExitBadOptions)
@@ -98,6 +124,7 @@ func main() {
 		OutputOptions:   outputOpts,
 		SessionProvider: sessionProvider,
 		Sleeptime:       time.Duration(sleeptime) * time.Second,
+		Screen:          screen,
 	}
 
 	// kick it off
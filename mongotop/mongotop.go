@@ -6,6 +6,7 @@ import (
 	"github.com/mongodb/mongo-tools/common/db"
 	"github.com/mongodb/mongo-tools/common/log"
 	"github.com/mongodb/mongo-tools/common/options"
+	"github.com/mongodb/mongo-tools/common/tui"
 	"time"
 )
 
@@ -24,6 +25,9 @@ type MongoTop struct {
 	// Length of time to sleep between each polling.
 	Sleeptime time.Duration
 
+	// Screen to show the diffs on, if set with --interactive.
+	Screen *tui.Screen
+
 	previousServerStatus *ServerStatus
 	previousTop          *Top
 }
@@ -85,6 +89,10 @@ func (mt *MongoTop) Run() error {
 		connURL = connURL + ":" + mt.Options.Port
 	}
 
+	if mt.Screen != nil {
+		return mt.runInteractive()
+	}
+
 	hasData := false
 	numPrinted := 0
 
@@ -8,9 +8,11 @@ See http://docs.mongodb.org/manual/reference/program/mongotop/ for more informat
 
 // Output defines the set of options to use in displaying data from the server.
 type Output struct {
-	Locks    bool `long:"locks" description:"report on use of per-database locks"`
-	RowCount int  `long:"rowcount" value-name:"<count>" short:"n" description:"number of stats lines to print (0 for indefinite)"`
-	Json     bool `long:"json" description:"format output as JSON"`
+	Locks       bool   `long:"locks" description:"report on use of per-database locks"`
+	RowCount    int    `long:"rowcount" value-name:"<count>" short:"n" description:"number of stats lines to print (0 for indefinite)"`
+	Json        bool   `long:"json" description:"format output as JSON"`
+	Interactive bool   `long:"interactive" description:"show the times on an interactive full-screen table, a row per namespace updated in place, whose rows can be sorted, filtered and paused"`
+	Highlight   string `long:"highlight" value-name:"<column>=<ms>,..." description:"with --interactive, highlight times at or above these thresholds, e.g. 'total=100,write=50'"`
 }
 
 // Name returns a human-readable group name for output options.
@@ -4,7 +4,7 @@ setlocal EnableDelayedExpansion
 
 set GOPATH=%cd%\vendor
 
-for /F "eol=; tokens=1,2,3" %%i in (Godeps) do (
+for /F "eol=# tokens=1,2,3" %%i in (Godeps) do (
 	set package=%%i
 	set version=%%j
 	set dest=%%k